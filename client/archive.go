@@ -0,0 +1,73 @@
+package client
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	. "github.com/LysetsDal/docker-api/types"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+)
+
+// PathStatHeader Header the daemon uses to describe the path behind an archive request
+const PathStatHeader string = "X-Docker-Container-Path-Stat"
+
+// DecodePathStat Decode the base64 encoded json in the X-Docker-Container-Path-Stat header
+func DecodePathStat(header string) (PathStat, error) {
+	stat := PathStat{}
+	raw, err := base64.StdEncoding.DecodeString(header)
+	if err != nil {
+		return stat, fmt.Errorf("decoding %s: %w", PathStatHeader, err)
+	}
+	if err := json.Unmarshal(raw, &stat); err != nil {
+		return stat, fmt.Errorf("decoding %s: %w", PathStatHeader, err)
+	}
+	return stat, nil
+}
+
+// StatContainerPath HEAD /containers/{id}/archive
+func (c *DockerClient) StatContainerPath(ctx context.Context, id, path string) (PathStat, error) {
+	response, err := c.Do(ctx, http.MethodHead, "containers/"+id+"/archive", url.Values{"path": {path}}, nil)
+	if err != nil {
+		return PathStat{}, err
+	}
+	response.Body.Close()
+
+	return DecodePathStat(response.Header.Get(PathStatHeader))
+}
+
+// GetArchive GET /containers/{id}/archive. The caller closes the returned tar stream.
+func (c *DockerClient) GetArchive(ctx context.Context, id, path string) (io.ReadCloser, PathStat, error) {
+	response, err := c.Do(ctx, http.MethodGet, "containers/"+id+"/archive", url.Values{"path": {path}}, nil)
+	if err != nil {
+		return nil, PathStat{}, err
+	}
+
+	stat, err := DecodePathStat(response.Header.Get(PathStatHeader))
+	if err != nil {
+		response.Body.Close()
+		return nil, PathStat{}, err
+	}
+
+	return response.Body, stat, nil
+}
+
+// PutArchive PUT /containers/{id}/archive. Extracts the (optionally compressed) tar into the directory at path.
+func (c *DockerClient) PutArchive(ctx context.Context, id, path string, noOverwriteDirNonDir, copyUIDGID bool, archive io.Reader) error {
+	query := url.Values{}
+	query.Set("path", path)
+	query.Set("noOverwriteDirNonDir", strconv.FormatBool(noOverwriteDirNonDir))
+	query.Set("copyUIDGID", strconv.FormatBool(copyUIDGID))
+
+	response, err := c.DoWithHeaders(ctx, http.MethodPut, "containers/"+id+"/archive", query, archive, http.Header{"Content-Type": {"application/x-tar"}})
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+
+	_, err = io.Copy(io.Discard, response.Body)
+	return err
+}
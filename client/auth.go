@@ -0,0 +1,77 @@
+package client
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	. "github.com/LysetsDal/docker-api/types"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// Headers carrying registry credentials to the daemon
+const (
+	RegistryAuthHeader   string = "X-Registry-Auth"
+	RegistryConfigHeader string = "X-Registry-Config"
+)
+
+// RegistryCredentials Looks up stored credentials by registry hostname
+type RegistryCredentials interface {
+	AuthConfig(registry string) (AuthConfig, bool)
+	AuthConfigs() map[string]AuthConfig
+}
+
+// EncodeAuthConfig base64url encoded json, as X-Registry-Auth expects it
+func EncodeAuthConfig(auth AuthConfig) string {
+	encoded, _ := json.Marshal(auth)
+	return base64.URLEncoding.EncodeToString(encoded)
+}
+
+// RegistryAuthTransport Adds stored credentials to requests that talk to a registry: X-Registry-Auth
+// on pulls (POST /images/create) and pushes (POST /images/{name}/push), and X-Registry-Config with
+// every credential on builds (POST /build). Wrapping the Docker socket's transport once covers every
+// handler, including pulls done on the way to creating a container.
+type RegistryAuthTransport struct {
+	Base        http.RoundTripper
+	Credentials RegistryCredentials
+}
+
+func (t *RegistryAuthTransport) RoundTrip(request *http.Request) (*http.Response, error) {
+	if request.Method != http.MethodPost {
+		return t.Base.RoundTrip(request)
+	}
+
+	path := strings.TrimPrefix(request.URL.Path, "/")
+	if version, rest, ok := strings.Cut(path, "/"); ok && strings.HasPrefix(version, "v1.") {
+		path = rest
+	}
+
+	ref := ""
+	switch {
+	case path == "images/create":
+		ref = request.URL.Query().Get("fromImage")
+	case strings.HasPrefix(path, "images/") && strings.HasSuffix(path, "/push"):
+		ref, _ = url.PathUnescape(strings.TrimSuffix(strings.TrimPrefix(path, "images/"), "/push"))
+	case path == "build":
+		configs := t.Credentials.AuthConfigs()
+		if len(configs) == 0 {
+			return t.Base.RoundTrip(request)
+		}
+		encoded, _ := json.Marshal(configs)
+		request = request.Clone(request.Context())
+		request.Header.Set(RegistryConfigHeader, base64.URLEncoding.EncodeToString(encoded))
+		return t.Base.RoundTrip(request)
+	}
+	if ref == "" {
+		return t.Base.RoundTrip(request)
+	}
+
+	auth, ok := t.Credentials.AuthConfig(RegistryHost(ref))
+	if !ok {
+		return t.Base.RoundTrip(request)
+	}
+
+	request = request.Clone(request.Context())
+	request.Header.Set(RegistryAuthHeader, EncodeAuthConfig(auth))
+	return t.Base.RoundTrip(request)
+}
@@ -0,0 +1,87 @@
+package client
+
+import (
+	"encoding/base64"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/LysetsDal/docker-api/types"
+)
+
+func TestRegistryAuthTransport(t *testing.T) {
+	credentials := stubCredentials{"registry.example.com:5000": {Username: "ci", Password: "s3cret", ServerAddress: "registry.example.com:5000"}}
+
+	tests := []struct {
+		name       string
+		method     string
+		target     string
+		wantHeader string
+		wantAuth   string
+	}{
+		{name: "pull", method: http.MethodPost, target: "/images/create?fromImage=registry.example.com:5000/app&tag=1", wantHeader: RegistryAuthHeader, wantAuth: "ci"},
+		{name: "versioned pull", method: http.MethodPost, target: "/v1.43/images/create?fromImage=registry.example.com:5000/app", wantHeader: RegistryAuthHeader, wantAuth: "ci"},
+		{name: "push", method: http.MethodPost, target: "/images/registry.example.com:5000/app/push?tag=1", wantHeader: RegistryAuthHeader, wantAuth: "ci"},
+		{name: "other registry", method: http.MethodPost, target: "/images/create?fromImage=ghcr.io/app"},
+		{name: "docker hub", method: http.MethodPost, target: "/images/create?fromImage=library/nginx"},
+		{name: "build", method: http.MethodPost, target: "/build", wantHeader: RegistryConfigHeader, wantAuth: "ci"},
+		{name: "not a registry call", method: http.MethodGet, target: "/images/create?fromImage=registry.example.com:5000/app"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var received http.Header
+			transport := &RegistryAuthTransport{Credentials: credentials, Base: roundTripFunc(func(r *http.Request) (*http.Response, error) {
+				received = r.Header
+				return &http.Response{StatusCode: http.StatusOK, Body: io.NopCloser(strings.NewReader("")), Request: r}, nil
+			})}
+
+			request := httptest.NewRequest(test.method, "http://unix"+test.target, nil)
+			if _, err := transport.RoundTrip(request); err != nil {
+				t.Fatal(err)
+			}
+
+			for _, header := range []string{RegistryAuthHeader, RegistryConfigHeader} {
+				if (received.Get(header) != "") != (header == test.wantHeader) {
+					t.Errorf("%s: %q", header, received.Get(header))
+				}
+			}
+			if test.wantHeader == "" {
+				return
+			}
+			decoded, err := base64.URLEncoding.DecodeString(received.Get(test.wantHeader))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !strings.Contains(string(decoded), `"username":"`+test.wantAuth+`"`) {
+				t.Errorf("%s carries %s", test.wantHeader, decoded)
+			}
+			if request.Header.Get(test.wantHeader) != "" {
+				t.Error("the caller's request was changed")
+			}
+		})
+	}
+}
+
+type stubCredentials map[string]types.AuthConfig
+
+func (s stubCredentials) AuthConfig(registry string) (types.AuthConfig, bool) {
+	auth, ok := s[registry]
+	return auth, ok
+}
+
+func (s stubCredentials) AuthConfigs() map[string]types.AuthConfig {
+	configs := map[string]types.AuthConfig{}
+	for _, auth := range s {
+		configs[auth.ServerAddress] = auth
+	}
+	return configs
+}
+
+type roundTripFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripFunc) RoundTrip(r *http.Request) (*http.Response, error) {
+	return f(r)
+}
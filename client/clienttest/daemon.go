@@ -0,0 +1,54 @@
+// Package clienttest A stand-in Docker daemon for tests
+package clienttest
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+)
+
+// Daemon An httptest server answering the Docker API with a test's handler. Each call is recorded
+// as "METHOD /path".
+type Daemon struct {
+	Server *httptest.Server
+
+	mu    sync.Mutex
+	calls []string
+}
+
+// NewDaemon Serve handler as the Docker daemon until the test ends
+func NewDaemon(t testing.TB, handler http.HandlerFunc) *Daemon {
+	d := &Daemon{}
+	d.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		d.mu.Lock()
+		d.calls = append(d.calls, r.Method+" "+r.URL.Path)
+		d.mu.Unlock()
+		handler(w, r)
+	}))
+	t.Cleanup(d.Server.Close)
+	return d
+}
+
+// Transport Dials the daemon whatever the request's host, like the unix socket transport does
+func (d *Daemon) Transport() http.RoundTripper {
+	return &http.Transport{
+		DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+			return (&net.Dialer{}).DialContext(ctx, "tcp", d.Server.Listener.Addr().String())
+		},
+	}
+}
+
+// Sock An http.Client for client.NewDockerClient and the handlers' constructors
+func (d *Daemon) Sock() http.Client {
+	return http.Client{Transport: d.Transport()}
+}
+
+// Calls The calls made so far
+func (d *Daemon) Calls() []string {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return append([]string(nil), d.calls...)
+}
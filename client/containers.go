@@ -0,0 +1,284 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	. "github.com/LysetsDal/docker-api/types"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ListContainers GET /containers/json. all includes stopped containers.
+func (c *DockerClient) ListContainers(ctx context.Context, all bool, filters map[string][]string) ([]Container, error) {
+	query := url.Values{}
+	if all {
+		query.Set("all", "true")
+	}
+	if encoded := EncodeFilters(filters); encoded != "" {
+		query.Set("filters", encoded)
+	}
+
+	containers := make([]Container, 0)
+	err := c.Call(ctx, http.MethodGet, "containers/json", query, nil, &containers)
+	return containers, err
+}
+
+// InspectContainer GET /containers/{id}/json
+func (c *DockerClient) InspectContainer(ctx context.Context, id string) (InspectObject, error) {
+	inspectObject := InspectObject{}
+	err := c.Call(ctx, http.MethodGet, fmt.Sprintf("containers/%s/json", id), nil, nil, &inspectObject)
+	return inspectObject, err
+}
+
+// InspectContainerRaw GET /containers/{id}/json, exactly as the daemon returned it.
+// size asks the daemon to compute SizeRw and SizeRootFs.
+func (c *DockerClient) InspectContainerRaw(ctx context.Context, id string, size bool) (json.RawMessage, error) {
+	query := url.Values{}
+	if size {
+		query.Set("size", "true")
+	}
+
+	raw := json.RawMessage{}
+	err := c.Call(ctx, http.MethodGet, fmt.Sprintf("containers/%s/json", id), query, nil, &raw)
+	return raw, err
+}
+
+// CreateContainer POST /containers/create. An empty name lets Docker pick one.
+func (c *DockerClient) CreateContainer(ctx context.Context, name string, payload Payload) (CreateContainerResponse, error) {
+	query := url.Values{}
+	if name != "" {
+		query.Set("name", name)
+	}
+
+	created := CreateContainerResponse{}
+	err := c.Call(ctx, http.MethodPost, "containers/create", query, payload, &created)
+	return created, err
+}
+
+// CreateContainerRaw CreateContainer with the config sent as given, including fields Payload doesn't model
+func (c *DockerClient) CreateContainerRaw(ctx context.Context, name string, config json.RawMessage) (CreateContainerResponse, error) {
+	query := url.Values{}
+	if name != "" {
+		query.Set("name", name)
+	}
+
+	created := CreateContainerResponse{}
+	err := c.Call(ctx, http.MethodPost, "containers/create", query, config, &created)
+	return created, err
+}
+
+// StartContainer POST /containers/{id}/start. Starting a running container is not an error.
+func (c *DockerClient) StartContainer(ctx context.Context, id string) error {
+	return c.Call(ctx, http.MethodPost, fmt.Sprintf("containers/%s/start", id), nil, nil, nil)
+}
+
+// ChangeContainerState POST /containers/{id}/{action} for start or stop, reporting whether the
+// container changed: the daemon answers 304 when it already was started or stopped
+func (c *DockerClient) ChangeContainerState(ctx context.Context, id, action string, query url.Values) (bool, error) {
+	response, err := c.Do(ctx, http.MethodPost, fmt.Sprintf("containers/%s/%s", id, action), query, nil)
+	if err != nil {
+		return false, err
+	}
+	defer response.Body.Close()
+
+	return response.StatusCode != http.StatusNotModified, nil
+}
+
+// StopContainer POST /containers/{id}/stop. Stopping a stopped container is not an error.
+func (c *DockerClient) StopContainer(ctx context.Context, id string, params StopParams) error {
+	query := url.Values{}
+	if params.Signal != "" {
+		query.Set("signal", params.Signal)
+	}
+	if params.T > 0 {
+		query.Set("t", strconv.Itoa(params.T))
+	}
+
+	return c.Call(ctx, http.MethodPost, fmt.Sprintf("containers/%s/stop", id), query, nil, nil)
+}
+
+// TopContainer GET /containers/{id}/top
+func (c *DockerClient) TopContainer(ctx context.Context, id, psArgs string) (Processes, error) {
+	query := url.Values{}
+	if psArgs != "" {
+		query.Set("ps_args", psArgs)
+	}
+
+	processes := Processes{}
+	err := c.Call(ctx, http.MethodGet, fmt.Sprintf("containers/%s/top", id), query, nil, &processes)
+	return processes, err
+}
+
+// RestartContainer POST /containers/{id}/restart
+func (c *DockerClient) RestartContainer(ctx context.Context, id string, params StopParams) error {
+	query := url.Values{}
+	if params.Signal != "" {
+		query.Set("signal", params.Signal)
+	}
+	if params.T > 0 {
+		query.Set("t", strconv.Itoa(params.T))
+	}
+
+	return c.Call(ctx, http.MethodPost, fmt.Sprintf("containers/%s/restart", id), query, nil, nil)
+}
+
+// KillContainer POST /containers/{id}/kill. An empty signal sends SIGKILL.
+func (c *DockerClient) KillContainer(ctx context.Context, id, signal string) error {
+	query := url.Values{}
+	if signal != "" {
+		query.Set("signal", signal)
+	}
+
+	return c.Call(ctx, http.MethodPost, fmt.Sprintf("containers/%s/kill", id), query, nil, nil)
+}
+
+// PauseContainer POST /containers/{id}/pause
+func (c *DockerClient) PauseContainer(ctx context.Context, id string) error {
+	return c.Call(ctx, http.MethodPost, fmt.Sprintf("containers/%s/pause", id), nil, nil, nil)
+}
+
+// UnpauseContainer POST /containers/{id}/unpause
+func (c *DockerClient) UnpauseContainer(ctx context.Context, id string) error {
+	return c.Call(ctx, http.MethodPost, fmt.Sprintf("containers/%s/unpause", id), nil, nil, nil)
+}
+
+// RemoveContainer DELETE /containers/{id}
+func (c *DockerClient) RemoveContainer(ctx context.Context, id string, force, volumes bool) error {
+	query := url.Values{}
+	query.Set("force", strconv.FormatBool(force))
+	query.Set("v", strconv.FormatBool(volumes))
+
+	return c.Call(ctx, http.MethodDelete, "containers/"+id, query, nil, nil)
+}
+
+// WaitHealthy Poll the container until its healthcheck reports healthy. Containers without a
+// healthcheck are considered healthy as soon as they are running.
+func (c *DockerClient) WaitHealthy(ctx context.Context, id string, timeout time.Duration) error {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+
+	for {
+		inspect, err := c.InspectContainer(ctx, id)
+		if err != nil {
+			return err
+		}
+
+		state := inspect.State
+		switch {
+		case state.Status == "exited" || state.Status == "dead":
+			return Conflict("container %s %s before becoming healthy", id, state.Status)
+		case state.Health == nil && state.Status == "running":
+			return nil
+		case state.Health != nil && state.Health.Status == "healthy":
+			return nil
+		case state.Health != nil && state.Health.Status == "unhealthy":
+			return Conflict("container %s is unhealthy: %s", id, lastHealthOutput(state.Health))
+		}
+
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("container %s not healthy after %s: %w", id, timeout, ctx.Err())
+		case <-ticker.C:
+		}
+	}
+}
+
+func lastHealthOutput(health *Health) string {
+	if len(health.Log) == 0 {
+		return "no healthcheck output"
+	}
+	return strings.TrimSpace(health.Log[len(health.Log)-1].Output)
+}
+
+// CreateContainerWithPull CreateContainer, pulling the image first if the daemon doesn't have it
+func (c *DockerClient) CreateContainerWithPull(ctx context.Context, name string, payload Payload) (CreateContainerResponse, error) {
+	created, err := c.CreateContainer(ctx, name, payload)
+	if !IsNotFound(err) {
+		return created, err
+	}
+
+	if err := c.PullImage(ctx, payload.Image, nil); err != nil {
+		return created, fmt.Errorf("pulling %s: %w", payload.Image, err)
+	}
+	return c.CreateContainer(ctx, name, payload)
+}
+
+// RunContainer Create a container from payload and, if start is set, start it, the way docker run does.
+// pull is missing (the default: pull only if the daemon lacks the image), always or never. If starting
+// fails the created container is still returned, so the caller can report or remove it.
+func (c *DockerClient) RunContainer(ctx context.Context, name string, payload Payload, pull string, start bool) (RunResponse, error) {
+	response := RunResponse{Name: name}
+	if pull == "always" {
+		if err := c.PullImage(ctx, payload.Image, nil); err != nil {
+			return response, fmt.Errorf("pulling %s: %w", payload.Image, err)
+		}
+		response.Pulled = true
+	}
+
+	var created CreateContainerResponse
+	var err error
+	if pull == "never" || pull == "always" {
+		created, err = c.CreateContainer(ctx, name, payload)
+	} else {
+		created, err = c.CreateContainerWithPull(ctx, name, payload)
+	}
+	if err != nil {
+		return response, err
+	}
+	response.Id = created.Id
+	response.Warnings = created.Warnings
+
+	if start {
+		if err := c.StartContainer(ctx, created.Id); err != nil {
+			return response, fmt.Errorf("container %s created but not started: %w", created.Id, err)
+		}
+		response.Started = true
+	}
+
+	return response, nil
+}
+
+// RenameContainer POST /containers/{id}/rename
+func (c *DockerClient) RenameContainer(ctx context.Context, id, name string) error {
+	query := url.Values{}
+	query.Set("name", name)
+
+	return c.Call(ctx, http.MethodPost, fmt.Sprintf("containers/%s/rename", id), query, nil, nil)
+}
+
+// ContainerChanges GET /containers/{id}/changes
+func (c *DockerClient) ContainerChanges(ctx context.Context, id string) ([]ContainerChange, error) {
+	changes := make([]ContainerChange, 0)
+	err := c.Call(ctx, http.MethodGet, "containers/"+id+"/changes", nil, nil, &changes)
+	return changes, err
+}
+
+// ExportContainer GET /containers/{id}/export. The caller closes the returned tar stream.
+func (c *DockerClient) ExportContainer(ctx context.Context, id string) (io.ReadCloser, error) {
+	response, err := c.Do(ctx, http.MethodGet, "containers/"+id+"/export", nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	return response.Body, nil
+}
+
+// WaitContainer POST /containers/{id}/wait. Blocks until the container reaches condition
+// ("not-running", "next-exit" or "removed") or ctx is cancelled.
+func (c *DockerClient) WaitContainer(ctx context.Context, id, condition string) (ContainerWaitResponse, error) {
+	query := url.Values{}
+	if condition != "" {
+		query.Set("condition", condition)
+	}
+
+	response := ContainerWaitResponse{}
+	err := c.Call(ctx, http.MethodPost, "containers/"+id+"/wait", query, nil, &response)
+	return response, err
+}
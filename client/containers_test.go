@@ -0,0 +1,98 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"reflect"
+	"testing"
+
+	"github.com/LysetsDal/docker-api/client/clienttest"
+	"github.com/LysetsDal/docker-api/types"
+)
+
+func TestRunContainer(t *testing.T) {
+	tests := []struct {
+		name        string
+		pull        string
+		start       bool
+		hasImage    bool
+		failStart   bool
+		want        []string
+		wantPulled  bool
+		wantStarted bool
+		wantErr     error
+	}{
+		{
+			name: "missing image is pulled", pull: "missing", start: true,
+			want:        []string{"POST /containers/create", "POST /images/create", "POST /containers/create", "POST /containers/c1/start"},
+			wantStarted: true,
+		},
+		{
+			name: "present image isn't pulled", pull: "", start: true, hasImage: true,
+			want:        []string{"POST /containers/create", "POST /containers/c1/start"},
+			wantStarted: true,
+		},
+		{
+			name: "always", pull: "always", hasImage: true,
+			want:       []string{"POST /images/create", "POST /containers/create"},
+			wantPulled: true,
+		},
+		{
+			name: "never", pull: "never", start: true,
+			want:    []string{"POST /containers/create"},
+			wantErr: types.ErrNotFound,
+		},
+		{
+			name: "start fails", pull: "missing", start: true, hasImage: true, failStart: true,
+			want:    []string{"POST /containers/create", "POST /containers/c1/start"},
+			wantErr: types.ErrDaemon,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			hasImage := test.hasImage
+			daemon := clienttest.NewDaemon(t, func(w http.ResponseWriter, r *http.Request) {
+				switch r.URL.Path {
+				case "/images/create":
+					hasImage = true
+					_, _ = w.Write([]byte(`{"status":"done"}`))
+				case "/containers/create":
+					if !hasImage {
+						http.Error(w, `{"message":"No such image"}`, http.StatusNotFound)
+						return
+					}
+					w.WriteHeader(http.StatusCreated)
+					_, _ = w.Write([]byte(`{"Id":"c1"}`))
+				case "/containers/c1/start":
+					if test.failStart {
+						http.Error(w, `{"message":"port is already allocated"}`, http.StatusInternalServerError)
+						return
+					}
+					w.WriteHeader(http.StatusNoContent)
+				}
+			})
+			docker := NewDockerClient(daemon.Sock())
+
+			response, err := docker.RunContainer(context.Background(), "web", types.Payload{Image: "nginx"}, test.pull, test.start)
+			if test.wantErr != nil {
+				if !errors.Is(err, test.wantErr) {
+					t.Errorf("error: got %v, want %v", err, test.wantErr)
+				}
+			} else if err != nil {
+				t.Fatal(err)
+			}
+
+			if got := daemon.Calls(); !reflect.DeepEqual(got, test.want) {
+				t.Errorf("calls:\n got %v\nwant %v", got, test.want)
+			}
+			if response.Pulled != test.wantPulled || response.Started != test.wantStarted {
+				t.Errorf("response: %+v", response)
+			}
+			if test.failStart && response.Id != "c1" {
+				t.Errorf("the created container isn't returned when starting fails: %+v", response)
+			}
+		})
+	}
+}
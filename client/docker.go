@@ -0,0 +1,158 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	. "github.com/LysetsDal/docker-api/config"
+	. "github.com/LysetsDal/docker-api/types"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// DockerClient Thin wrapper around the Docker Engine API served on the unix socket
+type DockerClient struct {
+	Sock http.Client
+}
+
+// DockerError Non-2xx (and non-304) response from the Docker daemon
+type DockerError struct {
+	StatusCode int
+	Message    string
+}
+
+func (e *DockerError) Error() string {
+	return fmt.Sprintf("docker daemon returned %d: %s", e.StatusCode, e.Message)
+}
+
+// Is Matches the kind of error the daemon's status stands for, so errors.Is(err, ErrNotFound) and
+// StatusCode work on it
+func (e *DockerError) Is(target error) bool {
+	switch {
+	case e.StatusCode == http.StatusNotModified:
+		return target == ErrNotModified
+	case e.StatusCode == http.StatusNotFound:
+		return target == ErrNotFound
+	case e.StatusCode == http.StatusConflict:
+		return target == ErrConflict
+	case e.StatusCode >= http.StatusInternalServerError:
+		return target == ErrDaemon
+	}
+	return target == ErrBadRequest
+}
+
+// IsNotFound Reports whether err is a 404 from the Docker daemon
+func IsNotFound(err error) bool {
+	return errors.Is(err, ErrNotFound)
+}
+
+func NewDockerClient(sock http.Client) *DockerClient {
+	return &DockerClient{
+		Sock: sock,
+	}
+}
+
+// EncodeFilters Encode filters the way the Docker daemon expects them (map[string][]string as json)
+func EncodeFilters(filters map[string][]string) string {
+	if len(filters) == 0 {
+		return ""
+	}
+	encoded, _ := json.Marshal(filters)
+	return string(encoded)
+}
+
+// Do Send a request to the Docker Socket. Error responses are turned into a *DockerError,
+// otherwise the caller is responsible for closing the response body.
+func (c *DockerClient) Do(ctx context.Context, method, path string, query url.Values, body io.Reader) (*http.Response, error) {
+	header := http.Header{}
+	if body != nil {
+		header.Set("Content-Type", "application/json")
+	}
+	return c.DoWithHeaders(ctx, method, path, query, body, header)
+}
+
+// DoWithHeaders Do with extra request headers, e.g. a Content-Type for tar bodies or X-Registry-Auth
+func (c *DockerClient) DoWithHeaders(ctx context.Context, method, path string, query url.Values, body io.Reader, header http.Header) (*http.Response, error) {
+	target := UnixPrefix + strings.TrimPrefix(path, "/")
+	if len(query) > 0 {
+		target += "?" + query.Encode()
+	}
+
+	request, err := http.NewRequestWithContext(ctx, method, target, body)
+	if err != nil {
+		return nil, err
+	}
+	for key, values := range header {
+		request.Header[key] = values
+	}
+
+	response, err := c.Sock.Do(request)
+	if err != nil {
+		return nil, transportError(ctx, err)
+	}
+
+	if response.StatusCode >= http.StatusBadRequest {
+		defer response.Body.Close()
+		return nil, readDockerError(response)
+	}
+
+	return response, nil
+}
+
+// Call Send in as json (if not nil) and decode the response into out (if not nil)
+func (c *DockerClient) Call(ctx context.Context, method, path string, query url.Values, in any, out any) error {
+	var body io.Reader
+	if in != nil {
+		encoded, err := json.Marshal(in)
+		if err != nil {
+			return err
+		}
+		body = bytes.NewReader(encoded)
+	}
+
+	response, err := c.Do(ctx, method, path, query, body)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+
+	if out == nil || response.StatusCode == http.StatusNoContent || response.StatusCode == http.StatusNotModified {
+		_, _ = io.Copy(io.Discard, response.Body)
+		return nil
+	}
+
+	return json.NewDecoder(response.Body).Decode(out)
+}
+
+// transportError The kind of a failed round trip: a timeout when ctx ran out, otherwise the daemon is
+// unavailable. A cancelled ctx means the caller gave up and is left as it is.
+func transportError(ctx context.Context, err error) error {
+	switch {
+	case errors.Is(ctx.Err(), context.DeadlineExceeded):
+		return Timeout(err)
+	case errors.Is(ctx.Err(), context.Canceled):
+		return err
+	}
+	return Unavailable(err)
+}
+
+// readDockerError Docker reports errors as {"message": "..."}
+func readDockerError(response *http.Response) error {
+	raw, _ := io.ReadAll(response.Body)
+
+	message := struct {
+		Message string `json:"message"`
+	}{}
+	if err := json.Unmarshal(raw, &message); err != nil || message.Message == "" {
+		message.Message = strings.TrimSpace(string(raw))
+	}
+	if message.Message == "" {
+		message.Message = http.StatusText(response.StatusCode)
+	}
+
+	return &DockerError{StatusCode: response.StatusCode, Message: message.Message}
+}
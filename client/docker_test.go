@@ -0,0 +1,74 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/LysetsDal/docker-api/client/clienttest"
+	"github.com/LysetsDal/docker-api/types"
+)
+
+func TestCallErrors(t *testing.T) {
+	tests := []struct {
+		name        string
+		status      int
+		body        string
+		wantStatus  int
+		wantMessage string
+	}{
+		{name: "not found", status: http.StatusNotFound, body: `{"message":"No such container: web"}`, wantStatus: http.StatusNotFound, wantMessage: "No such container: web"},
+		{name: "conflict", status: http.StatusConflict, body: `{"message":"name in use"}`, wantStatus: http.StatusConflict, wantMessage: "name in use"},
+		{name: "bad parameter", status: http.StatusBadRequest, body: `{"message":"invalid mode"}`, wantStatus: http.StatusBadRequest, wantMessage: "invalid mode"},
+		{name: "forbidden", status: http.StatusForbidden, body: `{"message":"not allowed"}`, wantStatus: http.StatusBadRequest, wantMessage: "not allowed"},
+		{name: "daemon error", status: http.StatusInternalServerError, body: "driver failed\n", wantStatus: http.StatusBadGateway, wantMessage: "driver failed"},
+		{name: "no message", status: http.StatusServiceUnavailable, wantStatus: http.StatusBadGateway, wantMessage: "Service Unavailable"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			daemon := clienttest.NewDaemon(t, func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(test.status)
+				_, _ = w.Write([]byte(test.body))
+			})
+
+			err := NewDockerClient(daemon.Sock()).Call(context.Background(), http.MethodGet, "containers/web/json", nil, nil, nil)
+			if status := types.StatusCode(err); status != test.wantStatus {
+				t.Errorf("status: got %d, want %d", status, test.wantStatus)
+			}
+			dockerError := &DockerError{}
+			if !errors.As(err, &dockerError) || dockerError.StatusCode != test.status || dockerError.Message != test.wantMessage {
+				t.Errorf("got %#v", err)
+			}
+		})
+	}
+}
+
+func TestTransportErrors(t *testing.T) {
+	daemon := clienttest.NewDaemon(t, func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-r.Context().Done():
+		case <-time.After(time.Second):
+		}
+	})
+	docker := NewDockerClient(daemon.Sock())
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if err := docker.Call(ctx, http.MethodGet, "info", nil, nil, nil); types.StatusCode(err) != http.StatusGatewayTimeout {
+		t.Errorf("timeout: %v", err)
+	}
+
+	ctx, cancel = context.WithCancel(context.Background())
+	cancel()
+	if err := docker.Call(ctx, http.MethodGet, "info", nil, nil, nil); !errors.Is(err, context.Canceled) || types.StatusCode(err) != http.StatusInternalServerError {
+		t.Errorf("cancelled: %v", err)
+	}
+
+	daemon.Server.Close()
+	if err := docker.Call(context.Background(), http.MethodGet, "info", nil, nil, nil); types.StatusCode(err) != http.StatusServiceUnavailable {
+		t.Errorf("daemon down: %v", err)
+	}
+}
@@ -0,0 +1,44 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	. "github.com/LysetsDal/docker-api/types"
+	"net/http"
+	"net/url"
+)
+
+// Events GET /events. Events are delivered until ctx is cancelled or the stream breaks,
+// after which the channel is closed.
+func (c *DockerClient) Events(ctx context.Context, filters map[string][]string) (<-chan DockerEvent, error) {
+	query := url.Values{}
+	if encoded := EncodeFilters(filters); encoded != "" {
+		query.Set("filters", encoded)
+	}
+
+	response, err := c.Do(ctx, http.MethodGet, "events", query, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	events := make(chan DockerEvent)
+	go func() {
+		defer close(events)
+		defer response.Body.Close()
+
+		decoder := json.NewDecoder(response.Body)
+		for {
+			event := DockerEvent{}
+			if err := decoder.Decode(&event); err != nil {
+				return
+			}
+			select {
+			case events <- event:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	return events, nil
+}
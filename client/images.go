@@ -0,0 +1,191 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	. "github.com/LysetsDal/docker-api/types"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// SplitImageRef Split "repo:tag" (or "repo@digest") into the repo and tag/digest. Defaults to latest.
+func SplitImageRef(ref string) (string, string) {
+	if at := strings.Index(ref, "@"); at >= 0 {
+		return ref[:at], ref[at+1:]
+	}
+
+	colon := strings.LastIndex(ref, ":")
+	if colon > strings.LastIndex(ref, "/") {
+		return ref[:colon], ref[colon+1:]
+	}
+
+	return ref, "latest"
+}
+
+// RegistryHost The registry an image reference points at, "docker.io" for Docker Hub images
+func RegistryHost(ref string) string {
+	first, _, hasSlash := strings.Cut(ref, "/")
+	if !hasSlash || (!strings.ContainsAny(first, ".:") && first != "localhost") {
+		return "docker.io"
+	}
+	return NormalizeRegistryHost(first)
+}
+
+// NormalizeRegistryHost Docker Hub goes by several names, credentials are kept under docker.io
+func NormalizeRegistryHost(host string) string {
+	host = strings.ToLower(strings.TrimSuffix(strings.TrimPrefix(strings.TrimPrefix(host, "https://"), "http://"), "/"))
+	switch host {
+	case "index.docker.io", "registry-1.docker.io", "index.docker.io/v1":
+		return "docker.io"
+	}
+	return host
+}
+
+// PullImage POST /images/create. Progress messages are copied to progress when it is not nil.
+func (c *DockerClient) PullImage(ctx context.Context, ref string, progress io.Writer) error {
+	stream, err := c.PullImageStream(ctx, ref)
+	if err != nil {
+		return err
+	}
+	defer stream.Close()
+
+	return ReadJsonMessages(stream, progress)
+}
+
+// PullImageStream POST /images/create. The caller closes the returned progress stream of JsonMessages.
+func (c *DockerClient) PullImageStream(ctx context.Context, ref string) (io.ReadCloser, error) {
+	repo, tag := SplitImageRef(ref)
+
+	query := url.Values{}
+	query.Set("fromImage", repo)
+	query.Set("tag", tag)
+
+	response, err := c.Do(ctx, http.MethodPost, "images/create", query, nil)
+	if err != nil {
+		return nil, err
+	}
+	return response.Body, nil
+}
+
+// PushImage POST /images/{name}/push. The caller closes the returned progress stream of JsonMessages.
+// The daemon insists on an X-Registry-Auth header, so an empty one is sent unless a RegistryAuthTransport
+// has credentials for the registry.
+func (c *DockerClient) PushImage(ctx context.Context, ref string) (io.ReadCloser, error) {
+	repo, tag := SplitImageRef(ref)
+
+	query := url.Values{}
+	query.Set("tag", tag)
+
+	header := http.Header{}
+	header.Set(RegistryAuthHeader, EncodeAuthConfig(AuthConfig{}))
+
+	response, err := c.DoWithHeaders(ctx, http.MethodPost, "images/"+repo+"/push", query, nil, header)
+	if err != nil {
+		return nil, err
+	}
+	return response.Body, nil
+}
+
+// Login POST /auth. Checks the credentials against the registry without storing them in the daemon.
+func (c *DockerClient) Login(ctx context.Context, auth AuthConfig) (AuthResponse, error) {
+	response := AuthResponse{}
+	err := c.Call(ctx, http.MethodPost, "auth", nil, auth, &response)
+	return response, err
+}
+
+// ReadJsonMessages Drain a progress stream, failing on the first error message.
+// The daemon answers 200 before it knows whether e.g. a pull will succeed.
+func ReadJsonMessages(stream io.Reader, progress io.Writer) error {
+	decoder := json.NewDecoder(stream)
+	for {
+		message := JsonMessage{}
+		if err := decoder.Decode(&message); err == io.EOF {
+			return nil
+		} else if err != nil {
+			return err
+		}
+
+		if progress != nil {
+			if err := json.NewEncoder(progress).Encode(message); err != nil {
+				return err
+			}
+			if flusher, ok := progress.(http.Flusher); ok {
+				flusher.Flush()
+			}
+		}
+
+		if message.Error != "" {
+			return errors.New(message.Error)
+		}
+	}
+}
+
+// InspectImage GET /images/{name}/json
+func (c *DockerClient) InspectImage(ctx context.Context, ref string) (ImageInspect, error) {
+	image := ImageInspect{}
+	err := c.Call(ctx, http.MethodGet, "images/"+ref+"/json", nil, nil, &image)
+	return image, err
+}
+
+// ListImages GET /images/json. all includes intermediate images.
+func (c *DockerClient) ListImages(ctx context.Context, all bool, filters map[string][]string) ([]ImageSummary, error) {
+	query := url.Values{}
+	query.Set("all", strconv.FormatBool(all))
+	if encoded := EncodeFilters(filters); encoded != "" {
+		query.Set("filters", encoded)
+	}
+
+	images := make([]ImageSummary, 0)
+	err := c.Call(ctx, http.MethodGet, "images/json", query, nil, &images)
+	return images, err
+}
+
+// CommitContainer POST /commit. labels are merged into the image's labels.
+func (c *DockerClient) CommitContainer(ctx context.Context, id string, request CommitRequest, labels map[string]string) (CommitResponse, error) {
+	query := url.Values{}
+	query.Set("container", id)
+	query.Set("repo", request.Repo)
+	query.Set("tag", request.Tag)
+	query.Set("comment", request.Message)
+	query.Set("author", request.Author)
+	if request.Pause != nil {
+		query.Set("pause", strconv.FormatBool(*request.Pause))
+	}
+	for _, change := range request.Changes {
+		query.Add("changes", change)
+	}
+
+	var config any
+	if len(labels) > 0 {
+		config = map[string]any{"Labels": labels}
+	}
+
+	response := CommitResponse{}
+	err := c.Call(ctx, http.MethodPost, "commit", query, config, &response)
+	return response, err
+}
+
+// SaveImages GET /images/get. The caller closes the returned tar stream, which holds every image in names.
+func (c *DockerClient) SaveImages(ctx context.Context, names []string) (io.ReadCloser, error) {
+	response, err := c.Do(ctx, http.MethodGet, "images/get", url.Values{"names": names}, nil)
+	if err != nil {
+		return nil, err
+	}
+	return response.Body, nil
+}
+
+// LoadImages POST /images/load. The caller closes the returned progress stream of JsonMessages.
+func (c *DockerClient) LoadImages(ctx context.Context, archive io.Reader) (io.ReadCloser, error) {
+	query := url.Values{}
+	query.Set("quiet", "false")
+
+	response, err := c.DoWithHeaders(ctx, http.MethodPost, "images/load", query, archive, http.Header{"Content-Type": {"application/x-tar"}})
+	if err != nil {
+		return nil, err
+	}
+	return response.Body, nil
+}
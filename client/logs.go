@@ -0,0 +1,48 @@
+package client
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"io"
+	"net/http"
+	"net/url"
+)
+
+// ContainerLogs GET /containers/{id}/logs with stdout and stderr. tail is a line count or "all".
+// Unless the container has a TTY the stream is multiplexed, see DemuxLogs. The caller closes it.
+func (c *DockerClient) ContainerLogs(ctx context.Context, id, tail string) (io.ReadCloser, error) {
+	query := url.Values{}
+	query.Set("stdout", "true")
+	query.Set("stderr", "true")
+	if tail != "" {
+		query.Set("tail", tail)
+	}
+
+	response, err := c.Do(ctx, http.MethodGet, "containers/"+id+"/logs", query, nil)
+	if err != nil {
+		return nil, err
+	}
+	return response.Body, nil
+}
+
+// DemuxLogs Copy a multiplexed log stream to stdout and stderr. Each frame is an 8 byte header
+// (stream type, three padding bytes, big endian payload size) followed by the payload.
+func DemuxLogs(stdout, stderr io.Writer, stream io.Reader) error {
+	header := make([]byte, 8)
+	for {
+		if _, err := io.ReadFull(stream, header); errors.Is(err, io.EOF) {
+			return nil
+		} else if err != nil {
+			return err
+		}
+
+		dst := stdout
+		if header[0] == 2 {
+			dst = stderr
+		}
+		if _, err := io.CopyN(dst, stream, int64(binary.BigEndian.Uint32(header[4:]))); err != nil {
+			return err
+		}
+	}
+}
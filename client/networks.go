@@ -0,0 +1,38 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	. "github.com/LysetsDal/docker-api/types"
+	"net/http"
+	"net/url"
+)
+
+// ListNetworks GET /networks
+func (c *DockerClient) ListNetworks(ctx context.Context, filters map[string][]string) ([]NetworkResource, error) {
+	query := url.Values{}
+	if encoded := EncodeFilters(filters); encoded != "" {
+		query.Set("filters", encoded)
+	}
+
+	networks := make([]NetworkResource, 0)
+	err := c.Call(ctx, http.MethodGet, "networks", query, nil, &networks)
+	return networks, err
+}
+
+// CreateNetwork POST /networks/create
+func (c *DockerClient) CreateNetwork(ctx context.Context, request NetworkCreateRequest) (NetworkCreateResponse, error) {
+	created := NetworkCreateResponse{}
+	err := c.Call(ctx, http.MethodPost, "networks/create", nil, request, &created)
+	return created, err
+}
+
+// ConnectNetwork POST /networks/{id}/connect
+func (c *DockerClient) ConnectNetwork(ctx context.Context, network string, request NetworkConnectRequest) error {
+	return c.Call(ctx, http.MethodPost, fmt.Sprintf("networks/%s/connect", network), nil, request, nil)
+}
+
+// RemoveNetwork DELETE /networks/{id}
+func (c *DockerClient) RemoveNetwork(ctx context.Context, network string) error {
+	return c.Call(ctx, http.MethodDelete, "networks/"+network, nil, nil, nil)
+}
@@ -0,0 +1,49 @@
+package client
+
+import (
+	"context"
+	. "github.com/LysetsDal/docker-api/types"
+	"net/http"
+	"net/url"
+	"strconv"
+)
+
+// DiskUsage GET /system/df
+func (c *DockerClient) DiskUsage(ctx context.Context) (DiskUsage, error) {
+	usage := DiskUsage{}
+	err := c.Call(ctx, http.MethodGet, "system/df", nil, nil, &usage)
+	return usage, err
+}
+
+// PruneContainers POST /containers/prune
+func (c *DockerClient) PruneContainers(ctx context.Context, filters map[string][]string) (PruneResponse, error) {
+	query := url.Values{}
+	if encoded := EncodeFilters(filters); encoded != "" {
+		query.Set("filters", encoded)
+	}
+
+	pruned := PruneResponse{}
+	err := c.Call(ctx, http.MethodPost, "containers/prune", query, nil, &pruned)
+	return pruned, err
+}
+
+// RemoveImage DELETE /images/{name}. force also removes an image tagged in several repositories.
+func (c *DockerClient) RemoveImage(ctx context.Context, name string, force bool) error {
+	query := url.Values{}
+	query.Set("force", strconv.FormatBool(force))
+
+	return c.Call(ctx, http.MethodDelete, "images/"+name, query, nil, nil)
+}
+
+// PruneBuildCache POST /build/prune. all includes cache that is still referenced.
+func (c *DockerClient) PruneBuildCache(ctx context.Context, all bool, filters map[string][]string) (BuildCachePruneResponse, error) {
+	query := url.Values{}
+	query.Set("all", strconv.FormatBool(all))
+	if encoded := EncodeFilters(filters); encoded != "" {
+		query.Set("filters", encoded)
+	}
+
+	pruned := BuildCachePruneResponse{}
+	err := c.Call(ctx, http.MethodPost, "build/prune", query, nil, &pruned)
+	return pruned, err
+}
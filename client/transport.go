@@ -0,0 +1,141 @@
+package client
+
+import (
+	"io"
+	"log/slog"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// LoggingTransport Logs every Docker Engine call with its method, path, status and duration: at debug
+// level, or warn when the call fails or the daemon answers 5xx. Lines carry the request id of the
+// API request that made the call.
+type LoggingTransport struct {
+	Base http.RoundTripper
+}
+
+func (t *LoggingTransport) RoundTrip(request *http.Request) (*http.Response, error) {
+	started := time.Now()
+	response, err := t.Base.RoundTrip(request)
+
+	attrs := []slog.Attr{
+		slog.String("method", request.Method),
+		slog.String("path", request.URL.Path),
+		slog.Int64("duration_ms", time.Since(started).Milliseconds()),
+	}
+	level := slog.LevelDebug
+	if err != nil {
+		level = slog.LevelWarn
+		attrs = append(attrs, slog.String("error", err.Error()))
+	} else {
+		if response.StatusCode >= 500 {
+			level = slog.LevelWarn
+		}
+		attrs = append(attrs, slog.Int("status", response.StatusCode))
+	}
+
+	slog.LogAttrs(request.Context(), level, "docker call", attrs...)
+	return response, err
+}
+
+const tracerName = "github.com/LysetsDal/docker-api/client"
+
+// TracingTransport Makes a client span per Docker Engine call and passes the trace context to the
+// daemon in the W3C headers. Spans of streamed responses, such as pulls and logs, end when the body
+// is closed.
+type TracingTransport struct {
+	Base http.RoundTripper
+}
+
+func (t *TracingTransport) RoundTrip(request *http.Request) (*http.Response, error) {
+	operation, containerId := dockerOperation(request.URL.Path)
+	attrs := []attribute.KeyValue{
+		semconv.HTTPRequestMethodKey.String(request.Method),
+		semconv.URLPath(request.URL.Path),
+	}
+	if containerId != "" {
+		attrs = append(attrs, semconv.ContainerID(containerId))
+	}
+
+	ctx, span := otel.Tracer(tracerName).Start(request.Context(), "docker "+request.Method+" "+operation,
+		trace.WithSpanKind(trace.SpanKindClient), trace.WithAttributes(attrs...))
+
+	// A RoundTripper must not change the request it is given
+	request = request.Clone(ctx)
+	otel.GetTextMapPropagator().Inject(ctx, propagation.HeaderCarrier(request.Header))
+
+	response, err := t.Base.RoundTrip(request)
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		span.End()
+		return response, err
+	}
+
+	span.SetAttributes(semconv.HTTPResponseStatusCode(response.StatusCode))
+	if response.StatusCode >= 400 {
+		span.SetStatus(codes.Error, response.Status)
+	}
+	response.Body = &spanBody{ReadCloser: response.Body, span: span}
+	return response, nil
+}
+
+// spanBody Ends the span of a call once its response has been read
+type spanBody struct {
+	io.ReadCloser
+	span trace.Span
+	once sync.Once
+}
+
+func (b *spanBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.once.Do(func() { b.span.End() })
+	return err
+}
+
+// dockerIds The Engine API resources addressed by id or name in the second path segment
+var dockerIds = map[string]bool{"containers": true, "exec": true, "networks": true, "volumes": true, "plugins": true}
+
+// dockerFixed Second path segments that are operations rather than ids
+var dockerFixed = map[string]bool{"json": true, "create": true, "prune": true, "load": true, "get": true, "search": true}
+
+// dockerOperation The path with ids replaced by placeholders, for low cardinality span names, and the
+// container id or name when the call is about one container
+func dockerOperation(path string) (string, string) {
+	segments := strings.Split(strings.Trim(path, "/"), "/")
+	if len(segments) > 0 && strings.HasPrefix(segments[0], "v1.") {
+		segments = segments[1:]
+	}
+	if len(segments) < 2 || dockerFixed[segments[1]] {
+		return "/" + strings.Join(segments, "/"), ""
+	}
+
+	switch {
+	case dockerIds[segments[0]]:
+		id := segments[1]
+		segments[1] = "{id}"
+		operation := "/" + strings.Join(segments, "/")
+		if segments[0] == "containers" {
+			return operation, id
+		}
+		return operation, ""
+	case segments[0] == "images":
+		// Image names contain slashes, the operation is the last segment if it is one
+		last := segments[len(segments)-1]
+		switch last {
+		case "json", "history", "push", "tag":
+			return "/images/{name}/" + last, ""
+		}
+		return "/images/{name}", ""
+	}
+	return "/" + strings.Join(segments, "/"), ""
+}
@@ -0,0 +1,185 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/LysetsDal/docker-api/client/clienttest"
+	"github.com/LysetsDal/docker-api/utils"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func TestLoggingTransport(t *testing.T) {
+	output := &bytes.Buffer{}
+	logger, err := utils.NewLogger(output, "debug", "json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	previous := slog.Default()
+	slog.SetDefault(logger)
+	t.Cleanup(func() { slog.SetDefault(previous) })
+
+	daemon := clienttest.NewDaemon(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/info" {
+			http.Error(w, `{"message":"boom"}`, http.StatusInternalServerError)
+			return
+		}
+		_, _ = w.Write([]byte(`{}`))
+	})
+	transport := &LoggingTransport{Base: daemon.Transport()}
+	ctx := utils.WithRequestId(context.Background(), "req-1")
+
+	for _, path := range []string{"/containers/web/json", "/info"} {
+		request, _ := http.NewRequestWithContext(ctx, http.MethodGet, "http://docker"+path, nil)
+		response, err := transport.RoundTrip(request)
+		if err != nil {
+			t.Fatal(err)
+		}
+		response.Body.Close()
+	}
+	failing := &LoggingTransport{Base: roundTripFunc(func(*http.Request) (*http.Response, error) {
+		return nil, errors.New("dial unix /var/run/docker.sock: connect: permission denied")
+	})}
+	request, _ := http.NewRequestWithContext(ctx, http.MethodPost, "http://docker/containers/create", nil)
+	if _, err := failing.RoundTrip(request); err == nil {
+		t.Fatal("no error")
+	}
+
+	want := []struct {
+		level, path string
+		status      int
+		error       bool
+	}{
+		{"DEBUG", "/containers/web/json", http.StatusOK, false},
+		{"WARN", "/info", http.StatusInternalServerError, false},
+		{"WARN", "/containers/create", 0, true},
+	}
+	lines := strings.Split(strings.TrimSpace(output.String()), "\n")
+	if len(lines) != len(want) {
+		t.Fatalf("got %d lines: %s", len(lines), output)
+	}
+	for i, line := range lines {
+		record := struct {
+			Level     string `json:"level"`
+			Path      string `json:"path"`
+			Status    int    `json:"status"`
+			Error     string `json:"error"`
+			RequestId string `json:"request_id"`
+		}{}
+		if err := json.Unmarshal([]byte(line), &record); err != nil {
+			t.Fatal(err)
+		}
+		if record.Level != want[i].level || record.Path != want[i].path || record.Status != want[i].status ||
+			(record.Error != "") != want[i].error || record.RequestId != "req-1" {
+			t.Errorf("line %d: %s", i, line)
+		}
+	}
+}
+
+func TestDockerOperation(t *testing.T) {
+	tests := []struct {
+		path, operation, container string
+	}{
+		{"/v1.43/containers/web/start", "/containers/{id}/start", "web"},
+		{"/containers/abc123/json", "/containers/{id}/json", "abc123"},
+		{"/containers/json", "/containers/json", ""},
+		{"/containers/create", "/containers/create", ""},
+		{"/networks/front", "/networks/{id}", ""},
+		{"/images/library/nginx:1.25/json", "/images/{name}/json", ""},
+		{"/images/registry.local/app/push", "/images/{name}/push", ""},
+		{"/images/nginx", "/images/{name}", ""},
+		{"/images/create", "/images/create", ""},
+		{"/info", "/info", ""},
+	}
+
+	for _, test := range tests {
+		operation, container := dockerOperation(test.path)
+		if operation != test.operation || container != test.container {
+			t.Errorf("%s: got %s %q, want %s %q", test.path, operation, container, test.operation, test.container)
+		}
+	}
+}
+
+// recordSpans Install a tracer provider that records every span until the test ends
+func recordSpans(t *testing.T) *tracetest.SpanRecorder {
+	recorder := tracetest.NewSpanRecorder()
+	provider, propagator := otel.GetTracerProvider(), otel.GetTextMapPropagator()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	t.Cleanup(func() {
+		otel.SetTracerProvider(provider)
+		otel.SetTextMapPropagator(propagator)
+	})
+	return recorder
+}
+
+func TestTracingTransport(t *testing.T) {
+	spans := recordSpans(t)
+
+	var traceparent string
+	daemon := clienttest.NewDaemon(t, func(w http.ResponseWriter, r *http.Request) {
+		traceparent = r.Header.Get("traceparent")
+		if r.URL.Path == "/v1.43/containers/missing/logs" {
+			http.Error(w, `{"message":"No such container"}`, http.StatusNotFound)
+			return
+		}
+		_, _ = w.Write([]byte("log line\n"))
+	})
+	transport := &TracingTransport{Base: daemon.Transport()}
+
+	request, _ := http.NewRequest(http.MethodGet, "http://docker/v1.43/containers/web/logs?follow=true", nil)
+	response, err := transport.RoundTrip(request)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if request.Header.Get("traceparent") != "" {
+		t.Error("the caller's request was changed")
+	}
+	if len(spans.Ended()) != 0 {
+		t.Error("the span ended before the body was read")
+	}
+	_, _ = io.Copy(io.Discard, response.Body)
+	response.Body.Close()
+
+	request, _ = http.NewRequest(http.MethodGet, "http://docker/v1.43/containers/missing/logs", nil)
+	if response, err = transport.RoundTrip(request); err != nil {
+		t.Fatal(err)
+	}
+	response.Body.Close()
+
+	ended := spans.Ended()
+	if len(ended) != 2 {
+		t.Fatalf("got %d spans", len(ended))
+	}
+	span := ended[0]
+	if span.Name() != "docker GET /containers/{id}/logs" || span.SpanKind() != trace.SpanKindClient {
+		t.Errorf("span %s (%s)", span.Name(), span.SpanKind())
+	}
+	attrs := map[attribute.Key]attribute.Value{}
+	for _, attr := range span.Attributes() {
+		attrs[attr.Key] = attr.Value
+	}
+	if attrs[semconv.ContainerIDKey].AsString() != "web" || attrs[semconv.HTTPResponseStatusCodeKey].AsInt64() != http.StatusOK {
+		t.Errorf("attributes: %v", span.Attributes())
+	}
+	if !strings.Contains(traceparent, ended[1].SpanContext().SpanID().String()) {
+		t.Errorf("traceparent %q doesn't name the call's span", traceparent)
+	}
+	if ended[1].Status().Code != codes.Error || span.Status().Code == codes.Error {
+		t.Errorf("statuses: %v, %v", span.Status(), ended[1].Status())
+	}
+}
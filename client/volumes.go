@@ -0,0 +1,43 @@
+package client
+
+import (
+	"context"
+	. "github.com/LysetsDal/docker-api/types"
+	"net/http"
+	"net/url"
+	"strconv"
+)
+
+// ListVolumes GET /volumes
+func (c *DockerClient) ListVolumes(ctx context.Context, filters map[string][]string) ([]Volume, error) {
+	query := url.Values{}
+	if encoded := EncodeFilters(filters); encoded != "" {
+		query.Set("filters", encoded)
+	}
+
+	volumes := VolumeListResponse{}
+	err := c.Call(ctx, http.MethodGet, "volumes", query, nil, &volumes)
+	return volumes.Volumes, err
+}
+
+// CreateVolume POST /volumes/create. Creating an existing volume returns it unchanged.
+func (c *DockerClient) CreateVolume(ctx context.Context, request VolumeCreateRequest) (Volume, error) {
+	volume := Volume{}
+	err := c.Call(ctx, http.MethodPost, "volumes/create", nil, request, &volume)
+	return volume, err
+}
+
+// RemoveVolume DELETE /volumes/{name}
+func (c *DockerClient) RemoveVolume(ctx context.Context, name string, force bool) error {
+	query := url.Values{}
+	query.Set("force", strconv.FormatBool(force))
+
+	return c.Call(ctx, http.MethodDelete, "volumes/"+name, query, nil, nil)
+}
+
+// InspectVolume GET /volumes/{name}
+func (c *DockerClient) InspectVolume(ctx context.Context, name string) (Volume, error) {
+	volume := Volume{}
+	err := c.Call(ctx, http.MethodGet, "volumes/"+name, nil, nil, &volume)
+	return volume, err
+}
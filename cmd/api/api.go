@@ -2,21 +2,50 @@ package api
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"github.com/LysetsDal/docker-api/client"
+	. "github.com/LysetsDal/docker-api/config"
+	"github.com/LysetsDal/docker-api/service/backup"
 	"github.com/LysetsDal/docker-api/service/container"
+	"github.com/LysetsDal/docker-api/service/deployment"
+	"github.com/LysetsDal/docker-api/service/desired"
+	"github.com/LysetsDal/docker-api/service/idempotency"
+	"github.com/LysetsDal/docker-api/service/image"
+	"github.com/LysetsDal/docker-api/service/job"
+	"github.com/LysetsDal/docker-api/service/operation"
+	"github.com/LysetsDal/docker-api/service/registry"
+	"github.com/LysetsDal/docker-api/service/stack"
+	"github.com/LysetsDal/docker-api/service/system"
+	"github.com/LysetsDal/docker-api/service/template"
 	. "github.com/LysetsDal/docker-api/utils"
-	"log"
+	"log/slog"
 	"net"
 	"net/http"
 	"os"
 	"os/signal"
+	"path/filepath"
+	"strings"
+	"syscall"
 	"time"
 
 	"github.com/gorilla/mux"
 	. "github.com/klauspost/cpuid/v2"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
 )
 
 const dockerSocket string = "/var/run/docker.sock"
 
+const tracerName = "github.com/LysetsDal/docker-api/cmd/api"
+
+// unixConnKey Context key set to whether the request came in on a unix socket
+type unixConnKey struct{}
+
 // APIServer API struct
 type APIServer struct {
 	Name           string
@@ -61,39 +90,396 @@ func NewAPIServer(listenAddr string) *APIServer {
 
 // Run Start Listening
 func (s *APIServer) Run() {
+	logger, err := NewLogger(os.Stderr, LogLevel, LogFormat)
+	if err != nil {
+		fatal("configuring logging", err)
+	}
+	slog.SetDefault(logger)
+
+	shutdownTracing, err := SetupTracing(context.Background(), TraceExporter, TraceFile)
+	if err != nil {
+		fatal("configuring tracing", err)
+	}
+	defer func() {
+		if err := shutdownTracing(context.Background()); err != nil {
+			slog.Error("flushing traces", "error", err)
+		}
+	}()
+
+	reloader, err := tlsReloader()
+	if err != nil {
+		fatal("configuring TLS", err)
+	}
+
 	router := mux.NewRouter()
 	subrouter := router.PathPrefix("/api/v1").Subrouter()
 
-	containerHandler := container.NewHandler(s.DockerSock)
+	operations, err := operation.NewOperations()
+	if err != nil {
+		fatal("starting operations", err)
+	}
+	operationHandler := operation.NewHandler(operations)
+	operationHandler.RegisterRoutes(subrouter)
+
+	idempotencyKeys, err := idempotency.NewKeys()
+	if err != nil {
+		fatal("starting idempotency keys", err)
+	}
+
+	// Every handler copies DockerSock, so credentials must be wired in before any handler is created
+	credentials, err := registry.NewCredentialStore()
+	if err != nil {
+		fatal("starting registry credentials", err)
+	}
+	s.DockerSock.Transport = &client.RegistryAuthTransport{
+		Base:        &client.TracingTransport{Base: &client.LoggingTransport{Base: s.DockerSock.Transport}},
+		Credentials: credentials,
+	}
+
+	registryHandler := registry.NewHandler(s.DockerSock, credentials)
+	registryHandler.RegisterRoutes(subrouter)
+
+	containerHandler := container.NewHandler(s.DockerSock, operations, idempotencyKeys)
 	containerHandler.RegisterRoutes(subrouter)
 
+	stackHandler := stack.NewHandler(s.DockerSock, operations, idempotencyKeys)
+	stackHandler.RegisterRoutes(subrouter)
+
+	desiredHandler, err := desired.NewHandler(s.DockerSock)
+	if err != nil {
+		fatal("starting desired state", err)
+	}
+	desiredHandler.RegisterRoutes(subrouter)
+	go desiredHandler.Controller.Run(context.Background())
+
+	deploymentHandler := deployment.NewHandler(s.DockerSock, operations)
+	deploymentHandler.RegisterRoutes(subrouter)
+
+	backupHandler, err := backup.NewHandler(s.DockerSock, operations)
+	if err != nil {
+		fatal("starting backups", err)
+	}
+	backupHandler.RegisterRoutes(subrouter)
+	go backupHandler.Backups.Run(context.Background())
+
+	imageHandler := image.NewHandler(s.DockerSock, operations)
+	imageHandler.RegisterRoutes(subrouter)
+
+	templateHandler, err := template.NewHandler(s.DockerSock)
+	if err != nil {
+		fatal("starting templates", err)
+	}
+	templateHandler.RegisterRoutes(subrouter)
+
+	systemHandler := system.NewHandler(s.DockerSock, operations)
+	systemHandler.RegisterRoutes(subrouter)
+
+	jobHandler, err := job.NewHandler(s.DockerSock, templateHandler.Templates, backupHandler.Backups)
+	if err != nil {
+		fatal("starting jobs", err)
+	}
+	jobHandler.RegisterRoutes(subrouter)
+	go jobHandler.Scheduler.Run(context.Background())
+
 	subrouter.HandleFunc("/", MakeHttpHandleFunc(s.HomeHandler))
 
-	log.Printf("%s listening on %s\n", s.Name, s.ListenAddr)
+	router.Use(routeMW)
+	router.NotFoundHandler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_ = WriteProblem(w, http.StatusNotFound, fmt.Sprintf("No route for %s %s", r.Method, r.URL.Path))
+	})
+
+	var handler http.Handler = router
+	if reloader != nil && reloader.ClientAuth() {
+		handler = roleMW(reloader, router)
+	}
+	server := &http.Server{
+		Handler: traceMW(requestMW(handler)),
+		ConnContext: func(ctx context.Context, c net.Conn) context.Context {
+			return context.WithValue(ctx, unixConnKey{}, c.LocalAddr().Network() == "unix")
+		},
+	}
+	if reloader != nil {
+		server.TLSConfig = reloader.Config()
+		go reloader.Run(context.Background(), TLSReloadInterval)
+	}
+
+	listeners, err := s.listeners()
+	if err != nil {
+		fatal("listening", err)
+	}
+
+	// Graceful shutdown on ctrl + c, so in-flight requests finish and buffered spans are exported
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+		c := make(chan os.Signal, 1)
+		signal.Notify(c, os.Interrupt, syscall.SIGTERM)
+		<-c
 
-	subrouter.Use(logMW)
+		SystemdStopping()
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		if err := server.Shutdown(ctx); err != nil {
+			slog.Error("shutting down", "error", err)
+		}
+	}()
 
-	// Run server in go func so it doesn't block
-	go func() {}()
-	if err := http.ListenAndServe(s.ListenAddr, router); err != nil {
+	// TCP listeners are served over TLS when it is configured; unix sockets are guarded by their
+	// permissions instead
+	served := make(chan error, len(listeners))
+	for _, l := range listeners {
+		network, useTLS := l.Addr().Network(), reloader != nil && l.Addr().Network() != "unix"
+		attrs := []any{"name", s.Name, "network", network, "addr", l.Addr().String(), "tls", useTLS}
+		if useTLS {
+			attrs = append(attrs, "client_auth", reloader.ClientAuth())
+		}
+		slog.Info("listening", attrs...)
+
+		go func(l net.Listener) {
+			if useTLS {
+				served <- server.ServeTLS(l, "", "")
+			} else {
+				served <- server.Serve(l)
+			}
+		}(l)
+	}
+	SystemdReady()
+
+	if err := <-served; !errors.Is(err, http.ErrServerClosed) {
+		slog.Error("serving", "error", err)
+		_ = server.Close()
 		return
 	}
+	<-stopped
+}
+
+// listeners The sockets systemd passed when socket activated, otherwise the TCP address and unix
+// socket from the configuration
+func (s *APIServer) listeners() ([]net.Listener, error) {
+	listeners, err := SystemdListeners()
+	if err != nil || len(listeners) > 0 {
+		return listeners, err
+	}
+
+	if s.ListenAddr != "none" {
+		l, err := net.Listen("tcp", s.ListenAddr)
+		if err != nil {
+			return nil, err
+		}
+		listeners = append(listeners, l)
+	}
+	if UnixSocket != "" {
+		l, err := ListenUnix(UnixSocket, UnixSocketMode, UnixSocketGroup)
+		if err != nil {
+			for _, l := range listeners {
+				_ = l.Close()
+			}
+			return nil, err
+		}
+		listeners = append(listeners, l)
+	}
+	if len(listeners) == 0 {
+		return nil, fmt.Errorf("DOCKER_API_LISTEN_ADDR is none and DOCKER_API_UNIX_SOCKET is not set, nothing to listen on")
+	}
+	return listeners, nil
+}
+
+// tlsReloader The TLS files from the configuration, nil to serve plain HTTP
+func tlsReloader() (*TLSReloader, error) {
+	files := TLSFiles{Cert: TLSCertFile, Key: TLSKeyFile, ClientCA: TLSClientCA, Roles: TLSRolesFile}
+	if (files.Cert == "") != (files.Key == "") {
+		return nil, fmt.Errorf("set both DOCKER_API_TLS_CERT and DOCKER_API_TLS_KEY, or neither")
+	}
+
+	if files.Cert == "" && TLSSelfSigned {
+		files.Cert, files.Key = filepath.Join(DataDir, "tls", "cert.pem"), filepath.Join(DataDir, "tls", "key.pem")
+		if err := SelfSignedCertificate(files.Cert, files.Key); err != nil {
+			return nil, fmt.Errorf("creating self-signed certificate: %w", err)
+		}
+		slog.Warn("serving a self-signed certificate, for development only", "cert", files.Cert)
+	}
 
-	// Graceful shutdown on ctrl + c:
-	c := make(chan os.Signal, 1)
-	signal.Notify(c, os.Interrupt)
-	<-c
+	if files.Cert == "" {
+		if files.ClientCA != "" || files.Roles != "" {
+			return nil, fmt.Errorf("client certificates need a server certificate, set DOCKER_API_TLS_CERT and DOCKER_API_TLS_KEY")
+		}
+		return nil, nil
+	}
+	// Without a client CA no certificate is verified, so no request would ever have a role
+	if files.Roles != "" && files.ClientCA == "" {
+		return nil, fmt.Errorf("roles need a client CA, set DOCKER_API_TLS_CLIENT_CA or unset DOCKER_API_TLS_ROLES")
+	}
+	return NewTLSReloader(files)
 }
 
-func logMW(next http.Handler) http.Handler {
+// roleMW Refuses clients whose certificate maps to no role, and viewers anything but reads. Requests
+// on the unix socket carry no certificate and are let through, its permissions decide who connects.
+func roleMW(reloader *TLSReloader, next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		log.Printf("%s - %s (%s)", r.Method, r.URL.Path, r.RemoteAddr)
+		if unix, _ := r.Context().Value(unixConnKey{}).(bool); unix {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		role, ok := reloader.Role(r.TLS)
+		if !ok {
+			_ = WriteProblem(w, http.StatusForbidden, fmt.Sprintf("client certificate %s has no role", clientSubject(r)))
+			return
+		}
+		if role == RoleViewer && r.Method != http.MethodGet && r.Method != http.MethodHead {
+			_ = WriteProblem(w, http.StatusForbidden, fmt.Sprintf("role %s may only read, not %s", role, r.Method))
+			return
+		}
 
-		// compare the return-value to the authMW
+		trace.SpanFromContext(r.Context()).SetAttributes(attribute.String("client.role", role))
 		next.ServeHTTP(w, r)
 	})
 }
 
+// clientSubject The subject of the request's verified client certificate, "" without one
+func clientSubject(r *http.Request) string {
+	if r.TLS == nil || len(r.TLS.VerifiedChains) == 0 {
+		return ""
+	}
+	return r.TLS.VerifiedChains[0][0].Subject.String()
+}
+
+// requestMW Gives every request an id, taken from a valid X-Request-ID or generated, which is sent back
+// and logged with every line about the request, and logs the request once it is served
+func requestMW(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		started := time.Now()
+
+		id := r.Header.Get(RequestIdHeader)
+		if !validRequestId(id) {
+			id = RandomId()
+		}
+		w.Header().Set(RequestIdHeader, id)
+		r = r.WithContext(WithRequestId(r.Context(), id))
+		trace.SpanFromContext(r.Context()).SetAttributes(attribute.String("request_id", id))
+
+		sw := &statusWriter{ResponseWriter: w}
+		next.ServeHTTP(sw, r)
+
+		level := slog.LevelInfo
+		if sw.Status() >= 500 {
+			level = slog.LevelError
+		}
+		attrs := []slog.Attr{
+			slog.String("method", r.Method),
+			slog.String("path", r.URL.Path),
+			slog.Int("status", sw.Status()),
+			slog.Int64("bytes", sw.bytes),
+			slog.Int64("duration_ms", time.Since(started).Milliseconds()),
+			slog.String("remote", r.RemoteAddr),
+		}
+		if subject := clientSubject(r); subject != "" {
+			attrs = append(attrs, slog.String("client", subject))
+		}
+		slog.LogAttrs(r.Context(), level, "request", attrs...)
+	})
+}
+
+// traceMW Makes a server span per request, continuing the caller's trace from its W3C traceparent header.
+// The span is named after the route once routeMW knows it.
+func traceMW(next http.Handler) http.Handler {
+	tracer := otel.Tracer(tracerName)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := otel.GetTextMapPropagator().Extract(r.Context(), propagation.HeaderCarrier(r.Header))
+		remote, _, _ := net.SplitHostPort(r.RemoteAddr)
+		ctx, span := tracer.Start(ctx, r.Method, trace.WithSpanKind(trace.SpanKindServer), trace.WithAttributes(
+			semconv.HTTPRequestMethodKey.String(r.Method),
+			semconv.URLPath(r.URL.Path),
+			semconv.ClientAddress(remote),
+		))
+		defer span.End()
+
+		sw := &statusWriter{ResponseWriter: w}
+		next.ServeHTTP(sw, r.WithContext(ctx))
+
+		span.SetAttributes(semconv.HTTPResponseStatusCode(sw.Status()))
+		if sw.Status() >= 500 {
+			span.SetStatus(codes.Error, http.StatusText(sw.Status()))
+		}
+	})
+}
+
+// routeMW Names the request's span after the matched route and adds the container it is about
+func routeMW(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		span := trace.SpanFromContext(r.Context())
+		if template, err := mux.CurrentRoute(r).GetPathTemplate(); err == nil {
+			span.SetName(r.Method + " " + template)
+			span.SetAttributes(semconv.HTTPRoute(template))
+			if id, ok := mux.Vars(r)["id"]; ok && strings.HasPrefix(template, "/api/v1/containers/") {
+				span.SetAttributes(semconv.ContainerID(id))
+			}
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// validRequestId Ids from callers are kept when they are 1 to 128 printable ASCII characters
+func validRequestId(id string) bool {
+	if id == "" || len(id) > 128 {
+		return false
+	}
+	for i := 0; i < len(id); i++ {
+		if id[i] <= 0x20 || id[i] > 0x7e {
+			return false
+		}
+	}
+	return true
+}
+
+// statusWriter Records the status and size of a response
+type statusWriter struct {
+	http.ResponseWriter
+	status int
+	bytes  int64
+}
+
+func (w *statusWriter) WriteHeader(statusCode int) {
+	if w.status == 0 {
+		w.status = statusCode
+	}
+	w.ResponseWriter.WriteHeader(statusCode)
+}
+
+func (w *statusWriter) Write(p []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	n, err := w.ResponseWriter.Write(p)
+	w.bytes += int64(n)
+	return n, err
+}
+
+func (w *statusWriter) Flush() {
+	if flusher, ok := w.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+// Unwrap Lets http.ResponseController reach the underlying writer
+func (w *statusWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// Status The status sent, 200 when the handler wrote nothing
+func (w *statusWriter) Status() int {
+	if w.status == 0 {
+		return http.StatusOK
+	}
+	return w.status
+}
+
+// fatal Log err and exit
+func fatal(msg string, err error) {
+	slog.Error(msg, "error", err)
+	os.Exit(1)
+}
+
 // HomeHandler Displays version info
 func (s *APIServer) HomeHandler(w http.ResponseWriter, _ *http.Request) error {
 
@@ -0,0 +1,248 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/LysetsDal/docker-api/config"
+	"github.com/LysetsDal/docker-api/utils"
+	"github.com/gorilla/mux"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// captureLogs Send the default logger's json lines to the returned buffer until the test ends
+func captureLogs(t *testing.T) *bytes.Buffer {
+	output := &bytes.Buffer{}
+	logger, err := utils.NewLogger(output, "debug", "json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	previous := slog.Default()
+	slog.SetDefault(logger)
+	t.Cleanup(func() { slog.SetDefault(previous) })
+	return output
+}
+
+func TestRequestMW(t *testing.T) {
+	tests := []struct {
+		name      string
+		header    string
+		status    int
+		wantId    string
+		wantLevel string
+	}{
+		{name: "caller's id", header: "build-42", status: http.StatusCreated, wantId: "build-42", wantLevel: "INFO"},
+		{name: "no id", status: http.StatusOK, wantLevel: "INFO"},
+		{name: "invalid id", header: "has spaces", status: http.StatusOK, wantLevel: "INFO"},
+		{name: "too long", header: strings.Repeat("a", 129), status: http.StatusOK, wantLevel: "INFO"},
+		{name: "server error", header: "build-43", status: http.StatusBadGateway, wantId: "build-43", wantLevel: "ERROR"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			output := captureLogs(t)
+
+			var seen string
+			handler := requestMW(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				seen = utils.RequestId(r.Context())
+				slog.InfoContext(r.Context(), "handling")
+				w.WriteHeader(test.status)
+				_, _ = w.Write([]byte("hello"))
+			}))
+
+			request := httptest.NewRequest(http.MethodPost, "/api/v1/containers", nil)
+			if test.header != "" {
+				request.Header.Set(utils.RequestIdHeader, test.header)
+			}
+			recorder := httptest.NewRecorder()
+			handler.ServeHTTP(recorder, request)
+
+			id := recorder.Header().Get(utils.RequestIdHeader)
+			if id == "" || id != seen {
+				t.Errorf("request id: sent %q, handler saw %q", id, seen)
+			}
+			if test.wantId != "" && id != test.wantId {
+				t.Errorf("request id: got %q, want %q", id, test.wantId)
+			}
+			if test.wantId == "" && id == test.header {
+				t.Errorf("request id %q wasn't replaced", id)
+			}
+
+			lines := strings.Split(strings.TrimSpace(output.String()), "\n")
+			if len(lines) != 2 {
+				t.Fatalf("got %d lines: %s", len(lines), output)
+			}
+			for _, line := range lines {
+				if !strings.Contains(line, `"request_id":"`+id+`"`) {
+					t.Errorf("line without the request id: %s", line)
+				}
+			}
+
+			record := struct {
+				Level  string `json:"level"`
+				Msg    string `json:"msg"`
+				Method string `json:"method"`
+				Path   string `json:"path"`
+				Status int    `json:"status"`
+				Bytes  int64  `json:"bytes"`
+			}{}
+			if err := json.Unmarshal([]byte(lines[1]), &record); err != nil {
+				t.Fatal(err)
+			}
+			if record.Msg != "request" || record.Level != test.wantLevel || record.Method != http.MethodPost ||
+				record.Path != "/api/v1/containers" || record.Status != test.status || record.Bytes != 5 {
+				t.Errorf("request line: %s", lines[1])
+			}
+		})
+	}
+}
+
+func TestTraceMW(t *testing.T) {
+	spans := tracetest.NewSpanRecorder()
+	provider, propagator := otel.GetTracerProvider(), otel.GetTextMapPropagator()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(spans)))
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	t.Cleanup(func() {
+		otel.SetTracerProvider(provider)
+		otel.SetTextMapPropagator(propagator)
+	})
+
+	router := mux.NewRouter()
+	router.Use(routeMW)
+	router.HandleFunc("/api/v1/containers/{id}/start", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadGateway)
+	})
+	handler := traceMW(requestMW(router))
+
+	const traceId = "4bf92f3577b34da6a3ce929d0e0e4736"
+	request := httptest.NewRequest(http.MethodPost, "/api/v1/containers/web/start", nil)
+	request.Header.Set("traceparent", "00-"+traceId+"-00f067aa0ba902b7-01")
+	request.Header.Set(utils.RequestIdHeader, "req-1")
+	handler.ServeHTTP(httptest.NewRecorder(), request)
+
+	ended := spans.Ended()
+	if len(ended) != 1 {
+		t.Fatalf("got %d spans", len(ended))
+	}
+	span := ended[0]
+	if span.Name() != "POST /api/v1/containers/{id}/start" || span.SpanKind() != trace.SpanKindServer {
+		t.Errorf("span %s (%s)", span.Name(), span.SpanKind())
+	}
+	if span.SpanContext().TraceID().String() != traceId || span.Parent().SpanID().String() != "00f067aa0ba902b7" {
+		t.Errorf("the caller's trace wasn't continued: %s, parent %s", span.SpanContext().TraceID(), span.Parent().SpanID())
+	}
+	attrs := map[attribute.Key]attribute.Value{}
+	for _, attr := range span.Attributes() {
+		attrs[attr.Key] = attr.Value
+	}
+	if attrs[semconv.ContainerIDKey].AsString() != "web" || attrs["request_id"].AsString() != "req-1" ||
+		attrs[semconv.HTTPResponseStatusCodeKey].AsInt64() != http.StatusBadGateway || span.Status().Code != codes.Error {
+		t.Errorf("attributes %v, status %v", span.Attributes(), span.Status())
+	}
+}
+
+func TestTLSReloaderConfig(t *testing.T) {
+	cert, key, clientCA, roles, selfSigned := config.TLSCertFile, config.TLSKeyFile, config.TLSClientCA, config.TLSRolesFile, config.TLSSelfSigned
+	t.Cleanup(func() {
+		config.TLSCertFile, config.TLSKeyFile, config.TLSClientCA, config.TLSRolesFile, config.TLSSelfSigned = cert, key, clientCA, roles, selfSigned
+	})
+
+	tests := []struct {
+		name                       string
+		cert, key, clientCA, roles string
+		wantErr                    string
+	}{
+		{name: "plain http"},
+		{name: "key without cert", key: "key.pem", wantErr: "set both"},
+		{name: "client CA without cert", clientCA: "ca.pem", wantErr: "need a server certificate"},
+		{name: "roles without client CA", cert: "cert.pem", key: "key.pem", roles: "roles.json", wantErr: "roles need a client CA"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			config.TLSCertFile, config.TLSKeyFile, config.TLSClientCA, config.TLSRolesFile = test.cert, test.key, test.clientCA, test.roles
+			config.TLSSelfSigned = false
+
+			reloader, err := tlsReloader()
+			if test.wantErr == "" {
+				if err != nil || reloader != nil {
+					t.Errorf("got %v, %v", reloader, err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), test.wantErr) {
+				t.Errorf("got %v, want %q", err, test.wantErr)
+			}
+		})
+	}
+}
+
+func TestRoleMW(t *testing.T) {
+	dir := t.TempDir()
+	files := utils.TLSFiles{Cert: filepath.Join(dir, "cert.pem"), Key: filepath.Join(dir, "key.pem"), Roles: filepath.Join(dir, "roles.json")}
+	if err := utils.SelfSignedCertificate(files.Cert, files.Key); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(files.Roles, []byte(`{"ci":"admin","dashboard":"viewer"}`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	reloader, err := utils.NewTLSReloader(files)
+	if err != nil {
+		t.Fatal(err)
+	}
+	handler := roleMW(reloader, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+
+	tests := []struct {
+		name       string
+		client     string
+		method     string
+		unix       bool
+		wantStatus int
+	}{
+		{name: "admin writes", client: "ci", method: http.MethodPost, wantStatus: http.StatusNoContent},
+		{name: "viewer reads", client: "dashboard", method: http.MethodGet, wantStatus: http.StatusNoContent},
+		{name: "viewer heads", client: "dashboard", method: http.MethodHead, wantStatus: http.StatusNoContent},
+		{name: "viewer writes", client: "dashboard", method: http.MethodDelete, wantStatus: http.StatusForbidden},
+		{name: "unlisted", client: "intruder", method: http.MethodGet, wantStatus: http.StatusForbidden},
+		{name: "no certificate", method: http.MethodGet, wantStatus: http.StatusForbidden},
+		{name: "unix socket", method: http.MethodPost, unix: true, wantStatus: http.StatusNoContent},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			request := httptest.NewRequest(test.method, "/api/v1/containers/web", nil)
+			if test.client != "" {
+				request.TLS = &tls.ConnectionState{VerifiedChains: [][]*x509.Certificate{{{Subject: pkix.Name{CommonName: test.client}}}}}
+			}
+			if test.unix {
+				request = request.WithContext(context.WithValue(request.Context(), unixConnKey{}, true))
+			}
+			recorder := httptest.NewRecorder()
+			handler.ServeHTTP(recorder, request)
+
+			if recorder.Code != test.wantStatus {
+				t.Errorf("status %d: %s", recorder.Code, recorder.Body)
+			}
+		})
+	}
+}
@@ -1,8 +1,11 @@
 package main
 
-import "github.com/LysetsDal/docker-api/cmd/api"
+import (
+	"github.com/LysetsDal/docker-api/cmd/api"
+	"github.com/LysetsDal/docker-api/config"
+)
 
 func main() {
-	server := api.NewAPIServer(":4000")
+	server := api.NewAPIServer(config.ListenAddr)
 	server.Run()
 }
@@ -1,3 +1,151 @@
 package config
 
+import (
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+)
+
 const UnixPrefix string = "http://unix/"
+
+// Labels set on every resource created for a stack
+const (
+	StackLabel        string = "com.docker-api.stack"
+	StackServiceLabel string = "com.docker-api.stack.service"
+)
+
+// Labels set on containers managed by the desired-state controller
+const (
+	DesiredLabel        string = "com.docker-api.desired"
+	DesiredHashLabel    string = "com.docker-api.desired.hash"
+	DesiredReplicaLabel string = "com.docker-api.desired.replica"
+)
+
+// TemplateLabel Set on containers created from a template, value is the template name
+const TemplateLabel string = "com.docker-api.template"
+
+// Labels set on images committed by the snapshot endpoints. Snapshots are grouped by container name,
+// so they survive the container being restored (recreated under a new id).
+const (
+	SnapshotContainerLabel   string = "com.docker-api.snapshot.container"
+	SnapshotContainerIdLabel string = "com.docker-api.snapshot.container-id"
+	SnapshotCreatedLabel     string = "com.docker-api.snapshot.created"
+	SnapshotMessageLabel     string = "com.docker-api.snapshot.message"
+)
+
+// BackupHelperLabel Set on the short-lived containers that read and write volume backups
+const BackupHelperLabel string = "com.docker-api.backup.helper"
+
+// Labels set on the one-shot containers started by scheduled jobs
+const (
+	JobLabel    string = "com.docker-api.job"
+	JobRunLabel string = "com.docker-api.job.run"
+)
+
+// DataDir Directory for locally persisted state
+var DataDir = getEnv("DOCKER_API_DATA_DIR", "data")
+
+// KeyFile Server key used to encrypt registry credentials at rest, generated on first start
+var KeyFile = getEnv("DOCKER_API_KEY_FILE", filepath.Join(DataDir, "server.key"))
+
+// BackupDir Where volume backups written to local disk are kept
+var BackupDir = getEnv("DOCKER_API_BACKUP_DIR", filepath.Join(DataDir, "backups"))
+
+// BackupHelperImage Image of the helper container that mounts a volume for backup and restore
+var BackupHelperImage = getEnv("DOCKER_API_BACKUP_IMAGE", "busybox:1.36")
+
+// ReconcileInterval How often the desired-state controller compares specs with running containers
+var ReconcileInterval = getEnvDuration("DOCKER_API_RECONCILE_INTERVAL", 30*time.Second)
+
+// ReconcileBackoff How long the controller waits before starting a replica again, doubled on every
+// consecutive start up to ReconcileMaxBackoff
+var ReconcileBackoff = getEnvDuration("DOCKER_API_RECONCILE_BACKOFF", time.Second)
+
+// ReconcileMaxBackoff The longest wait between starts of a replica that keeps dying
+var ReconcileMaxBackoff = getEnvDuration("DOCKER_API_RECONCILE_MAX_BACKOFF", 5*time.Minute)
+
+// OperationRetention How long finished async operations are kept
+var OperationRetention = getEnvDuration("DOCKER_API_OPERATION_RETENTION", 24*time.Hour)
+
+// OperationLimit How many finished async operations are kept at most
+var OperationLimit = getEnvInt("DOCKER_API_OPERATION_LIMIT", 1000)
+
+// PersistOperations Keep async operations in the data directory so they survive a restart
+var PersistOperations = getEnv("DOCKER_API_PERSIST_OPERATIONS", "false") == "true"
+
+// IdempotencyTTL How long the response to a request with an Idempotency-Key is replayed for retries
+var IdempotencyTTL = getEnvDuration("DOCKER_API_IDEMPOTENCY_TTL", 24*time.Hour)
+
+// LogLevel debug, info, warn or error. Each Docker Engine call is logged at debug.
+var LogLevel = getEnv("DOCKER_API_LOG_LEVEL", "info")
+
+// LogFormat json or text
+var LogFormat = getEnv("DOCKER_API_LOG_FORMAT", "json")
+
+// TraceExporter Where spans go: none, otlp, stdout or file. otlp is configured with the standard
+// OTEL_EXPORTER_OTLP_* variables and sampling with OTEL_TRACES_SAMPLER.
+var TraceExporter = getEnv("DOCKER_API_TRACE_EXPORTER", "none")
+
+// TraceFile The file the file exporter appends spans to, one json object each
+var TraceFile = getEnv("DOCKER_API_TRACE_FILE", "traces.json")
+
+// ListenAddr TCP address the API is served on, "none" to serve only on the unix socket
+var ListenAddr = getEnv("DOCKER_API_LISTEN_ADDR", ":4000")
+
+// UnixSocket Path of a unix socket to serve the API on as well, off when empty. Access is controlled by
+// the socket's UnixSocketMode and UnixSocketGroup rather than client certificates.
+var UnixSocket = getEnv("DOCKER_API_UNIX_SOCKET", "")
+
+// UnixSocketMode Permissions of the unix socket, in octal
+var UnixSocketMode = getEnv("DOCKER_API_UNIX_SOCKET_MODE", "0660")
+
+// UnixSocketGroup Group name or id that owns the unix socket, the process's group when empty
+var UnixSocketGroup = getEnv("DOCKER_API_UNIX_SOCKET_GROUP", "")
+
+// TLSCertFile and TLSKeyFile The server's certificate (with any intermediates) and key in PEM. With
+// both set the API is served over HTTPS only.
+var (
+	TLSCertFile = getEnv("DOCKER_API_TLS_CERT", "")
+	TLSKeyFile  = getEnv("DOCKER_API_TLS_KEY", "")
+)
+
+// TLSSelfSigned Serve HTTPS with a self-signed certificate for development, generated into the data
+// directory on first start unless TLSCertFile and TLSKeyFile are set
+var TLSSelfSigned = getEnv("DOCKER_API_TLS_SELF_SIGNED", "false") == "true"
+
+// TLSClientCA CA bundle (PEM) that client certificates must chain to. Setting it requires a client
+// certificate on every connection.
+var TLSClientCA = getEnv("DOCKER_API_TLS_CLIENT_CA", "")
+
+// TLSRolesFile json object mapping client certificate subjects ("CN=ci,O=Example") or common names
+// to admin or viewer, needs TLSClientCA. Without it every verified client is admin; with it unlisted
+// clients are refused.
+var TLSRolesFile = getEnv("DOCKER_API_TLS_ROLES", "")
+
+// TLSReloadInterval How often the certificate, client CA and roles files are checked for changes.
+// SIGHUP reloads them at once.
+var TLSReloadInterval = getEnvDuration("DOCKER_API_TLS_RELOAD_INTERVAL", 30*time.Second)
+
+func getEnv(key, fallback string) string {
+	if value, ok := os.LookupEnv(key); ok && value != "" {
+		return value
+	}
+	return fallback
+}
+
+func getEnvDuration(key string, fallback time.Duration) time.Duration {
+	duration, err := time.ParseDuration(getEnv(key, ""))
+	if err != nil {
+		return fallback
+	}
+	return duration
+}
+
+func getEnvInt(key string, fallback int) int {
+	value, err := strconv.Atoi(getEnv(key, ""))
+	if err != nil {
+		return fallback
+	}
+	return value
+}
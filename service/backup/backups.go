@@ -0,0 +1,421 @@
+package backup
+
+import (
+	"archive/tar"
+	"bufio"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	. "github.com/LysetsDal/docker-api/client"
+	. "github.com/LysetsDal/docker-api/config"
+	. "github.com/LysetsDal/docker-api/types"
+	. "github.com/LysetsDal/docker-api/utils"
+	"io"
+	"log/slog"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// volumeMount Where helper containers mount the volume. Backups hold its contents under volume/.
+const volumeMount = "/volume"
+
+// scheduleCheckInterval How often schedules are checked for a due backup
+const scheduleCheckInterval = time.Minute
+
+// Backups Volume backups through helper containers, the catalogue of backups on local disk
+// and the schedules that create them.
+type Backups struct {
+	Docker    *DockerClient
+	Catalogue *JsonStore[VolumeBackup]
+	Schedules *JsonStore[BackupSchedule]
+
+	mu sync.Mutex
+}
+
+func NewBackups(docker *DockerClient) (*Backups, error) {
+	catalogue, err := NewJsonStore[VolumeBackup](filepath.Join(DataDir, "backups.json"))
+	if err != nil {
+		return nil, fmt.Errorf("loading backup catalogue: %w", err)
+	}
+	schedules, err := NewJsonStore[BackupSchedule](filepath.Join(DataDir, "backup-schedules.json"))
+	if err != nil {
+		return nil, fmt.Errorf("loading backup schedules: %w", err)
+	}
+
+	return &Backups{Docker: docker, Catalogue: catalogue, Schedules: schedules}, nil
+}
+
+// Stream Write a gzipped tar of the volume to w
+func (b *Backups) Stream(ctx context.Context, volume string, w io.Writer) error {
+	compressed := gzip.NewWriter(w)
+	if err := b.archive(ctx, volume, compressed); err != nil {
+		return err
+	}
+	return compressed.Close()
+}
+
+// Save Back up the volume into BackupDir and add it to the catalogue. origin is what made the backup,
+// BackupOriginSchedule or job/<name>, and empty for a manual backup.
+func (b *Backups) Save(ctx context.Context, volume string, origin string) (VolumeBackup, error) {
+	created := time.Now().UTC()
+	id, file, err := b.reserve(volume, created)
+	if err != nil {
+		return VolumeBackup{}, err
+	}
+	path := filepath.Join(BackupDir, id+".tar.gz")
+	defer os.Remove(path + ".tmp")
+
+	hash := sha256.New()
+	counter := &countingWriter{}
+	err = b.Stream(ctx, volume, io.MultiWriter(file, hash, counter))
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return VolumeBackup{}, err
+	}
+	if err := os.Rename(path+".tmp", path); err != nil {
+		return VolumeBackup{}, err
+	}
+
+	backup := VolumeBackup{
+		Id:        id,
+		Volume:    volume,
+		File:      path,
+		Size:      counter.written,
+		Sha256:    hex.EncodeToString(hash.Sum(nil)),
+		Created:   created,
+		Scheduled: origin != "",
+		Origin:    origin,
+	}
+	if err := b.Catalogue.Put(id, backup); err != nil {
+		_ = os.Remove(path)
+		return VolumeBackup{}, err
+	}
+	return backup, nil
+}
+
+// Open Open a catalogued backup after checking that the file still matches its checksum
+func (b *Backups) Open(id string) (*os.File, VolumeBackup, error) {
+	backup, ok := b.Catalogue.Get(id)
+	if !ok {
+		return nil, backup, NotFound("No such backup: %s", id)
+	}
+
+	file, err := os.Open(backup.File)
+	if err != nil {
+		return nil, backup, err
+	}
+	hash := sha256.New()
+	if _, err := io.Copy(hash, file); err != nil {
+		file.Close()
+		return nil, backup, err
+	}
+	if sum := hex.EncodeToString(hash.Sum(nil)); sum != backup.Sha256 {
+		file.Close()
+		return nil, backup, fmt.Errorf("backup %s is corrupt: checksum %s, expected %s", id, sum, backup.Sha256)
+	}
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		file.Close()
+		return nil, backup, err
+	}
+
+	return file, backup, nil
+}
+
+// Delete Remove a backup's file and catalogue entry, reporting whether it existed
+func (b *Backups) Delete(id string) (bool, error) {
+	backup, ok := b.Catalogue.Get(id)
+	if !ok {
+		return false, nil
+	}
+	if err := os.Remove(backup.File); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return false, err
+	}
+	return b.Catalogue.Delete(id)
+}
+
+// List Catalogued backups, newest first. An empty volume lists every volume's backups.
+func (b *Backups) List(volume string) []VolumeBackup {
+	backups := make([]VolumeBackup, 0)
+	for _, backup := range b.Catalogue.List() {
+		if volume == "" || backup.Volume == volume {
+			backups = append(backups, backup)
+		}
+	}
+	sort.Slice(backups, func(i, j int) bool {
+		if !backups[i].Created.Equal(backups[j].Created) {
+			return backups[i].Created.After(backups[j].Created)
+		}
+		return backups[i].Id > backups[j].Id
+	})
+	return backups
+}
+
+// Prune Delete the volume's backups from origin beyond the keep newest, returning the deleted ids.
+// Scheduled backups from before origins were recorded count as the schedule's.
+func (b *Backups) Prune(volume string, origin string, keep int) ([]string, error) {
+	deleted := make([]string, 0)
+	kept := 0
+	for _, backup := range b.List(volume) {
+		if backup.Origin == "" && backup.Scheduled {
+			backup.Origin = BackupOriginSchedule
+		}
+		if origin == "" || backup.Origin != origin {
+			continue
+		}
+		if kept < keep {
+			kept++
+			continue
+		}
+		if _, err := b.Delete(backup.Id); err != nil {
+			return deleted, err
+		}
+		deleted = append(deleted, backup.Id)
+	}
+	return deleted, nil
+}
+
+// Restore Extract a gzipped (or plain) tar with the contents under volume/ into the volume, creating
+// the volume if it doesn't exist. clear empties the volume first, otherwise files not in the archive stay.
+// The archive is checked before the volume is touched; one with entries outside volume/ is a BadRequest.
+func (b *Backups) Restore(ctx context.Context, volume string, archive io.Reader, clear bool) error {
+	checked, err := b.checkArchive(archive)
+	if err != nil {
+		return err
+	}
+	defer checked.Close()
+
+	if _, err := b.Docker.CreateVolume(ctx, VolumeCreateRequest{Name: volume}); err != nil {
+		return err
+	}
+
+	return b.withHelper(ctx, volume, false, func(helper string) error {
+		if clear {
+			if err := b.Docker.StartContainer(ctx, helper); err != nil {
+				return fmt.Errorf("clearing %s: %w", volume, err)
+			}
+			result, err := b.Docker.WaitContainer(ctx, helper, "not-running")
+			if err != nil {
+				return fmt.Errorf("clearing %s: %w", volume, err)
+			}
+			if result.StatusCode != 0 {
+				return fmt.Errorf("clearing %s: helper exited with %d", volume, result.StatusCode)
+			}
+		}
+
+		return b.Docker.PutArchive(ctx, helper, "/", false, false, checked)
+	})
+}
+
+// checkArchive Read the archive through, checking that every entry is under volume/, and return it
+// from the start. An upload is spooled to a temporary file in BackupDir, which Close removes.
+func (b *Backups) checkArchive(archive io.Reader) (io.ReadCloser, error) {
+	if file, ok := archive.(*os.File); ok {
+		if err := checkEntries(file); err != nil {
+			return nil, err
+		}
+		if _, err := file.Seek(0, io.SeekStart); err != nil {
+			return nil, err
+		}
+		return io.NopCloser(file), nil
+	}
+
+	if err := os.MkdirAll(BackupDir, 0o700); err != nil {
+		return nil, err
+	}
+	spool, err := os.CreateTemp(BackupDir, "restore-*.tmp")
+	if err != nil {
+		return nil, err
+	}
+	fail := func(err error) (io.ReadCloser, error) {
+		_ = spool.Close()
+		_ = os.Remove(spool.Name())
+		return nil, err
+	}
+
+	if err := checkEntries(io.TeeReader(archive, spool)); err != nil {
+		return fail(err)
+	}
+	if _, err := spool.Seek(0, io.SeekStart); err != nil {
+		return fail(err)
+	}
+	return &spooled{File: spool}, nil
+}
+
+// checkEntries Read a gzipped (or plain) tar to the end, failing on an entry outside volume/
+func checkEntries(archive io.Reader) error {
+	buffered := bufio.NewReader(archive)
+	var contents io.Reader = buffered
+	if magic, _ := buffered.Peek(2); len(magic) == 2 && magic[0] == 0x1f && magic[1] == 0x8b {
+		decompressed, err := gzip.NewReader(buffered)
+		if err != nil {
+			return BadRequest("reading the archive: %w", err)
+		}
+		defer decompressed.Close()
+		contents = decompressed
+	}
+
+	reader := tar.NewReader(contents)
+	entries := 0
+	for {
+		header, err := reader.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return BadRequest("reading the archive: %w", err)
+		}
+		name := strings.TrimPrefix(path.Clean("/"+header.Name), "/")
+		if name != "volume" && !strings.HasPrefix(name, "volume/") {
+			return BadRequest("%s is outside volume/, the archive must hold the volume's contents under volume/", header.Name)
+		}
+		entries++
+	}
+	if entries == 0 {
+		return BadRequest("the archive is empty")
+	}
+
+	// The rest, so all of it is spooled
+	_, err := io.Copy(io.Discard, buffered)
+	return err
+}
+
+// spooled A temporary file that is removed when closed
+type spooled struct {
+	*os.File
+}
+
+func (s *spooled) Close() error {
+	err := s.File.Close()
+	if removeErr := os.Remove(s.Name()); err == nil {
+		err = removeErr
+	}
+	return err
+}
+
+// Run Check the schedules every minute until ctx is cancelled
+func (b *Backups) Run(ctx context.Context) {
+	ticker := time.NewTicker(scheduleCheckInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			b.runDueSchedules(ctx)
+		}
+	}
+}
+
+func (b *Backups) runDueSchedules(ctx context.Context) {
+	for _, schedule := range b.Schedules.List() {
+		every, err := time.ParseDuration(schedule.Every)
+		if err != nil || time.Since(schedule.LastRun) < every {
+			continue
+		}
+
+		schedule.LastRun = time.Now().UTC()
+		schedule.LastError = ""
+		backup, err := b.Save(ctx, schedule.Volume, BackupOriginSchedule)
+		if err == nil {
+			schedule.LastBackup = backup.Id
+			_, err = b.Prune(schedule.Volume, BackupOriginSchedule, schedule.Keep)
+		}
+		if err != nil {
+			schedule.LastError = err.Error()
+			slog.WarnContext(ctx, "scheduled backup failed", "volume", schedule.Volume, "error", err)
+		}
+
+		// The schedule may have been deleted or replaced while the backup ran
+		if current, ok := b.Schedules.Get(schedule.Volume); ok && current.Every == schedule.Every && current.Keep == schedule.Keep {
+			if err := b.Schedules.Put(schedule.Volume, schedule); err != nil {
+				slog.ErrorContext(ctx, "saving backup schedule", "volume", schedule.Volume, "error", err)
+			}
+		}
+	}
+}
+
+// archive Write the volume's tar (contents under volume/) to w
+func (b *Backups) archive(ctx context.Context, volume string, w io.Writer) error {
+	if _, err := b.Docker.InspectVolume(ctx, volume); err != nil {
+		return err
+	}
+
+	return b.withHelper(ctx, volume, true, func(helper string) error {
+		archive, _, err := b.Docker.GetArchive(ctx, helper, volumeMount)
+		if err != nil {
+			return err
+		}
+		defer archive.Close()
+
+		_, err = io.Copy(w, archive)
+		return err
+	})
+}
+
+// withHelper Create (but don't start) a container with the volume mounted at /volume, and remove it
+// once fn returns. The archive endpoints work on created containers. Started, it empties the volume.
+func (b *Backups) withHelper(ctx context.Context, volume string, readOnly bool, fn func(helper string) error) error {
+	payload := Payload{
+		Image:           BackupHelperImage,
+		Cmd:             []string{"find", volumeMount, "-mindepth", "1", "-delete"},
+		Labels:          map[string]string{BackupHelperLabel: volume},
+		NetworkDisabled: true,
+	}
+	payload.HostConfig.Mounts = []HostMount{{Type: "volume", Source: volume, Target: volumeMount, ReadOnly: readOnly}}
+
+	helper, err := b.Docker.CreateContainerWithPull(ctx, "", payload)
+	if err != nil {
+		return fmt.Errorf("creating backup helper: %w", err)
+	}
+	defer func() {
+		if err := b.Docker.RemoveContainer(context.Background(), helper.Id, true, false); err != nil {
+			slog.WarnContext(ctx, "removing backup helper", "container", helper.Id, "error", err)
+		}
+	}()
+
+	return fn(helper.Id)
+}
+
+// reserve Pick the id volume-20060102T150405Z (with a counter if that second is taken) and create its temp file
+func (b *Backups) reserve(volume string, created time.Time) (string, *os.File, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if err := os.MkdirAll(BackupDir, 0o700); err != nil {
+		return "", nil, err
+	}
+
+	id := volume + "-" + created.Format("20060102T150405Z")
+	for i := 2; ; i++ {
+		if _, taken := b.Catalogue.Get(id); !taken {
+			file, err := os.OpenFile(filepath.Join(BackupDir, id+".tar.gz.tmp"), os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0o600)
+			if err == nil {
+				return id, file, nil
+			}
+			if !errors.Is(err, os.ErrExist) {
+				return "", nil, err
+			}
+		}
+		id = fmt.Sprintf("%s-%s-%d", volume, created.Format("20060102T150405Z"), i)
+	}
+}
+
+type countingWriter struct {
+	written int64
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	c.written += int64(len(p))
+	return len(p), nil
+}
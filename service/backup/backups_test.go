@@ -0,0 +1,225 @@
+package backup
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/LysetsDal/docker-api/client"
+	"github.com/LysetsDal/docker-api/client/clienttest"
+	"github.com/LysetsDal/docker-api/config"
+	"github.com/LysetsDal/docker-api/types"
+	"github.com/LysetsDal/docker-api/utils"
+)
+
+// newBackups Backups with its catalogue and BackupDir in a temporary directory
+func newBackups(t *testing.T) *Backups {
+	dir := t.TempDir()
+	previous := config.BackupDir
+	config.BackupDir = filepath.Join(dir, "backups")
+	t.Cleanup(func() { config.BackupDir = previous })
+
+	catalogue, err := utils.NewJsonStore[types.VolumeBackup](filepath.Join(dir, "backups.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	schedules, err := utils.NewJsonStore[types.BackupSchedule](filepath.Join(dir, "backup-schedules.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &Backups{Catalogue: catalogue, Schedules: schedules}
+}
+
+func TestReserve(t *testing.T) {
+	b := newBackups(t)
+	created := time.Date(2026, 10, 19, 12, 0, 0, 0, time.UTC)
+
+	// Taken by the catalogue, then by a backup still being written
+	if err := b.Catalogue.Put("data-20261019T120000Z", types.VolumeBackup{Id: "data-20261019T120000Z"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.MkdirAll(config.BackupDir, 0o700); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(config.BackupDir, "data-20261019T120000Z-2.tar.gz.tmp"), nil, 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	want := []string{"data-20261019T120000Z-3", "data-20261019T120000Z-4", "other-20261019T120000Z"}
+	for i, volume := range []string{"data", "data", "other"} {
+		id, file, err := b.reserve(volume, created)
+		if err != nil {
+			t.Fatal(err)
+		}
+		_ = file.Close()
+		if id != want[i] {
+			t.Errorf("reservation %d: got %s, want %s", i, id, want[i])
+		}
+		if _, err := os.Stat(filepath.Join(config.BackupDir, id+".tar.gz.tmp")); err != nil {
+			t.Errorf("reservation %d: %s", i, err)
+		}
+	}
+}
+
+func TestPrune(t *testing.T) {
+	start := time.Date(2026, 10, 1, 0, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name   string
+		origin string
+		keep   int
+		want   []string
+	}{
+		{name: "keep two", origin: types.BackupOriginSchedule, keep: 2, want: []string{"data-3", "data-1"}},
+		{name: "keep all", origin: types.BackupOriginSchedule, keep: 10, want: []string{}},
+		{name: "keep none", origin: types.BackupOriginSchedule, keep: 0, want: []string{"data-5", "data-4", "data-3", "data-1"}},
+		{name: "a job's", origin: "job/nightly", keep: 1, want: []string{"data-6"}},
+		{name: "manual", keep: 0, want: []string{}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			b := newBackups(t)
+			if err := os.MkdirAll(config.BackupDir, 0o700); err != nil {
+				t.Fatal(err)
+			}
+
+			// data-2 is a manual backup and other-1 another volume's; neither may be pruned. data-1 was
+			// made before origins were recorded, data-6 and data-7 by jobs.
+			for i, backup := range []types.VolumeBackup{
+				{Id: "data-1", Volume: "data", Scheduled: true},
+				{Id: "data-2", Volume: "data"},
+				{Id: "data-3", Volume: "data", Scheduled: true, Origin: types.BackupOriginSchedule},
+				{Id: "data-4", Volume: "data", Scheduled: true, Origin: types.BackupOriginSchedule},
+				{Id: "data-5", Volume: "data", Scheduled: true, Origin: types.BackupOriginSchedule},
+				{Id: "data-6", Volume: "data", Scheduled: true, Origin: "job/nightly"},
+				{Id: "data-7", Volume: "data", Scheduled: true, Origin: "job/nightly"},
+				{Id: "data-8", Volume: "data", Scheduled: true, Origin: "job/hourly"},
+				{Id: "other-1", Volume: "other", Scheduled: true, Origin: types.BackupOriginSchedule},
+			} {
+				backup.Created = start.Add(time.Duration(i) * time.Hour)
+				if backup.Id == "other-1" {
+					backup.Created = start
+				}
+				backup.File = filepath.Join(config.BackupDir, backup.Id+".tar.gz")
+				if err := os.WriteFile(backup.File, []byte("x"), 0o600); err != nil {
+					t.Fatal(err)
+				}
+				if err := b.Catalogue.Put(backup.Id, backup); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			deleted, err := b.Prune("data", test.origin, test.keep)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(deleted, test.want) {
+				t.Errorf("deleted: got %v, want %v", deleted, test.want)
+			}
+			for _, id := range deleted {
+				if _, ok := b.Catalogue.Get(id); ok {
+					t.Errorf("%s is still catalogued", id)
+				}
+				if _, err := os.Stat(filepath.Join(config.BackupDir, id+".tar.gz")); !os.IsNotExist(err) {
+					t.Errorf("%s's file is still there", id)
+				}
+			}
+			for _, id := range []string{"data-2", "other-1"} {
+				if _, ok := b.Catalogue.Get(id); !ok {
+					t.Errorf("%s was pruned", id)
+				}
+			}
+		})
+	}
+}
+
+// tarball A tar of files, gzipped if compress is set
+func tarball(t *testing.T, compress bool, files ...string) []byte {
+	buffer := &bytes.Buffer{}
+	var w io.Writer = buffer
+	var compressed *gzip.Writer
+	if compress {
+		compressed = gzip.NewWriter(buffer)
+		w = compressed
+	}
+	archive := tar.NewWriter(w)
+	for _, name := range files {
+		if err := archive.WriteHeader(&tar.Header{Name: name, Mode: 0o644, Size: 2}); err != nil {
+			t.Fatal(err)
+		}
+		_, _ = archive.Write([]byte("hi"))
+	}
+	if err := archive.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if compressed != nil {
+		_ = compressed.Close()
+	}
+	return buffer.Bytes()
+}
+
+func TestRestore(t *testing.T) {
+	restored := []string{"POST /volumes/create", "POST /containers/create", "PUT /containers/helper/archive", "DELETE /containers/helper"}
+
+	tests := []struct {
+		name      string
+		archive   []byte
+		wantCalls []string
+		isErr     bool
+	}{
+		{name: "gzipped", archive: tarball(t, true, "volume/", "volume/data.txt"), wantCalls: restored},
+		{name: "plain", archive: tarball(t, false, "./volume/data.txt"), wantCalls: restored},
+		{name: "outside volume/", archive: tarball(t, true, "volume/data.txt", "data/other.txt"), isErr: true},
+		{name: "escaping volume/", archive: tarball(t, false, "volume/../etc/passwd"), isErr: true},
+		{name: "empty", archive: tarball(t, false), isErr: true},
+		{name: "not a tar", archive: []byte("not a tar"), isErr: true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var uploaded []byte
+			daemon := clienttest.NewDaemon(t, func(w http.ResponseWriter, r *http.Request) {
+				switch r.Method + " " + r.URL.Path {
+				case "POST /volumes/create":
+					_, _ = w.Write([]byte(`{"Name":"data"}`))
+				case "POST /containers/create":
+					_, _ = w.Write([]byte(`{"Id":"helper"}`))
+				case "PUT /containers/helper/archive":
+					uploaded, _ = io.ReadAll(r.Body)
+				default:
+					w.WriteHeader(http.StatusNoContent)
+				}
+			})
+			b := newBackups(t)
+			b.Docker = client.NewDockerClient(daemon.Sock())
+
+			err := b.Restore(context.Background(), "data", bytes.NewReader(test.archive), false)
+			if test.isErr {
+				if types.StatusCode(err) != http.StatusBadRequest {
+					t.Errorf("got %v, want a bad request", err)
+				}
+			} else if err != nil {
+				t.Fatal(err)
+			}
+
+			if got := daemon.Calls(); !reflect.DeepEqual(got, test.wantCalls) {
+				t.Errorf("calls: got %q, want %q", got, test.wantCalls)
+			}
+			if !test.isErr && !bytes.Equal(uploaded, test.archive) {
+				t.Errorf("the archive changed on the way to the daemon")
+			}
+			if spooled, _ := filepath.Glob(filepath.Join(config.BackupDir, "restore-*")); len(spooled) > 0 {
+				t.Errorf("the spooled upload was left behind: %v", spooled)
+			}
+		})
+	}
+}
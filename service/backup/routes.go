@@ -0,0 +1,239 @@
+package backup
+
+import (
+	"fmt"
+	. "github.com/LysetsDal/docker-api/client"
+	"github.com/LysetsDal/docker-api/service/operation"
+	. "github.com/LysetsDal/docker-api/types"
+	. "github.com/LysetsDal/docker-api/utils"
+	"github.com/gorilla/mux"
+	"io"
+	"mime"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+type Handler struct {
+	Backups    *Backups
+	Operations *operation.Operations
+}
+
+func NewHandler(sock http.Client, operations *operation.Operations) (*Handler, error) {
+	backups, err := NewBackups(NewDockerClient(sock))
+	if err != nil {
+		return nil, err
+	}
+
+	return &Handler{
+		Backups:    backups,
+		Operations: operations,
+	}, nil
+}
+
+// RegisterRoutes Volume backup and restore
+func (h *Handler) RegisterRoutes(router *mux.Router) {
+	router.Handle("/volumes/{name}/backup", h.asyncOnServer("volume.backup", MakeHttpHandleFunc(h.handleBackupVolume),
+		func(r *http.Request) bool { return r.URL.Query().Get("target") == "local" },
+		"async backups need ?target=local, a streamed archive can't be kept by an operation")).Methods(http.MethodPost)
+	router.Handle("/volumes/{name}/restore", h.asyncOnServer("volume.restore", MakeHttpHandleFunc(h.handleRestoreVolume),
+		func(r *http.Request) bool { return r.URL.Query().Get("backup") != "" },
+		"async restores need ?backup=<id>, an uploaded archive can't be kept by an operation")).Methods(http.MethodPost)
+	router.HandleFunc("/volumes/{name}/backup-schedule", MakeHttpHandleFunc(h.handlePutSchedule)).Methods(http.MethodPut)
+	router.HandleFunc("/volumes/{name}/backup-schedule", MakeHttpHandleFunc(h.handleDeleteSchedule)).Methods(http.MethodDelete)
+
+	router.HandleFunc("/backups", MakeHttpHandleFunc(h.handleListBackups)).Methods(http.MethodGet)
+	router.HandleFunc("/backup-schedules", MakeHttpHandleFunc(h.handleListSchedules)).Methods(http.MethodGet)
+	router.HandleFunc("/backups/{id}", MakeHttpHandleFunc(h.handleGetBackup)).Methods(http.MethodGet)
+	router.HandleFunc("/backups/{id}", MakeHttpHandleFunc(h.handleDeleteBackup)).Methods(http.MethodDelete)
+	router.HandleFunc("/backups/{id}/download", MakeHttpHandleFunc(h.handleDownloadBackup)).Methods(http.MethodGet)
+}
+
+// asyncOnServer Operations.Async for requests whose archive stays on the server (onServer). Operations
+// keep only a small result and a capped request body, so streamed archives are refused with an explicit
+// ?async=true, and served synchronously when the client only sent Prefer: respond-async.
+func (h *Handler) asyncOnServer(kind string, next http.Handler, onServer func(*http.Request) bool, refusal string) http.Handler {
+	async := h.Operations.Async(kind, next)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case onServer(r):
+			async.ServeHTTP(w, r)
+		case r.URL.Query().Get("async") == "true":
+			_ = WriteProblem(w, http.StatusBadRequest, refusal)
+		default:
+			next.ServeHTTP(w, r)
+		}
+	})
+}
+
+// POST Back up a volume. The gzipped tar is streamed back, or with ?target=local written to the
+// backup directory and added to the catalogue.
+func (h *Handler) handleBackupVolume(w http.ResponseWriter, r *http.Request) error {
+	volume := mux.Vars(r)["name"]
+	ctx := r.Context()
+
+	switch r.URL.Query().Get("target") {
+	case "local":
+		backup, err := h.Backups.Save(ctx, volume, "")
+		if err != nil {
+			return WriteProblem(w, StatusCode(err), fmt.Sprintf("backing up %s: %s", volume, err))
+		}
+		return WriteJson(w, http.StatusCreated, backup)
+
+	case "", "stream":
+		// Fail before the headers go out if the volume doesn't exist
+		if _, err := h.Backups.Docker.InspectVolume(ctx, volume); err != nil {
+			return WriteError(w, err)
+		}
+
+		fileName := fmt.Sprintf("%s-%s.tar.gz", volume, time.Now().UTC().Format("20060102T150405Z"))
+		w.Header().Set("Content-Type", "application/gzip")
+		w.Header().Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": fileName}))
+		w.WriteHeader(http.StatusOK)
+		return h.Backups.Stream(ctx, volume, w)
+
+	default:
+		return BadRequest("target must be stream or local")
+	}
+}
+
+// POST Restore a volume from ?backup=<id> in the catalogue, or from a gzipped tar in the request body.
+// ?clear=true empties the volume first.
+func (h *Handler) handleRestoreVolume(w http.ResponseWriter, r *http.Request) error {
+	volume := mux.Vars(r)["name"]
+	query := r.URL.Query()
+	clear := query.Get("clear") == "true"
+
+	var archive io.Reader
+	if id := query.Get("backup"); id != "" {
+		if _, ok := h.Backups.Catalogue.Get(id); !ok {
+			return WriteProblem(w, http.StatusNotFound, fmt.Sprintf("No such backup: %s", id))
+		}
+		file, _, err := h.Backups.Open(id)
+		if err != nil {
+			return WriteError(w, err)
+		}
+		defer file.Close()
+		archive = file
+	} else {
+		mediaType, err := MediaType(r)
+		if err != nil {
+			return err
+		}
+		if !ArchiveContentTypes[mediaType] {
+			return WriteProblem(w, http.StatusUnsupportedMediaType, fmt.Sprintf("unsupported Content-Type %s, send a tar", mediaType))
+		}
+		archive = r.Body
+	}
+
+	if err := h.Backups.Restore(r.Context(), volume, archive, clear); err != nil {
+		return WriteProblem(w, StatusCode(err), fmt.Sprintf("restoring %s: %s", volume, err))
+	}
+
+	return WriteJson(w, http.StatusOK, ApiMessage{Message: fmt.Sprintf("Volume %s restored", volume)})
+}
+
+// GET The backup catalogue, newest first. ?volume= limits it to one volume.
+func (h *Handler) handleListBackups(w http.ResponseWriter, r *http.Request) error {
+	return WriteJson(w, http.StatusOK, h.Backups.List(r.URL.Query().Get("volume")))
+}
+
+func (h *Handler) handleGetBackup(w http.ResponseWriter, r *http.Request) error {
+	id := mux.Vars(r)["id"]
+
+	backup, ok := h.Backups.Catalogue.Get(id)
+	if !ok {
+		return WriteProblem(w, http.StatusNotFound, fmt.Sprintf("No such backup: %s", id))
+	}
+
+	return WriteJson(w, http.StatusOK, backup)
+}
+
+// GET Download a catalogued backup, after checking its checksum
+func (h *Handler) handleDownloadBackup(w http.ResponseWriter, r *http.Request) error {
+	id := mux.Vars(r)["id"]
+	if _, ok := h.Backups.Catalogue.Get(id); !ok {
+		return WriteProblem(w, http.StatusNotFound, fmt.Sprintf("No such backup: %s", id))
+	}
+
+	file, backup, err := h.Backups.Open(id)
+	if err != nil {
+		return WriteError(w, err)
+	}
+	defer file.Close()
+
+	w.Header().Set("Content-Type", "application/gzip")
+	w.Header().Set("Content-Length", strconv.FormatInt(backup.Size, 10))
+	w.Header().Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": backup.Id + ".tar.gz"}))
+	w.WriteHeader(http.StatusOK)
+	_, err = io.Copy(w, file)
+	return err
+}
+
+func (h *Handler) handleDeleteBackup(w http.ResponseWriter, r *http.Request) error {
+	id := mux.Vars(r)["id"]
+
+	deleted, err := h.Backups.Delete(id)
+	if err != nil {
+		return WriteError(w, err)
+	}
+	if !deleted {
+		return WriteProblem(w, http.StatusNotFound, fmt.Sprintf("No such backup: %s", id))
+	}
+
+	return WriteJson(w, http.StatusOK, ApiMessage{Message: fmt.Sprintf("Backup %s deleted", id)})
+}
+
+// GET Every backup schedule with the outcome of its last run
+func (h *Handler) handleListSchedules(w http.ResponseWriter, _ *http.Request) error {
+	return WriteJson(w, http.StatusOK, h.Backups.Schedules.List())
+}
+
+// PUT Back up the volume to the backup directory on a schedule: {"Every": "6h", "Keep": 7}
+func (h *Handler) handlePutSchedule(w http.ResponseWriter, r *http.Request) error {
+	volume := mux.Vars(r)["name"]
+
+	schedule := BackupSchedule{}
+	if err := ParseJson(r, &schedule); err != nil {
+		return BadRequest("invalid backup schedule: %w", err)
+	}
+
+	every, err := time.ParseDuration(schedule.Every)
+	switch {
+	case err != nil:
+		return BadRequest("every must be a duration such as 6h: %w", err)
+	case every < scheduleCheckInterval:
+		return BadRequest("every must be at least %s", scheduleCheckInterval)
+	case schedule.Keep < 1:
+		return BadRequest("keep must be at least 1")
+	}
+
+	if _, err := h.Backups.Docker.InspectVolume(r.Context(), volume); err != nil {
+		return WriteError(w, err)
+	}
+
+	schedule = BackupSchedule{Volume: volume, Every: schedule.Every, Keep: schedule.Keep}
+	if previous, ok := h.Backups.Schedules.Get(volume); ok {
+		schedule.LastRun, schedule.LastBackup, schedule.LastError = previous.LastRun, previous.LastBackup, previous.LastError
+	}
+	if err := h.Backups.Schedules.Put(volume, schedule); err != nil {
+		return WriteError(w, err)
+	}
+
+	return WriteJson(w, http.StatusOK, schedule)
+}
+
+// DELETE Stop scheduled backups of the volume. Existing backups are kept.
+func (h *Handler) handleDeleteSchedule(w http.ResponseWriter, r *http.Request) error {
+	volume := mux.Vars(r)["name"]
+
+	deleted, err := h.Backups.Schedules.Delete(volume)
+	if err != nil {
+		return WriteError(w, err)
+	}
+	if !deleted {
+		return WriteProblem(w, http.StatusNotFound, fmt.Sprintf("No backup schedule for volume: %s", volume))
+	}
+
+	return WriteJson(w, http.StatusOK, ApiMessage{Message: fmt.Sprintf("Backup schedule for %s deleted", volume)})
+}
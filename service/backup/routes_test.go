@@ -0,0 +1,78 @@
+package backup
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/LysetsDal/docker-api/client"
+	"github.com/LysetsDal/docker-api/client/clienttest"
+	"github.com/LysetsDal/docker-api/service/operation"
+	"github.com/LysetsDal/docker-api/types"
+	"github.com/gorilla/mux"
+)
+
+// waitForOperations Wait for the started operations to finish, they use the test's backup directory
+func waitForOperations(t *testing.T, operations *operation.Operations) {
+	deadline := time.Now().Add(5 * time.Second)
+	for _, op := range operations.List() {
+		for op.Status == types.OperationRunning {
+			if time.Now().After(deadline) {
+				t.Fatalf("operation %s is still running", op.Id)
+			}
+			time.Sleep(10 * time.Millisecond)
+			op, _ = operations.Get(op.Id)
+		}
+	}
+}
+
+func TestAsyncOnlyForArchivesOnTheServer(t *testing.T) {
+	tests := []struct {
+		name       string
+		target     string
+		prefer     bool
+		body       string
+		wantStatus int
+	}{
+		{name: "streamed backup", target: "/volumes/data/backup?async=true", wantStatus: http.StatusBadRequest},
+		{name: "streamed backup preferring async is served right away", target: "/volumes/data/backup", prefer: true, wantStatus: http.StatusNotFound},
+		{name: "local backup", target: "/volumes/data/backup?async=true&target=local", wantStatus: http.StatusAccepted},
+		{name: "restore from an upload", target: "/volumes/data/restore?async=true", body: "tar", wantStatus: http.StatusBadRequest},
+		{name: "restore from the catalogue", target: "/volumes/data/restore?async=true&backup=data-1", wantStatus: http.StatusAccepted},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			// A daemon without volumes
+			daemon := clienttest.NewDaemon(t, func(w http.ResponseWriter, r *http.Request) {
+				http.Error(w, `{"message":"no such volume"}`, http.StatusNotFound)
+			})
+
+			b := newBackups(t)
+			b.Docker = client.NewDockerClient(daemon.Sock())
+			operations, err := operation.NewOperations()
+			if err != nil {
+				t.Fatal(err)
+			}
+			router := mux.NewRouter()
+			(&Handler{Backups: b, Operations: operations}).RegisterRoutes(router)
+
+			request := httptest.NewRequest(http.MethodPost, test.target, strings.NewReader(test.body))
+			if test.prefer {
+				request.Header.Set("Prefer", "respond-async")
+			}
+			recorder := httptest.NewRecorder()
+			router.ServeHTTP(recorder, request)
+
+			if recorder.Code != test.wantStatus {
+				t.Errorf("status %d, want %d: %s", recorder.Code, test.wantStatus, recorder.Body)
+			}
+			if async := len(operations.List()) > 0; async != (test.wantStatus == http.StatusAccepted) {
+				t.Errorf("operation started: %v", async)
+			}
+			waitForOperations(t, operations)
+		})
+	}
+}
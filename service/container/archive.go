@@ -0,0 +1,204 @@
+package container
+
+import (
+	"archive/tar"
+	"errors"
+	"fmt"
+	. "github.com/LysetsDal/docker-api/types"
+	. "github.com/LysetsDal/docker-api/utils"
+	"github.com/gorilla/mux"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"path"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// maxUploadMemory Multipart files above this are buffered in temp files instead of memory
+const maxUploadMemory = 32 << 20
+
+// HEAD Stat a path inside the container.
+// The decoded X-Docker-Container-Path-Stat header is returned as X-Container-Path-* headers.
+func (h *Handler) handleStatContainerPath(w http.ResponseWriter, r *http.Request) error {
+	containerPath := r.URL.Query().Get("path")
+	if containerPath == "" {
+		return BadRequest("path is required")
+	}
+
+	stat, err := h.Docker.StatContainerPath(r.Context(), mux.Vars(r)["id"], containerPath)
+	if err != nil {
+		return WriteError(w, err)
+	}
+
+	header := w.Header()
+	header.Set("X-Container-Path-Name", stat.Name)
+	header.Set("X-Container-Path-Size", strconv.FormatInt(stat.Size, 10))
+	header.Set("X-Container-Path-Mode", stat.Mode.String())
+	header.Set("X-Container-Path-Mtime", stat.Mtime.Format(time.RFC3339))
+	if stat.LinkTarget != "" {
+		header.Set("X-Container-Path-Link-Target", stat.LinkTarget)
+	}
+
+	return WriteJson(w, http.StatusOK, stat)
+}
+
+// GET Download a path from the container.
+// ?path=/var/crash is streamed as a tar, ?format=file sends a single regular file as it is.
+func (h *Handler) handleGetArchive(w http.ResponseWriter, r *http.Request) error {
+	query := r.URL.Query()
+	containerPath := query.Get("path")
+	if containerPath == "" {
+		return BadRequest("path is required")
+	}
+	format := query.Get("format")
+	if format != "" && format != "tar" && format != "file" {
+		return BadRequest("format must be tar or file")
+	}
+
+	archive, stat, err := h.Docker.GetArchive(r.Context(), mux.Vars(r)["id"], containerPath)
+	if err != nil {
+		return WriteError(w, err)
+	}
+	defer archive.Close()
+
+	if format != "file" {
+		w.Header().Set("Content-Type", "application/x-tar")
+		w.Header().Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": stat.Name + ".tar"}))
+		w.WriteHeader(http.StatusOK)
+		_, err := io.Copy(w, archive)
+		return err
+	}
+
+	if stat.Mode.IsDir() {
+		return BadRequest("%s is a directory, download it as a tar", containerPath)
+	}
+
+	reader := tar.NewReader(archive)
+	for {
+		entry, err := reader.Next()
+		if errors.Is(err, io.EOF) {
+			return BadRequest("%s is not a regular file", containerPath)
+		}
+		if err != nil {
+			return WriteError(w, err)
+		}
+		if entry.Typeflag != tar.TypeReg {
+			continue
+		}
+
+		w.Header().Set("Content-Type", "application/octet-stream")
+		w.Header().Set("Content-Length", strconv.FormatInt(entry.Size, 10))
+		w.Header().Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": path.Base(entry.Name)}))
+		w.WriteHeader(http.StatusOK)
+		_, err = io.Copy(w, reader)
+		return err
+	}
+}
+
+// PUT Upload into a directory of the container.
+// The body is a tar (optionally compressed), or multipart/form-data whose files are packed into one.
+// ?noOverwriteDirNonDir=true refuses to replace a directory with a file or the other way round.
+func (h *Handler) handlePutArchive(w http.ResponseWriter, r *http.Request) error {
+	query := r.URL.Query()
+	containerPath := query.Get("path")
+	if containerPath == "" {
+		return BadRequest("path is required")
+	}
+	noOverwriteDirNonDir := query.Get("noOverwriteDirNonDir") == "true"
+	copyUIDGID := query.Get("copyUIDGID") == "true"
+
+	mediaType, err := MediaType(r)
+	if err != nil {
+		return err
+	}
+
+	response := ArchiveUploadResponse{Path: containerPath}
+	var archive io.Reader
+	switch {
+	case mediaType == "multipart/form-data":
+		if err := r.ParseMultipartForm(maxUploadMemory); err != nil {
+			return BadRequest("invalid multipart upload: %w", err)
+		}
+		defer r.MultipartForm.RemoveAll()
+
+		files := multipartFiles(r)
+		if len(files) == 0 {
+			return BadRequest("no files in upload")
+		}
+		for _, file := range files {
+			response.Files = append(response.Files, file.Filename)
+		}
+		pipe := tarFiles(files)
+		defer pipe.Close()
+		archive = pipe
+
+	case ArchiveContentTypes[mediaType]:
+		archive = r.Body
+
+	default:
+		return WriteProblem(w, http.StatusUnsupportedMediaType, fmt.Sprintf("unsupported Content-Type %s, send a tar or multipart/form-data", mediaType))
+	}
+
+	if err := h.Docker.PutArchive(r.Context(), mux.Vars(r)["id"], containerPath, noOverwriteDirNonDir, copyUIDGID, archive); err != nil {
+		return WriteError(w, err)
+	}
+
+	return WriteJson(w, http.StatusOK, response)
+}
+
+// multipartFiles Every uploaded file, ordered by form field then upload order
+func multipartFiles(r *http.Request) []*multipart.FileHeader {
+	fields := make([]string, 0, len(r.MultipartForm.File))
+	for field := range r.MultipartForm.File {
+		fields = append(fields, field)
+	}
+	sort.Strings(fields)
+
+	files := make([]*multipart.FileHeader, 0)
+	for _, field := range fields {
+		files = append(files, r.MultipartForm.File[field]...)
+	}
+	return files
+}
+
+// tarFiles Stream the files as a tar, written as it is read
+func tarFiles(files []*multipart.FileHeader) *io.PipeReader {
+	reader, writer := io.Pipe()
+
+	go func() {
+		archive := tar.NewWriter(writer)
+		for _, file := range files {
+			if err := writeTarFile(archive, file); err != nil {
+				writer.CloseWithError(err)
+				return
+			}
+		}
+		writer.CloseWithError(archive.Close())
+	}()
+
+	return reader
+}
+
+func writeTarFile(archive *tar.Writer, file *multipart.FileHeader) error {
+	content, err := file.Open()
+	if err != nil {
+		return err
+	}
+	defer content.Close()
+
+	header := &tar.Header{
+		Typeflag: tar.TypeReg,
+		Name:     file.Filename,
+		Size:     file.Size,
+		Mode:     0o644,
+		ModTime:  time.Now(),
+	}
+	if err := archive.WriteHeader(header); err != nil {
+		return err
+	}
+	_, err = io.Copy(archive, content)
+	return err
+}
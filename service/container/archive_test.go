@@ -0,0 +1,199 @@
+package container
+
+import (
+	"archive/tar"
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/LysetsDal/docker-api/client"
+	"github.com/LysetsDal/docker-api/client/clienttest"
+	"github.com/LysetsDal/docker-api/types"
+	"github.com/LysetsDal/docker-api/utils"
+	"github.com/gorilla/mux"
+)
+
+// pathStat Encode stat the way the daemon sends it
+func pathStat(t *testing.T, stat types.PathStat) string {
+	t.Helper()
+	raw, err := json.Marshal(stat)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return base64.StdEncoding.EncodeToString(raw)
+}
+
+// tarEntries The names and contents of a tar's regular files
+func tarEntries(t *testing.T, archive io.Reader) map[string]string {
+	t.Helper()
+	entries := map[string]string{}
+	reader := tar.NewReader(archive)
+	for {
+		entry, err := reader.Next()
+		if errors.Is(err, io.EOF) {
+			return entries
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		content, err := io.ReadAll(reader)
+		if err != nil {
+			t.Fatal(err)
+		}
+		entries[entry.Name] = string(content)
+	}
+}
+
+func TestGetArchive(t *testing.T) {
+	archive := &bytes.Buffer{}
+	writer := tar.NewWriter(archive)
+	_ = writer.WriteHeader(&tar.Header{Typeflag: tar.TypeDir, Name: "crash/", Mode: 0o755})
+	_ = writer.WriteHeader(&tar.Header{Typeflag: tar.TypeReg, Name: "crash/core.txt", Size: 4, Mode: 0o644})
+	_, _ = writer.Write([]byte("dump"))
+	_ = writer.Close()
+
+	tests := []struct {
+		name         string
+		query        string
+		stat         types.PathStat
+		wantStatus   int
+		wantType     string
+		wantBody     string
+		wantFileName string
+	}{
+		{
+			name:         "tar",
+			query:        "?path=/var/crash",
+			stat:         types.PathStat{Name: "crash", Mode: os.ModeDir | 0o755},
+			wantStatus:   http.StatusOK,
+			wantType:     "application/x-tar",
+			wantBody:     archive.String(),
+			wantFileName: "crash.tar",
+		},
+		{
+			name:         "file",
+			query:        "?path=/var/crash/core.txt&format=file",
+			stat:         types.PathStat{Name: "core.txt", Size: 4, Mode: 0o644},
+			wantStatus:   http.StatusOK,
+			wantType:     "application/octet-stream",
+			wantBody:     "dump",
+			wantFileName: "core.txt",
+		},
+		{name: "directory as a file", query: "?path=/var/crash&format=file", stat: types.PathStat{Name: "crash", Mode: os.ModeDir}, wantStatus: http.StatusBadRequest},
+		{name: "unknown format", query: "?path=/var/crash&format=zip", wantStatus: http.StatusBadRequest},
+		{name: "no path", wantStatus: http.StatusBadRequest},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			daemon := clienttest.NewDaemon(t, func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set(client.PathStatHeader, pathStat(t, test.stat))
+				_, _ = w.Write(archive.Bytes())
+			})
+			h := &Handler{Docker: client.NewDockerClient(daemon.Sock())}
+
+			request := mux.SetURLVars(httptest.NewRequest(http.MethodGet, "/containers/web/archive"+test.query, nil), map[string]string{"id": "web"})
+			recorder := httptest.NewRecorder()
+			utils.MakeHttpHandleFunc(h.handleGetArchive)(recorder, request)
+
+			if recorder.Code != test.wantStatus {
+				t.Fatalf("status %d: %s", recorder.Code, recorder.Body)
+			}
+			if test.wantStatus != http.StatusOK {
+				return
+			}
+			if contentType := recorder.Header().Get("Content-Type"); contentType != test.wantType {
+				t.Errorf("Content-Type: %q", contentType)
+			}
+			if recorder.Body.String() != test.wantBody {
+				t.Errorf("body: got %q, want %q", recorder.Body, test.wantBody)
+			}
+			if disposition := recorder.Header().Get("Content-Disposition"); disposition != "attachment; filename="+test.wantFileName {
+				t.Errorf("Content-Disposition: %q", disposition)
+			}
+		})
+	}
+}
+
+func TestPutArchive(t *testing.T) {
+	var uploaded []byte
+	var query string
+	daemon := clienttest.NewDaemon(t, func(w http.ResponseWriter, r *http.Request) {
+		query = r.URL.RawQuery
+		uploaded, _ = io.ReadAll(r.Body)
+	})
+	h := &Handler{Docker: client.NewDockerClient(daemon.Sock())}
+
+	put := func(contentType string, body io.Reader) *httptest.ResponseRecorder {
+		request := httptest.NewRequest(http.MethodPut, "/containers/web/archive?path=/etc/nginx&noOverwriteDirNonDir=true", body)
+		request.Header.Set("Content-Type", contentType)
+		recorder := httptest.NewRecorder()
+		utils.MakeHttpHandleFunc(h.handlePutArchive)(recorder, mux.SetURLVars(request, map[string]string{"id": "web"}))
+		return recorder
+	}
+
+	form := &bytes.Buffer{}
+	writer := multipart.NewWriter(form)
+	for field, file := range map[string][2]string{"b": {"nginx.conf", "events {}"}, "a": {"mime.types", "types {}"}} {
+		part, _ := writer.CreateFormFile(field, file[0])
+		_, _ = part.Write([]byte(file[1]))
+	}
+	_ = writer.Close()
+
+	recorder := put(writer.FormDataContentType(), form)
+	if recorder.Code != http.StatusOK {
+		t.Fatalf("status %d: %s", recorder.Code, recorder.Body)
+	}
+	response := types.ArchiveUploadResponse{}
+	_ = json.Unmarshal(recorder.Body.Bytes(), &response)
+	if want := []string{"mime.types", "nginx.conf"}; response.Path != "/etc/nginx" || !reflect.DeepEqual(response.Files, want) {
+		t.Errorf("response: %+v", response)
+	}
+	if want := map[string]string{"nginx.conf": "events {}", "mime.types": "types {}"}; !reflect.DeepEqual(tarEntries(t, bytes.NewReader(uploaded)), want) {
+		t.Errorf("uploaded %q, want %v", uploaded, want)
+	}
+	if query != "copyUIDGID=false&noOverwriteDirNonDir=true&path=%2Fetc%2Fnginx" {
+		t.Errorf("query: %s", query)
+	}
+
+	if recorder := put("application/json", bytes.NewReader([]byte("{}"))); recorder.Code != http.StatusUnsupportedMediaType {
+		t.Errorf("json body: status %d", recorder.Code)
+	}
+	if recorder := put("multipart/form-data; boundary=x", bytes.NewReader([]byte("--x--\r\n"))); recorder.Code != http.StatusBadRequest {
+		t.Errorf("no files: status %d", recorder.Code)
+	}
+}
+
+func TestStatContainerPath(t *testing.T) {
+	mtime := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	daemon := clienttest.NewDaemon(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set(client.PathStatHeader, pathStat(t, types.PathStat{Name: "nginx", Size: 12, Mode: os.ModeSymlink | 0o777, Mtime: mtime, LinkTarget: "/usr/sbin/nginx"}))
+	})
+	h := &Handler{Docker: client.NewDockerClient(daemon.Sock())}
+
+	request := mux.SetURLVars(httptest.NewRequest(http.MethodHead, "/containers/web/archive?path=/usr/bin/nginx", nil), map[string]string{"id": "web"})
+	recorder := httptest.NewRecorder()
+	utils.MakeHttpHandleFunc(h.handleStatContainerPath)(recorder, request)
+
+	want := map[string]string{
+		"X-Container-Path-Name":        "nginx",
+		"X-Container-Path-Size":        "12",
+		"X-Container-Path-Mode":        "Lrwxrwxrwx",
+		"X-Container-Path-Mtime":       "2024-05-01T12:00:00Z",
+		"X-Container-Path-Link-Target": "/usr/sbin/nginx",
+	}
+	for header, value := range want {
+		if got := recorder.Header().Get(header); got != value {
+			t.Errorf("%s: got %q, want %q", header, got, value)
+		}
+	}
+}
@@ -0,0 +1,244 @@
+package container
+
+import (
+	"context"
+	"fmt"
+	. "github.com/LysetsDal/docker-api/types"
+	. "github.com/LysetsDal/docker-api/utils"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// defaultParallelism How many containers a bulk action works on at once when the request doesn't say
+const defaultParallelism = 4
+
+// defaultDeadline How long a bulk action may take when the request doesn't say
+const defaultDeadline = 2 * time.Minute
+
+// POST Apply one action to a selection of containers concurrently:
+// {"Action": "restart", "Selector": {"Labels": ["app=web"], "Filter": {"Status": ["running"]}}, "Parallelism": 4, "OnError": "fail-fast"}
+// Ids and names that match no selected container, and id prefixes that match several, are reported as failed.
+func (h *Handler) handleBulk(w http.ResponseWriter, r *http.Request) error {
+	request := BulkRequest{}
+	if err := ParseJsonStrict(r, &request); err != nil {
+		return BadRequest("invalid bulk request: %w", err)
+	}
+	if err := ValidateBulkRequest(request); err != nil {
+		return WriteValidationError(w, "invalid bulk request", err)
+	}
+
+	selector := request.Selector
+	filters := map[string][]string{}
+	if len(selector.Labels) > 0 {
+		filters["label"] = selector.Labels
+	}
+	if selector.Filter != nil && len(selector.Filter.Status) > 0 {
+		filters["status"] = selector.Filter.Status
+	}
+	containers, err := h.Docker.ListContainers(r.Context(), true, filters)
+	if err != nil {
+		return WriteError(w, err)
+	}
+
+	refs := append(append([]string{}, selector.Ids...), selector.Names...)
+	unresolved := make([]ContainerResult, 0)
+	if len(refs) > 0 {
+		chosen := map[string]bool{}
+		for _, ref := range refs {
+			switch matched := matchRef(containers, ref); len(matched) {
+			case 0:
+				unresolved = append(unresolved, ContainerResult{Name: ref, Status: BulkFailed, Error: fmt.Sprintf("No such container matching the selector: %s", ref)})
+			case 1:
+				chosen[matched[0].Id] = true
+			default:
+				unresolved = append(unresolved, ContainerResult{Name: ref, Status: BulkFailed, Error: ambiguousRef(ref, matched).Error()})
+			}
+		}
+
+		selected := make([]Container, 0, len(chosen))
+		for _, container := range containers {
+			if chosen[container.Id] {
+				selected = append(selected, container)
+			}
+		}
+		containers = selected
+	}
+
+	deadline := defaultDeadline
+	if request.Deadline != "" {
+		deadline, _ = time.ParseDuration(request.Deadline)
+	}
+	parallelism := request.Parallelism
+	if parallelism == 0 {
+		parallelism = defaultParallelism
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), deadline)
+	defer cancel()
+	report := runBulk(ctx, request.Action, containers, parallelism, request.OnError == "fail-fast", h.bulkAction(request))
+
+	report.Results = append(report.Results, unresolved...)
+	report.Total += len(unresolved)
+	report.Failed += len(unresolved)
+
+	return WriteJson(w, bulkStatus(report), report)
+}
+
+// bulkAction The client call for the request's action
+func (h *Handler) bulkAction(request BulkRequest) func(ctx context.Context, id string) error {
+	params := StopParams{Signal: request.Signal, T: request.T}
+
+	switch request.Action {
+	case BulkStart:
+		return h.Docker.StartContainer
+	case BulkStop:
+		return func(ctx context.Context, id string) error { return h.Docker.StopContainer(ctx, id, params) }
+	case BulkRestart:
+		return func(ctx context.Context, id string) error { return h.Docker.RestartContainer(ctx, id, params) }
+	case BulkKill:
+		return func(ctx context.Context, id string) error { return h.Docker.KillContainer(ctx, id, request.Signal) }
+	case BulkPause:
+		return h.Docker.PauseContainer
+	case BulkUnpause:
+		return h.Docker.UnpauseContainer
+	default:
+		return func(ctx context.Context, id string) error {
+			return h.Docker.RemoveContainer(ctx, id, request.Force, request.Volumes)
+		}
+	}
+}
+
+// runBulk Apply action to every container with at most parallelism running at once. Containers
+// not started before ctx is done, or after a failure with failFast, are skipped.
+func runBulk(ctx context.Context, name string, containers []Container, parallelism int, failFast bool, action func(ctx context.Context, id string) error) BulkReport {
+	started := time.Now()
+	report := BulkReport{Action: name, Total: len(containers), Results: make([]ContainerResult, len(containers))}
+
+	// Cancelled on the first failure with failFast. Actions in flight keep ctx and finish.
+	dispatch, stop := context.WithCancelCause(ctx)
+	defer stop(nil)
+
+	queue := make(chan int)
+	var wg sync.WaitGroup
+	for worker := 0; worker < parallelism; worker++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range queue {
+				report.Results[i] = runOne(ctx, dispatch, containers[i], action)
+				if failFast && report.Results[i].Status == BulkFailed {
+					stop(fmt.Errorf("skipped after %s failed", report.Results[i].Name))
+				}
+			}
+		}()
+	}
+
+	for i, container := range containers {
+		select {
+		case queue <- i:
+		case <-dispatch.Done():
+			report.Results[i] = skipped(container, dispatch)
+		}
+	}
+	close(queue)
+	wg.Wait()
+
+	for _, result := range report.Results {
+		switch result.Status {
+		case BulkSucceeded:
+			report.Succeeded++
+		case BulkFailed:
+			report.Failed++
+		case BulkSkipped:
+			report.Skipped++
+		}
+	}
+	report.DurationMs = time.Since(started).Milliseconds()
+	return report
+}
+
+func runOne(ctx, dispatch context.Context, container Container, action func(ctx context.Context, id string) error) ContainerResult {
+	// The pool may pick the container up just as dispatching stops
+	if dispatch.Err() != nil {
+		return skipped(container, dispatch)
+	}
+
+	result := ContainerResult{Id: container.Id, Name: container.Name(), Status: BulkSucceeded}
+	started := time.Now()
+	if err := action(ctx, container.Id); err != nil {
+		result.Status, result.Error = BulkFailed, err.Error()
+	}
+	result.DurationMs = time.Since(started).Milliseconds()
+	return result
+}
+
+func skipped(container Container, dispatch context.Context) ContainerResult {
+	return ContainerResult{Id: container.Id, Name: container.Name(), Status: BulkSkipped, Error: context.Cause(dispatch).Error()}
+}
+
+// bulkStatus 200 when every container succeeded, 207 Multi-Status otherwise
+func bulkStatus(report BulkReport) int {
+	if report.Failed > 0 || report.Skipped > 0 {
+		return http.StatusMultiStatus
+	}
+	return http.StatusOK
+}
+
+// excludeContainers Drop the containers exclude names, each by its full id, its name or an id prefix.
+// A prefix matching several containers is refused rather than guessed at.
+func excludeContainers(containers []Container, exclude []string) ([]Container, error) {
+	if len(exclude) == 0 {
+		return containers, nil
+	}
+
+	excluded := map[string]bool{}
+	for _, ref := range exclude {
+		matched := matchRef(containers, ref)
+		if len(matched) > 1 {
+			return nil, BadRequest("invalid Exclude: %w", ambiguousRef(ref, matched))
+		}
+		for _, container := range matched {
+			excluded[container.Id] = true
+		}
+	}
+
+	kept := make([]Container, 0, len(containers))
+	for _, container := range containers {
+		if !excluded[container.Id] {
+			kept = append(kept, container)
+		}
+	}
+	return kept, nil
+}
+
+// matchRef The containers ref stands for: the one with that full id or name, like the daemon resolves
+// them, otherwise every container whose id starts with ref
+func matchRef(containers []Container, ref string) []Container {
+	ref = strings.TrimPrefix(ref, "/")
+	prefixed := make([]Container, 0)
+	for _, container := range containers {
+		if container.Id == ref {
+			return []Container{container}
+		}
+		for _, name := range container.Names {
+			if strings.TrimPrefix(name, "/") == ref {
+				return []Container{container}
+			}
+		}
+		if ref != "" && strings.HasPrefix(container.Id, ref) {
+			prefixed = append(prefixed, container)
+		}
+	}
+	return prefixed
+}
+
+// ambiguousRef The error for an id prefix that matches several containers
+func ambiguousRef(ref string, matched []Container) error {
+	names := make([]string, 0, len(matched))
+	for _, container := range matched {
+		names = append(names, container.Name())
+	}
+	return fmt.Errorf("%s matches %d containers (%s), give more of the id", ref, len(matched), strings.Join(names, ", "))
+}
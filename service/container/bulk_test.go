@@ -0,0 +1,151 @@
+package container
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/LysetsDal/docker-api/client"
+	"github.com/LysetsDal/docker-api/client/clienttest"
+	"github.com/LysetsDal/docker-api/types"
+	"github.com/LysetsDal/docker-api/utils"
+)
+
+// bulkContainers Two containers whose ids share the prefix "4f2a"
+const bulkContainers = `[
+	{"Id":"4f2a9c1be8d07a3e","Names":["/web"]},
+	{"Id":"4f2ab7701c2d9e4f","Names":["/worker"]},
+	{"Id":"9be10c4d5e6f7a8b","Names":["/db"]}
+]`
+
+func TestMatchRef(t *testing.T) {
+	containers := []types.Container{}
+	if err := json.Unmarshal([]byte(bulkContainers), &containers); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		ref  string
+		want []string
+	}{
+		{ref: "4f2a9c1be8d07a3e", want: []string{"web"}},
+		{ref: "4f2a9", want: []string{"web"}},
+		{ref: "9b", want: []string{"db"}},
+		{ref: "4f2a", want: []string{"web", "worker"}},
+		{ref: "/worker", want: []string{"worker"}},
+		{ref: "w", want: []string{}},
+		{ref: "", want: []string{}},
+	}
+
+	for _, test := range tests {
+		got := []string{}
+		for _, container := range matchRef(containers, test.ref) {
+			got = append(got, container.Name())
+		}
+		if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%q: got %q, want %q", test.ref, got, test.want)
+		}
+	}
+}
+
+func TestHandleBulkRefs(t *testing.T) {
+	daemon := clienttest.NewDaemon(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/containers/json" {
+			_, _ = w.Write([]byte(bulkContainers))
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	})
+	h := &Handler{Docker: client.NewDockerClient(daemon.Sock())}
+
+	body := `{"Action":"restart","Selector":{"Ids":["9b","4f2a","4f2ab","0000"],"Names":["web"]}}`
+	recorder := httptest.NewRecorder()
+	utils.MakeHttpHandleFunc(h.handleBulk)(recorder, httptest.NewRequest(http.MethodPost, "/containers/bulk", strings.NewReader(body)))
+
+	if recorder.Code != http.StatusMultiStatus {
+		t.Fatalf("status %d: %s", recorder.Code, recorder.Body)
+	}
+	report := types.BulkReport{}
+	if err := json.Unmarshal(recorder.Body.Bytes(), &report); err != nil {
+		t.Fatal(err)
+	}
+
+	results := map[string]types.ContainerResult{}
+	for _, result := range report.Results {
+		results[result.Name] = result
+	}
+	for _, name := range []string{"web", "worker", "db"} {
+		if results[name].Status != types.BulkSucceeded {
+			t.Errorf("%s: %+v", name, results[name])
+		}
+	}
+	if result := results["4f2a"]; result.Status != types.BulkFailed || !strings.Contains(result.Error, "matches 2 containers") {
+		t.Errorf("ambiguous prefix: %+v", result)
+	}
+	if result := results["0000"]; result.Status != types.BulkFailed {
+		t.Errorf("unknown id: %+v", result)
+	}
+	if report.Total != 5 || report.Succeeded != 3 || report.Failed != 2 {
+		t.Errorf("report: %+v", report)
+	}
+
+	restarted := []string{}
+	for _, call := range daemon.Calls() {
+		if strings.HasSuffix(call, "/restart") {
+			restarted = append(restarted, call)
+		}
+	}
+	sort.Strings(restarted)
+	want := []string{"POST /containers/4f2a9c1be8d07a3e/restart", "POST /containers/4f2ab7701c2d9e4f/restart", "POST /containers/9be10c4d5e6f7a8b/restart"}
+	if !reflect.DeepEqual(restarted, want) {
+		t.Errorf("got %q, want %q", restarted, want)
+	}
+}
+
+func TestHandleStopAllExclude(t *testing.T) {
+	daemon := clienttest.NewDaemon(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/containers/json" {
+			_, _ = w.Write([]byte(bulkContainers))
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	})
+	h := &Handler{Docker: client.NewDockerClient(daemon.Sock())}
+
+	tests := []struct {
+		name        string
+		exclude     string
+		wantStatus  int
+		wantStopped int
+	}{
+		{name: "short prefix", exclude: `["4f2a9"]`, wantStatus: http.StatusOK, wantStopped: 2},
+		{name: "name and prefix", exclude: `["web","9b"]`, wantStatus: http.StatusOK, wantStopped: 1},
+		{name: "ambiguous prefix", exclude: `["4f2a"]`, wantStatus: http.StatusBadRequest},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			request := httptest.NewRequest(http.MethodPost, "/containers/stopall", strings.NewReader(`{"Exclude":`+test.exclude+`}`))
+			recorder := httptest.NewRecorder()
+			utils.MakeHttpHandleFunc(h.handleStopAllContainers)(recorder, request)
+
+			if recorder.Code != test.wantStatus {
+				t.Fatalf("status %d: %s", recorder.Code, recorder.Body)
+			}
+			if test.wantStatus != http.StatusOK {
+				return
+			}
+			report := types.BulkReport{}
+			if err := json.Unmarshal(recorder.Body.Bytes(), &report); err != nil {
+				t.Fatal(err)
+			}
+			if report.Total != test.wantStopped || report.Succeeded != test.wantStopped {
+				t.Errorf("report: %+v", report)
+			}
+		})
+	}
+}
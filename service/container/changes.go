@@ -0,0 +1,96 @@
+package container
+
+import (
+	"fmt"
+	. "github.com/LysetsDal/docker-api/types"
+	. "github.com/LysetsDal/docker-api/utils"
+	"github.com/gorilla/mux"
+	"io"
+	"mime"
+	"net/http"
+	"sort"
+	"strings"
+)
+
+// GET Filesystem changes since the container was created, with a summary per top-level directory.
+// ?kind=added,deleted limits the result to those kinds.
+func (h *Handler) handleGetContainerChanges(w http.ResponseWriter, r *http.Request) error {
+	kinds := map[string]bool{}
+	if filter := r.URL.Query().Get("kind"); filter != "" {
+		for _, kind := range strings.Split(filter, ",") {
+			kind = strings.ToLower(strings.TrimSpace(kind))
+			if kind != "added" && kind != "modified" && kind != "deleted" {
+				return BadRequest("invalid kind %q, use added, modified or deleted", kind)
+			}
+			kinds[kind] = true
+		}
+	}
+
+	changes, err := h.Docker.ContainerChanges(r.Context(), mux.Vars(r)["id"])
+	if err != nil {
+		return WriteError(w, err)
+	}
+
+	response := ContainerChangesResponse{
+		Changes: make([]FilesystemChange, 0, len(changes)),
+		Summary: make([]ChangeSummary, 0),
+	}
+	summaries := map[string]*ChangeSummary{}
+	for _, change := range changes {
+		kind, ok := ChangeKinds[change.Kind]
+		if !ok {
+			kind = fmt.Sprintf("Unknown(%d)", change.Kind)
+		}
+		if len(kinds) > 0 && !kinds[strings.ToLower(kind)] {
+			continue
+		}
+		response.Changes = append(response.Changes, FilesystemChange{Path: change.Path, Kind: kind})
+
+		directory := topLevelDirectory(change.Path)
+		summary, ok := summaries[directory]
+		if !ok {
+			summary = &ChangeSummary{Directory: directory}
+			summaries[directory] = summary
+		}
+		switch change.Kind {
+		case ChangeAdded:
+			summary.Added++
+		case ChangeModified:
+			summary.Modified++
+		case ChangeDeleted:
+			summary.Deleted++
+		}
+	}
+
+	for _, summary := range summaries {
+		response.Summary = append(response.Summary, *summary)
+	}
+	sort.Slice(response.Summary, func(i, j int) bool {
+		return response.Summary[i].Directory < response.Summary[j].Directory
+	})
+
+	return WriteJson(w, http.StatusOK, response)
+}
+
+// GET Stream the container's whole filesystem as a tar
+func (h *Handler) handleExportContainer(w http.ResponseWriter, r *http.Request) error {
+	id := mux.Vars(r)["id"]
+
+	export, err := h.Docker.ExportContainer(r.Context(), id)
+	if err != nil {
+		return WriteError(w, err)
+	}
+	defer export.Close()
+
+	w.Header().Set("Content-Type", "application/x-tar")
+	w.Header().Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": id + ".tar"}))
+	w.WriteHeader(http.StatusOK)
+	_, err = io.Copy(w, export)
+	return err
+}
+
+// topLevelDirectory "/etc/nginx/nginx.conf" -> "/etc"
+func topLevelDirectory(path string) string {
+	first, _, _ := strings.Cut(strings.TrimPrefix(path, "/"), "/")
+	return "/" + first
+}
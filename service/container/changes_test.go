@@ -0,0 +1,122 @@
+package container
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"github.com/LysetsDal/docker-api/client"
+	"github.com/LysetsDal/docker-api/client/clienttest"
+	"github.com/LysetsDal/docker-api/types"
+	"github.com/LysetsDal/docker-api/utils"
+	"github.com/gorilla/mux"
+)
+
+func TestGetContainerChanges(t *testing.T) {
+	daemon := clienttest.NewDaemon(t, func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(`[
+			{"Path":"/etc","Kind":0},
+			{"Path":"/etc/nginx/nginx.conf","Kind":0},
+			{"Path":"/etc/nginx/conf.d/app.conf","Kind":1},
+			{"Path":"/var/log/nginx/access.log","Kind":1},
+			{"Path":"/tmp/cache","Kind":2},
+			{"Path":"/opt","Kind":7}
+		]`))
+	})
+	h := &Handler{Docker: client.NewDockerClient(daemon.Sock())}
+
+	tests := []struct {
+		name        string
+		query       string
+		wantStatus  int
+		wantChanges []types.FilesystemChange
+		wantSummary []types.ChangeSummary
+	}{
+		{
+			name:       "all",
+			wantStatus: http.StatusOK,
+			wantChanges: []types.FilesystemChange{
+				{Path: "/etc", Kind: "Modified"},
+				{Path: "/etc/nginx/nginx.conf", Kind: "Modified"},
+				{Path: "/etc/nginx/conf.d/app.conf", Kind: "Added"},
+				{Path: "/var/log/nginx/access.log", Kind: "Added"},
+				{Path: "/tmp/cache", Kind: "Deleted"},
+				{Path: "/opt", Kind: "Unknown(7)"},
+			},
+			wantSummary: []types.ChangeSummary{
+				{Directory: "/etc", Added: 1, Modified: 2},
+				{Directory: "/opt"},
+				{Directory: "/tmp", Deleted: 1},
+				{Directory: "/var", Added: 1},
+			},
+		},
+		{
+			name:       "some kinds",
+			query:      "?kind=Added,%20deleted",
+			wantStatus: http.StatusOK,
+			wantChanges: []types.FilesystemChange{
+				{Path: "/etc/nginx/conf.d/app.conf", Kind: "Added"},
+				{Path: "/var/log/nginx/access.log", Kind: "Added"},
+				{Path: "/tmp/cache", Kind: "Deleted"},
+			},
+			wantSummary: []types.ChangeSummary{
+				{Directory: "/etc", Added: 1},
+				{Directory: "/tmp", Deleted: 1},
+				{Directory: "/var", Added: 1},
+			},
+		},
+		{name: "unknown kind", query: "?kind=renamed", wantStatus: http.StatusBadRequest},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			request := mux.SetURLVars(httptest.NewRequest(http.MethodGet, "/containers/web/changes"+test.query, nil), map[string]string{"id": "web"})
+			recorder := httptest.NewRecorder()
+			utils.MakeHttpHandleFunc(h.handleGetContainerChanges)(recorder, request)
+
+			if recorder.Code != test.wantStatus {
+				t.Fatalf("status %d: %s", recorder.Code, recorder.Body)
+			}
+			if test.wantStatus != http.StatusOK {
+				return
+			}
+			response := types.ContainerChangesResponse{}
+			if err := json.Unmarshal(recorder.Body.Bytes(), &response); err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(response.Changes, test.wantChanges) {
+				t.Errorf("changes: got %+v, want %+v", response.Changes, test.wantChanges)
+			}
+			if !reflect.DeepEqual(response.Summary, test.wantSummary) {
+				t.Errorf("summary: got %+v, want %+v", response.Summary, test.wantSummary)
+			}
+		})
+	}
+}
+
+func TestExportContainer(t *testing.T) {
+	daemon := clienttest.NewDaemon(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/containers/web/export" {
+			http.Error(w, `{"message":"No such container"}`, http.StatusNotFound)
+			return
+		}
+		_, _ = w.Write([]byte("filesystem"))
+	})
+	h := &Handler{Docker: client.NewDockerClient(daemon.Sock())}
+
+	for id, wantStatus := range map[string]int{"web": http.StatusOK, "missing": http.StatusNotFound} {
+		request := mux.SetURLVars(httptest.NewRequest(http.MethodGet, "/containers/"+id+"/export", nil), map[string]string{"id": id})
+		recorder := httptest.NewRecorder()
+		utils.MakeHttpHandleFunc(h.handleExportContainer)(recorder, request)
+
+		if recorder.Code != wantStatus {
+			t.Errorf("%s: status %d", id, recorder.Code)
+			continue
+		}
+		if wantStatus == http.StatusOK && (recorder.Body.String() != "filesystem" || recorder.Header().Get("Content-Disposition") != "attachment; filename=web.tar") {
+			t.Errorf("%s: %q %q", id, recorder.Header().Get("Content-Disposition"), recorder.Body)
+		}
+	}
+}
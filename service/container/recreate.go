@@ -0,0 +1,242 @@
+package container
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	. "github.com/LysetsDal/docker-api/client"
+	. "github.com/LysetsDal/docker-api/types"
+	. "github.com/LysetsDal/docker-api/utils"
+	"github.com/gorilla/mux"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// handleRecreateContainer
+// Replace the container with an equivalent one built from its inspect data, optionally with a new image,
+// env or resources. The new container takes over the name and is started if the old one was running.
+func (h *Handler) handleRecreateContainer(w http.ResponseWriter, r *http.Request) error {
+	id := mux.Vars(r)["id"]
+	ctx := r.Context()
+
+	request, err := parseRecreateRequest(r)
+	if err != nil {
+		return err
+	}
+
+	old, err := h.Docker.InspectContainer(ctx, id)
+	if err != nil {
+		return WriteError(w, err)
+	}
+
+	response, err := h.replaceContainer(ctx, old, request)
+	if err != nil {
+		return WriteError(w, err)
+	}
+
+	return WriteJson(w, http.StatusCreated, response)
+}
+
+// replaceContainer Swap old for an equivalent container with the overrides applied. The old container
+// is only removed once the replacement holds its name and has started; if any step fails, the
+// replacement is removed and the old container is put back as it was.
+// Stopping an auto-removed container deletes it, so those keep running until the replacement has
+// started, and ones holding fixed host ports or static IPs, which both can't hold, are refused.
+// Errors from the daemon are wrapped, so StatusCode still reports the daemon's status.
+func (h *Handler) replaceContainer(ctx context.Context, old InspectObject, request RecreateRequest) (RecreateResponse, error) {
+	name := strings.TrimPrefix(old.Name, "/")
+	autoRemove := old.HostConfig.AutoRemove && old.State.Running
+	if autoRemove && old.ExclusiveAddresses() {
+		return RecreateResponse{}, Conflict("%s is auto-removed and holds fixed host ports or static IPs, it would be deleted when stopped to free them", name)
+	}
+
+	payload := old.ReplacementPayload()
+	applyOverrides(&payload, request)
+	if autoRemove {
+		// The replacement starts next to the old container, so it can't take over its MAC addresses
+		payload.ClearAddresses()
+	}
+
+	// Create under a temporary name first, so a bad override leaves the original untouched
+	temporaryName := fmt.Sprintf("%s-recreate-%.12s", name, old.Id)
+	created, err := h.Docker.CreateContainerWithPull(ctx, temporaryName, payload)
+	if err != nil {
+		return RecreateResponse{}, fmt.Errorf("creating replacement: %w", err)
+	}
+
+	// Removing the replacement frees its name and ports, then the other steps are undone in reverse.
+	// This runs even if the request was cancelled.
+	var undo []func()
+	fail := func(err error) (RecreateResponse, error) {
+		_ = h.Docker.RemoveContainer(context.Background(), created.Id, true, false)
+		for i := len(undo) - 1; i >= 0; i-- {
+			undo[i]()
+		}
+		return RecreateResponse{}, err
+	}
+
+	stop := func() error {
+		if err := h.Docker.StopContainer(ctx, old.Id, StopParams{}); err != nil && !IsNotFound(err) {
+			return fmt.Errorf("stopping %s: %w", name, err)
+		}
+		return nil
+	}
+
+	if !autoRemove {
+		// Before stopping, a failed stop may have stopped it anyway
+		if old.State.Running {
+			undo = append(undo, func() { _ = h.Docker.StartContainer(context.Background(), old.Id) })
+		}
+		if err := stop(); err != nil {
+			return fail(err)
+		}
+	}
+
+	if err := h.Docker.RenameContainer(ctx, old.Id, fmt.Sprintf("%s-replaced-%.12s", name, old.Id)); err != nil {
+		return fail(fmt.Errorf("renaming %s out of the way: %w", name, err))
+	}
+	undo = append(undo, func() { _ = h.Docker.RenameContainer(context.Background(), old.Id, name) })
+
+	if err := h.Docker.RenameContainer(ctx, created.Id, name); err != nil {
+		return fail(fmt.Errorf("renaming replacement: %w", err))
+	}
+
+	response := RecreateResponse{Id: created.Id, Name: name, SourceId: old.Id, Warnings: created.Warnings}
+	start := old.State.Running
+	if request.Start != nil {
+		start = *request.Start
+	}
+	if start {
+		if err := h.Docker.StartContainer(ctx, created.Id); err != nil {
+			return fail(fmt.Errorf("starting %s: %w", name, err))
+		}
+		response.Started = true
+	}
+
+	// The daemon deletes an auto-removed container once it stops, keeping the volumes the replacement mounts
+	if autoRemove {
+		if err := stop(); err != nil {
+			return fail(err)
+		}
+		return response, nil
+	}
+
+	// The replacement mounts the old container's volumes, so they are kept
+	if err := h.Docker.RemoveContainer(ctx, old.Id, true, false); err != nil && !IsNotFound(err) {
+		return fail(fmt.Errorf("removing %s: %w", name, err))
+	}
+
+	return response, nil
+}
+
+// handleCloneContainer
+// Create a copy of the container next to the original. Published host ports are switched to
+// random ports and static addresses are dropped, since the original still holds them. The clone is only started if Start is true.
+func (h *Handler) handleCloneContainer(w http.ResponseWriter, r *http.Request) error {
+	id := mux.Vars(r)["id"]
+	ctx := r.Context()
+
+	request, err := parseRecreateRequest(r)
+	if err != nil {
+		return err
+	}
+
+	source, err := h.Docker.InspectContainer(ctx, id)
+	if err != nil {
+		return WriteError(w, err)
+	}
+
+	payload := source.CreatePayload()
+	applyOverrides(&payload, request)
+	payload.ClearAddresses()
+
+	// A random suffix, so cloning the same container twice doesn't collide
+	name := request.Name
+	if name == "" {
+		name = fmt.Sprintf("%s-clone-%.8s", strings.TrimPrefix(source.Name, "/"), RandomId())
+	}
+
+	created, err := h.Docker.CreateContainerWithPull(ctx, name, payload)
+	if errors.Is(err, ErrConflict) {
+		return WriteError(w, Conflict("a container named %s already exists", name))
+	}
+	if err != nil {
+		return WriteError(w, err)
+	}
+
+	response := RecreateResponse{Id: created.Id, Name: name, SourceId: source.Id, Warnings: created.Warnings}
+	if request.Start != nil && *request.Start {
+		if err := h.Docker.StartContainer(ctx, created.Id); err != nil {
+			return WriteProblem(w, StatusCode(err), fmt.Sprintf("starting %s: %s", name, err))
+		}
+		response.Started = true
+	}
+
+	return WriteJson(w, http.StatusCreated, response)
+}
+
+// parseRecreateRequest The body is optional
+func parseRecreateRequest(r *http.Request) (RecreateRequest, error) {
+	request := RecreateRequest{}
+	if r.Body == nil {
+		return request, nil
+	}
+	if err := ParseJson(r, &request); err != nil && !errors.Is(err, io.EOF) {
+		return request, BadRequest("invalid request body: %w", err)
+	}
+	return request, nil
+}
+
+func applyOverrides(payload *Payload, request RecreateRequest) {
+	if request.Image != "" {
+		payload.Image = request.Image
+	}
+
+	if len(request.Env) > 0 {
+		env := make([]string, 0, len(payload.Env)+len(request.Env))
+		for _, variable := range payload.Env {
+			key, _, _ := strings.Cut(variable, "=")
+			if _, overridden := request.Env[key]; !overridden {
+				env = append(env, variable)
+			}
+		}
+		for key, value := range request.Env {
+			env = append(env, key+"="+value)
+		}
+		payload.Env = env
+	}
+
+	resources := request.Resources
+	if resources == nil {
+		return
+	}
+	hostConfig := &payload.HostConfig
+	if resources.Memory != nil {
+		hostConfig.Memory = *resources.Memory
+	}
+	if resources.MemorySwap != nil {
+		hostConfig.MemorySwap = *resources.MemorySwap
+	}
+	if resources.MemoryReservation != nil {
+		hostConfig.MemoryReservation = *resources.MemoryReservation
+	}
+	if resources.NanoCpus != nil {
+		hostConfig.NanoCpus = *resources.NanoCpus
+	}
+	if resources.CpuShares != nil {
+		hostConfig.CpuShares = *resources.CpuShares
+	}
+	if resources.CpuPeriod != nil {
+		hostConfig.CpuPeriod = *resources.CpuPeriod
+	}
+	if resources.CpuQuota != nil {
+		hostConfig.CpuQuota = *resources.CpuQuota
+	}
+	if resources.CpusetCpus != nil {
+		hostConfig.CpusetCpus = *resources.CpusetCpus
+	}
+	if resources.PidsLimit != nil {
+		hostConfig.PidsLimit = *resources.PidsLimit
+	}
+}
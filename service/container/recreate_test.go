@@ -0,0 +1,125 @@
+package container
+
+import (
+	"context"
+	"net/http"
+	"reflect"
+	"testing"
+
+	"github.com/LysetsDal/docker-api/client"
+	"github.com/LysetsDal/docker-api/client/clienttest"
+	"github.com/LysetsDal/docker-api/types"
+)
+
+func TestReplaceContainer(t *testing.T) {
+	tests := []struct {
+		name       string
+		fail       string
+		autoRemove bool
+		ports      bool
+		want       []string
+		isErr      bool
+	}{
+		{
+			name: "replaced",
+			want: []string{
+				"POST /containers/create", "POST /containers/old/stop", "POST /containers/old/rename",
+				"POST /containers/new/rename", "POST /containers/new/start", "DELETE /containers/old",
+			},
+		},
+		{
+			name: "stop fails",
+			fail: "POST /containers/old/stop",
+			want: []string{
+				"POST /containers/create", "POST /containers/old/stop",
+				"DELETE /containers/new", "POST /containers/old/start",
+			},
+			isErr: true,
+		},
+		{
+			name: "rename fails",
+			fail: "POST /containers/new/rename",
+			want: []string{
+				"POST /containers/create", "POST /containers/old/stop", "POST /containers/old/rename",
+				"POST /containers/new/rename",
+				"DELETE /containers/new", "POST /containers/old/rename", "POST /containers/old/start",
+			},
+			isErr: true,
+		},
+		{
+			name: "remove fails",
+			fail: "DELETE /containers/old",
+			want: []string{
+				"POST /containers/create", "POST /containers/old/stop", "POST /containers/old/rename",
+				"POST /containers/new/rename", "POST /containers/new/start", "DELETE /containers/old",
+				"DELETE /containers/new", "POST /containers/old/rename", "POST /containers/old/start",
+			},
+			isErr: true,
+		},
+		{
+			name:       "auto-removed",
+			autoRemove: true,
+			want: []string{
+				"POST /containers/create", "POST /containers/old/rename", "POST /containers/new/rename",
+				"POST /containers/new/start", "POST /containers/old/stop",
+			},
+		},
+		{
+			name:       "auto-removed, start fails",
+			autoRemove: true,
+			fail:       "POST /containers/new/start",
+			want: []string{
+				"POST /containers/create", "POST /containers/old/rename", "POST /containers/new/rename",
+				"POST /containers/new/start",
+				"DELETE /containers/new", "POST /containers/old/rename",
+			},
+			isErr: true,
+		},
+		{
+			name:       "auto-removed with fixed ports",
+			autoRemove: true,
+			ports:      true,
+			want:       nil,
+			isErr:      true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			old := types.InspectObject{Id: "old", Name: "/web"}
+			old.State.Running = true
+			old.Config.Image = "nginx"
+			old.HostConfig.AutoRemove = test.autoRemove
+			if test.ports {
+				old.HostConfig.PortBindings = map[string][]types.PortBinding{"80/tcp": {{HostPort: "8080"}}}
+			}
+
+			daemon := clienttest.NewDaemon(t, func(w http.ResponseWriter, r *http.Request) {
+				call := r.Method + " " + r.URL.Path
+				switch {
+				case call == test.fail:
+					http.Error(w, `{"message":"boom"}`, http.StatusInternalServerError)
+				case call == "POST /containers/create":
+					_, _ = w.Write([]byte(`{"Id":"new"}`))
+				case call == "DELETE /containers/old" && r.URL.Query().Get("v") == "true":
+					t.Error("the old container's volumes were removed")
+				case call == "DELETE /containers/old" && test.autoRemove:
+					t.Error("the daemon already removes a stopped auto-removed container")
+				default:
+					w.WriteHeader(http.StatusNoContent)
+				}
+			})
+			docker := client.NewDockerClient(daemon.Sock())
+			h := &Handler{Docker: docker}
+
+			_, err := h.replaceContainer(context.Background(), old, types.RecreateRequest{})
+			if (err != nil) != test.isErr {
+				t.Fatalf("error: %v", err)
+			}
+
+			if got := daemon.Calls(); !reflect.DeepEqual(got, test.want) {
+				t.Errorf("calls:\n got %v\nwant %v", got, test.want)
+			}
+		})
+	}
+}
@@ -1,22 +1,37 @@
 package container
 
 import (
-	"fmt"
-	. "github.com/LysetsDal/docker-api/config"
+	"context"
+	"encoding/json"
+	"errors"
+	. "github.com/LysetsDal/docker-api/client"
+	"github.com/LysetsDal/docker-api/service/idempotency"
+	"github.com/LysetsDal/docker-api/service/operation"
 	. "github.com/LysetsDal/docker-api/types"
 	. "github.com/LysetsDal/docker-api/utils"
 	"github.com/gorilla/mux"
 	"io"
 	"net/http"
+	"net/url"
+	"reflect"
+	"strings"
+	"time"
 )
 
+// maxCreateBodySize Largest container config accepted by create
+const maxCreateBodySize = 1 << 20
+
 type Handler struct {
-	DockerSock http.Client
+	Docker      *DockerClient
+	Operations  *operation.Operations
+	Idempotency *idempotency.Keys
 }
 
-func NewHandler(sock http.Client) *Handler {
+func NewHandler(sock http.Client, operations *operation.Operations, keys *idempotency.Keys) *Handler {
 	return &Handler{
-		DockerSock: sock,
+		Docker:      NewDockerClient(sock),
+		Operations:  operations,
+		Idempotency: keys,
 	}
 }
 
@@ -24,218 +39,212 @@ func NewHandler(sock http.Client) *Handler {
 func (h *Handler) RegisterRoutes(router *mux.Router) {
 	// Multi container functions
 	router.HandleFunc("/containers/list", MakeHttpHandleFunc(h.handleListContainers))
-	router.HandleFunc("/containers/create", MakeHttpHandleFunc(h.handleCreateContainer))
-	router.HandleFunc("/containers/stopall", MakeHttpHandleFunc(h.handleStopAllContainers))
-	router.HandleFunc("/containers/prune", MakeHttpHandleFunc(h.handlePruneContainers))
+	router.Handle("/containers/create", h.Idempotency.Wrap(MakeHttpHandleFunc(h.handleCreateContainer)))
+	router.Handle("/containers/run", h.Idempotency.Wrap(MakeHttpHandleFunc(h.handleRunContainer))).Methods(http.MethodPost)
+	router.Handle("/containers/stopall", h.Idempotency.Wrap(h.Operations.Async("container.stopall", MakeHttpHandleFunc(h.handleStopAllContainers)))).Methods(http.MethodPost)
+	router.HandleFunc("/containers/prune", MakeHttpHandleFunc(h.handlePruneContainers)).Methods(http.MethodPost)
+	router.Handle("/containers/bulk", h.Idempotency.Wrap(h.Operations.Async("container.bulk", MakeHttpHandleFunc(h.handleBulk)))).Methods(http.MethodPost)
 
 	// Single container functions
 	router.HandleFunc("/containers/{id}/json", MakeHttpHandleFunc(h.handleGetContainerById))
-	router.HandleFunc("/containers/{id}/start", MakeHttpHandleFunc(h.handleStartContainer))
-	router.HandleFunc("/containers/{id}/stop", MakeHttpHandleFunc(h.handleStopContainer))
+	router.Handle("/containers/{id}/start", h.Idempotency.Wrap(MakeHttpHandleFunc(h.handleStartContainer)))
+	router.Handle("/containers/{id}/stop", h.Idempotency.Wrap(MakeHttpHandleFunc(h.handleStopContainer)))
 	router.HandleFunc("/containers/{id}/top", MakeHttpHandleFunc(h.handleGetContainersProcesses))
+	router.Handle("/containers/{id}/recreate", h.Operations.Async("container.recreate", MakeHttpHandleFunc(h.handleRecreateContainer))).Methods(http.MethodPost)
+	router.HandleFunc("/containers/{id}/clone", MakeHttpHandleFunc(h.handleCloneContainer)).Methods(http.MethodPost)
+	router.HandleFunc("/containers/{id}/archive", MakeHttpHandleFunc(h.handleStatContainerPath)).Methods(http.MethodHead)
+	router.HandleFunc("/containers/{id}/archive", MakeHttpHandleFunc(h.handleGetArchive)).Methods(http.MethodGet)
+	router.HandleFunc("/containers/{id}/archive", MakeHttpHandleFunc(h.handlePutArchive)).Methods(http.MethodPut)
+	router.HandleFunc("/containers/{id}/changes", MakeHttpHandleFunc(h.handleGetContainerChanges)).Methods(http.MethodGet)
+	router.HandleFunc("/containers/{id}/export", MakeHttpHandleFunc(h.handleExportContainer)).Methods(http.MethodGet)
+	router.HandleFunc("/containers/{id}/commit", MakeHttpHandleFunc(h.handleCommitContainer)).Methods(http.MethodPost)
+	router.HandleFunc("/containers/{id}/snapshots", MakeHttpHandleFunc(h.handleListSnapshots)).Methods(http.MethodGet)
+	router.HandleFunc("/containers/{id}/snapshots", MakeHttpHandleFunc(h.handleCreateSnapshot)).Methods(http.MethodPost)
+	router.HandleFunc("/containers/{id}/snapshots/{snapshot}/restore", MakeHttpHandleFunc(h.handleRestoreSnapshot)).Methods(http.MethodPost)
 }
 
-// sendDockerGetRequest Send a get request to the Docker Socket
-func (h *Handler) sendDockerGetRequest(w http.ResponseWriter, url string) (*http.Response, error) {
-	response, err := h.DockerSock.Get(url)
+// handleCreateContainer
+// Send POST request to docker. Request.Body is validated as a Payload first, but sent to the daemon as it
+// is, so fields Payload doesn't model still reach it; ?name= names the container.
+func (h *Handler) handleCreateContainer(w http.ResponseWriter, r *http.Request) error {
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxCreateBodySize))
 	if err != nil {
-		WriteError(w, http.StatusInternalServerError, err)
-		return nil, err
+		return BadRequest("reading container config: %w", err)
 	}
 
-	return response, nil
-}
-
-// sendDockerGetRequest Send a get request to the Docker Socket
-func (h *Handler) sendDockerGetRequestWithPayload(w http.ResponseWriter, url string, payload io.Reader) (*http.Response, error) {
-	//response, err := h.DockerSock.Get(url)
-	request, err := http.NewRequest("GET", url, payload)
-	if err != nil {
-		fmt.Printf("New request error: %s", err)
+	payload := Payload{}
+	if err := json.Unmarshal(body, &payload); err != nil {
+		return BadRequest("invalid container config: %w", err)
 	}
-
-	response, err := h.DockerSock.Do(request)
-	if err != nil {
-		WriteError(w, http.StatusInternalServerError, err)
-		return nil, err
+	if err := ValidatePayload(payload); err != nil {
+		return WriteValidationError(w, "invalid container config", err)
 	}
 
-	return response, nil
-}
-
-// sendDockerPostRequest Send a POST request to the Docker Socket
-func (h *Handler) sendDockerPostRequest(w http.ResponseWriter, url string, reader io.Reader) (*http.Response, error) {
-	request, err := h.DockerSock.Post(url, "application/json", reader)
+	createContainerResponse, err := h.Docker.CreateContainerRaw(r.Context(), r.URL.Query().Get("name"), body)
 	if err != nil {
-		WriteError(w, http.StatusInternalServerError, err)
-		return nil, err
+		return WriteError(w, err)
 	}
 
-	return request, nil
+	return WriteJson(w, http.StatusCreated, createContainerResponse)
 }
 
-// handleCreateContainer
-// Send POST request to docker. Uses data from Request.Body as container specifications.
-func (h *Handler) handleCreateContainer(w http.ResponseWriter, r *http.Request) error {
-	url := fmt.Sprintf(UnixPrefix + "containers/create")
+// GET Inspect a container.
+// ?raw=true passes the daemon's response through untouched, ?fields=State.Health,Config.Image
+// projects the response down to the given paths and ?size=true adds SizeRw and SizeRootFs.
+func (h *Handler) handleGetContainerById(w http.ResponseWriter, r *http.Request) error {
+	pathVars := mux.Vars(r)
+	query := r.URL.Query()
 
-	createContainerResponse := CreateContainerResponse{}
-	response, _ := h.sendDockerGetRequestWithPayload(w, url, r.Body)
-	if response.Body == nil {
-		return fmt.Errorf("missing request body")
+	raw, err := h.Docker.InspectContainerRaw(r.Context(), pathVars["id"], query.Get("size") == "true")
+	if err != nil {
+		return WriteError(w, err)
 	}
-	defer response.Body.Close()
 
-	switch response.StatusCode {
-	case http.StatusCreated:
-		if err := ReadJson(response.Body, &createContainerResponse); err != nil {
-			fmt.Printf("Json read-error: %s", err)
+	var inspect any = raw
+	if query.Get("raw") != "true" {
+		inspectObject := InspectObject{}
+		if err := json.Unmarshal(raw, &inspectObject); err != nil {
+			return WriteError(w, err)
 		}
-		return WriteJson(w, http.StatusCreated, createContainerResponse)
-
-	case http.StatusNotModified:
-		return WriteJson(w, http.StatusOK, ApiMessage{Message: "Container already started"})
-
-	case http.StatusNotFound:
-		return WriteJson(w, http.StatusNotFound, ApiError{Error: fmt.Sprintf("No such image: %s", response.Body)})
-
-	default:
-		return WriteJson(w, http.StatusInternalServerError, ApiError{Error: "Something went wrong"})
+		inspect = inspectObject
 	}
-}
-
-// GET List of all containers
-func (h *Handler) handleGetContainerById(w http.ResponseWriter, r *http.Request) error {
-	pathVars := mux.Vars(r)
-	url := fmt.Sprintf(UnixPrefix+"containers/%s/json", pathVars["id"])
 
-	response, _ := h.sendDockerGetRequest(w, url)
-	defer response.Body.Close()
-
-	// Read and decode the JSON response
-	inspectObject := InspectObject{}
-	if err := ReadJson(response.Body, &inspectObject); err != nil {
-		WriteError(w, http.StatusInternalServerError, err)
-		return err
+	if fields := query.Get("fields"); fields != "" {
+		projected, err := ProjectFields(inspect, reflect.TypeOf(InspectObject{}), fields)
+		if err != nil {
+			return err
+		}
+		return WriteJson(w, http.StatusOK, projected)
 	}
 
-	return WriteJson(w, response.StatusCode, inspectObject)
+	return WriteJson(w, http.StatusOK, inspect)
 }
 
+// GET The processes running in a container. ?ps_args= is passed to ps.
 func (h *Handler) handleGetContainersProcesses(w http.ResponseWriter, r *http.Request) error {
-	pathVars := mux.Vars(r)
-	url := fmt.Sprintf(UnixPrefix+"containers/%s/top", pathVars["id"])
-
-	response, _ := h.sendDockerGetRequest(w, url)
-	defer response.Body.Close()
-
-	containerProcs := Processes{}
-	if err := ReadJson(response.Body, &containerProcs); err != nil {
-		WriteError(w, http.StatusInternalServerError, err)
+	processes, err := h.Docker.TopContainer(r.Context(), mux.Vars(r)["id"], r.URL.Query().Get("ps_args"))
+	if err != nil {
+		return WriteError(w, err)
 	}
 
-	return WriteJson(w, http.StatusOK, containerProcs)
+	return WriteJson(w, http.StatusOK, processes)
 }
 
 // GET List of all running containers
-func (h *Handler) handleListContainers(w http.ResponseWriter, _ *http.Request) error {
-	url := fmt.Sprintf(UnixPrefix + "containers/json")
-
-	response, _ := h.sendDockerGetRequest(w, url)
-	defer response.Body.Close()
-
-	// Read and decode the JSON response
-	containers := make([]Container, 0)
-	if err := ReadJson(response.Body, &containers); err != nil {
-		WriteError(w, http.StatusInternalServerError, err)
-		return err
+func (h *Handler) handleListContainers(w http.ResponseWriter, r *http.Request) error {
+	containers, err := h.Docker.ListContainers(r.Context(), false, nil)
+	if err != nil {
+		return WriteError(w, err)
 	}
 
-	return WriteJson(w, response.StatusCode, containers)
+	return WriteJson(w, http.StatusOK, containers)
 }
 
 // POST Start container
 func (h *Handler) handleStartContainer(w http.ResponseWriter, r *http.Request) error {
-	pathVars := mux.Vars(r)
-	url := fmt.Sprintf(UnixPrefix+"containers/%s/start", pathVars["id"])
-
-	request, _ := h.sendDockerGetRequest(w, url)
-	defer request.Body.Close()
-
-	switch request.StatusCode {
-	case http.StatusNoContent:
-		return WriteJson(w, http.StatusOK, ApiMessage{Message: "Container started"})
-
-	case http.StatusNotModified:
+	changed, err := h.Docker.ChangeContainerState(r.Context(), mux.Vars(r)["id"], "start", nil)
+	if err != nil {
+		return WriteError(w, err)
+	}
+	if !changed {
 		return WriteJson(w, http.StatusOK, ApiMessage{Message: "Container already started"})
-
-	case http.StatusNotFound:
-		return WriteJson(w, http.StatusNotFound, ApiError{Error: "No such container"})
-
-	default:
-		return WriteJson(w, http.StatusInternalServerError, ApiError{Error: "Something went wrong"})
 	}
+
+	return WriteJson(w, http.StatusOK, ApiMessage{Message: "Container started"})
 }
 
-// POST Stop container
+// POST Stop container. ?t= and ?signal= are passed to the daemon.
 func (h *Handler) handleStopContainer(w http.ResponseWriter, r *http.Request) error {
-	pathVars := mux.Vars(r)
-	url := fmt.Sprintf(UnixPrefix+"containers/%s/stop", pathVars["id"])
-
-	request, _ := h.sendDockerPostRequest(w, url, r.Body)
-	defer request.Body.Close()
-
-	fmt.Printf("r.Body: %s\n", r.Body)
-
-	switch request.StatusCode {
-	case http.StatusNoContent:
-		return WriteJson(w, http.StatusOK, ApiMessage{Message: "Container stopped"})
+	query := url.Values{}
+	for _, name := range []string{"t", "signal"} {
+		if value := r.URL.Query().Get(name); value != "" {
+			query.Set(name, value)
+		}
+	}
 
-	case http.StatusNotModified:
+	changed, err := h.Docker.ChangeContainerState(r.Context(), mux.Vars(r)["id"], "stop", query)
+	if err != nil {
+		return WriteError(w, err)
+	}
+	if !changed {
 		return WriteJson(w, http.StatusOK, ApiMessage{Message: "Container already stopped"})
-
-	case http.StatusNotFound:
-		return WriteJson(w, http.StatusNotFound, ApiError{Error: "No such container"})
-
-	default:
-		return WriteJson(w, http.StatusInternalServerError, ApiError{Error: "Something went wrong"})
 	}
+
+	return WriteJson(w, http.StatusOK, ApiMessage{Message: "Container stopped"})
 }
 
-// POST Stop all containers
+// POST Stop running containers concurrently. The optional body selects which and how, see StopAllRequest:
+// {"Labels": ["env=dev"], "Exclude": ["db"], "Signal": "SIGINT", "T": 20, "Parallelism": 8, "Deadline": "1m"}
 func (h *Handler) handleStopAllContainers(w http.ResponseWriter, r *http.Request) error {
-	url := fmt.Sprintf(UnixPrefix + "containers/json")
+	request := StopAllRequest{}
+	if err := ParseJsonStrict(r, &request); err != nil && !errors.Is(err, io.EOF) {
+		return BadRequest("invalid stop request: %w", err)
+	}
+	if err := ValidateStopAllRequest(request); err != nil {
+		return WriteValidationError(w, "invalid stop request", err)
+	}
 
-	response, _ := h.sendDockerGetRequest(w, url)
-	defer response.Body.Close()
+	filters := map[string][]string{}
+	if len(request.Labels) > 0 {
+		filters["label"] = request.Labels
+	}
+	if len(request.Names) > 0 {
+		filters["name"] = request.Names
+	}
+	if len(request.Images) > 0 {
+		filters["ancestor"] = request.Images
+	}
 
-	// Read and decode the JSON response
-	var containers []Container
-	if err := ReadJson(response.Body, &containers); err != nil {
-		WriteError(w, http.StatusInternalServerError, err)
+	containers, err := h.Docker.ListContainers(r.Context(), false, filters)
+	if err != nil {
+		return WriteError(w, err)
+	}
+	if containers, err = excludeContainers(containers, request.Exclude); err != nil {
 		return err
 	}
 
-	// Iterate over all containers, and send POST request to stop.
-	for _, c := range containers {
-		apiUrl := fmt.Sprintf(UnixPrefix+"containers/%s/stop", c.Id)
-		request, _ := h.sendDockerPostRequest(w, apiUrl, r.Body)
-		request.Body.Close()
+	deadline := defaultDeadline
+	if request.Deadline != "" {
+		deadline, _ = time.ParseDuration(request.Deadline)
+	}
+	parallelism := request.Parallelism
+	if parallelism == 0 {
+		parallelism = defaultParallelism
 	}
 
-	return WriteJson(w, http.StatusOK, ApiMessage{Message: "all containers stopped"})
+	ctx, cancel := context.WithTimeout(r.Context(), deadline)
+	defer cancel()
+	report := runBulk(ctx, BulkStop, containers, parallelism, false, func(ctx context.Context, id string) error {
+		return h.Docker.StopContainer(ctx, id, request.StopParams)
+	})
+
+	return WriteJson(w, bulkStatus(report), report)
 }
 
+// POST Remove stopped containers. The optional body narrows it down: {"Until": "24h", "Labels": ["env=dev"]}
 func (h *Handler) handlePruneContainers(w http.ResponseWriter, r *http.Request) error {
-	url := fmt.Sprintf(UnixPrefix + "containers/prune")
+	request := PruneRequest{}
+	if err := ParseJsonStrict(r, &request); err != nil && !errors.Is(err, io.EOF) {
+		return BadRequest("invalid prune request: %w", err)
+	}
 
-	response, _ := h.sendDockerPostRequest(w, url, r.Body)
-	defer response.Body.Close()
+	filters := map[string][]string{}
+	if request.Until != "" {
+		if _, err := time.ParseDuration(request.Until); err != nil {
+			return BadRequest("until must be a duration such as 24h: %w", err)
+		}
+		filters["until"] = []string{request.Until}
+	}
+	for _, label := range request.Labels {
+		if key, _, _ := strings.Cut(label, "="); strings.TrimSpace(key) == "" {
+			return BadRequest("labels must be key or key=value, got %q", label)
+		}
+		filters["label"] = append(filters["label"], label)
+	}
 
-	deletedContainers := PruneResponse{}
-	if err := ReadJson(response.Body, &deletedContainers); err != nil {
-		WriteError(w, http.StatusInternalServerError, err)
-		return err
+	deletedContainers, err := h.Docker.PruneContainers(r.Context(), filters)
+	if err != nil {
+		return WriteError(w, err)
 	}
 
 	return WriteJson(w, http.StatusOK, deletedContainers)
@@ -0,0 +1,74 @@
+package container
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/LysetsDal/docker-api/client"
+	"github.com/LysetsDal/docker-api/client/clienttest"
+	"github.com/LysetsDal/docker-api/utils"
+)
+
+func TestHandleCreateContainer(t *testing.T) {
+	tests := []struct {
+		name       string
+		body       string
+		wantStatus int
+		forwarded  bool
+	}{
+		{
+			name:       "fields Payload doesn't model",
+			body:       `{"Image":"nginx","StopSignal":"SIGQUIT","HostConfig":{"CapAdd":["NET_ADMIN"],"Unmodeled":true},"Future":1}`,
+			wantStatus: http.StatusCreated,
+			forwarded:  true,
+		},
+		{name: "string command", body: `{"Image":"alpine","Cmd":"date"}`, wantStatus: http.StatusCreated, forwarded: true},
+		{name: "string entrypoint", body: `{"Image":"alpine","Entrypoint":"/bin/sh","Cmd":["-c","date"]}`, wantStatus: http.StatusCreated, forwarded: true},
+		{name: "numeric command", body: `{"Image":"alpine","Cmd":7}`, wantStatus: http.StatusBadRequest},
+		{name: "invalid", body: `{"Image":"","HostConfig":{"MemorySwappiness":-2}}`, wantStatus: http.StatusBadRequest},
+		{name: "not json", body: `{"Image":`, wantStatus: http.StatusBadRequest},
+		{name: "empty", body: ``, wantStatus: http.StatusBadRequest},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var received []byte
+			daemon := clienttest.NewDaemon(t, func(w http.ResponseWriter, r *http.Request) {
+				received, _ = io.ReadAll(r.Body)
+				if r.URL.Query().Get("name") != "web" {
+					t.Errorf("name: %q", r.URL.Query().Get("name"))
+				}
+				w.WriteHeader(http.StatusCreated)
+				_, _ = w.Write([]byte(`{"Id":"new","Warnings":[]}`))
+			})
+			docker := client.NewDockerClient(daemon.Sock())
+			h := &Handler{Docker: docker}
+
+			recorder := httptest.NewRecorder()
+			request := httptest.NewRequest(http.MethodPost, "/containers/create?name=web", strings.NewReader(test.body))
+			utils.MakeHttpHandleFunc(h.handleCreateContainer)(recorder, request)
+
+			if recorder.Code != test.wantStatus {
+				t.Fatalf("status %d: %s", recorder.Code, recorder.Body)
+			}
+			if !test.forwarded {
+				if len(daemon.Calls()) > 0 {
+					t.Errorf("sent to the daemon: %v", daemon.Calls())
+				}
+				return
+			}
+
+			var sent, want any
+			_ = json.Unmarshal(received, &sent)
+			_ = json.Unmarshal([]byte(test.body), &want)
+			if !reflect.DeepEqual(sent, want) {
+				t.Errorf("daemon got %s, want %s", received, test.body)
+			}
+		})
+	}
+}
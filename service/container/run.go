@@ -0,0 +1,32 @@
+package container
+
+import (
+	. "github.com/LysetsDal/docker-api/types"
+	. "github.com/LysetsDal/docker-api/utils"
+	"net/http"
+)
+
+// handleRunContainer
+// Create (and by default start) a container from docker run style shorthand:
+// {"Image": "nginx", "Ports": ["8080:80"], "Volumes": ["data:/data:ro"], "Memory": "512m", "Cpus": 1.5,
+// "Env": {"K": "V"}, "Restart": "on-failure:3"}
+func (h *Handler) handleRunContainer(w http.ResponseWriter, r *http.Request) error {
+	ctx := r.Context()
+
+	request := RunRequest{}
+	if err := ParseJsonStrict(r, &request); err != nil {
+		return BadRequest("invalid run request: %w", err)
+	}
+
+	payload, err := ExpandRunRequest(request)
+	if err != nil {
+		return WriteValidationError(w, "invalid run request", err)
+	}
+
+	response, err := h.Docker.RunContainer(ctx, request.Name, payload, request.Pull, request.Start == nil || *request.Start)
+	if err != nil {
+		return WriteError(w, err)
+	}
+
+	return WriteJson(w, http.StatusCreated, response)
+}
@@ -0,0 +1,171 @@
+package container
+
+import (
+	"errors"
+	. "github.com/LysetsDal/docker-api/client"
+	. "github.com/LysetsDal/docker-api/config"
+	. "github.com/LysetsDal/docker-api/types"
+	. "github.com/LysetsDal/docker-api/utils"
+	"github.com/gorilla/mux"
+	"io"
+	"net/http"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+)
+
+var repoUnsafeRegex = regexp.MustCompile(`[^a-z0-9._-]+`)
+
+// handleCommitContainer
+// POST Commit the container's filesystem to an image:
+// {"Repo": "myapp", "Tag": "debug", "Message": "...", "Author": "...", "Changes": ["ENV DEBUG=1"], "Pause": true}
+func (h *Handler) handleCommitContainer(w http.ResponseWriter, r *http.Request) error {
+	request := CommitRequest{}
+	if err := ParseJsonStrict(r, &request); err != nil {
+		return BadRequest("invalid commit request: %w", err)
+	}
+
+	if err := ValidateCommitRequest(request); err != nil {
+		return WriteValidationError(w, "invalid commit request", err)
+	}
+
+	committed, err := h.Docker.CommitContainer(r.Context(), mux.Vars(r)["id"], request, nil)
+	if err != nil {
+		return WriteError(w, err)
+	}
+
+	return WriteJson(w, http.StatusCreated, committed)
+}
+
+// handleCreateSnapshot
+// POST Commit the container as snapshots/<name>:<tag>, labelled with the source container and time
+func (h *Handler) handleCreateSnapshot(w http.ResponseWriter, r *http.Request) error {
+	ctx := r.Context()
+
+	request := SnapshotRequest{}
+	if err := ParseJsonStrict(r, &request); err != nil && !errors.Is(err, io.EOF) {
+		return BadRequest("invalid snapshot request: %w", err)
+	}
+
+	source, err := h.Docker.InspectContainer(ctx, mux.Vars(r)["id"])
+	if err != nil {
+		return WriteError(w, err)
+	}
+	name := strings.TrimPrefix(source.Name, "/")
+
+	created := time.Now().UTC()
+	commit := CommitRequest{
+		Repo:    snapshotRepo(name),
+		Tag:     request.Tag,
+		Message: request.Message,
+		Author:  request.Author,
+		Pause:   request.Pause,
+	}
+	if commit.Tag == "" {
+		commit.Tag = created.Format("20060102-150405")
+	}
+	if err := ValidateCommitRequest(commit); err != nil {
+		return WriteValidationError(w, "invalid snapshot request", err)
+	}
+
+	labels := map[string]string{
+		SnapshotContainerLabel:   name,
+		SnapshotContainerIdLabel: source.Id,
+		SnapshotCreatedLabel:     created.Format(time.RFC3339),
+		SnapshotMessageLabel:     request.Message,
+	}
+	committed, err := h.Docker.CommitContainer(ctx, source.Id, commit, labels)
+	if err != nil {
+		return WriteError(w, err)
+	}
+
+	return WriteJson(w, http.StatusCreated, Snapshot{
+		Id:          committed.Id,
+		RepoTags:    []string{commit.Repo + ":" + commit.Tag},
+		Container:   name,
+		ContainerId: source.Id,
+		Message:     request.Message,
+		Created:     labels[SnapshotCreatedLabel],
+	})
+}
+
+// handleListSnapshots
+// GET Snapshots of the container, newest first. Snapshots are kept per container name,
+// so {id} may also name a container that has since been removed.
+func (h *Handler) handleListSnapshots(w http.ResponseWriter, r *http.Request) error {
+	ctx := r.Context()
+	id := mux.Vars(r)["id"]
+
+	name := id
+	source, err := h.Docker.InspectContainer(ctx, id)
+	switch {
+	case err == nil:
+		name = strings.TrimPrefix(source.Name, "/")
+	case !IsNotFound(err):
+		return WriteError(w, err)
+	}
+
+	images, err := h.Docker.ListImages(ctx, false, map[string][]string{"label": {SnapshotContainerLabel + "=" + name}})
+	if err != nil {
+		return WriteError(w, err)
+	}
+
+	snapshots := make([]Snapshot, 0, len(images))
+	for _, image := range images {
+		snapshots = append(snapshots, Snapshot{
+			Id:          image.Id,
+			RepoTags:    image.RepoTags,
+			Container:   image.Labels[SnapshotContainerLabel],
+			ContainerId: image.Labels[SnapshotContainerIdLabel],
+			Message:     image.Labels[SnapshotMessageLabel],
+			Created:     image.Labels[SnapshotCreatedLabel],
+			Size:        image.Size,
+		})
+	}
+	sort.SliceStable(snapshots, func(i, j int) bool {
+		return snapshots[i].Created > snapshots[j].Created
+	})
+
+	return WriteJson(w, http.StatusOK, snapshots)
+}
+
+// handleRestoreSnapshot
+// POST Recreate the container from one of its snapshots, {snapshot} is the snapshot's image id.
+// Takes the same optional body as /recreate, except that Image is always the snapshot.
+func (h *Handler) handleRestoreSnapshot(w http.ResponseWriter, r *http.Request) error {
+	ctx := r.Context()
+	pathVars := mux.Vars(r)
+
+	request, err := parseRecreateRequest(r)
+	if err != nil {
+		return err
+	}
+
+	old, err := h.Docker.InspectContainer(ctx, pathVars["id"])
+	if err != nil {
+		return WriteError(w, err)
+	}
+	name := strings.TrimPrefix(old.Name, "/")
+
+	snapshot, err := h.Docker.InspectImage(ctx, pathVars["snapshot"])
+	if err != nil {
+		return WriteError(w, err)
+	}
+	if snapshot.Config.Labels[SnapshotContainerLabel] != name {
+		return NotFound("image %s is not a snapshot of %s", pathVars["snapshot"], name)
+	}
+
+	request.Image = snapshot.Id
+	response, err := h.replaceContainer(ctx, old, request)
+	if err != nil {
+		return WriteError(w, err)
+	}
+
+	return WriteJson(w, http.StatusCreated, response)
+}
+
+// snapshotRepo Image repositories must be lowercase
+func snapshotRepo(container string) string {
+	return "snapshots/" + strings.Trim(repoUnsafeRegex.ReplaceAllString(strings.ToLower(container), "-"), "-._")
+}
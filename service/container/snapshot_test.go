@@ -0,0 +1,185 @@
+package container
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/LysetsDal/docker-api/client"
+	"github.com/LysetsDal/docker-api/client/clienttest"
+	"github.com/LysetsDal/docker-api/config"
+	"github.com/LysetsDal/docker-api/types"
+	"github.com/LysetsDal/docker-api/utils"
+	"github.com/gorilla/mux"
+)
+
+func TestSnapshotRepo(t *testing.T) {
+	for container, want := range map[string]string{
+		"web":          "snapshots/web",
+		"My_App.1":     "snapshots/my_app.1",
+		"shop web!!":   "snapshots/shop-web",
+		"-.api-server": "snapshots/api-server",
+	} {
+		if got := snapshotRepo(container); got != want {
+			t.Errorf("%s: got %s, want %s", container, got, want)
+		}
+	}
+}
+
+func TestCreateSnapshot(t *testing.T) {
+	var mu sync.Mutex
+	var query url.Values
+	var body []byte
+	daemon := clienttest.NewDaemon(t, func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/containers/abc/json":
+			_, _ = w.Write([]byte(`{"Id":"abc123","Name":"/Shop_Web"}`))
+		case "/commit":
+			mu.Lock()
+			query = r.URL.Query()
+			body, _ = io.ReadAll(r.Body)
+			mu.Unlock()
+			_, _ = w.Write([]byte(`{"Id":"sha256:snap"}`))
+		default:
+			http.Error(w, `{"message":"No such container"}`, http.StatusNotFound)
+		}
+	})
+	h := &Handler{Docker: client.NewDockerClient(daemon.Sock())}
+
+	tests := []struct {
+		name       string
+		id         string
+		body       string
+		wantStatus int
+		wantTag    string
+	}{
+		{name: "tagged", id: "abc", body: `{"Tag":"before-upgrade","Message":"pre 2.0"}`, wantStatus: http.StatusCreated, wantTag: "before-upgrade"},
+		{name: "no body", id: "abc", wantStatus: http.StatusCreated},
+		{name: "invalid tag", id: "abc", body: `{"Tag":"no spaces"}`, wantStatus: http.StatusBadRequest},
+		{name: "unknown field", id: "abc", body: `{"Repo":"other"}`, wantStatus: http.StatusBadRequest},
+		{name: "missing container", id: "missing", wantStatus: http.StatusNotFound},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			request := mux.SetURLVars(httptest.NewRequest(http.MethodPost, "/containers/"+test.id+"/snapshots", strings.NewReader(test.body)), map[string]string{"id": test.id})
+			recorder := httptest.NewRecorder()
+			utils.MakeHttpHandleFunc(h.handleCreateSnapshot)(recorder, request)
+
+			if recorder.Code != test.wantStatus {
+				t.Fatalf("status %d: %s", recorder.Code, recorder.Body)
+			}
+			if test.wantStatus != http.StatusCreated {
+				return
+			}
+
+			snapshot := types.Snapshot{}
+			if err := json.Unmarshal(recorder.Body.Bytes(), &snapshot); err != nil {
+				t.Fatal(err)
+			}
+			mu.Lock()
+			defer mu.Unlock()
+			tag := query.Get("tag")
+			if test.wantTag != "" && tag != test.wantTag || tag == "" {
+				t.Errorf("tag %q", tag)
+			}
+			if query.Get("container") != "abc123" || query.Get("repo") != "snapshots/shop_web" {
+				t.Errorf("commit query: %v", query)
+			}
+			if want := []string{"snapshots/shop_web:" + tag}; snapshot.Id != "sha256:snap" || snapshot.Container != "Shop_Web" || !reflect.DeepEqual(snapshot.RepoTags, want) {
+				t.Errorf("snapshot: %+v", snapshot)
+			}
+
+			commit := struct{ Labels map[string]string }{}
+			if err := json.Unmarshal(body, &commit); err != nil {
+				t.Fatal(err)
+			}
+			if commit.Labels[config.SnapshotContainerLabel] != "Shop_Web" || commit.Labels[config.SnapshotContainerIdLabel] != "abc123" || commit.Labels[config.SnapshotCreatedLabel] != snapshot.Created {
+				t.Errorf("labels: %v", commit.Labels)
+			}
+		})
+	}
+}
+
+func TestListSnapshots(t *testing.T) {
+	var mu sync.Mutex
+	var filters string
+	daemon := clienttest.NewDaemon(t, func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/containers/web/json":
+			_, _ = w.Write([]byte(`{"Id":"abc123","Name":"/web"}`))
+		case "/images/json":
+			mu.Lock()
+			filters = r.URL.Query().Get("filters")
+			mu.Unlock()
+			_, _ = w.Write([]byte(`[
+				{"Id":"old","Labels":{"com.docker-api.snapshot.created":"2024-01-01T00:00:00Z"}},
+				{"Id":"new","Labels":{"com.docker-api.snapshot.created":"2024-03-01T00:00:00Z"}},
+				{"Id":"mid","Labels":{"com.docker-api.snapshot.created":"2024-02-01T00:00:00Z"}}
+			]`))
+		default:
+			http.Error(w, `{"message":"No such container"}`, http.StatusNotFound)
+		}
+	})
+	h := &Handler{Docker: client.NewDockerClient(daemon.Sock())}
+
+	// A removed container's snapshots are found by the name it had
+	for id, wantName := range map[string]string{"web": "web", "removed": "removed"} {
+		request := mux.SetURLVars(httptest.NewRequest(http.MethodGet, "/containers/"+id+"/snapshots", nil), map[string]string{"id": id})
+		recorder := httptest.NewRecorder()
+		utils.MakeHttpHandleFunc(h.handleListSnapshots)(recorder, request)
+
+		if recorder.Code != http.StatusOK {
+			t.Fatalf("%s: status %d: %s", id, recorder.Code, recorder.Body)
+		}
+		snapshots := []types.Snapshot{}
+		_ = json.Unmarshal(recorder.Body.Bytes(), &snapshots)
+		got := []string{}
+		for _, snapshot := range snapshots {
+			got = append(got, snapshot.Id)
+		}
+		if want := []string{"new", "mid", "old"}; !reflect.DeepEqual(got, want) {
+			t.Errorf("%s: got %q, want %q", id, got, want)
+		}
+		mu.Lock()
+		if !strings.Contains(filters, config.SnapshotContainerLabel+"="+wantName) {
+			t.Errorf("%s: filters %s", id, filters)
+		}
+		mu.Unlock()
+	}
+}
+
+func TestRestoreSnapshot(t *testing.T) {
+	daemon := clienttest.NewDaemon(t, func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/containers/web/json":
+			_, _ = w.Write([]byte(`{"Id":"web","Name":"/web","Config":{"Image":"nginx"},"HostConfig":{}}`))
+		case "/images/sha256:other/json":
+			_, _ = w.Write([]byte(`{"Id":"sha256:other","Config":{"Labels":{"com.docker-api.snapshot.container":"api"}}}`))
+		default:
+			http.Error(w, `{"message":"No such image"}`, http.StatusNotFound)
+		}
+	})
+	h := &Handler{Docker: client.NewDockerClient(daemon.Sock())}
+
+	for snapshot, wantStatus := range map[string]int{"sha256:other": http.StatusNotFound, "sha256:missing": http.StatusNotFound} {
+		request := httptest.NewRequest(http.MethodPost, "/containers/web/snapshots/"+snapshot+"/restore", nil)
+		recorder := httptest.NewRecorder()
+		utils.MakeHttpHandleFunc(h.handleRestoreSnapshot)(recorder, mux.SetURLVars(request, map[string]string{"id": "web", "snapshot": snapshot}))
+
+		if recorder.Code != wantStatus {
+			t.Errorf("%s: status %d: %s", snapshot, recorder.Code, recorder.Body)
+		}
+	}
+	for _, call := range daemon.Calls() {
+		if strings.HasPrefix(call, "POST") || strings.HasPrefix(call, "DELETE") {
+			t.Errorf("the container was touched: %s", call)
+		}
+	}
+}
@@ -0,0 +1,224 @@
+package deployment
+
+import (
+	"context"
+	"fmt"
+	. "github.com/LysetsDal/docker-api/client"
+	. "github.com/LysetsDal/docker-api/types"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	statusUpdated    = "updated"
+	statusSkipped    = "skipped"
+	statusRolledBack = "rolled-back"
+	statusFailed     = "failed"
+)
+
+// rollout Settings for one rolling update, resolved from the request
+type rollout struct {
+	image         string
+	tag           string
+	batchSize     int
+	pause         time.Duration
+	healthTimeout time.Duration
+	stopParams    StopParams
+
+	// Image reference to image id, so each new image is pulled once
+	pulledMu sync.Mutex
+	pulled   map[string]string
+}
+
+// run Update the containers batch by batch. A failed or rolled back batch stops the rollout;
+// batches that already completed keep the new image.
+func (h *Handler) run(ctx context.Context, plan *rollout, containers []Container) ([]ContainerUpdate, string) {
+	updates := make([]ContainerUpdate, 0, len(containers))
+
+	for start := 0; start < len(containers); start += plan.batchSize {
+		end := min(start+plan.batchSize, len(containers))
+		batch := make([]ContainerUpdate, end-start)
+
+		wg := sync.WaitGroup{}
+		for i, container := range containers[start:end] {
+			wg.Add(1)
+			go func(i int, id string) {
+				defer wg.Done()
+				batch[i] = h.updateContainer(ctx, plan, id)
+			}(i, container.Id)
+		}
+		wg.Wait()
+		updates = append(updates, batch...)
+
+		for _, update := range batch {
+			if update.Status == statusRolledBack || update.Status == statusFailed {
+				return updates, update.Status
+			}
+		}
+
+		if end < len(containers) && plan.pause > 0 {
+			select {
+			case <-ctx.Done():
+				return updates, statusFailed
+			case <-time.After(plan.pause):
+			}
+		}
+	}
+
+	return updates, "completed"
+}
+
+// updateContainer Replace one container with a copy on the new image.
+// Containers publishing fixed host ports or with static IPs are stopped before the copy starts, since both
+// can't hold the address. Stopping an auto-removed container deletes it, so those keep running until the
+// copy holds their name, and ones that also hold fixed addresses are refused.
+func (h *Handler) updateContainer(ctx context.Context, plan *rollout, id string) ContainerUpdate {
+	started := time.Now()
+	update := ContainerUpdate{OldId: id}
+	finish := func(status string, err error) ContainerUpdate {
+		update.Status = status
+		if err != nil {
+			update.Error = err.Error()
+		}
+		update.Duration = time.Since(started).Round(time.Millisecond).String()
+		return update
+	}
+
+	old, err := h.Docker.InspectContainer(ctx, id)
+	if err != nil {
+		return finish(statusFailed, err)
+	}
+	name := strings.TrimPrefix(old.Name, "/")
+	update.Name = name
+	update.OldImage = old.Config.Image
+
+	image := plan.image
+	if image == "" {
+		repo, _ := SplitImageRef(old.Config.Image)
+		image = repo + ":" + plan.tag
+	}
+	update.NewImage = image
+
+	imageId, err := plan.pull(ctx, h.Docker, image)
+	if err != nil {
+		return finish(statusFailed, err)
+	}
+	if old.Image == imageId {
+		return finish(statusSkipped, nil)
+	}
+	exclusiveAddresses := old.ExclusiveAddresses()
+	if old.HostConfig.AutoRemove && exclusiveAddresses {
+		return finish(statusFailed, Conflict("%s is auto-removed and holds fixed host ports or static IPs, it would be deleted when stopped to free them", name))
+	}
+
+	payload := old.ReplacementPayload()
+	payload.Image = image
+	if !exclusiveAddresses {
+		// The replacement runs next to the old container, so it can't take over its MAC addresses
+		payload.ClearAddresses()
+	}
+
+	created, err := h.Docker.CreateContainer(ctx, fmt.Sprintf("%s-update-%.12s", name, id), payload)
+	if err != nil {
+		return finish(statusFailed, fmt.Errorf("creating replacement: %w", err))
+	}
+	update.NewId = created.Id
+
+	if exclusiveAddresses {
+		if err := h.Docker.StopContainer(ctx, id, plan.stopParams); err != nil {
+			h.discard(created.Id)
+			return finish(statusFailed, fmt.Errorf("stopping old container: %w", err))
+		}
+	}
+
+	err = h.Docker.StartContainer(ctx, created.Id)
+	if err == nil {
+		err = h.Docker.WaitHealthy(ctx, created.Id, plan.healthTimeout)
+	}
+	if err != nil {
+		h.discard(created.Id)
+		if exclusiveAddresses {
+			if restartErr := h.Docker.StartContainer(context.Background(), id); restartErr != nil {
+				err = fmt.Errorf("%w; restarting old container: %s", err, restartErr)
+			}
+		}
+		return finish(statusRolledBack, err)
+	}
+
+	// The old container is renamed out of the way and only removed once the replacement holds its
+	// name, so a failure in between can still put the old one back
+	renamed := false
+	rollBack := func(err error) ContainerUpdate {
+		h.discard(created.Id)
+		if renamed {
+			if renameErr := h.Docker.RenameContainer(context.Background(), id, name); renameErr != nil {
+				err = fmt.Errorf("%w; renaming old container back: %s", err, renameErr)
+			}
+		}
+		// A stopped auto-removed container is gone, one that failed to stop is still running
+		if old.State.Running && !old.HostConfig.AutoRemove {
+			if restartErr := h.Docker.StartContainer(context.Background(), id); restartErr != nil {
+				err = fmt.Errorf("%w; restarting old container: %s", err, restartErr)
+			}
+		}
+		return finish(statusRolledBack, err)
+	}
+
+	stop := func() error {
+		if err := h.Docker.StopContainer(ctx, id, plan.stopParams); err != nil && !IsNotFound(err) {
+			return fmt.Errorf("stopping old container: %w", err)
+		}
+		return nil
+	}
+
+	if !old.HostConfig.AutoRemove {
+		if err := stop(); err != nil {
+			return rollBack(err)
+		}
+	}
+	if err := h.Docker.RenameContainer(ctx, id, fmt.Sprintf("%s-replaced-%.12s", name, id)); err != nil {
+		return rollBack(fmt.Errorf("renaming old container: %w", err))
+	}
+	renamed = true
+	if err := h.Docker.RenameContainer(ctx, created.Id, name); err != nil {
+		return rollBack(fmt.Errorf("renaming replacement: %w", err))
+	}
+	// The daemon deletes an auto-removed container once it stops
+	if old.HostConfig.AutoRemove {
+		if err := stop(); err != nil {
+			return rollBack(err)
+		}
+		return finish(statusUpdated, nil)
+	}
+	if err := h.Docker.RemoveContainer(ctx, id, true, false); err != nil && !IsNotFound(err) {
+		return rollBack(fmt.Errorf("removing old container: %w", err))
+	}
+
+	return finish(statusUpdated, nil)
+}
+
+// pull Pull the image once per rollout and return its id
+func (r *rollout) pull(ctx context.Context, docker *DockerClient, image string) (string, error) {
+	r.pulledMu.Lock()
+	defer r.pulledMu.Unlock()
+
+	if id, ok := r.pulled[image]; ok {
+		return id, nil
+	}
+	if err := docker.PullImage(ctx, image, nil); err != nil {
+		return "", fmt.Errorf("pulling %s: %w", image, err)
+	}
+	inspect, err := docker.InspectImage(ctx, image)
+	if err != nil {
+		return "", err
+	}
+
+	r.pulled[image] = inspect.Id
+	return inspect.Id, nil
+}
+
+// discard Remove a replacement that didn't work out. Runs even if the request was cancelled.
+func (h *Handler) discard(id string) {
+	_ = h.Docker.RemoveContainer(context.Background(), id, true, false)
+}
@@ -0,0 +1,193 @@
+package deployment
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/LysetsDal/docker-api/client"
+	"github.com/LysetsDal/docker-api/client/clienttest"
+)
+
+func TestUpdateContainer(t *testing.T) {
+	// The calls every update makes before the swap
+	prepare := []string{
+		"GET /containers/old/json", "POST /images/create", "GET /images/nginx:2/json",
+		"POST /containers/create", "POST /containers/new/start", "GET /containers/new/json",
+	}
+
+	tests := []struct {
+		name       string
+		fail       string
+		autoRemove bool
+		ports      bool
+		staticIp   bool
+		wantStatus string
+		wantSwap   []string
+		wantCalls  []string
+	}{
+		{
+			name:       "updated",
+			wantStatus: statusUpdated,
+			wantSwap: []string{
+				"POST /containers/old/stop", "POST /containers/old/rename web-replaced-old",
+				"POST /containers/new/rename web", "DELETE /containers/old",
+			},
+		},
+		{
+			name:       "stop fails",
+			fail:       "POST /containers/old/stop",
+			wantStatus: statusRolledBack,
+			wantSwap: []string{
+				"POST /containers/old/stop",
+				"DELETE /containers/new", "POST /containers/old/start",
+			},
+		},
+		{
+			name:       "renaming the replacement fails",
+			fail:       "POST /containers/new/rename",
+			wantStatus: statusRolledBack,
+			wantSwap: []string{
+				"POST /containers/old/stop", "POST /containers/old/rename web-replaced-old", "POST /containers/new/rename web",
+				"DELETE /containers/new", "POST /containers/old/rename web", "POST /containers/old/start",
+			},
+		},
+		{
+			name:       "removing the old container fails",
+			fail:       "DELETE /containers/old",
+			wantStatus: statusRolledBack,
+			wantSwap: []string{
+				"POST /containers/old/stop", "POST /containers/old/rename web-replaced-old",
+				"POST /containers/new/rename web", "DELETE /containers/old",
+				"DELETE /containers/new", "POST /containers/old/rename web", "POST /containers/old/start",
+			},
+		},
+		{
+			name:       "auto-removed",
+			autoRemove: true,
+			wantStatus: statusUpdated,
+			wantSwap: []string{
+				"POST /containers/old/rename web-replaced-old", "POST /containers/new/rename web",
+				"POST /containers/old/stop",
+			},
+		},
+		{
+			name:       "auto-removed, renaming the replacement fails",
+			autoRemove: true,
+			fail:       "POST /containers/new/rename",
+			wantStatus: statusRolledBack,
+			wantSwap: []string{
+				"POST /containers/old/rename web-replaced-old", "POST /containers/new/rename web",
+				"DELETE /containers/new", "POST /containers/old/rename web",
+			},
+		},
+		{
+			name:       "auto-removed with fixed ports",
+			autoRemove: true,
+			ports:      true,
+			wantStatus: statusFailed,
+			wantCalls:  []string{"GET /containers/old/json", "POST /images/create", "GET /images/nginx:2/json"},
+		},
+		{
+			name:       "static IP",
+			staticIp:   true,
+			wantStatus: statusUpdated,
+			wantCalls: []string{
+				"GET /containers/old/json", "POST /images/create", "GET /images/nginx:2/json",
+				"POST /containers/create", "POST /containers/old/stop", "POST /containers/new/start", "GET /containers/new/json",
+				"POST /containers/old/stop", "POST /containers/old/rename web-replaced-old",
+				"POST /containers/new/rename web", "DELETE /containers/old",
+			},
+		},
+		{
+			name:       "auto-removed with a static IP",
+			autoRemove: true,
+			staticIp:   true,
+			wantStatus: statusFailed,
+			wantCalls:  []string{"GET /containers/old/json", "POST /images/create", "GET /images/nginx:2/json"},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var mu sync.Mutex
+			var calls []string
+			var created string
+			daemon := clienttest.NewDaemon(t, func(w http.ResponseWriter, r *http.Request) {
+				call := r.Method + " " + r.URL.Path
+				mu.Lock()
+				if name := r.URL.Query().Get("name"); strings.HasSuffix(r.URL.Path, "/rename") {
+					calls = append(calls, call+" "+name)
+				} else {
+					calls = append(calls, call)
+				}
+				mu.Unlock()
+
+				switch {
+				case call == test.fail:
+					http.Error(w, `{"message":"boom"}`, http.StatusInternalServerError)
+				case call == "GET /containers/old/json":
+					hostConfig := fmt.Sprintf(`{"AutoRemove":%t}`, test.autoRemove)
+					if test.ports {
+						hostConfig = fmt.Sprintf(`{"AutoRemove":%t,"PortBindings":{"80/tcp":[{"HostPort":"8080"}]}}`, test.autoRemove)
+					}
+					ipam := "null"
+					if test.staticIp {
+						ipam = `{"IPv4Address":"172.20.0.10"}`
+					}
+					networks := fmt.Sprintf(`{"app":{"MacAddress":"02:42:ac:14:00:0a","IPAMConfig":%s}}`, ipam)
+					_, _ = fmt.Fprintf(w, `{"Id":"old","Name":"/web","Image":"sha:1","State":{"Status":"running","Running":true},"Config":{"Image":"nginx:1"},"HostConfig":%s,"NetworkSettings":{"Networks":%s}}`, hostConfig, networks)
+				case call == "DELETE /containers/old" && test.autoRemove:
+					t.Error("the daemon already removes a stopped auto-removed container")
+				case call == "GET /containers/new/json":
+					_, _ = w.Write([]byte(`{"Id":"new","State":{"Status":"running","Running":true}}`))
+				case call == "GET /images/nginx:2/json":
+					_, _ = w.Write([]byte(`{"Id":"sha:2"}`))
+				case call == "POST /containers/create":
+					body, _ := io.ReadAll(r.Body)
+					mu.Lock()
+					created = string(body)
+					mu.Unlock()
+					_, _ = w.Write([]byte(`{"Id":"new"}`))
+				case call == "POST /images/create":
+					_, _ = w.Write([]byte(`{"status":"done"}`))
+				default:
+					w.WriteHeader(http.StatusNoContent)
+				}
+			})
+
+			h := &Handler{Docker: client.NewDockerClient(daemon.Sock())}
+			plan := &rollout{image: "nginx:2", batchSize: 1, healthTimeout: time.Second, pulled: map[string]string{}}
+
+			update := h.updateContainer(context.Background(), plan, "old")
+			if update.Status != test.wantStatus {
+				t.Errorf("status: got %s (%s), want %s", update.Status, update.Error, test.wantStatus)
+			}
+			if update.NewId != "new" && test.wantCalls == nil {
+				t.Errorf("the replacement isn't reported: %+v", update)
+			}
+
+			want := test.wantCalls
+			if want == nil {
+				want = append(append([]string(nil), prepare...), test.wantSwap...)
+			}
+			if !reflect.DeepEqual(calls, want) {
+				t.Errorf("calls:\n got %q\nwant %q", calls, want)
+			}
+
+			// Only a replacement that starts after the old container stopped takes over its addresses
+			if created != "" && strings.Contains(created, "02:42:ac:14:00:0a") != test.staticIp {
+				t.Errorf("MAC address carried over: %v", !test.staticIp)
+			}
+			if test.staticIp && created != "" && !strings.Contains(created, `"IPv4Address":"172.20.0.10"`) {
+				t.Errorf("the static IP was dropped: %s", created)
+			}
+		})
+	}
+}
@@ -0,0 +1,100 @@
+package deployment
+
+import (
+	"fmt"
+	. "github.com/LysetsDal/docker-api/client"
+	"github.com/LysetsDal/docker-api/service/operation"
+	. "github.com/LysetsDal/docker-api/types"
+	. "github.com/LysetsDal/docker-api/utils"
+	"github.com/gorilla/mux"
+	"net/http"
+	"time"
+)
+
+const defaultHealthTimeout = time.Minute
+
+type Handler struct {
+	Docker     *DockerClient
+	Operations *operation.Operations
+}
+
+func NewHandler(sock http.Client, operations *operation.Operations) *Handler {
+	return &Handler{
+		Docker:     NewDockerClient(sock),
+		Operations: operations,
+	}
+}
+
+// RegisterRoutes Deployment controller
+func (h *Handler) RegisterRoutes(router *mux.Router) {
+	router.Handle("/deployments/{label}/update", h.Operations.Async("deployment.update", MakeHttpHandleFunc(h.handleRollingUpdate))).Methods(http.MethodPost)
+}
+
+// handleRollingUpdate
+// Replace the running containers carrying {label} ("key" or "key=value") one batch at a time with copies
+// on the new image. A replacement that doesn't become healthy is rolled back and ends the rollout.
+func (h *Handler) handleRollingUpdate(w http.ResponseWriter, r *http.Request) error {
+	label := mux.Vars(r)["label"]
+
+	request := DeploymentUpdateRequest{}
+	if err := ParseJson(r, &request); err != nil {
+		return BadRequest("invalid update request: %w", err)
+	}
+
+	plan, err := newRollout(request)
+	if err != nil {
+		return err
+	}
+
+	containers, err := h.Docker.ListContainers(r.Context(), false, map[string][]string{"label": {label}})
+	if err != nil {
+		return WriteError(w, err)
+	}
+	if len(containers) == 0 {
+		return WriteProblem(w, http.StatusNotFound, fmt.Sprintf("No running containers with label %s", label))
+	}
+
+	updates, status := h.run(r.Context(), plan, containers)
+	result := DeploymentUpdateResult{Label: label, Status: status, Containers: updates}
+	if status != "completed" {
+		result.Error = "rollout stopped after a replacement " + status
+		return WriteJson(w, http.StatusConflict, result)
+	}
+
+	return WriteJson(w, http.StatusOK, result)
+}
+
+func newRollout(request DeploymentUpdateRequest) (*rollout, error) {
+	plan := &rollout{
+		image:         request.Image,
+		tag:           request.Tag,
+		batchSize:     request.BatchSize,
+		healthTimeout: defaultHealthTimeout,
+		stopParams:    StopParams{T: request.StopTimeout},
+		pulled:        map[string]string{},
+	}
+
+	if (plan.image == "") == (plan.tag == "") {
+		return nil, BadRequest("exactly one of Image or Tag is required")
+	}
+	if plan.batchSize == 0 {
+		plan.batchSize = 1
+	}
+	if plan.batchSize < 0 {
+		return nil, BadRequest("BatchSize must be positive")
+	}
+
+	var err error
+	if request.Pause != "" {
+		if plan.pause, err = time.ParseDuration(request.Pause); err != nil {
+			return nil, BadRequest("invalid Pause %q", request.Pause)
+		}
+	}
+	if request.HealthTimeout != "" {
+		if plan.healthTimeout, err = time.ParseDuration(request.HealthTimeout); err != nil {
+			return nil, BadRequest("invalid HealthTimeout %q", request.HealthTimeout)
+		}
+	}
+
+	return plan, nil
+}
@@ -0,0 +1,447 @@
+package desired
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	. "github.com/LysetsDal/docker-api/client"
+	. "github.com/LysetsDal/docker-api/config"
+	. "github.com/LysetsDal/docker-api/types"
+	. "github.com/LysetsDal/docker-api/utils"
+	"log/slog"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const maxHistory = 200
+
+// Controller Compares the stored desired specs with the labelled containers and corrects the difference
+type Controller struct {
+	Docker *DockerClient
+	Specs  *JsonStore[DesiredSpec]
+
+	reconcileMu sync.Mutex
+	trigger     chan struct{}
+
+	historyMu sync.Mutex
+	history   []ReconcileAction
+	lastSync  time.Time
+
+	// Restarts per replica, so a container that keeps dying isn't restarted in a tight loop
+	backoffMu sync.Mutex
+	backoff   map[string]*restartBackoff
+}
+
+// restartBackoff Consecutive starts of one replica and when the next one may happen
+type restartBackoff struct {
+	attempts int
+	retryAt  time.Time
+}
+
+func NewController(docker *DockerClient, specs *JsonStore[DesiredSpec]) *Controller {
+	return &Controller{
+		Docker:  docker,
+		Specs:   specs,
+		trigger: make(chan struct{}, 1),
+		backoff: map[string]*restartBackoff{},
+	}
+}
+
+// Run Reconcile every ReconcileInterval, when a managed container dies or is removed, and when triggered
+func (c *Controller) Run(ctx context.Context) {
+	ticker := time.NewTicker(ReconcileInterval)
+	defer ticker.Stop()
+
+	events := c.subscribe(ctx)
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if events == nil {
+				events = c.subscribe(ctx)
+			}
+		case <-c.trigger:
+		case _, ok := <-events:
+			if !ok {
+				events = nil
+				continue
+			}
+		}
+
+		if _, err := c.Reconcile(ctx); err != nil {
+			slog.WarnContext(ctx, "reconcile failed", "error", err)
+		}
+	}
+}
+
+// Trigger Ask Run for a reconcile without waiting for the next tick
+func (c *Controller) Trigger() {
+	select {
+	case c.trigger <- struct{}{}:
+	default:
+	}
+}
+
+// subscribe Listen for managed containers going away. Returns nil if the daemon is unreachable;
+// Run retries on the next tick.
+func (c *Controller) subscribe(ctx context.Context) <-chan DockerEvent {
+	events, err := c.Docker.Events(ctx, map[string][]string{
+		"type":  {"container"},
+		"label": {DesiredLabel},
+		"event": {"die", "destroy", "oom"},
+	})
+	if err != nil {
+		slog.WarnContext(ctx, "reconcile: subscribing to docker events", "error", err)
+		return nil
+	}
+	return events
+}
+
+// Status Drift report per spec, without changing anything
+func (c *Controller) Status(ctx context.Context) ([]DesiredStatus, error) {
+	actions, running, err := c.plan(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	c.historyMu.Lock()
+	lastSync := ""
+	if !c.lastSync.IsZero() {
+		lastSync = c.lastSync.Format(time.RFC3339)
+	}
+	c.historyMu.Unlock()
+
+	names := make([]string, 0)
+	bySpec := map[string]*DesiredStatus{}
+	for _, spec := range c.Specs.List() {
+		names = append(names, spec.Name)
+		bySpec[spec.Name] = &DesiredStatus{
+			Spec:     spec.Name,
+			Desired:  spec.Replicas,
+			Running:  running[spec.Name],
+			Drift:    []ReconcileAction{},
+			LastSync: lastSync,
+		}
+	}
+
+	for _, action := range actions {
+		status, ok := bySpec[action.Spec]
+		if !ok {
+			// Containers of deleted specs are reported under their old spec name
+			status = &DesiredStatus{Spec: action.Spec, Drift: []ReconcileAction{}, LastSync: lastSync}
+			names = append(names, action.Spec)
+			bySpec[action.Spec] = status
+		}
+		status.Drift = append(status.Drift, action)
+	}
+
+	statuses := make([]DesiredStatus, 0, len(names))
+	for _, name := range names {
+		status := bySpec[name]
+		status.InSync = len(status.Drift) == 0
+		statuses = append(statuses, *status)
+	}
+	return statuses, nil
+}
+
+// History Most recent executed actions, newest last
+func (c *Controller) History() []ReconcileAction {
+	c.historyMu.Lock()
+	defer c.historyMu.Unlock()
+
+	history := make([]ReconcileAction, len(c.history))
+	copy(history, c.history)
+	return history
+}
+
+// Reconcile Plan and execute the corrective actions. Individual failures are recorded on the action.
+// Starting or creating a replica again is delayed with an exponential backoff; delayed actions are
+// returned with RetryAt set but not executed or recorded, and Run triggers a reconcile when they are due.
+func (c *Controller) Reconcile(ctx context.Context) ([]ReconcileAction, error) {
+	c.reconcileMu.Lock()
+	defer c.reconcileMu.Unlock()
+
+	actions, _, err := c.plan(ctx)
+	if err != nil {
+		return nil, err
+	}
+	c.resetBackoff(actions)
+
+	executed := make([]ReconcileAction, 0, len(actions))
+	for i := range actions {
+		if delay, deferred := c.backOff(&actions[i]); deferred {
+			time.AfterFunc(delay, c.Trigger)
+			continue
+		}
+		if err := c.execute(ctx, actions[i]); err != nil {
+			actions[i].Error = err.Error()
+			slog.WarnContext(ctx, "reconcile action failed", "action", actions[i].Action, "spec", actions[i].Spec, "replica", actions[i].Replica, "error", err)
+		} else {
+			slog.InfoContext(ctx, "reconcile action", "action", actions[i].Action, "spec", actions[i].Spec, "replica", actions[i].Replica, "reason", actions[i].Reason)
+		}
+		actions[i].Time = time.Now().Format(time.RFC3339)
+		executed = append(executed, actions[i])
+	}
+
+	c.historyMu.Lock()
+	c.lastSync = time.Now()
+	c.history = append(c.history, executed...)
+	if len(c.history) > maxHistory {
+		c.history = c.history[len(c.history)-maxHistory:]
+	}
+	c.historyMu.Unlock()
+
+	return actions, nil
+}
+
+// plan Work out what has to change, and how many replicas per spec are running
+func (c *Controller) plan(ctx context.Context) ([]ReconcileAction, map[string]int, error) {
+	containers, err := c.Docker.ListContainers(ctx, true, map[string][]string{"label": {DesiredLabel}})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	bySpec := map[string][]Container{}
+	for _, container := range containers {
+		name := container.Labels[DesiredLabel]
+		bySpec[name] = append(bySpec[name], container)
+	}
+
+	actions := make([]ReconcileAction, 0)
+	running := map[string]int{}
+
+	for _, spec := range c.Specs.List() {
+		hash := specHash(spec)
+
+		byReplica := map[int][]Container{}
+		for _, container := range bySpec[spec.Name] {
+			label := container.Labels[DesiredReplicaLabel]
+			replica, err := strconv.Atoi(label)
+			reason := ""
+			switch {
+			case err != nil:
+				reason = fmt.Sprintf("replica label %q is not a number", label)
+			case replica < 1:
+				reason = fmt.Sprintf("replica %d is not a valid replica number", replica)
+			case replica > spec.Replicas:
+				reason = fmt.Sprintf("replica exceeds desired count %d", spec.Replicas)
+			}
+			if reason != "" {
+				actions = append(actions, ReconcileAction{
+					Spec: spec.Name, Action: "remove", Replica: replica, ContainerId: container.Id, Reason: reason,
+				})
+				continue
+			}
+			byReplica[replica] = append(byReplica[replica], container)
+		}
+
+		for replica := 1; replica <= spec.Replicas; replica++ {
+			candidates := byReplica[replica]
+			if len(candidates) == 0 {
+				actions = append(actions, ReconcileAction{Spec: spec.Name, Action: "create", Replica: replica, Reason: "missing"})
+				continue
+			}
+
+			container := candidates[0]
+			for _, duplicate := range candidates[1:] {
+				actions = append(actions, ReconcileAction{
+					Spec: spec.Name, Action: "remove", Replica: replica, ContainerId: duplicate.Id, Reason: "duplicate replica",
+				})
+			}
+
+			switch {
+			case container.Labels[DesiredHashLabel] != hash:
+				actions = append(actions, ReconcileAction{
+					Spec: spec.Name, Action: "replace", Replica: replica, ContainerId: container.Id, Reason: "config drifted",
+				})
+			case container.Image != spec.Image:
+				actions = append(actions, ReconcileAction{
+					Spec: spec.Name, Action: "replace", Replica: replica, ContainerId: container.Id,
+					Reason: fmt.Sprintf("image drifted: running %s, want %s", container.Image, spec.Image),
+				})
+			case container.State != "running":
+				actions = append(actions, ReconcileAction{
+					Spec: spec.Name, Action: "start", Replica: replica, ContainerId: container.Id,
+					Reason: fmt.Sprintf("container is %s", container.State),
+				})
+			default:
+				running[spec.Name]++
+			}
+		}
+		delete(bySpec, spec.Name)
+	}
+
+	// Whatever is left belongs to specs that no longer exist
+	orphans := make([]string, 0, len(bySpec))
+	for name := range bySpec {
+		orphans = append(orphans, name)
+	}
+	sort.Strings(orphans)
+	for _, name := range orphans {
+		for _, container := range bySpec[name] {
+			replica, _ := strconv.Atoi(container.Labels[DesiredReplicaLabel])
+			actions = append(actions, ReconcileAction{
+				Spec: name, Action: "remove", Replica: replica, ContainerId: container.Id, Reason: "no desired spec",
+			})
+		}
+	}
+
+	return actions, running, nil
+}
+
+// backOff Whether a start or create has to wait for the replica's backoff, and for how long. Otherwise
+// the attempt is counted and the action records it and when the next one may happen.
+func (c *Controller) backOff(action *ReconcileAction) (time.Duration, bool) {
+	if action.Action != "start" && action.Action != "create" {
+		return 0, false
+	}
+
+	c.backoffMu.Lock()
+	defer c.backoffMu.Unlock()
+
+	key := backoffKey(action.Spec, action.Replica)
+	backoff, ok := c.backoff[key]
+	if !ok {
+		backoff = &restartBackoff{}
+		c.backoff[key] = backoff
+	}
+
+	now := time.Now()
+	if now.Before(backoff.retryAt) {
+		action.Attempt = backoff.attempts
+		action.RetryAt = backoff.retryAt.Format(time.RFC3339)
+		return backoff.retryAt.Sub(now), true
+	}
+
+	backoff.attempts++
+	delay := ReconcileBackoff
+	for i := 1; i < backoff.attempts && delay < ReconcileMaxBackoff; i++ {
+		delay *= 2
+	}
+	backoff.retryAt = now.Add(min(delay, ReconcileMaxBackoff))
+
+	action.Attempt = backoff.attempts
+	action.RetryAt = backoff.retryAt.Format(time.RFC3339)
+	return 0, false
+}
+
+// resetBackoff Forget the attempts of replicas that need nothing done and have outlived their backoff
+func (c *Controller) resetBackoff(actions []ReconcileAction) {
+	pending := map[string]bool{}
+	for _, action := range actions {
+		pending[backoffKey(action.Spec, action.Replica)] = true
+	}
+
+	c.backoffMu.Lock()
+	defer c.backoffMu.Unlock()
+
+	now := time.Now()
+	for key, backoff := range c.backoff {
+		if !pending[key] && now.After(backoff.retryAt) {
+			delete(c.backoff, key)
+		}
+	}
+}
+
+func backoffKey(spec string, replica int) string {
+	return spec + "/" + strconv.Itoa(replica)
+}
+
+func (c *Controller) execute(ctx context.Context, action ReconcileAction) error {
+	switch action.Action {
+	case "start":
+		return c.Docker.StartContainer(ctx, action.ContainerId)
+
+	case "remove":
+		return c.Docker.RemoveContainer(ctx, action.ContainerId, true, false)
+
+	case "replace":
+		return c.replace(ctx, action)
+
+	case "create":
+		id, err := c.create(ctx, action, "")
+		if err != nil {
+			return err
+		}
+		return c.Docker.StartContainer(ctx, id)
+	}
+
+	return fmt.Errorf("unknown action %q", action.Action)
+}
+
+// replace Create the new container before removing the old one, so a spec that can't be created (a
+// missing image, a rejected config) leaves the old container running
+func (c *Controller) replace(ctx context.Context, action ReconcileAction) error {
+	id, err := c.create(ctx, action, fmt.Sprintf("-replace-%.12s", action.ContainerId))
+	if err != nil {
+		return err
+	}
+
+	if err := c.Docker.StopContainer(ctx, action.ContainerId, StopParams{}); err != nil && !IsNotFound(err) {
+		_ = c.Docker.RemoveContainer(context.Background(), id, true, false)
+		return err
+	}
+	if err := c.Docker.RemoveContainer(ctx, action.ContainerId, true, false); err != nil && !IsNotFound(err) {
+		_ = c.Docker.RemoveContainer(context.Background(), id, true, false)
+		_ = c.Docker.StartContainer(context.Background(), action.ContainerId)
+		return err
+	}
+
+	// The replica is stopped or missing now, so a failure from here on is picked up by the next reconcile
+	if err := c.Docker.RenameContainer(ctx, id, replicaName(action.Spec, action.Replica)); err != nil {
+		return err
+	}
+	return c.Docker.StartContainer(ctx, id)
+}
+
+// create Create the replica's container, named <spec>-<replica> followed by suffix
+func (c *Controller) create(ctx context.Context, action ReconcileAction, suffix string) (string, error) {
+	spec, ok := c.Specs.Get(action.Spec)
+	if !ok {
+		return "", fmt.Errorf("spec %s was deleted", action.Spec)
+	}
+
+	payload := specPayload(spec)
+	payload.Labels[DesiredHashLabel] = specHash(spec)
+	payload.Labels[DesiredReplicaLabel] = strconv.Itoa(action.Replica)
+
+	created, err := c.Docker.CreateContainerWithPull(ctx, replicaName(spec.Name, action.Replica)+suffix, payload)
+	if err != nil {
+		return "", err
+	}
+	return created.Id, nil
+}
+
+func replicaName(spec string, replica int) string {
+	return fmt.Sprintf("%s-%d", spec, replica)
+}
+
+// specPayload The create payload for a spec, with its image and ownership label applied
+func specPayload(spec DesiredSpec) Payload {
+	payload := spec.Config
+	payload.Image = spec.Image
+
+	labels := map[string]string{}
+	for key, value := range spec.Config.Labels {
+		if !strings.HasPrefix(key, DesiredLabel) {
+			labels[key] = value
+		}
+	}
+	labels[DesiredLabel] = spec.Name
+	payload.Labels = labels
+
+	return payload
+}
+
+// specHash Fingerprint of everything that ends up in the container, stored as a label to detect config drift
+func specHash(spec DesiredSpec) string {
+	encoded, _ := json.Marshal(specPayload(spec))
+	sum := sha256.Sum256(encoded)
+	return hex.EncodeToString(sum[:])[:16]
+}
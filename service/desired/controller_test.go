@@ -0,0 +1,332 @@
+package desired
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/LysetsDal/docker-api/client"
+	"github.com/LysetsDal/docker-api/client/clienttest"
+	"github.com/LysetsDal/docker-api/config"
+	"github.com/LysetsDal/docker-api/types"
+	"github.com/LysetsDal/docker-api/utils"
+	"github.com/gorilla/mux"
+)
+
+// fakeDaemon A Docker daemon whose container list is containers
+func fakeDaemon(t *testing.T, containers []types.Container) *client.DockerClient {
+	daemon := clienttest.NewDaemon(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/containers/json" {
+			t.Errorf("unexpected call %s %s", r.Method, r.URL.Path)
+			return
+		}
+		_ = json.NewEncoder(w).Encode(containers)
+	})
+	return client.NewDockerClient(daemon.Sock())
+}
+
+func newController(t *testing.T, containers []types.Container, specs ...types.DesiredSpec) *Controller {
+	store, err := utils.NewJsonStore[types.DesiredSpec](filepath.Join(t.TempDir(), "desired.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, spec := range specs {
+		if err := store.Put(spec.Name, spec); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return NewController(fakeDaemon(t, containers), store)
+}
+
+func TestPlan(t *testing.T) {
+	web := types.DesiredSpec{Name: "web", Image: "nginx:1", Replicas: 2}
+	hash := specHash(web)
+
+	replica := func(id, spec, replica, hash, image, state string) types.Container {
+		return types.Container{Id: id, Image: image, State: state, Labels: map[string]string{
+			config.DesiredLabel: spec, config.DesiredReplicaLabel: replica, config.DesiredHashLabel: hash,
+		}}
+	}
+
+	tests := []struct {
+		name        string
+		containers  []types.Container
+		want        []string
+		wantRunning int
+	}{
+		{
+			name:       "in sync",
+			containers: []types.Container{replica("a", "web", "1", hash, "nginx:1", "running"), replica("b", "web", "2", hash, "nginx:1", "running")},
+			want:       []string{}, wantRunning: 2,
+		},
+		{
+			name: "missing",
+			want: []string{"create 1 missing", "create 2 missing"},
+		},
+		{
+			name: "drift",
+			containers: []types.Container{
+				replica("a", "web", "1", "stale", "nginx:1", "running"),
+				replica("b", "web", "2", hash, "nginx:0", "running"),
+			},
+			want: []string{"replace 1 a config drifted", "replace 2 b image drifted: running nginx:0, want nginx:1"},
+		},
+		{
+			name:       "stopped",
+			containers: []types.Container{replica("a", "web", "1", hash, "nginx:1", "exited"), replica("b", "web", "2", hash, "nginx:1", "running")},
+			want:       []string{"start 1 a container is exited"}, wantRunning: 1,
+		},
+		{
+			name: "extra, duplicate and bad replicas",
+			containers: []types.Container{
+				replica("a", "web", "1", hash, "nginx:1", "running"),
+				replica("a2", "web", "1", hash, "nginx:1", "running"),
+				replica("b", "web", "2", hash, "nginx:1", "running"),
+				replica("c", "web", "3", hash, "nginx:1", "running"),
+				replica("x", "web", "two", hash, "nginx:1", "running"),
+				replica("z", "web", "0", hash, "nginx:1", "running"),
+			},
+			want: []string{
+				"remove 3 c replica exceeds desired count 2",
+				`remove 0 x replica label "two" is not a number`,
+				"remove 0 z replica 0 is not a valid replica number",
+				"remove 1 a2 duplicate replica",
+			},
+			wantRunning: 2,
+		},
+		{
+			name:       "orphans",
+			containers: []types.Container{replica("a", "web", "1", hash, "nginx:1", "running"), replica("b", "web", "2", hash, "nginx:1", "running"), replica("o", "gone", "1", "", "x", "running")},
+			want:       []string{"remove 1 o no desired spec"}, wantRunning: 2,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			controller := newController(t, test.containers, web)
+
+			actions, running, err := controller.plan(context.Background())
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			got := []string{}
+			for _, action := range actions {
+				got = append(got, strings.Join(strings.Fields(fmt.Sprintf("%s %d %s %s", action.Action, action.Replica, action.ContainerId, action.Reason)), " "))
+			}
+			if !reflect.DeepEqual(got, test.want) {
+				t.Errorf("actions:\n got %q\nwant %q", got, test.want)
+			}
+			if running["web"] != test.wantRunning {
+				t.Errorf("running: got %d, want %d", running["web"], test.wantRunning)
+			}
+		})
+	}
+}
+
+func TestHandlePutSpecReplicas(t *testing.T) {
+	tests := []struct {
+		name string
+		body string
+		want int
+	}{
+		{name: "omitted", body: `{"Image":"nginx"}`, want: 1},
+		{name: "zero", body: `{"Image":"nginx","Replicas":0}`, want: 0},
+		{name: "three", body: `{"Image":"nginx","Replicas":3}`, want: 3},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			h := &Handler{Controller: newController(t, nil)}
+			request := httptest.NewRequest(http.MethodPut, "/desired/web", strings.NewReader(test.body))
+			recorder := httptest.NewRecorder()
+			utils.MakeHttpHandleFunc(h.handlePutSpec)(recorder, mux.SetURLVars(request, map[string]string{"name": "web"}))
+			if recorder.Code != http.StatusOK {
+				t.Fatalf("status %d: %s", recorder.Code, recorder.Body)
+			}
+
+			spec, ok := h.Controller.Specs.Get("web")
+			if !ok || spec.Replicas != test.want {
+				t.Errorf("replicas: got %d (stored %v), want %d", spec.Replicas, ok, test.want)
+			}
+		})
+	}
+}
+
+// newDaemonController A controller whose daemon lists containers() and answers the other calls with
+// handler, or 204 if it returns false
+func newDaemonController(t *testing.T, containers func() []types.Container, handler func(w http.ResponseWriter, r *http.Request) bool, specs ...types.DesiredSpec) (*Controller, *clienttest.Daemon) {
+	daemon := clienttest.NewDaemon(t, func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.URL.Path == "/containers/json":
+			_ = json.NewEncoder(w).Encode(containers())
+		case handler != nil && handler(w, r):
+		default:
+			w.WriteHeader(http.StatusNoContent)
+		}
+	})
+	controller := newController(t, nil, specs...)
+	controller.Docker = client.NewDockerClient(daemon.Sock())
+	return controller, daemon
+}
+
+func TestReconcileBackoff(t *testing.T) {
+	backoff, maxBackoff := config.ReconcileBackoff, config.ReconcileMaxBackoff
+	config.ReconcileBackoff, config.ReconcileMaxBackoff = time.Hour, 3*time.Hour
+	t.Cleanup(func() { config.ReconcileBackoff, config.ReconcileMaxBackoff = backoff, maxBackoff })
+
+	web := types.DesiredSpec{Name: "web", Image: "nginx:1", Replicas: 1}
+	state := "exited"
+	controller, daemon := newDaemonController(t, func() []types.Container {
+		return []types.Container{{Id: "a", Image: "nginx:1", State: state, Labels: map[string]string{
+			config.DesiredLabel: "web", config.DesiredReplicaLabel: "1", config.DesiredHashLabel: specHash(web),
+		}}}
+	}, nil, web)
+
+	starts := func() int {
+		count := 0
+		for _, call := range daemon.Calls() {
+			if call == "POST /containers/a/start" {
+				count++
+			}
+		}
+		return count
+	}
+	reconcile := func() types.ReconcileAction {
+		t.Helper()
+		actions, err := controller.Reconcile(context.Background())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(actions) != 1 || actions[0].Action != "start" {
+			t.Fatalf("actions: %+v", actions)
+		}
+		return actions[0]
+	}
+	retryIn := func(action types.ReconcileAction) time.Duration {
+		retryAt, err := time.Parse(time.RFC3339, action.RetryAt)
+		if err != nil {
+			t.Fatalf("RetryAt: %v", err)
+		}
+		return time.Until(retryAt).Round(time.Hour)
+	}
+
+	if action := reconcile(); action.Attempt != 1 || retryIn(action) != time.Hour || starts() != 1 {
+		t.Errorf("first start: %+v, %d starts", action, starts())
+	}
+
+	// The container died again straight away
+	if action := reconcile(); action.Attempt != 1 || starts() != 1 {
+		t.Errorf("restarted during the backoff: %+v, %d starts", action, starts())
+	}
+	if history := controller.History(); len(history) != 1 {
+		t.Errorf("a delayed start was recorded: %+v", history)
+	}
+
+	// The delay doubles per attempt, up to the maximum
+	for attempt, want := range []time.Duration{2 * time.Hour, 3 * time.Hour} {
+		controller.backoff["web/1"].retryAt = time.Now().Add(-time.Second)
+		if action := reconcile(); action.Attempt != attempt+2 || retryIn(action) != want || action.Error != "" {
+			t.Errorf("attempt %d: %+v", attempt+2, action)
+		}
+	}
+	if history := controller.History(); len(history) != 3 || history[2].Attempt != 3 {
+		t.Errorf("history: %+v", history)
+	}
+
+	// A replica that is still running once its backoff ran out starts over
+	state = "running"
+	controller.backoff["web/1"].retryAt = time.Now().Add(-time.Second)
+	if _, err := controller.Reconcile(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if len(controller.backoff) != 0 {
+		t.Errorf("the backoff wasn't reset: %+v", controller.backoff)
+	}
+}
+
+func TestReconcileReplace(t *testing.T) {
+	web := types.DesiredSpec{Name: "web", Image: "nginx:2", Replicas: 1}
+	stale := func() []types.Container {
+		return []types.Container{{Id: "a", Image: "nginx:1", State: "running", Labels: map[string]string{
+			config.DesiredLabel: "web", config.DesiredReplicaLabel: "1", config.DesiredHashLabel: "stale",
+		}}}
+	}
+
+	tests := []struct {
+		name  string
+		fail  string
+		want  []string
+		isErr bool
+	}{
+		{
+			name: "replaced",
+			want: []string{
+				"POST /containers/create web-1-replace-a", "POST /containers/a/stop", "DELETE /containers/a",
+				"POST /containers/new/rename web-1", "POST /containers/new/start",
+			},
+		},
+		{
+			name:  "create fails",
+			fail:  "POST /containers/create",
+			want:  []string{"POST /containers/create web-1-replace-a"},
+			isErr: true,
+		},
+		{
+			name: "removing the old container fails",
+			fail: "DELETE /containers/a",
+			want: []string{
+				"POST /containers/create web-1-replace-a", "POST /containers/a/stop", "DELETE /containers/a",
+				"DELETE /containers/new", "POST /containers/a/start",
+			},
+			isErr: true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var mu sync.Mutex
+			var calls []string
+			controller, _ := newDaemonController(t, stale, func(w http.ResponseWriter, r *http.Request) bool {
+				call := r.Method + " " + r.URL.Path
+				mu.Lock()
+				if name := r.URL.Query().Get("name"); name != "" {
+					calls = append(calls, call+" "+name)
+				} else {
+					calls = append(calls, call)
+				}
+				mu.Unlock()
+				switch {
+				case call == test.fail:
+					http.Error(w, `{"message":"boom"}`, http.StatusInternalServerError)
+				case call == "POST /containers/create":
+					_, _ = w.Write([]byte(`{"Id":"new"}`))
+				default:
+					return false
+				}
+				return true
+			}, web)
+
+			actions, err := controller.Reconcile(context.Background())
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(actions) != 1 || actions[0].Action != "replace" || (actions[0].Error != "") != test.isErr {
+				t.Errorf("actions: %+v", actions)
+			}
+			mu.Lock()
+			defer mu.Unlock()
+			if !reflect.DeepEqual(calls, test.want) {
+				t.Errorf("calls:\n got %q\nwant %q", calls, test.want)
+			}
+		})
+	}
+}
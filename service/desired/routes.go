@@ -0,0 +1,138 @@
+package desired
+
+import (
+	"fmt"
+	. "github.com/LysetsDal/docker-api/client"
+	. "github.com/LysetsDal/docker-api/config"
+	. "github.com/LysetsDal/docker-api/types"
+	. "github.com/LysetsDal/docker-api/utils"
+	"github.com/gorilla/mux"
+	"net/http"
+	"path/filepath"
+	"regexp"
+)
+
+var specNameRegex = regexp.MustCompile(`^[a-zA-Z0-9][a-zA-Z0-9_.-]*$`)
+
+// reservedNames Would shadow the fixed /desired/... routes
+var reservedNames = map[string]bool{"status": true, "reconcile": true, "actions": true}
+
+type Handler struct {
+	Controller *Controller
+}
+
+func NewHandler(sock http.Client) (*Handler, error) {
+	specs, err := NewJsonStore[DesiredSpec](filepath.Join(DataDir, "desired.json"))
+	if err != nil {
+		return nil, fmt.Errorf("loading desired specs: %w", err)
+	}
+
+	return &Handler{
+		Controller: NewController(NewDockerClient(sock), specs),
+	}, nil
+}
+
+// RegisterRoutes Desired-state controller
+func (h *Handler) RegisterRoutes(router *mux.Router) {
+	router.HandleFunc("/desired", MakeHttpHandleFunc(h.handleListSpecs)).Methods(http.MethodGet)
+	router.HandleFunc("/desired/status", MakeHttpHandleFunc(h.handleStatus)).Methods(http.MethodGet)
+	router.HandleFunc("/desired/actions", MakeHttpHandleFunc(h.handleActions)).Methods(http.MethodGet)
+	router.HandleFunc("/desired/reconcile", MakeHttpHandleFunc(h.handleReconcile)).Methods(http.MethodPost)
+
+	router.HandleFunc("/desired/{name}", MakeHttpHandleFunc(h.handleGetSpec)).Methods(http.MethodGet)
+	router.HandleFunc("/desired/{name}", MakeHttpHandleFunc(h.handlePutSpec)).Methods(http.MethodPut)
+	router.HandleFunc("/desired/{name}", MakeHttpHandleFunc(h.handleDeleteSpec)).Methods(http.MethodDelete)
+}
+
+// GET All desired specs
+func (h *Handler) handleListSpecs(w http.ResponseWriter, _ *http.Request) error {
+	return WriteJson(w, http.StatusOK, h.Controller.Specs.List())
+}
+
+// GET Drift between the desired specs and the actual containers
+func (h *Handler) handleStatus(w http.ResponseWriter, r *http.Request) error {
+	statuses, err := h.Controller.Status(r.Context())
+	if err != nil {
+		return WriteError(w, err)
+	}
+
+	return WriteJson(w, http.StatusOK, statuses)
+}
+
+// GET Actions taken by recent reconciles
+func (h *Handler) handleActions(w http.ResponseWriter, _ *http.Request) error {
+	return WriteJson(w, http.StatusOK, h.Controller.History())
+}
+
+// POST Reconcile now and return the actions taken
+func (h *Handler) handleReconcile(w http.ResponseWriter, r *http.Request) error {
+	actions, err := h.Controller.Reconcile(r.Context())
+	if err != nil {
+		return WriteError(w, err)
+	}
+
+	return WriteJson(w, http.StatusOK, actions)
+}
+
+func (h *Handler) handleGetSpec(w http.ResponseWriter, r *http.Request) error {
+	name := mux.Vars(r)["name"]
+
+	spec, ok := h.Controller.Specs.Get(name)
+	if !ok {
+		return WriteProblem(w, http.StatusNotFound, fmt.Sprintf("No such desired spec: %s", name))
+	}
+
+	return WriteJson(w, http.StatusOK, spec)
+}
+
+// PUT Create or replace a spec. The controller converges on it right away.
+// Replicas defaults to 1; only an explicit 0 scales the spec down to no containers.
+func (h *Handler) handlePutSpec(w http.ResponseWriter, r *http.Request) error {
+	name := mux.Vars(r)["name"]
+
+	// Decoding leaves Replicas alone when the body doesn't mention it
+	spec := DesiredSpec{Replicas: 1}
+	if err := ParseJson(r, &spec); err != nil {
+		return BadRequest("invalid desired spec: %w", err)
+	}
+	spec.Name = name
+	if spec.Image == "" {
+		spec.Image = spec.Config.Image
+	}
+
+	switch {
+	case !specNameRegex.MatchString(name) || reservedNames[name]:
+		return BadRequest("invalid spec name %q", name)
+	case spec.Image == "":
+		return BadRequest("image is required")
+	case spec.Replicas < 0:
+		return BadRequest("replicas must not be negative")
+	}
+
+	if err := ValidatePayload(specPayload(spec)); err != nil {
+		return WriteValidationError(w, "invalid desired spec config", err)
+	}
+
+	if err := h.Controller.Specs.Put(name, spec); err != nil {
+		return WriteError(w, err)
+	}
+	h.Controller.Trigger()
+
+	return WriteJson(w, http.StatusOK, spec)
+}
+
+// DELETE Forget a spec. Its containers are removed by the next reconcile.
+func (h *Handler) handleDeleteSpec(w http.ResponseWriter, r *http.Request) error {
+	name := mux.Vars(r)["name"]
+
+	deleted, err := h.Controller.Specs.Delete(name)
+	if err != nil {
+		return WriteError(w, err)
+	}
+	if !deleted {
+		return WriteProblem(w, http.StatusNotFound, fmt.Sprintf("No such desired spec: %s", name))
+	}
+	h.Controller.Trigger()
+
+	return WriteJson(w, http.StatusOK, ApiMessage{Message: fmt.Sprintf("Desired spec %s deleted", name)})
+}
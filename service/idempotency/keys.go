@@ -0,0 +1,225 @@
+package idempotency
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	. "github.com/LysetsDal/docker-api/config"
+	. "github.com/LysetsDal/docker-api/types"
+	. "github.com/LysetsDal/docker-api/utils"
+	"io"
+	"log/slog"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// KeyHeader The request header carrying the client's key
+const KeyHeader string = "Idempotency-Key"
+
+// ReplayedHeader Set on responses replayed from a stored record
+const ReplayedHeader string = "Idempotent-Replayed"
+
+const (
+	maxKeyLength    = 255
+	maxRequestBody  = 32 << 20
+	maxResponseBody = 1 << 20
+)
+
+// replayedHeaders The response headers stored with a record, the rest are per response
+var replayedHeaders = []string{"Content-Type", "Location", "Preference-Applied"}
+
+// Keys Stored responses by Idempotency-Key. A retry with the same key and request gets the stored
+// response instead of running again; the same key with a different request is rejected.
+type Keys struct {
+	records records
+
+	mu          sync.Mutex
+	inFlight    map[string]string
+	lastExpired time.Time
+}
+
+// NewKeys Records are kept in the idempotency directory under the data directory, one file per key
+func NewKeys() (*Keys, error) {
+	records := records{dir: filepath.Join(DataDir, "idempotency")}
+	if err := os.MkdirAll(records.dir, 0o700); err != nil {
+		return nil, fmt.Errorf("creating the idempotency directory: %w", err)
+	}
+
+	k := &Keys{records: records, inFlight: map[string]string{}}
+	k.expire()
+	return k, nil
+}
+
+// Wrap Honour the Idempotency-Key header on next. Requests without one are served as usual.
+// Responses with a 5xx status aren't stored, so the retry runs again.
+func (k *Keys) Wrap(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		key := r.Header.Get(KeyHeader)
+		if key == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+		if len(key) > maxKeyLength || !printable(key) {
+			_ = WriteProblem(w, http.StatusBadRequest, fmt.Sprintf("%s must be 1 to %d printable ASCII characters", KeyHeader, maxKeyLength))
+			return
+		}
+
+		body, err := io.ReadAll(io.LimitReader(r.Body, maxRequestBody+1))
+		if err != nil {
+			_ = WriteProblem(w, http.StatusBadRequest, fmt.Sprintf("reading request body: %s", err))
+			return
+		}
+		if len(body) > maxRequestBody {
+			_ = WriteProblem(w, http.StatusRequestEntityTooLarge, fmt.Sprintf("requests with an %s are limited to %d MiB bodies", KeyHeader, maxRequestBody>>20))
+			return
+		}
+		r.Body = io.NopCloser(bytes.NewReader(body))
+		fingerprint := fingerprint(r, body)
+
+		record, status := k.claim(key, fingerprint)
+		switch status {
+		case http.StatusOK:
+			replay(w, record)
+			return
+		case http.StatusConflict:
+			_ = WriteProblem(w, http.StatusConflict, fmt.Sprintf("a request with %s %s is still in progress", KeyHeader, key))
+			return
+		case http.StatusUnprocessableEntity:
+			_ = WriteProblem(w, http.StatusUnprocessableEntity, fmt.Sprintf("%s %s was already used for a different request", KeyHeader, key))
+			return
+		}
+
+		recorder := &teeWriter{ResponseWriter: w, recorder: NewResponseRecorder(maxResponseBody)}
+		defer k.release(key)
+		next.ServeHTTP(recorder, r)
+
+		if recorder.recorder.Status() >= 500 || recorder.recorder.Truncated {
+			return
+		}
+		k.store(key, fingerprint, recorder)
+	})
+}
+
+// claim Look the key up, and if it is unused mark it in flight. The status says what to do:
+// 200 replay the record, 409 the first request is still running, 422 the key belongs to another
+// request, 0 run the request.
+func (k *Keys) claim(key, fingerprint string) (IdempotencyRecord, int) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	record, ok, err := k.records.get(key)
+	if err != nil {
+		slog.Error("reading idempotency record", "key", key, "error", err)
+	}
+	if ok && time.Now().Before(record.Expires) {
+		if record.Fingerprint != fingerprint {
+			return record, http.StatusUnprocessableEntity
+		}
+		return record, http.StatusOK
+	}
+	if running, ok := k.inFlight[key]; ok {
+		if running != fingerprint {
+			return IdempotencyRecord{}, http.StatusUnprocessableEntity
+		}
+		return IdempotencyRecord{}, http.StatusConflict
+	}
+
+	k.inFlight[key] = fingerprint
+	return IdempotencyRecord{}, 0
+}
+
+func (k *Keys) release(key string) {
+	k.mu.Lock()
+	delete(k.inFlight, key)
+	k.mu.Unlock()
+}
+
+func (k *Keys) store(key, fingerprint string, response *teeWriter) {
+	now := time.Now().UTC()
+	record := IdempotencyRecord{
+		Key:         key,
+		Fingerprint: fingerprint,
+		StatusCode:  response.recorder.Status(),
+		Header:      http.Header{},
+		Body:        response.recorder.Body,
+		Created:     now,
+		Expires:     now.Add(IdempotencyTTL),
+	}
+	for _, name := range replayedHeaders {
+		if value := response.Header().Get(name); value != "" {
+			record.Header.Set(name, value)
+		}
+	}
+
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	k.expire()
+	if err := k.records.put(record); err != nil {
+		slog.Error("storing idempotency record", "key", key, "error", err)
+	}
+}
+
+// expire Drop expired records, at most once a minute. Called with mu held, or before k is shared.
+func (k *Keys) expire() {
+	if time.Since(k.lastExpired) < time.Minute {
+		return
+	}
+	k.lastExpired = time.Now()
+
+	if err := k.records.expire(IdempotencyTTL); err != nil {
+		slog.Error("expiring idempotency records", "error", err)
+	}
+}
+
+func replay(w http.ResponseWriter, record IdempotencyRecord) {
+	for name, values := range record.Header {
+		w.Header()[name] = values
+	}
+	w.Header().Set(ReplayedHeader, "true")
+	w.WriteHeader(record.StatusCode)
+	_, _ = w.Write(record.Body)
+}
+
+// fingerprint Hash of what makes two requests the same: method, path, query and body
+func fingerprint(r *http.Request, body []byte) string {
+	hash := sha256.New()
+	fmt.Fprintf(hash, "%s %s?%s\n", r.Method, r.URL.Path, r.URL.Query().Encode())
+	hash.Write(body)
+	return hex.EncodeToString(hash.Sum(nil))
+}
+
+func printable(key string) bool {
+	for i := 0; i < len(key); i++ {
+		if key[i] < 0x20 || key[i] > 0x7e {
+			return false
+		}
+	}
+	return true
+}
+
+// teeWriter Sends the response to the client while recording it
+type teeWriter struct {
+	http.ResponseWriter
+	recorder *ResponseRecorder
+}
+
+func (t *teeWriter) WriteHeader(statusCode int) {
+	t.recorder.WriteHeader(statusCode)
+	t.ResponseWriter.WriteHeader(statusCode)
+}
+
+func (t *teeWriter) Write(p []byte) (int, error) {
+	_, _ = t.recorder.Write(p)
+	return t.ResponseWriter.Write(p)
+}
+
+func (t *teeWriter) Flush() {
+	if flusher, ok := t.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
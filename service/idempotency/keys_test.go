@@ -0,0 +1,169 @@
+package idempotency
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/LysetsDal/docker-api/config"
+	"github.com/LysetsDal/docker-api/types"
+)
+
+func newKeys(t *testing.T) *Keys {
+	dataDir := config.DataDir
+	config.DataDir = t.TempDir()
+	t.Cleanup(func() { config.DataDir = dataDir })
+
+	keys, err := NewKeys()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return keys
+}
+
+type request struct {
+	key, body string
+}
+
+type response struct {
+	status   int
+	replayed bool
+}
+
+func TestWrap(t *testing.T) {
+	tests := []struct {
+		name     string
+		status   int
+		requests []request
+		want     []response
+		wantRuns int
+	}{
+		{
+			name:     "retry is replayed",
+			status:   http.StatusCreated,
+			requests: []request{{"a", "{}"}, {"a", "{}"}},
+			want:     []response{{http.StatusCreated, false}, {http.StatusCreated, true}},
+			wantRuns: 1,
+		},
+		{
+			name:     "key reused for another request",
+			status:   http.StatusCreated,
+			requests: []request{{"a", `{"Image":"nginx"}`}, {"a", `{"Image":"redis"}`}},
+			want:     []response{{http.StatusCreated, false}, {http.StatusUnprocessableEntity, false}},
+			wantRuns: 1,
+		},
+		{
+			name:     "different keys",
+			status:   http.StatusCreated,
+			requests: []request{{"a", "{}"}, {"b", "{}"}},
+			want:     []response{{http.StatusCreated, false}, {http.StatusCreated, false}},
+			wantRuns: 2,
+		},
+		{
+			name:     "no key",
+			status:   http.StatusCreated,
+			requests: []request{{"", "{}"}, {"", "{}"}},
+			want:     []response{{http.StatusCreated, false}, {http.StatusCreated, false}},
+			wantRuns: 2,
+		},
+		{
+			name:     "server errors run again",
+			status:   http.StatusBadGateway,
+			requests: []request{{"a", "{}"}, {"a", "{}"}},
+			want:     []response{{http.StatusBadGateway, false}, {http.StatusBadGateway, false}},
+			wantRuns: 2,
+		},
+		{
+			name:     "client errors are replayed",
+			status:   http.StatusConflict,
+			requests: []request{{"a", "{}"}, {"a", "{}"}},
+			want:     []response{{http.StatusConflict, false}, {http.StatusConflict, true}},
+			wantRuns: 1,
+		},
+		{
+			name:     "bad key",
+			status:   http.StatusCreated,
+			requests: []request{{"a\tb", "{}"}},
+			want:     []response{{http.StatusBadRequest, false}},
+			wantRuns: 0,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			runs := 0
+			handler := newKeys(t).Wrap(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				runs++
+				w.Header().Set("Location", "/containers/new")
+				w.Header().Set("X-Request-Id", "per-response")
+				w.WriteHeader(test.status)
+				_, _ = w.Write([]byte(`{"Id":"new"}`))
+			}))
+
+			for i, req := range test.requests {
+				request := httptest.NewRequest(http.MethodPost, "/containers/create?name=web", strings.NewReader(req.body))
+				if req.key != "" {
+					request.Header.Set(KeyHeader, req.key)
+				}
+				recorder := httptest.NewRecorder()
+				handler.ServeHTTP(recorder, request)
+
+				got := response{recorder.Code, recorder.Header().Get(ReplayedHeader) == "true"}
+				if got != test.want[i] {
+					t.Errorf("request %d: got %+v, want %+v: %s", i, got, test.want[i], recorder.Body)
+				}
+				if got.replayed {
+					if recorder.Body.String() != `{"Id":"new"}` || recorder.Header().Get("Location") != "/containers/new" {
+						t.Errorf("request %d: replayed %q with Location %q", i, recorder.Body, recorder.Header().Get("Location"))
+					}
+					if recorder.Header().Get("X-Request-Id") != "" {
+						t.Errorf("request %d: a per-response header was replayed", i)
+					}
+				}
+			}
+			if runs != test.wantRuns {
+				t.Errorf("runs: got %d, want %d", runs, test.wantRuns)
+			}
+		})
+	}
+}
+
+func TestExpire(t *testing.T) {
+	keys := newKeys(t)
+	written := time.Now().UTC().Add(-2 * config.IdempotencyTTL)
+	for _, key := range []string{"retried", "abandoned"} {
+		request := httptest.NewRequest(http.MethodPost, "/containers/web/start", nil)
+		record := types.IdempotencyRecord{Key: key, Fingerprint: fingerprint(request, nil), StatusCode: http.StatusNoContent, Created: written, Expires: written.Add(config.IdempotencyTTL)}
+		if err := keys.records.put(record); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Chtimes(keys.records.path(key), written, written); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	runs := 0
+	handler := keys.Wrap(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		runs++
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	keys.lastExpired = time.Time{}
+	request := httptest.NewRequest(http.MethodPost, "/containers/web/start", nil)
+	request.Header.Set(KeyHeader, "retried")
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, request)
+
+	// An expired record isn't replayed, and the others are removed before the new one is written
+	if runs != 1 || recorder.Header().Get(ReplayedHeader) != "" {
+		t.Errorf("the expired record was replayed")
+	}
+	if record, ok, err := keys.records.get("retried"); !ok || err != nil || !record.Expires.After(time.Now()) {
+		t.Errorf("the retry wasn't stored: %+v, %v, %v", record, ok, err)
+	}
+	if _, err := os.Stat(keys.records.path("abandoned")); !os.IsNotExist(err) {
+		t.Errorf("the expired record wasn't removed: %v", err)
+	}
+}
@@ -0,0 +1,85 @@
+package idempotency
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	. "github.com/LysetsDal/docker-api/types"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// records Idempotency records, one json file per key named by the key's hash. Storing a response
+// writes only that response's file, and expiring them only needs the files' modification times.
+type records struct {
+	dir string
+}
+
+func (r records) path(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return filepath.Join(r.dir, hex.EncodeToString(sum[:])+".json")
+}
+
+// get A missing record isn't an error
+func (r records) get(key string) (IdempotencyRecord, bool, error) {
+	data, err := os.ReadFile(r.path(key))
+	if errors.Is(err, os.ErrNotExist) {
+		return IdempotencyRecord{}, false, nil
+	}
+	if err != nil {
+		return IdempotencyRecord{}, false, err
+	}
+
+	var record IdempotencyRecord
+	if err := json.Unmarshal(data, &record); err != nil {
+		return IdempotencyRecord{}, false, err
+	}
+	return record, record.Key == key, nil
+}
+
+// put Write to a temp file and rename it over the old one, so a crash never leaves half a record
+func (r records) put(record IdempotencyRecord) error {
+	data, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+
+	path := r.path(record.Key)
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// expire Remove the records written more than ttl ago
+func (r records) expire(ttl time.Duration) error {
+	entries, err := os.ReadDir(r.dir)
+	if err != nil {
+		return err
+	}
+
+	var errs []error
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.HasSuffix(entry.Name(), ".json") {
+			continue
+		}
+		info, err := entry.Info()
+		if errors.Is(err, os.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		if time.Since(info.ModTime()) > ttl {
+			if err := os.Remove(filepath.Join(r.dir, entry.Name())); err != nil && !errors.Is(err, os.ErrNotExist) {
+				errs = append(errs, err)
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,169 @@
+package image
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	. "github.com/LysetsDal/docker-api/client"
+	"github.com/LysetsDal/docker-api/service/operation"
+	. "github.com/LysetsDal/docker-api/types"
+	. "github.com/LysetsDal/docker-api/utils"
+	"github.com/gorilla/mux"
+	"io"
+	"mime"
+	"net/http"
+	"regexp"
+	"strings"
+)
+
+var fileNameUnsafeRegex = regexp.MustCompile(`[^a-zA-Z0-9._-]+`)
+
+type Handler struct {
+	Docker     *DockerClient
+	Operations *operation.Operations
+}
+
+func NewHandler(sock http.Client, operations *operation.Operations) *Handler {
+	return &Handler{
+		Docker:     NewDockerClient(sock),
+		Operations: operations,
+	}
+}
+
+// RegisterRoutes Image functions. {name} may contain slashes, e.g. /images/library/nginx:1.25/save
+func (h *Handler) RegisterRoutes(router *mux.Router) {
+	router.HandleFunc("/images/save", MakeHttpHandleFunc(h.handleSaveImages)).Methods(http.MethodGet)
+	router.Handle("/images/load", h.Operations.Async("image.load", MakeHttpHandleFunc(h.handleLoadImages))).Methods(http.MethodPost)
+	router.HandleFunc("/images/{name:.+}/save", MakeHttpHandleFunc(h.handleSaveImage)).Methods(http.MethodGet)
+	router.Handle("/images/{name:.+}/pull", h.Operations.Async("image.pull", MakeHttpHandleFunc(h.handlePullImage))).Methods(http.MethodPost)
+	router.Handle("/images/{name:.+}/push", h.Operations.Async("image.push", MakeHttpHandleFunc(h.handlePushImage))).Methods(http.MethodPost)
+}
+
+// POST Pull an image, streaming the daemon's progress as newline delimited json.
+// Stored registry credentials are added by the Docker socket's transport.
+func (h *Handler) handlePullImage(w http.ResponseWriter, r *http.Request) error {
+	progress, err := h.Docker.PullImageStream(r.Context(), mux.Vars(r)["name"])
+	if err != nil {
+		return WriteError(w, err)
+	}
+	defer progress.Close()
+
+	streamProgress(w, progress)
+	return nil
+}
+
+// POST Push an image (repo:tag, the tag defaults to latest), streaming progress as newline delimited json.
+// Stored registry credentials are added by the Docker socket's transport.
+func (h *Handler) handlePushImage(w http.ResponseWriter, r *http.Request) error {
+	name := mux.Vars(r)["name"]
+	if _, err := h.Docker.InspectImage(r.Context(), name); err != nil {
+		return WriteError(w, err)
+	}
+
+	progress, err := h.Docker.PushImage(r.Context(), name)
+	if err != nil {
+		return WriteError(w, err)
+	}
+	defer progress.Close()
+
+	streamProgress(w, progress)
+	return nil
+}
+
+// GET Stream a docker save tarball of one image
+func (h *Handler) handleSaveImage(w http.ResponseWriter, r *http.Request) error {
+	return h.saveImages(w, r, []string{mux.Vars(r)["name"]})
+}
+
+// GET Stream a docker save tarball of several images: ?names=nginx:1.25&names=redis:7 (or names=nginx:1.25,redis:7).
+// Layers shared between the images are only included once.
+func (h *Handler) handleSaveImages(w http.ResponseWriter, r *http.Request) error {
+	names := make([]string, 0)
+	for _, value := range r.URL.Query()["names"] {
+		for _, name := range strings.Split(value, ",") {
+			if name = strings.TrimSpace(name); name != "" {
+				names = append(names, name)
+			}
+		}
+	}
+	if len(names) == 0 {
+		return BadRequest("names is required")
+	}
+
+	return h.saveImages(w, r, names)
+}
+
+func (h *Handler) saveImages(w http.ResponseWriter, r *http.Request, names []string) error {
+	ctx := r.Context()
+
+	// The daemon only notices a missing image once it has started the stream
+	for _, name := range names {
+		if _, err := h.Docker.InspectImage(ctx, name); err != nil {
+			return WriteError(w, err)
+		}
+	}
+
+	archive, err := h.Docker.SaveImages(ctx, names)
+	if err != nil {
+		return WriteError(w, err)
+	}
+	defer archive.Close()
+
+	fileName := "images.tar"
+	if len(names) == 1 {
+		fileName = strings.Trim(fileNameUnsafeRegex.ReplaceAllString(names[0], "_"), "_") + ".tar"
+	}
+
+	w.Header().Set("Content-Type", "application/x-tar")
+	w.Header().Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": fileName}))
+	w.WriteHeader(http.StatusOK)
+	_, err = io.Copy(w, archive)
+	return err
+}
+
+// POST Load images from a docker save tarball (optionally compressed) in the request body.
+// The daemon's progress is streamed back as newline delimited json, ending with "Loaded image: ..." lines.
+func (h *Handler) handleLoadImages(w http.ResponseWriter, r *http.Request) error {
+	mediaType, err := MediaType(r)
+	if err != nil {
+		return err
+	}
+	if !ArchiveContentTypes[mediaType] {
+		return WriteProblem(w, http.StatusUnsupportedMediaType, fmt.Sprintf("unsupported Content-Type %s, send a tar", mediaType))
+	}
+
+	progress, err := h.Docker.LoadImages(r.Context(), r.Body)
+	if err != nil {
+		return WriteError(w, err)
+	}
+	defer progress.Close()
+
+	streamProgress(w, progress)
+	return nil
+}
+
+// streamProgress Relay a JsonMessage stream as newline delimited json, flushing after every message.
+// The status is already sent when a failure shows up, so failures are reported as a final error message.
+func streamProgress(w http.ResponseWriter, stream io.Reader) {
+	w.Header().Set("Content-Type", "application/x-ndjson")
+	w.WriteHeader(http.StatusOK)
+
+	encoder := json.NewEncoder(w)
+	decoder := json.NewDecoder(stream)
+	for {
+		message := JsonMessage{}
+		if err := decoder.Decode(&message); errors.Is(err, io.EOF) {
+			return
+		} else if err != nil {
+			_ = encoder.Encode(JsonMessage{Error: err.Error()})
+			return
+		}
+
+		if err := encoder.Encode(message); err != nil {
+			return
+		}
+		if flusher, ok := w.(http.Flusher); ok {
+			flusher.Flush()
+		}
+	}
+}
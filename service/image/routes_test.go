@@ -0,0 +1,144 @@
+package image
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/LysetsDal/docker-api/client"
+	"github.com/LysetsDal/docker-api/client/clienttest"
+	"github.com/LysetsDal/docker-api/types"
+	"github.com/LysetsDal/docker-api/utils"
+	"github.com/gorilla/mux"
+)
+
+// messages The JsonMessages of a newline delimited json body
+func messages(t *testing.T, body io.Reader) []types.JsonMessage {
+	t.Helper()
+	decoded := []types.JsonMessage{}
+	decoder := json.NewDecoder(body)
+	for decoder.More() {
+		message := types.JsonMessage{}
+		if err := decoder.Decode(&message); err != nil {
+			t.Fatal(err)
+		}
+		decoded = append(decoded, message)
+	}
+	return decoded
+}
+
+func TestSaveImages(t *testing.T) {
+	daemon := clienttest.NewDaemon(t, func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.URL.Path == "/images/missing:1/json":
+			http.Error(w, `{"message":"No such image: missing:1"}`, http.StatusNotFound)
+		case strings.HasSuffix(r.URL.Path, "/json"):
+			_, _ = w.Write([]byte(`{}`))
+		case r.URL.Path == "/images/get":
+			_, _ = w.Write([]byte("tar of " + strings.Join(r.URL.Query()["names"], " ")))
+		}
+	})
+	h := &Handler{Docker: client.NewDockerClient(daemon.Sock())}
+
+	tests := []struct {
+		name         string
+		path         string
+		vars         map[string]string
+		handler      func(http.ResponseWriter, *http.Request) error
+		wantStatus   int
+		wantBody     string
+		wantFileName string
+	}{
+		{
+			name:         "one image",
+			path:         "/images/library/nginx:1.25/save",
+			vars:         map[string]string{"name": "library/nginx:1.25"},
+			handler:      h.handleSaveImage,
+			wantStatus:   http.StatusOK,
+			wantBody:     "tar of library/nginx:1.25",
+			wantFileName: "library_nginx_1.25.tar",
+		},
+		{
+			name:         "several images",
+			path:         "/images/save?names=nginx:1.25,redis:7&names=+busybox+",
+			handler:      h.handleSaveImages,
+			wantStatus:   http.StatusOK,
+			wantBody:     "tar of nginx:1.25 redis:7 busybox",
+			wantFileName: "images.tar",
+		},
+		{name: "no names", path: "/images/save?names=,", handler: h.handleSaveImages, wantStatus: http.StatusBadRequest},
+		{name: "missing image", path: "/images/save?names=nginx:1.25,missing:1", handler: h.handleSaveImages, wantStatus: http.StatusNotFound},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			request := mux.SetURLVars(httptest.NewRequest(http.MethodGet, test.path, nil), test.vars)
+			recorder := httptest.NewRecorder()
+			utils.MakeHttpHandleFunc(test.handler)(recorder, request)
+
+			if recorder.Code != test.wantStatus {
+				t.Fatalf("status %d: %s", recorder.Code, recorder.Body)
+			}
+			if test.wantStatus != http.StatusOK {
+				return
+			}
+			if recorder.Body.String() != test.wantBody {
+				t.Errorf("body: got %q, want %q", recorder.Body, test.wantBody)
+			}
+			if disposition := recorder.Header().Get("Content-Disposition"); disposition != `attachment; filename=`+test.wantFileName {
+				t.Errorf("Content-Disposition: %q", disposition)
+			}
+		})
+	}
+}
+
+func TestLoadImages(t *testing.T) {
+	var loaded string
+	daemon := clienttest.NewDaemon(t, func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		loaded = string(body)
+		_, _ = w.Write([]byte(`{"status":"Loading layer"}` + "\r\n" + `{"stream":"Loaded image: nginx:1.25\n"}` + "\r\n"))
+	})
+	h := &Handler{Docker: client.NewDockerClient(daemon.Sock())}
+
+	request := httptest.NewRequest(http.MethodPost, "/images/load", strings.NewReader("a tarball"))
+	request.Header.Set("Content-Type", "application/x-tar")
+	recorder := httptest.NewRecorder()
+	utils.MakeHttpHandleFunc(h.handleLoadImages)(recorder, request)
+
+	if recorder.Code != http.StatusOK || recorder.Header().Get("Content-Type") != "application/x-ndjson" {
+		t.Fatalf("status %d %s: %s", recorder.Code, recorder.Header().Get("Content-Type"), recorder.Body)
+	}
+	if loaded != "a tarball" {
+		t.Errorf("the daemon got %q", loaded)
+	}
+	want := []types.JsonMessage{{Status: "Loading layer"}, {Stream: "Loaded image: nginx:1.25\n"}}
+	if got := messages(t, recorder.Body); !reflect.DeepEqual(got, want) {
+		t.Errorf("got %+v, want %+v", got, want)
+	}
+
+	request = httptest.NewRequest(http.MethodPost, "/images/load", strings.NewReader("{}"))
+	request.Header.Set("Content-Type", "application/json")
+	recorder = httptest.NewRecorder()
+	utils.MakeHttpHandleFunc(h.handleLoadImages)(recorder, request)
+	if recorder.Code != http.StatusUnsupportedMediaType {
+		t.Errorf("json body: status %d", recorder.Code)
+	}
+}
+
+func TestStreamProgress(t *testing.T) {
+	recorder := httptest.NewRecorder()
+	streamProgress(recorder, strings.NewReader(`{"status":"Pushing","id":"abc"}`+"\n"+`{"status":`))
+
+	got := messages(t, recorder.Body)
+	if len(got) != 2 || got[0].Status != "Pushing" || got[1].Error == "" {
+		t.Errorf("a broken stream should end with an error message: %+v", got)
+	}
+	if !recorder.Flushed {
+		t.Error("progress wasn't flushed")
+	}
+}
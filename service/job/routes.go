@@ -0,0 +1,151 @@
+package job
+
+import (
+	"fmt"
+	. "github.com/LysetsDal/docker-api/client"
+	"github.com/LysetsDal/docker-api/service/backup"
+	. "github.com/LysetsDal/docker-api/types"
+	. "github.com/LysetsDal/docker-api/utils"
+	"github.com/gorilla/mux"
+	"net/http"
+	"regexp"
+	"time"
+)
+
+var jobNameRegex = regexp.MustCompile(`^[a-zA-Z0-9][a-zA-Z0-9_.-]*$`)
+
+type Handler struct {
+	Scheduler *Scheduler
+}
+
+// NewHandler Template jobs use the template handler's store, backup jobs the backup handler's backups
+func NewHandler(sock http.Client, templates *JsonStore[ContainerTemplate], backups *backup.Backups) (*Handler, error) {
+	scheduler, err := NewScheduler(NewDockerClient(sock), templates, backups)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Handler{
+		Scheduler: scheduler,
+	}, nil
+}
+
+// RegisterRoutes Scheduled jobs and their run history
+func (h *Handler) RegisterRoutes(router *mux.Router) {
+	router.HandleFunc("/jobs", MakeHttpHandleFunc(h.handleListJobs)).Methods(http.MethodGet)
+	router.HandleFunc("/jobs/{name}", MakeHttpHandleFunc(h.handleGetJob)).Methods(http.MethodGet)
+	router.HandleFunc("/jobs/{name}", MakeHttpHandleFunc(h.handlePutJob)).Methods(http.MethodPut)
+	router.HandleFunc("/jobs/{name}", MakeHttpHandleFunc(h.handleDeleteJob)).Methods(http.MethodDelete)
+	router.HandleFunc("/jobs/{name}/run", MakeHttpHandleFunc(h.handleRunJob)).Methods(http.MethodPost)
+	router.HandleFunc("/jobs/{name}/runs", MakeHttpHandleFunc(h.handleListRuns)).Methods(http.MethodGet)
+	router.HandleFunc("/jobs/{name}/runs/{id}", MakeHttpHandleFunc(h.handleGetRun)).Methods(http.MethodGet)
+}
+
+// GET Every job with its next run and latest run
+func (h *Handler) handleListJobs(w http.ResponseWriter, _ *http.Request) error {
+	jobs := make([]JobInfo, 0)
+	for _, job := range h.Scheduler.Jobs.List() {
+		jobs = append(jobs, h.Scheduler.Info(job))
+	}
+
+	return WriteJson(w, http.StatusOK, jobs)
+}
+
+func (h *Handler) handleGetJob(w http.ResponseWriter, r *http.Request) error {
+	name := mux.Vars(r)["name"]
+
+	job, ok := h.Scheduler.Jobs.Get(name)
+	if !ok {
+		return WriteProblem(w, http.StatusNotFound, fmt.Sprintf("No such job: %s", name))
+	}
+
+	return WriteJson(w, http.StatusOK, h.Scheduler.Info(job))
+}
+
+// PUT Create or replace a job:
+// {"Schedule": "0 3 * * *", "Task": {"Type": "backup", "Backup": {"Volumes": ["pgdata"], "Keep": 7}}}
+func (h *Handler) handlePutJob(w http.ResponseWriter, r *http.Request) error {
+	name := mux.Vars(r)["name"]
+	if !jobNameRegex.MatchString(name) {
+		return BadRequest("invalid job name %q", name)
+	}
+
+	job := Job{}
+	if err := ParseJsonStrict(r, &job); err != nil {
+		return BadRequest("invalid job: %w", err)
+	}
+	job.Name = name
+
+	if err := ValidateJob(job); err != nil {
+		return WriteValidationError(w, "invalid job", err)
+	}
+	if job.Task.Type == TaskTemplate {
+		if _, ok := h.Scheduler.Templates.Get(job.Task.Template.Template); !ok {
+			return WriteProblem(w, http.StatusBadRequest, fmt.Sprintf("No such template: %s", job.Task.Template.Template))
+		}
+	}
+
+	now := time.Now().UTC()
+	job.Created, job.Updated = now, now
+	if previous, ok := h.Scheduler.Jobs.Get(name); ok {
+		job.Created = previous.Created
+	}
+
+	if err := h.Scheduler.Put(job); err != nil {
+		return WriteError(w, err)
+	}
+
+	return WriteJson(w, http.StatusOK, h.Scheduler.Info(job))
+}
+
+// DELETE Remove a job and its run history
+func (h *Handler) handleDeleteJob(w http.ResponseWriter, r *http.Request) error {
+	name := mux.Vars(r)["name"]
+
+	deleted, err := h.Scheduler.Delete(name)
+	if err != nil {
+		return WriteError(w, err)
+	}
+	if !deleted {
+		return WriteProblem(w, http.StatusNotFound, fmt.Sprintf("No such job: %s", name))
+	}
+
+	return WriteJson(w, http.StatusOK, ApiMessage{Message: fmt.Sprintf("Job %s deleted", name)})
+}
+
+// POST Run a job now, paused or not. The run happens in the background; follow it at /jobs/{name}/runs/{id}.
+func (h *Handler) handleRunJob(w http.ResponseWriter, r *http.Request) error {
+	name := mux.Vars(r)["name"]
+	if _, ok := h.Scheduler.Jobs.Get(name); !ok {
+		return WriteProblem(w, http.StatusNotFound, fmt.Sprintf("No such job: %s", name))
+	}
+
+	run, err := h.Scheduler.Trigger(name, "manual")
+	if err != nil {
+		return WriteError(w, err)
+	}
+
+	return WriteJson(w, http.StatusAccepted, run)
+}
+
+// GET The job's run history, newest first
+func (h *Handler) handleListRuns(w http.ResponseWriter, r *http.Request) error {
+	name := mux.Vars(r)["name"]
+	if _, ok := h.Scheduler.Jobs.Get(name); !ok {
+		return WriteProblem(w, http.StatusNotFound, fmt.Sprintf("No such job: %s", name))
+	}
+
+	return WriteJson(w, http.StatusOK, h.Scheduler.History(name))
+}
+
+func (h *Handler) handleGetRun(w http.ResponseWriter, r *http.Request) error {
+	name, id := mux.Vars(r)["name"], mux.Vars(r)["id"]
+
+	for _, run := range h.Scheduler.History(name) {
+		if run.Id == id {
+			return WriteJson(w, http.StatusOK, run)
+		}
+	}
+
+	return WriteProblem(w, http.StatusNotFound, fmt.Sprintf("No such run of job %s: %s", name, id))
+}
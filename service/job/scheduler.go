@@ -0,0 +1,287 @@
+package job
+
+import (
+	"context"
+	"fmt"
+	. "github.com/LysetsDal/docker-api/client"
+	. "github.com/LysetsDal/docker-api/config"
+	"github.com/LysetsDal/docker-api/service/backup"
+	"github.com/LysetsDal/docker-api/service/system"
+	. "github.com/LysetsDal/docker-api/types"
+	. "github.com/LysetsDal/docker-api/utils"
+	"github.com/robfig/cron/v3"
+	"log/slog"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// defaultHistory Runs kept per job when the job doesn't say
+const defaultHistory = 20
+
+// maxLogSize Only the tail of a run's logs is kept
+const maxLogSize = 64 * 1024
+
+// Scheduler Runs jobs on their cron schedules and keeps their run history.
+// A job whose previous run is still going skips its turn.
+type Scheduler struct {
+	Docker    *DockerClient
+	Jobs      *JsonStore[Job]
+	Runs      *JsonStore[[]JobRun]
+	Templates *JsonStore[ContainerTemplate]
+	Backups   *backup.Backups
+	Cleanup   *system.Cleanup
+
+	cron    *cron.Cron
+	ctx     context.Context
+	mu      sync.Mutex
+	entries map[string]cron.EntryID
+	running map[string]string
+}
+
+func NewScheduler(docker *DockerClient, templates *JsonStore[ContainerTemplate], backups *backup.Backups) (*Scheduler, error) {
+	jobs, err := NewJsonStore[Job](filepath.Join(DataDir, "jobs.json"))
+	if err != nil {
+		return nil, fmt.Errorf("loading jobs: %w", err)
+	}
+	runs, err := NewJsonStore[[]JobRun](filepath.Join(DataDir, "job-runs.json"))
+	if err != nil {
+		return nil, fmt.Errorf("loading job runs: %w", err)
+	}
+
+	s := &Scheduler{
+		Docker:    docker,
+		Jobs:      jobs,
+		Runs:      runs,
+		Templates: templates,
+		Backups:   backups,
+		Cleanup:   &system.Cleanup{Docker: docker},
+		cron:      cron.New(),
+		ctx:       context.Background(),
+		entries:   map[string]cron.EntryID{},
+		running:   map[string]string{},
+	}
+
+	// Runs that were going when the server stopped will never finish
+	for _, name := range runs.Names() {
+		history, _ := runs.Get(name)
+		changed := false
+		for i := range history {
+			if history[i].Status == RunRunning {
+				history[i].Status = RunInterrupted
+				history[i].Error = "the server stopped during the run"
+				changed = true
+			}
+		}
+		if changed {
+			if err := runs.Put(name, history); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	for _, job := range jobs.List() {
+		if err := s.schedule(job); err != nil {
+			slog.Warn("job not scheduled", "job", job.Name, "error", err)
+		}
+	}
+	return s, nil
+}
+
+// Run Start the cron loop and block until ctx is cancelled. Runs in progress get ctx's cancellation.
+func (s *Scheduler) Run(ctx context.Context) {
+	s.mu.Lock()
+	s.ctx = ctx
+	s.mu.Unlock()
+
+	s.cron.Start()
+	<-ctx.Done()
+	<-s.cron.Stop().Done()
+}
+
+// Put Save a job and (re)schedule it
+func (s *Scheduler) Put(job Job) error {
+	if err := s.Jobs.Put(job.Name, job); err != nil {
+		return err
+	}
+	return s.schedule(job)
+}
+
+// Delete Unschedule a job and drop it with its history. A run in progress finishes.
+func (s *Scheduler) Delete(name string) (bool, error) {
+	s.unschedule(name)
+
+	deleted, err := s.Jobs.Delete(name)
+	if err != nil || !deleted {
+		return deleted, err
+	}
+	_, err = s.Runs.Delete(name)
+	return true, err
+}
+
+// Info The job with its next run, whether it is running and its latest run
+func (s *Scheduler) Info(job Job) JobInfo {
+	info := JobInfo{Job: job}
+
+	s.mu.Lock()
+	if id, ok := s.entries[job.Name]; ok {
+		next := s.cron.Entry(id).Next
+		if next.IsZero() {
+			// The cron loop only computes Next once started
+			if schedule, err := ParseCronSchedule(job.Schedule); err == nil {
+				next = schedule.Next(time.Now())
+			}
+		}
+		info.NextRun = &next
+	}
+	_, info.Running = s.running[job.Name]
+	s.mu.Unlock()
+
+	if history, _ := s.Runs.Get(job.Name); len(history) > 0 {
+		info.LastRun = &history[0]
+	}
+	return info
+}
+
+// History The job's runs, newest first
+func (s *Scheduler) History(name string) []JobRun {
+	history, ok := s.Runs.Get(name)
+	if !ok {
+		return make([]JobRun, 0)
+	}
+	return history
+}
+
+// Trigger Start a run of the job now, in the background. Fails if the job is already running.
+func (s *Scheduler) Trigger(name, trigger string) (JobRun, error) {
+	job, ok := s.Jobs.Get(name)
+	if !ok {
+		return JobRun{}, NotFound("No such job: %s", name)
+	}
+
+	s.mu.Lock()
+	if id, running := s.running[name]; running {
+		s.mu.Unlock()
+		return JobRun{}, Conflict("job %s is already running (run %s)", name, id)
+	}
+	run := JobRun{Id: RandomId(), Job: name, Trigger: trigger, Status: RunRunning, Started: time.Now().UTC()}
+	s.running[name] = run.Id
+	ctx := s.ctx
+	s.mu.Unlock()
+
+	if err := s.record(job, run); err != nil {
+		s.finish(name)
+		return JobRun{}, err
+	}
+
+	go s.execute(ctx, job, run)
+	return run, nil
+}
+
+func (s *Scheduler) schedule(job Job) error {
+	s.unschedule(job.Name)
+	if job.Paused {
+		return nil
+	}
+
+	schedule, err := ParseCronSchedule(job.Schedule)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.entries[job.Name] = s.cron.Schedule(schedule, cron.FuncJob(func() {
+		if _, err := s.Trigger(job.Name, "schedule"); err != nil {
+			slog.Warn("scheduled job not started", "job", job.Name, "error", err)
+		}
+	}))
+	return nil
+}
+
+func (s *Scheduler) unschedule(name string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if id, ok := s.entries[name]; ok {
+		s.cron.Remove(id)
+		delete(s.entries, name)
+	}
+}
+
+func (s *Scheduler) execute(ctx context.Context, job Job, run JobRun) {
+	defer s.finish(job.Name)
+
+	output := &runLog{}
+	exitCode, err := s.runTask(ctx, job.Task, run, output)
+
+	finished := time.Now().UTC()
+	run.Finished = &finished
+	run.ExitCode = exitCode
+	run.Logs = output.String()
+	run.Status = RunSucceeded
+	if err != nil {
+		run.Status = RunFailed
+		run.Error = err.Error()
+		slog.WarnContext(ctx, "job run failed", "job", job.Name, "run", run.Id, "error", err)
+	}
+
+	if err := s.record(job, run); err != nil {
+		slog.ErrorContext(ctx, "saving job run", "job", job.Name, "run", run.Id, "error", err)
+	}
+}
+
+func (s *Scheduler) finish(name string) {
+	s.mu.Lock()
+	delete(s.running, name)
+	s.mu.Unlock()
+}
+
+// record Add or update the run at the front of the job's history, trimmed to the job's History
+func (s *Scheduler) record(job Job, run JobRun) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// The job may have been deleted while it ran
+	if _, ok := s.Jobs.Get(job.Name); !ok {
+		return nil
+	}
+
+	history, _ := s.Runs.Get(job.Name)
+	updated := []JobRun{run}
+	for _, previous := range history {
+		if previous.Id != run.Id {
+			updated = append(updated, previous)
+		}
+	}
+
+	keep := job.History
+	if keep == 0 {
+		keep = defaultHistory
+	}
+	if len(updated) > keep {
+		updated = updated[:keep]
+	}
+	return s.Runs.Put(job.Name, updated)
+}
+
+// runLog Collects a run's output, keeping only the last maxLogSize bytes
+type runLog struct {
+	data []byte
+}
+
+func (l *runLog) Write(p []byte) (int, error) {
+	l.data = append(l.data, p...)
+	if len(l.data) > maxLogSize {
+		l.data = l.data[len(l.data)-maxLogSize:]
+	}
+	return len(p), nil
+}
+
+func (l *runLog) Printf(format string, args ...any) {
+	_, _ = fmt.Fprintf(l, format+"\n", args...)
+}
+
+func (l *runLog) String() string {
+	return string(l.data)
+}
@@ -0,0 +1,300 @@
+package job
+
+import (
+	"context"
+	"fmt"
+	. "github.com/LysetsDal/docker-api/client"
+	. "github.com/LysetsDal/docker-api/config"
+	. "github.com/LysetsDal/docker-api/types"
+	. "github.com/LysetsDal/docker-api/utils"
+	"io"
+	"strings"
+	"time"
+)
+
+// defaultTemplateTimeout How long a template job's container may run when the job doesn't say
+const defaultTemplateTimeout = time.Hour
+
+// runTask Run the task, writing what it does to output. The exit code is only set for template tasks.
+func (s *Scheduler) runTask(ctx context.Context, task JobTask, run JobRun, output *runLog) (*int, error) {
+	switch task.Type {
+	case TaskTemplate:
+		return s.runTemplate(ctx, *task.Template, run, output)
+	case TaskPrune:
+		return nil, s.runPrune(ctx, *task.Prune, output)
+	case TaskBackup:
+		return nil, s.runBackup(ctx, *task.Backup, run, output)
+	case TaskRestart:
+		return nil, s.runRestart(ctx, *task.Restart, output)
+	case TaskBusinessHours:
+		return nil, s.runBusinessHours(ctx, *task.BusinessHours, time.Now(), output)
+	default:
+		return nil, fmt.Errorf("unknown task type %q", task.Type)
+	}
+}
+
+// runTemplate Create a container from the template, start it, wait for it to exit and collect its logs.
+// A non-zero exit code fails the run.
+func (s *Scheduler) runTemplate(ctx context.Context, task TemplateTask, run JobRun, output *runLog) (*int, error) {
+	template, ok := s.Templates.Get(task.Template)
+	if !ok {
+		return nil, fmt.Errorf("no such template: %s", task.Template)
+	}
+
+	payload, name, err := RenderTemplate(template, task.Parameters)
+	if err != nil {
+		return nil, fmt.Errorf("rendering template %s: %w", task.Template, err)
+	}
+	// Runs can overlap with kept containers from earlier runs, so names get the run id
+	if name != "" {
+		name = name + "-" + run.Id
+	}
+	if payload.Labels == nil {
+		payload.Labels = map[string]string{}
+	}
+	payload.Labels[JobLabel] = run.Job
+	payload.Labels[JobRunLabel] = run.Id
+
+	// Created but not started still leaves a container to clean up
+	created, err := s.Docker.RunContainer(ctx, name, payload, task.Pull, true)
+	if created.Id != "" {
+		output.Printf("created container %s from template %s", created.Id, task.Template)
+		if !task.Keep {
+			defer func() {
+				if err := s.Docker.RemoveContainer(context.Background(), created.Id, true, false); err != nil {
+					output.Printf("removing container %s: %s", created.Id, err)
+				}
+			}()
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	timeout := defaultTemplateTimeout
+	if task.Timeout != "" {
+		timeout, _ = time.ParseDuration(task.Timeout)
+	}
+	waitCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	result, waitErr := s.Docker.WaitContainer(waitCtx, created.Id, "not-running")
+	if waitErr != nil {
+		// Timed out or shutting down: stop it so the logs are complete and the container can go
+		if err := s.Docker.StopContainer(context.Background(), created.Id, StopParams{}); err != nil {
+			output.Printf("stopping container %s: %s", created.Id, err)
+		}
+	}
+	s.collectLogs(created.Id, output)
+
+	if waitErr != nil {
+		if waitCtx.Err() != nil && ctx.Err() == nil {
+			return nil, fmt.Errorf("container still running after %s, stopped it", timeout)
+		}
+		return nil, fmt.Errorf("waiting for container: %w", waitErr)
+	}
+
+	exitCode := result.StatusCode
+	if result.Error != nil && result.Error.Message != "" {
+		return &exitCode, fmt.Errorf("waiting for container: %s", result.Error.Message)
+	}
+	if exitCode != 0 {
+		return &exitCode, fmt.Errorf("container exited with code %d", exitCode)
+	}
+	return &exitCode, nil
+}
+
+// collectLogs Append the container's stdout and stderr to output
+func (s *Scheduler) collectLogs(id string, output *runLog) {
+	ctx := context.Background()
+
+	inspect, err := s.Docker.InspectContainer(ctx, id)
+	if err != nil {
+		output.Printf("reading logs: %s", err)
+		return
+	}
+	logs, err := s.Docker.ContainerLogs(ctx, id, "all")
+	if err != nil {
+		output.Printf("reading logs: %s", err)
+		return
+	}
+	defer logs.Close()
+
+	if inspect.Config.Tty {
+		_, err = io.Copy(output, logs)
+	} else {
+		err = DemuxLogs(output, output, logs)
+	}
+	if err != nil {
+		output.Printf("reading logs: %s", err)
+	}
+}
+
+// runPrune Plan and immediately execute a cleanup
+func (s *Scheduler) runPrune(ctx context.Context, policy CleanupPolicy, output *runLog) error {
+	plan, err := s.Cleanup.Plan(ctx, policy)
+	if err != nil {
+		return fmt.Errorf("planning cleanup: %w", err)
+	}
+	output.Printf("planned removal of %d bytes", plan.Reclaimable)
+
+	result, err := s.Cleanup.Execute(ctx, plan)
+	if err != nil {
+		return fmt.Errorf("executing cleanup: %w", err)
+	}
+	for _, item := range result.Items {
+		if item.Error != "" {
+			output.Printf("%s %s: %s", item.Status, item.Name, item.Error)
+		} else {
+			output.Printf("%s %s", item.Status, item.Name)
+		}
+	}
+	output.Printf("removed %d, skipped %d, failed %d, reclaimed %d bytes", result.Removed, result.Skipped, result.Failed, result.SpaceReclaimed)
+
+	if result.Failed > 0 {
+		return fmt.Errorf("%d of %d items could not be removed", result.Failed, len(result.Items))
+	}
+	return nil
+}
+
+// runBackup Back up every volume, carrying on past failures, then prune each volume's backups made by
+// this job. Manual backups, the volume's backup schedule and other jobs keep theirs.
+func (s *Scheduler) runBackup(ctx context.Context, task BackupTask, run JobRun, output *runLog) error {
+	origin := "job/" + run.Job
+	failed := 0
+	for _, volume := range task.Volumes {
+		backup, err := s.Backups.Save(ctx, volume, origin)
+		if err != nil {
+			output.Printf("backing up %s: %s", volume, err)
+			failed++
+			continue
+		}
+		output.Printf("backed up %s to %s (%d bytes)", volume, backup.Id, backup.Size)
+
+		deleted, err := s.Backups.Prune(volume, origin, task.Keep)
+		if err != nil {
+			output.Printf("pruning backups of %s: %s", volume, err)
+			failed++
+			continue
+		}
+		for _, id := range deleted {
+			output.Printf("deleted old backup %s", id)
+		}
+	}
+
+	if failed > 0 {
+		return fmt.Errorf("%d of %d volumes failed", failed, len(task.Volumes))
+	}
+	return nil
+}
+
+// runRestart Restart every running container carrying the labels
+func (s *Scheduler) runRestart(ctx context.Context, task RestartTask, output *runLog) error {
+	containers, err := s.Docker.ListContainers(ctx, false, map[string][]string{"label": task.Labels})
+	if err != nil {
+		return err
+	}
+	if len(containers) == 0 {
+		output.Printf("no running containers match %s", strings.Join(task.Labels, ", "))
+		return nil
+	}
+
+	failed := 0
+	for _, container := range containers {
+		name := container.Name()
+		if err := s.Docker.RestartContainer(ctx, container.Id, StopParams{T: task.Timeout}); err != nil {
+			output.Printf("restarting %s: %s", name, err)
+			failed++
+			continue
+		}
+		output.Printf("restarted %s", name)
+	}
+
+	if failed > 0 {
+		return fmt.Errorf("%d of %d containers failed to restart", failed, len(containers))
+	}
+	return nil
+}
+
+// runBusinessHours Stop the labelled containers outside business hours, and with StartInside start them inside
+func (s *Scheduler) runBusinessHours(ctx context.Context, task BusinessHoursTask, now time.Time, output *runLog) error {
+	inside, err := withinBusinessHours(task, now)
+	if err != nil {
+		return err
+	}
+	if inside && !task.StartInside {
+		output.Printf("inside business hours, nothing to do")
+		return nil
+	}
+
+	containers, err := s.Docker.ListContainers(ctx, true, map[string][]string{"label": task.Labels})
+	if err != nil {
+		return err
+	}
+
+	failed, changed := 0, 0
+	for _, container := range containers {
+		name := container.Name()
+		switch {
+		case !inside && container.State == "running":
+			err = s.Docker.StopContainer(ctx, container.Id, StopParams{})
+			if err == nil {
+				output.Printf("stopped %s", name)
+			}
+		case inside && container.State == "exited":
+			err = s.Docker.StartContainer(ctx, container.Id)
+			if err == nil {
+				output.Printf("started %s", name)
+			}
+		default:
+			continue
+		}
+
+		changed++
+		if err != nil {
+			output.Printf("%s: %s", name, err)
+			failed++
+		}
+	}
+
+	if changed == 0 {
+		output.Printf("no containers to change")
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d of %d containers could not be changed", failed, changed)
+	}
+	return nil
+}
+
+// withinBusinessHours Whether now falls on one of the task's days between Start and End in its time zone
+func withinBusinessHours(task BusinessHoursTask, now time.Time) (bool, error) {
+	location := time.Local
+	if task.Timezone != "" {
+		var err error
+		if location, err = time.LoadLocation(task.Timezone); err != nil {
+			return false, err
+		}
+	}
+	start, err := ParseClock(task.Start)
+	if err != nil {
+		return false, err
+	}
+	end, err := ParseClock(task.End)
+	if err != nil {
+		return false, err
+	}
+
+	days := task.Days
+	if len(days) == 0 {
+		days = []string{"mon", "tue", "wed", "thu", "fri"}
+	}
+
+	local := now.In(location)
+	workday := false
+	for _, day := range days {
+		workday = workday || Weekdays[strings.ToLower(day)] == local.Weekday()
+	}
+
+	sinceMidnight := time.Duration(local.Hour())*time.Hour + time.Duration(local.Minute())*time.Minute
+	return workday && sinceMidnight >= start && sinceMidnight < end, nil
+}
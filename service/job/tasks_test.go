@@ -0,0 +1,105 @@
+package job
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/LysetsDal/docker-api/client"
+	"github.com/LysetsDal/docker-api/client/clienttest"
+	"github.com/LysetsDal/docker-api/types"
+	"github.com/LysetsDal/docker-api/utils"
+)
+
+func TestRunTemplate(t *testing.T) {
+	tests := []struct {
+		name      string
+		task      types.TemplateTask
+		failStart bool
+		want      []string
+		wantErr   bool
+	}{
+		{
+			name: "exits cleanly",
+			task: types.TemplateTask{Template: "migrate"},
+			want: []string{
+				"POST /containers/create", "POST /containers/c1/start", "POST /containers/c1/wait",
+				"GET /containers/c1/json", "GET /containers/c1/logs", "DELETE /containers/c1",
+			},
+		},
+		{
+			name: "pull always",
+			task: types.TemplateTask{Template: "migrate", Pull: "always", Keep: true},
+			want: []string{
+				"POST /images/create", "POST /containers/create", "POST /containers/c1/start", "POST /containers/c1/wait",
+				"GET /containers/c1/json", "GET /containers/c1/logs",
+			},
+		},
+		{
+			name:      "start fails",
+			task:      types.TemplateTask{Template: "migrate"},
+			failStart: true,
+			want:      []string{"POST /containers/create", "POST /containers/c1/start", "DELETE /containers/c1"},
+			wantErr:   true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			daemon := clienttest.NewDaemon(t, func(w http.ResponseWriter, r *http.Request) {
+				switch r.URL.Path {
+				case "/containers/create":
+					w.WriteHeader(http.StatusCreated)
+					_, _ = w.Write([]byte(`{"Id":"c1"}`))
+				case "/containers/c1/start":
+					if test.failStart {
+						http.Error(w, `{"message":"boom"}`, http.StatusInternalServerError)
+						return
+					}
+					w.WriteHeader(http.StatusNoContent)
+				case "/containers/c1/wait":
+					_, _ = w.Write([]byte(`{"StatusCode":0}`))
+				case "/containers/c1/json":
+					_, _ = w.Write([]byte(`{"Id":"c1","Config":{"Tty":true}}`))
+				case "/containers/c1/logs":
+					_, _ = w.Write([]byte("migrated\n"))
+				case "/images/create":
+					_, _ = w.Write([]byte(`{"status":"done"}`))
+				default:
+					w.WriteHeader(http.StatusNoContent)
+				}
+			})
+
+			templates, err := utils.NewJsonStore[types.ContainerTemplate](filepath.Join(t.TempDir(), "templates.json"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			_ = templates.Put("migrate", types.ContainerTemplate{Name: "migrate", Payload: json.RawMessage(`{"Image":"app:1"}`)})
+
+			s := &Scheduler{
+				Docker:    client.NewDockerClient(daemon.Sock()),
+				Templates: templates,
+			}
+
+			output := &runLog{}
+			exitCode, err := s.runTemplate(context.Background(), test.task, types.JobRun{Id: "r1", Job: "nightly"}, output)
+			if (err != nil) != test.wantErr {
+				t.Fatalf("error: %v\n%s", err, output)
+			}
+			if !test.wantErr && (exitCode == nil || *exitCode != 0) {
+				t.Errorf("exit code: %v", exitCode)
+			}
+			if !test.wantErr && !strings.Contains(output.String(), "migrated") {
+				t.Errorf("logs not collected:\n%s", output)
+			}
+
+			if calls := daemon.Calls(); !reflect.DeepEqual(calls, test.want) {
+				t.Errorf("calls:\n got %v\nwant %v", calls, test.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,168 @@
+package job
+
+import (
+	"fmt"
+	. "github.com/LysetsDal/docker-api/types"
+	. "github.com/LysetsDal/docker-api/utils"
+	"github.com/robfig/cron/v3"
+	"strings"
+	"time"
+)
+
+// cronParser Five field cron expressions plus descriptors such as @daily and @every 1h
+var cronParser = cron.NewParser(cron.Minute | cron.Hour | cron.Dom | cron.Month | cron.Dow | cron.Descriptor)
+
+// Weekdays The day names business hours accept
+var Weekdays = map[string]time.Weekday{
+	"sun": time.Sunday, "mon": time.Monday, "tue": time.Tuesday, "wed": time.Wednesday,
+	"thu": time.Thursday, "fri": time.Friday, "sat": time.Saturday,
+}
+
+// validator Collects field errors
+type validator struct {
+	errors ValidationErrors
+}
+
+func (v *validator) fail(field, format string, args ...any) {
+	v.errors = append(v.errors, FieldError{Field: field, Message: fmt.Sprintf(format, args...)})
+}
+
+// merge Add the field errors of another validation, their fields prefixed
+func (v *validator) merge(prefix string, err error) {
+	if errors, ok := err.(ValidationErrors); ok {
+		for _, field := range errors {
+			v.fail(prefix+field.Field, "%s", field.Message)
+		}
+	}
+}
+
+// labelSelectors Require at least one label selector, each "key" or "key=value". Jobs that act on
+// containers must not select every container by accident.
+func (v *validator) labelSelectors(field string, labels []string) {
+	if len(labels) == 0 {
+		v.fail(field, "must list at least one label")
+	}
+	v.merge("", ValidateLabelSelectors(field, labels))
+}
+
+// ParseCronSchedule Parse a job's schedule, see Job
+func ParseCronSchedule(spec string) (cron.Schedule, error) {
+	return cronParser.Parse(spec)
+}
+
+// ParseClock Parse a "15:04" time of day into the duration since midnight
+func ParseClock(value string) (time.Duration, error) {
+	clock, err := time.Parse("15:04", value)
+	if err != nil {
+		return 0, fmt.Errorf("%q is not a time of day such as 08:30", value)
+	}
+	return time.Duration(clock.Hour())*time.Hour + time.Duration(clock.Minute())*time.Minute, nil
+}
+
+// ValidateJob Check a job's schedule and that its task has the settings its type needs
+func ValidateJob(job Job) error {
+	v := &validator{}
+
+	if _, err := ParseCronSchedule(job.Schedule); err != nil {
+		v.fail("Schedule", "%s", err)
+	}
+	if job.History < 0 {
+		v.fail("History", "must not be negative")
+	}
+
+	task := job.Task
+	set := 0
+	for _, configured := range []bool{task.Template != nil, task.Prune != nil, task.Backup != nil, task.Restart != nil, task.BusinessHours != nil} {
+		if configured {
+			set++
+		}
+	}
+	if set > 1 {
+		v.fail("Task", "must only configure the section for its type")
+	}
+
+	switch task.Type {
+	case TaskTemplate:
+		if task.Template == nil {
+			v.fail("Task.Template", "is required for template tasks")
+			break
+		}
+		if task.Template.Template == "" {
+			v.fail("Task.Template.Template", "is required")
+		}
+		switch task.Template.Pull {
+		case "", "missing", "always", "never":
+		default:
+			v.fail("Task.Template.Pull", "must be missing, always or never")
+		}
+		if task.Template.Timeout != "" {
+			if timeout, err := time.ParseDuration(task.Template.Timeout); err != nil || timeout <= 0 {
+				v.fail("Task.Template.Timeout", "must be a positive duration such as 30m")
+			}
+		}
+
+	case TaskPrune:
+		if task.Prune == nil {
+			v.fail("Task.Prune", "is required for prune tasks")
+			break
+		}
+		v.merge("Task.Prune.", ValidateCleanupPolicy(*task.Prune))
+
+	case TaskBackup:
+		if task.Backup == nil {
+			v.fail("Task.Backup", "is required for backup tasks")
+			break
+		}
+		if len(task.Backup.Volumes) == 0 {
+			v.fail("Task.Backup.Volumes", "must list at least one volume")
+		}
+		if task.Backup.Keep < 1 {
+			v.fail("Task.Backup.Keep", "must be at least 1")
+		}
+
+	case TaskRestart:
+		if task.Restart == nil {
+			v.fail("Task.Restart", "is required for restart tasks")
+			break
+		}
+		v.labelSelectors("Task.Restart.Labels", task.Restart.Labels)
+		if task.Restart.Timeout < 0 {
+			v.fail("Task.Restart.Timeout", "must not be negative")
+		}
+
+	case TaskBusinessHours:
+		hours := task.BusinessHours
+		if hours == nil {
+			v.fail("Task.BusinessHours", "is required for business-hours tasks")
+			break
+		}
+		v.labelSelectors("Task.BusinessHours.Labels", hours.Labels)
+		start, startErr := ParseClock(hours.Start)
+		if startErr != nil {
+			v.fail("Task.BusinessHours.Start", "%s", startErr)
+		}
+		end, endErr := ParseClock(hours.End)
+		if endErr != nil {
+			v.fail("Task.BusinessHours.End", "%s", endErr)
+		}
+		if startErr == nil && endErr == nil && end <= start {
+			v.fail("Task.BusinessHours.End", "must be after Start")
+		}
+		for i, day := range hours.Days {
+			if _, ok := Weekdays[strings.ToLower(day)]; !ok {
+				v.fail(fmt.Sprintf("Task.BusinessHours.Days[%d]", i), "%q is not one of mon, tue, wed, thu, fri, sat or sun", day)
+			}
+		}
+		if _, err := time.LoadLocation(hours.Timezone); err != nil {
+			v.fail("Task.BusinessHours.Timezone", "unknown time zone %q", hours.Timezone)
+		}
+
+	default:
+		v.fail("Task.Type", "must be one of %s, %s, %s, %s or %s", TaskTemplate, TaskPrune, TaskBackup, TaskRestart, TaskBusinessHours)
+	}
+
+	if len(v.errors) > 0 {
+		return v.errors
+	}
+	return nil
+}
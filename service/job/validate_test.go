@@ -0,0 +1,89 @@
+package job
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/LysetsDal/docker-api/types"
+)
+
+func TestValidateJob(t *testing.T) {
+	tests := []struct {
+		name string
+		job  types.Job
+		want []string
+	}{
+		{
+			name: "valid",
+			job:  types.Job{Schedule: "@daily", Task: types.JobTask{Type: types.TaskBackup, Backup: &types.BackupTask{Volumes: []string{"data"}, Keep: 3}}},
+			want: []string{},
+		},
+		{
+			name: "bad schedule and type",
+			job:  types.Job{Schedule: "every day", History: -1, Task: types.JobTask{Type: "reboot"}},
+			want: []string{"Schedule", "History", "Task.Type"},
+		},
+		{
+			name: "prune policy",
+			job:  types.Job{Schedule: "0 3 * * *", Task: types.JobTask{Type: types.TaskPrune, Prune: &types.CleanupPolicy{}}},
+			want: []string{"Task.Prune.Policy"},
+		},
+		{
+			name: "restart labels",
+			job:  types.Job{Schedule: "@hourly", Task: types.JobTask{Type: types.TaskRestart, Restart: &types.RestartTask{Labels: []string{"=x"}}}},
+			want: []string{"Task.Restart.Labels[0]"},
+		},
+		{
+			name: "business hours",
+			job: types.Job{Schedule: "*/5 * * * *", Task: types.JobTask{Type: types.TaskBusinessHours, BusinessHours: &types.BusinessHoursTask{
+				Days: []string{"mon", "someday"}, Start: "18:00", End: "08:00", Timezone: "Mars/Olympus",
+			}}},
+			want: []string{"Task.BusinessHours.Labels", "Task.BusinessHours.End", "Task.BusinessHours.Days[1]", "Task.BusinessHours.Timezone"},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got := []string{}
+			var validation types.ValidationErrors
+			if err := ValidateJob(test.job); errors.As(err, &validation) {
+				for _, fieldError := range validation {
+					got = append(got, fieldError.Field)
+				}
+			} else if err != nil {
+				t.Fatalf("not a ValidationErrors: %v", err)
+			}
+			if !reflect.DeepEqual(got, test.want) {
+				t.Errorf("got %q, want %q", got, test.want)
+			}
+		})
+	}
+}
+
+func TestParseClock(t *testing.T) {
+	tests := []struct {
+		value   string
+		want    time.Duration
+		invalid bool
+	}{
+		{value: "00:00", want: 0},
+		{value: "08:30", want: 8*time.Hour + 30*time.Minute},
+		{value: "23:59", want: 23*time.Hour + 59*time.Minute},
+		{value: "24:00", invalid: true},
+		{value: "8:30am", invalid: true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.value, func(t *testing.T) {
+			got, err := ParseClock(test.value)
+			if (err != nil) != test.invalid {
+				t.Fatalf("error: %v", err)
+			}
+			if got != test.want {
+				t.Errorf("got %s, want %s", got, test.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,353 @@
+package operation
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	. "github.com/LysetsDal/docker-api/config"
+	. "github.com/LysetsDal/docker-api/types"
+	. "github.com/LysetsDal/docker-api/utils"
+	"io"
+	"log/slog"
+	"mime"
+	"net/http"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// maxRequestBody Async requests are read into memory before the 202 goes out
+const maxRequestBody = 32 << 20
+
+// maxResultBody Larger json results are reported by size only
+const maxResultBody = 1 << 20
+
+// Operations Requests running in the background and the results of finished ones
+type Operations struct {
+	mu         sync.Mutex
+	operations map[string]*Operation
+	cancels    map[string]context.CancelFunc
+	store      *JsonStore[Operation]
+}
+
+// NewOperations With PersistOperations, finished operations are reloaded from the data directory.
+// Operations that were running when the server stopped are marked failed.
+func NewOperations() (*Operations, error) {
+	o := &Operations{
+		operations: map[string]*Operation{},
+		cancels:    map[string]context.CancelFunc{},
+	}
+	if !PersistOperations {
+		return o, nil
+	}
+
+	store, err := NewJsonStore[Operation](filepath.Join(DataDir, "operations.json"))
+	if err != nil {
+		return nil, fmt.Errorf("loading operations: %w", err)
+	}
+	o.store = store
+
+	for _, operation := range store.List() {
+		if operation.Status == OperationRunning {
+			finished := time.Now().UTC()
+			operation.Status, operation.Finished = OperationFailed, &finished
+			operation.Error = "the server stopped during the operation"
+			if err := store.Put(operation.Id, operation); err != nil {
+				return nil, err
+			}
+		}
+		o.operations[operation.Id] = &operation
+	}
+	return o, nil
+}
+
+// WantsAsync Whether the client asked for ?async=true or Prefer: respond-async
+func WantsAsync(r *http.Request) bool {
+	if r.URL.Query().Get("async") == "true" {
+		return true
+	}
+	for _, prefer := range r.Header.Values("Prefer") {
+		for _, preference := range strings.Split(prefer, ",") {
+			if strings.EqualFold(strings.TrimSpace(preference), "respond-async") {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Async Run next in the background when the client asks for it, answering 202 with the operation
+// and its Location. Without the async option the request is served as usual.
+func (o *Operations) Async(kind string, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !WantsAsync(r) {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		// The body is gone once this handler returns
+		body, err := io.ReadAll(io.LimitReader(r.Body, maxRequestBody+1))
+		if err != nil {
+			_ = WriteProblem(w, http.StatusBadRequest, fmt.Sprintf("reading request body: %s", err))
+			return
+		}
+		if len(body) > maxRequestBody {
+			_ = WriteProblem(w, http.StatusRequestEntityTooLarge, fmt.Sprintf("async requests are limited to %d MiB bodies", maxRequestBody>>20))
+			return
+		}
+
+		// Keeps the request's values, such as the route variables, but not its cancellation
+		ctx, cancel := context.WithCancel(context.WithoutCancel(r.Context()))
+		background := r.Clone(ctx)
+		background.Body = io.NopCloser(bytes.NewReader(body))
+
+		operation, err := o.start(kind, r, cancel)
+		if err != nil {
+			cancel()
+			_ = WriteError(w, err)
+			return
+		}
+		go o.run(ctx, operation.Id, next, background, w.Header().Get(RequestIdHeader))
+
+		w.Header().Set("Location", "/api/v1/operations/"+operation.Id)
+		w.Header().Set("Preference-Applied", "respond-async")
+		_ = WriteJson(w, http.StatusAccepted, operation)
+	})
+}
+
+// Get The operation with its current progress
+func (o *Operations) Get(id string) (Operation, bool) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	operation, ok := o.operations[id]
+	if !ok {
+		return Operation{}, false
+	}
+	return *operation, true
+}
+
+// List Operations newest first
+func (o *Operations) List() []Operation {
+	o.mu.Lock()
+	o.expire()
+	operations := make([]Operation, 0, len(o.operations))
+	for _, operation := range o.operations {
+		operations = append(operations, *operation)
+	}
+	o.mu.Unlock()
+
+	sort.Slice(operations, func(i, j int) bool { return operations[i].Created.After(operations[j].Created) })
+	return operations
+}
+
+// Cancel Cancel a running operation. It becomes cancelled once its handler has returned.
+func (o *Operations) Cancel(id string) (Operation, bool) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	operation, ok := o.operations[id]
+	if !ok {
+		return Operation{}, false
+	}
+	if cancel, running := o.cancels[id]; running {
+		cancel()
+	}
+	return *operation, true
+}
+
+// Delete Forget a finished operation, reporting whether it existed. Running operations must be cancelled first.
+func (o *Operations) Delete(id string) (bool, error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	operation, ok := o.operations[id]
+	if !ok {
+		return false, nil
+	}
+	if operation.Status == OperationRunning {
+		return false, Conflict("operation %s is still running, cancel it first", id)
+	}
+
+	delete(o.operations, id)
+	if o.store != nil {
+		if _, err := o.store.Delete(id); err != nil {
+			return true, err
+		}
+	}
+	return true, nil
+}
+
+func (o *Operations) start(kind string, r *http.Request, cancel context.CancelFunc) (Operation, error) {
+	operation := &Operation{
+		Id:      RandomId(),
+		Kind:    kind,
+		Method:  r.Method,
+		Path:    r.URL.RequestURI(),
+		Status:  OperationRunning,
+		Created: time.Now().UTC(),
+	}
+
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	o.expire()
+	o.operations[operation.Id] = operation
+	o.cancels[operation.Id] = cancel
+	return *operation, o.save(operation)
+}
+
+func (o *Operations) run(ctx context.Context, id string, next http.Handler, r *http.Request, requestId string) {
+	recorder := NewResponseRecorder(maxResultBody)
+	// Problems in the result name the request that started the operation
+	if requestId != "" {
+		recorder.Header().Set(RequestIdHeader, requestId)
+	}
+	progress := &progressWriter{update: func(message json.RawMessage) {
+		o.mu.Lock()
+		o.operations[id].Progress = message
+		o.mu.Unlock()
+	}}
+	recorder.OnWrite = func(p []byte) {
+		if isNdjson(recorder.Header()) {
+			progress.Write(p)
+		}
+	}
+
+	var panicked any
+	func() {
+		defer func() { panicked = recover() }()
+		next.ServeHTTP(recorder, r)
+	}()
+
+	cancelled := ctx.Err() != nil
+
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	operation := o.operations[id]
+	o.cancels[id]()
+	delete(o.cancels, id)
+
+	finished := time.Now().UTC()
+	operation.Finished = &finished
+	operation.Result = result(recorder, progress)
+	operation.Status = OperationSucceeded
+
+	switch {
+	case panicked != nil:
+		operation.Status, operation.Error = OperationFailed, fmt.Sprintf("%v", panicked)
+	case cancelled:
+		operation.Status, operation.Error = OperationCancelled, "cancelled"
+	case recorder.Status() >= 400:
+		operation.Status, operation.Error = OperationFailed, errorMessage(operation.Result.Body)
+	case progress.err != "":
+		operation.Status, operation.Error = OperationFailed, progress.err
+	}
+
+	if err := o.save(operation); err != nil {
+		slog.ErrorContext(ctx, "saving operation", "operation", id, "error", err)
+	}
+}
+
+// expire Drop finished operations past the retention, then the oldest beyond the limit. Called with mu held.
+func (o *Operations) expire() {
+	finished := make([]*Operation, 0)
+	for id, operation := range o.operations {
+		if operation.Finished == nil {
+			continue
+		}
+		if time.Since(*operation.Finished) > OperationRetention {
+			o.forget(id)
+			continue
+		}
+		finished = append(finished, operation)
+	}
+
+	if excess := len(finished) - OperationLimit; excess > 0 {
+		sort.Slice(finished, func(i, j int) bool { return finished[i].Finished.Before(*finished[j].Finished) })
+		for _, operation := range finished[:excess] {
+			o.forget(operation.Id)
+		}
+	}
+}
+
+func (o *Operations) forget(id string) {
+	delete(o.operations, id)
+	if o.store != nil {
+		if _, err := o.store.Delete(id); err != nil {
+			slog.Error("deleting operation", "operation", id, "error", err)
+		}
+	}
+}
+
+func (o *Operations) save(operation *Operation) error {
+	if o.store == nil {
+		return nil
+	}
+	return o.store.Put(operation.Id, *operation)
+}
+
+// result The recorded response. Streamed progress is summed up by its last message.
+func result(recorder *ResponseRecorder, progress *progressWriter) *OperationResult {
+	result := &OperationResult{
+		StatusCode:  recorder.Status(),
+		ContentType: recorder.Header().Get("Content-Type"),
+		Size:        recorder.Size,
+	}
+
+	switch {
+	case isNdjson(recorder.Header()):
+		result.Body = progress.last
+	case !recorder.Truncated && json.Valid(recorder.Body):
+		result.Body = bytes.TrimSpace(recorder.Body)
+	}
+	return result
+}
+
+// errorMessage The error of an ApiError body, or a generic message
+func errorMessage(body json.RawMessage) string {
+	apiError := ApiError{}
+	if err := json.Unmarshal(body, &apiError); err == nil && apiError.Error != "" {
+		return apiError.Error
+	}
+	return "the request failed, see the result"
+}
+
+func isNdjson(header http.Header) bool {
+	mediaType, _, _ := mime.ParseMediaType(header.Get("Content-Type"))
+	return mediaType == "application/x-ndjson"
+}
+
+// progressWriter Splits a newline delimited json stream, keeping the last message and the first error
+type progressWriter struct {
+	partial []byte
+	last    json.RawMessage
+	err     string
+	update  func(message json.RawMessage)
+}
+
+func (p *progressWriter) Write(data []byte) {
+	p.partial = append(p.partial, data...)
+	for {
+		end := bytes.IndexByte(p.partial, '\n')
+		if end < 0 {
+			return
+		}
+		line := bytes.TrimSpace(p.partial[:end])
+		p.partial = p.partial[end+1:]
+		if len(line) == 0 || !json.Valid(line) {
+			continue
+		}
+
+		message := JsonMessage{}
+		if err := json.Unmarshal(line, &message); err == nil && message.Error != "" && p.err == "" {
+			p.err = message.Error
+		}
+		p.last = append(json.RawMessage{}, line...)
+		p.update(p.last)
+	}
+}
@@ -0,0 +1,254 @@
+package operation
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/LysetsDal/docker-api/config"
+	"github.com/LysetsDal/docker-api/types"
+	"github.com/LysetsDal/docker-api/utils"
+)
+
+// newOperations Operations that aren't persisted
+func newOperations(t *testing.T) *Operations {
+	operations, err := NewOperations()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return operations
+}
+
+// start Send an async request to handler, check the 202 and return the operation it started
+func start(t *testing.T, operations *Operations, handler http.HandlerFunc) types.Operation {
+	t.Helper()
+	request := httptest.NewRequest(http.MethodPost, "/images/create?async=true", strings.NewReader(`{"Image":"nginx"}`))
+	recorder := httptest.NewRecorder()
+	operations.Async("image.pull", handler).ServeHTTP(recorder, request)
+
+	if recorder.Code != http.StatusAccepted {
+		t.Fatalf("status %d: %s", recorder.Code, recorder.Body)
+	}
+	operation := types.Operation{}
+	if err := json.Unmarshal(recorder.Body.Bytes(), &operation); err != nil {
+		t.Fatal(err)
+	}
+	if location := recorder.Header().Get("Location"); location != "/api/v1/operations/"+operation.Id {
+		t.Errorf("Location: %q", location)
+	}
+	if operation.Status != types.OperationRunning || operation.Kind != "image.pull" || operation.Path != "/images/create?async=true" {
+		t.Errorf("started: %+v", operation)
+	}
+	return operation
+}
+
+// wait Poll until the operation is no longer running
+func wait(t *testing.T, operations *Operations, id string) types.Operation {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		operation, ok := operations.Get(id)
+		if !ok {
+			t.Fatalf("operation %s is gone", id)
+		}
+		if operation.Status != types.OperationRunning {
+			return operation
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("operation %s is still running", id)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestAsync(t *testing.T) {
+	tests := []struct {
+		name       string
+		handler    http.HandlerFunc
+		wantStatus string
+		wantError  string
+		wantResult string
+	}{
+		{
+			name: "succeeded",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				body := map[string]string{}
+				_ = json.NewDecoder(r.Body).Decode(&body)
+				_ = utils.WriteJson(w, http.StatusCreated, map[string]string{"Id": "abc", "Image": body["Image"]})
+			},
+			wantStatus: types.OperationSucceeded,
+			wantResult: `{"Id":"abc","Image":"nginx"}`,
+		},
+		{
+			name: "problem",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				_ = utils.WriteProblem(w, http.StatusConflict, "name in use")
+			},
+			wantStatus: types.OperationFailed,
+			wantError:  "name in use",
+		},
+		{
+			name: "error in the stream",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Type", "application/x-ndjson")
+				_, _ = w.Write([]byte(`{"status":"pulling"}` + "\n" + `{"error":"manifest unknown"}` + "\n" + `{"status":"done"}` + "\n"))
+			},
+			wantStatus: types.OperationFailed,
+			wantError:  "manifest unknown",
+			wantResult: `{"status":"done"}`,
+		},
+		{
+			name: "panic",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				panic("nil map")
+			},
+			wantStatus: types.OperationFailed,
+			wantError:  "nil map",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			operations := newOperations(t)
+			operation := wait(t, operations, start(t, operations, test.handler).Id)
+
+			if operation.Status != test.wantStatus || operation.Error != test.wantError {
+				t.Errorf("got %s %q, want %s %q", operation.Status, operation.Error, test.wantStatus, test.wantError)
+			}
+			if operation.Finished == nil || operation.Result == nil {
+				t.Fatalf("no result: %+v", operation)
+			}
+			if test.wantResult != "" && string(operation.Result.Body) != test.wantResult {
+				t.Errorf("result: got %s, want %s", operation.Result.Body, test.wantResult)
+			}
+		})
+	}
+}
+
+func TestAsyncNotRequested(t *testing.T) {
+	operations := newOperations(t)
+	handler := operations.Async("image.pull", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodPost, "/images/create", nil))
+	if recorder.Code != http.StatusNoContent || len(operations.List()) != 0 {
+		t.Errorf("status %d with %d operations", recorder.Code, len(operations.List()))
+	}
+
+	request := httptest.NewRequest(http.MethodPost, "/images/create", nil)
+	request.Header.Set("Prefer", "handling=lenient, respond-async")
+	if !WantsAsync(request) {
+		t.Error("Prefer: respond-async wasn't honoured")
+	}
+}
+
+func TestCancel(t *testing.T) {
+	operations := newOperations(t)
+	started := make(chan struct{})
+	operation := start(t, operations, func(w http.ResponseWriter, r *http.Request) {
+		close(started)
+		<-r.Context().Done()
+		_ = utils.WriteProblem(w, http.StatusInternalServerError, r.Context().Err().Error())
+	})
+	<-started
+
+	if _, err := operations.Delete(operation.Id); types.StatusCode(err) != http.StatusConflict {
+		t.Errorf("deleting a running operation: %v", err)
+	}
+
+	if _, ok := operations.Cancel(operation.Id); !ok {
+		t.Fatal("the operation wasn't found")
+	}
+	if operation = wait(t, operations, operation.Id); operation.Status != types.OperationCancelled {
+		t.Errorf("got %s %q", operation.Status, operation.Error)
+	}
+
+	if deleted, err := operations.Delete(operation.Id); !deleted || err != nil {
+		t.Errorf("deleting: %v, %v", deleted, err)
+	}
+	if _, ok := operations.Get(operation.Id); ok {
+		t.Error("the operation is still there")
+	}
+}
+
+func TestExpire(t *testing.T) {
+	retention, limit := config.OperationRetention, config.OperationLimit
+	config.OperationRetention, config.OperationLimit = time.Hour, 2
+	t.Cleanup(func() { config.OperationRetention, config.OperationLimit = retention, limit })
+
+	operations := newOperations(t)
+	finished := func(age time.Duration) *types.Operation {
+		at := time.Now().Add(-age)
+		return &types.Operation{Status: types.OperationSucceeded, Created: at, Finished: &at}
+	}
+	operations.operations = map[string]*types.Operation{
+		"expired": finished(2 * time.Hour),
+		"oldest":  finished(30 * time.Minute),
+		"older":   finished(20 * time.Minute),
+		"newest":  finished(10 * time.Minute),
+		"running": {Status: types.OperationRunning, Created: time.Now().Add(-3 * time.Hour)},
+	}
+	for id, operation := range operations.operations {
+		operation.Id = id
+	}
+
+	got := []string{}
+	for _, operation := range operations.List() {
+		got = append(got, operation.Id)
+	}
+	if want := "newest older running"; strings.Join(got, " ") != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+func TestRestartMarksRunningFailed(t *testing.T) {
+	dataDir, persist := config.DataDir, config.PersistOperations
+	config.DataDir, config.PersistOperations = t.TempDir(), true
+	t.Cleanup(func() { config.DataDir, config.PersistOperations = dataDir, persist })
+
+	store, err := utils.NewJsonStore[types.Operation](filepath.Join(config.DataDir, "operations.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	finished := time.Now().UTC()
+	for _, operation := range []types.Operation{
+		{Id: "interrupted", Status: types.OperationRunning, Created: finished},
+		{Id: "done", Status: types.OperationSucceeded, Created: finished, Finished: &finished},
+	} {
+		if err := store.Put(operation.Id, operation); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	operations, err := NewOperations()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if operation, _ := operations.Get("interrupted"); operation.Status != types.OperationFailed || operation.Finished == nil || operation.Error == "" {
+		t.Errorf("interrupted: %+v", operation)
+	}
+	if operation, _ := operations.Get("done"); operation.Status != types.OperationSucceeded {
+		t.Errorf("done: %+v", operation)
+	}
+
+	// The new status is on disk, and operations started now are saved as well
+	operation := wait(t, operations, start(t, operations, func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}).Id)
+	reloaded, err := NewOperations()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if interrupted, _ := reloaded.Get("interrupted"); interrupted.Status != types.OperationFailed {
+		t.Errorf("the failure wasn't saved: %+v", interrupted)
+	}
+	if saved, ok := reloaded.Get(operation.Id); !ok || saved.Status != types.OperationSucceeded {
+		t.Errorf("the new operation wasn't saved: %+v", saved)
+	}
+}
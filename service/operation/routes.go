@@ -0,0 +1,80 @@
+package operation
+
+import (
+	"fmt"
+	. "github.com/LysetsDal/docker-api/types"
+	. "github.com/LysetsDal/docker-api/utils"
+	"github.com/gorilla/mux"
+	"net/http"
+)
+
+type Handler struct {
+	Operations *Operations
+}
+
+func NewHandler(operations *Operations) *Handler {
+	return &Handler{
+		Operations: operations,
+	}
+}
+
+// RegisterRoutes Async operations
+func (h *Handler) RegisterRoutes(router *mux.Router) {
+	router.HandleFunc("/operations", MakeHttpHandleFunc(h.handleListOperations)).Methods(http.MethodGet)
+	router.HandleFunc("/operations/{id}", MakeHttpHandleFunc(h.handleGetOperation)).Methods(http.MethodGet)
+	router.HandleFunc("/operations/{id}", MakeHttpHandleFunc(h.handleDeleteOperation)).Methods(http.MethodDelete)
+	router.HandleFunc("/operations/{id}/cancel", MakeHttpHandleFunc(h.handleCancelOperation)).Methods(http.MethodPost)
+}
+
+// GET Operations newest first. ?status= and ?kind= filter them.
+func (h *Handler) handleListOperations(w http.ResponseWriter, r *http.Request) error {
+	status, kind := r.URL.Query().Get("status"), r.URL.Query().Get("kind")
+
+	operations := make([]Operation, 0)
+	for _, operation := range h.Operations.List() {
+		if (status == "" || operation.Status == status) && (kind == "" || operation.Kind == kind) {
+			operations = append(operations, operation)
+		}
+	}
+
+	return WriteJson(w, http.StatusOK, operations)
+}
+
+// GET Status, progress and, once finished, the result of an operation
+func (h *Handler) handleGetOperation(w http.ResponseWriter, r *http.Request) error {
+	id := mux.Vars(r)["id"]
+
+	operation, ok := h.Operations.Get(id)
+	if !ok {
+		return WriteProblem(w, http.StatusNotFound, fmt.Sprintf("No such operation: %s", id))
+	}
+
+	return WriteJson(w, http.StatusOK, operation)
+}
+
+// POST Cancel a running operation. Cancelling a finished operation does nothing.
+func (h *Handler) handleCancelOperation(w http.ResponseWriter, r *http.Request) error {
+	id := mux.Vars(r)["id"]
+
+	operation, ok := h.Operations.Cancel(id)
+	if !ok {
+		return WriteProblem(w, http.StatusNotFound, fmt.Sprintf("No such operation: %s", id))
+	}
+
+	return WriteJson(w, http.StatusAccepted, operation)
+}
+
+// DELETE Forget a finished operation
+func (h *Handler) handleDeleteOperation(w http.ResponseWriter, r *http.Request) error {
+	id := mux.Vars(r)["id"]
+
+	deleted, err := h.Operations.Delete(id)
+	if err != nil {
+		return WriteError(w, err)
+	}
+	if !deleted {
+		return WriteProblem(w, http.StatusNotFound, fmt.Sprintf("No such operation: %s", id))
+	}
+
+	return WriteJson(w, http.StatusOK, ApiMessage{Message: fmt.Sprintf("Operation %s deleted", id)})
+}
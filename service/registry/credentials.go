@@ -0,0 +1,129 @@
+package registry
+
+import (
+	"encoding/json"
+	"fmt"
+	. "github.com/LysetsDal/docker-api/config"
+	. "github.com/LysetsDal/docker-api/types"
+	. "github.com/LysetsDal/docker-api/utils"
+	"log/slog"
+	"path/filepath"
+	"time"
+)
+
+// CredentialStore Registry credentials by hostname. Passwords and tokens are encrypted with the server key.
+type CredentialStore struct {
+	key     []byte
+	records *JsonStore[RegistryCredential]
+}
+
+// secret The encrypted part of a RegistryCredential
+type secret struct {
+	Password string `json:"Password,omitempty"`
+	Token    string `json:"Token,omitempty"`
+}
+
+// NewCredentialStore Load DataDir/credentials.json, encrypted with the key in KeyFile
+func NewCredentialStore() (*CredentialStore, error) {
+	key, err := LoadOrCreateKey(KeyFile)
+	if err != nil {
+		return nil, fmt.Errorf("loading server key: %w", err)
+	}
+
+	records, err := NewJsonStore[RegistryCredential](filepath.Join(DataDir, "credentials.json"))
+	if err != nil {
+		return nil, fmt.Errorf("loading registry credentials: %w", err)
+	}
+
+	return &CredentialStore{key: key, records: records}, nil
+}
+
+func (s *CredentialStore) Put(registry string, request RegistryCredentialRequest) (RegistryCredentialInfo, error) {
+	plaintext, err := json.Marshal(secret{Password: request.Password, Token: request.Token})
+	if err != nil {
+		return RegistryCredentialInfo{}, err
+	}
+	encrypted, err := Encrypt(s.key, plaintext)
+	if err != nil {
+		return RegistryCredentialInfo{}, err
+	}
+
+	credential := RegistryCredential{
+		Registry: registry,
+		Username: request.Username,
+		Secret:   encrypted,
+		Updated:  time.Now().UTC(),
+	}
+	if err := s.records.Put(registry, credential); err != nil {
+		return RegistryCredentialInfo{}, err
+	}
+	return info(credential), nil
+}
+
+func (s *CredentialStore) Delete(registry string) (bool, error) {
+	return s.records.Delete(registry)
+}
+
+// List Stored credentials without their secrets
+func (s *CredentialStore) List() []RegistryCredentialInfo {
+	credentials := s.records.List()
+	infos := make([]RegistryCredentialInfo, 0, len(credentials))
+	for _, credential := range credentials {
+		infos = append(infos, info(credential))
+	}
+	return infos
+}
+
+// AuthConfig Decrypted credentials for the registry
+func (s *CredentialStore) AuthConfig(registry string) (AuthConfig, bool) {
+	credential, ok := s.records.Get(registry)
+	if !ok {
+		return AuthConfig{}, false
+	}
+
+	plaintext, err := Decrypt(s.key, credential.Secret)
+	if err != nil {
+		slog.Error("decrypting registry credentials", "registry", registry, "error", err)
+		return AuthConfig{}, false
+	}
+	decrypted := secret{}
+	if err := json.Unmarshal(plaintext, &decrypted); err != nil {
+		slog.Error("decoding registry credentials", "registry", registry, "error", err)
+		return AuthConfig{}, false
+	}
+
+	return AuthConfig{
+		Username:      credential.Username,
+		Password:      decrypted.Password,
+		RegistryToken: decrypted.Token,
+		ServerAddress: ServerAddress(registry),
+	}, true
+}
+
+// AuthConfigs Every stored credential, keyed by server address
+func (s *CredentialStore) AuthConfigs() map[string]AuthConfig {
+	configs := map[string]AuthConfig{}
+	for _, registry := range s.records.Names() {
+		if auth, ok := s.AuthConfig(registry); ok {
+			configs[auth.ServerAddress] = auth
+		}
+	}
+	return configs
+}
+
+// ServerAddress The address the daemon knows the registry by
+func ServerAddress(registry string) string {
+	if registry == "docker.io" {
+		return "https://index.docker.io/v1/"
+	}
+	return registry
+}
+
+func info(credential RegistryCredential) RegistryCredentialInfo {
+	return RegistryCredentialInfo{
+		Registry: credential.Registry,
+		Username: credential.Username,
+		Token:    credential.Username == "",
+		Updated:  credential.Updated,
+	}
+}
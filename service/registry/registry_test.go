@@ -0,0 +1,187 @@
+package registry
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/LysetsDal/docker-api/client"
+	"github.com/LysetsDal/docker-api/client/clienttest"
+	"github.com/LysetsDal/docker-api/config"
+	"github.com/LysetsDal/docker-api/types"
+	"github.com/LysetsDal/docker-api/utils"
+	"github.com/gorilla/mux"
+)
+
+// registryStandIn Answers the /v2/ ping like registry:2 behind htpasswd auth, recording the users it let in
+func registryStandIn(t *testing.T, username, password string) (*httptest.Server, func() []string) {
+	var mu sync.Mutex
+	var users []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Docker-Distribution-API-Version", "registry/2.0")
+		user, pass, ok := r.BasicAuth()
+		if !ok || user != username || pass != password {
+			w.Header().Set("WWW-Authenticate", `Basic realm="Registry Realm"`)
+			http.Error(w, `{"errors":[{"code":"UNAUTHORIZED","message":"authentication required"}]}`, http.StatusUnauthorized)
+			return
+		}
+		mu.Lock()
+		users = append(users, user)
+		mu.Unlock()
+		_, _ = w.Write([]byte("{}"))
+	}))
+	t.Cleanup(server.Close)
+
+	return server, func() []string {
+		mu.Lock()
+		defer mu.Unlock()
+		return append([]string(nil), users...)
+	}
+}
+
+// daemonStandIn A Docker daemon that, like dockerd, logs in to the registry with the credentials it is
+// sent: the /auth body, or X-Registry-Auth on pulls and pushes
+func daemonStandIn(t *testing.T, registry *httptest.Server) http.RoundTripper {
+	ping := func(auth types.AuthConfig) bool {
+		request, _ := http.NewRequest(http.MethodGet, registry.URL+"/v2/", nil)
+		if auth.Username != "" {
+			request.SetBasicAuth(auth.Username, auth.Password)
+		}
+		response, err := registry.Client().Do(request)
+		if err != nil {
+			t.Errorf("registry: %v", err)
+			return false
+		}
+		_ = response.Body.Close()
+		return response.StatusCode == http.StatusOK
+	}
+
+	daemon := clienttest.NewDaemon(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/auth" {
+			auth := types.AuthConfig{}
+			_ = json.NewDecoder(r.Body).Decode(&auth)
+			if auth.ServerAddress != registry.Listener.Addr().String() || !ping(auth) {
+				http.Error(w, `{"message":"login attempt failed with status: 401 Unauthorized"}`, http.StatusUnauthorized)
+				return
+			}
+			_, _ = w.Write([]byte(`{"Status":"Login Succeeded"}`))
+			return
+		}
+
+		auth := types.AuthConfig{}
+		if header := r.Header.Get(client.RegistryAuthHeader); header != "" {
+			decoded, err := base64.URLEncoding.DecodeString(header)
+			if err != nil {
+				t.Errorf("%s isn't base64url: %v", client.RegistryAuthHeader, err)
+			}
+			_ = json.Unmarshal(decoded, &auth)
+		} else if strings.HasSuffix(r.URL.Path, "/push") {
+			http.Error(w, `{"message":"Bad parameters and missing X-Registry-Auth"}`, http.StatusBadRequest)
+			return
+		}
+
+		// The daemon answers 200 and reports registry errors in the stream
+		if !ping(auth) {
+			_, _ = w.Write([]byte(`{"errorDetail":{"message":"unauthorized: authentication required"},"error":"unauthorized: authentication required"}` + "\n"))
+			return
+		}
+		_, _ = w.Write([]byte(`{"status":"done"}` + "\n"))
+	})
+	return daemon.Transport()
+}
+
+func newCredentialStore(t *testing.T) *CredentialStore {
+	dataDir, keyFile := config.DataDir, config.KeyFile
+	config.DataDir = t.TempDir()
+	config.KeyFile = filepath.Join(config.DataDir, "server.key")
+	t.Cleanup(func() { config.DataDir, config.KeyFile = dataDir, keyFile })
+
+	credentials, err := NewCredentialStore()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return credentials
+}
+
+func TestRegistryCredentials(t *testing.T) {
+	registry, users := registryStandIn(t, "ci", "s3cret")
+	host := registry.Listener.Addr().String()
+	image := host + "/app:1"
+
+	credentials := newCredentialStore(t)
+	h := &Handler{
+		Docker:      client.NewDockerClient(http.Client{Transport: &client.RegistryAuthTransport{Base: daemonStandIn(t, registry), Credentials: credentials}}),
+		Credentials: credentials,
+	}
+
+	putCredentials := func(body string) *httptest.ResponseRecorder {
+		request := httptest.NewRequest(http.MethodPut, "/registries/"+host+"/credentials?verify=true", strings.NewReader(body))
+		recorder := httptest.NewRecorder()
+		utils.MakeHttpHandleFunc(h.handlePutCredentials)(recorder, mux.SetURLVars(request, map[string]string{"registry": host}))
+		return recorder
+	}
+	push := func() error {
+		progress, err := h.Docker.PushImage(context.Background(), image)
+		if err != nil {
+			return err
+		}
+		defer progress.Close()
+		return client.ReadJsonMessages(progress, nil)
+	}
+
+	if err := push(); err == nil || !strings.Contains(err.Error(), "unauthorized") {
+		t.Errorf("push without credentials: %v", err)
+	}
+
+	if recorder := putCredentials(`{"Username":"ci","Password":"wrong"}`); recorder.Code != http.StatusBadRequest {
+		t.Errorf("wrong password verified: %d %s", recorder.Code, recorder.Body)
+	}
+	if stored := credentials.List(); len(stored) != 0 {
+		t.Fatalf("credentials that failed verification were stored: %+v", stored)
+	}
+
+	if recorder := putCredentials(`{"Username":"ci","Password":"s3cret"}`); recorder.Code != http.StatusOK {
+		t.Fatalf("verifying credentials: %d %s", recorder.Code, recorder.Body)
+	}
+	saved, err := os.ReadFile(filepath.Join(config.DataDir, "credentials.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(saved), "s3cret") {
+		t.Errorf("the password was stored in plain text: %s", saved)
+	}
+
+	if err := push(); err != nil {
+		t.Errorf("push: %v", err)
+	}
+	if err := h.Docker.PullImage(context.Background(), image, nil); err != nil {
+		t.Errorf("pull: %v", err)
+	}
+	// Docker Hub images don't get this registry's credentials
+	if err := h.Docker.PullImage(context.Background(), "nginx:latest", nil); err == nil {
+		t.Error("the credentials were sent for a Docker Hub pull")
+	}
+
+	// The verification, the push and the pull
+	if got := users(); fmt.Sprint(got) != "[ci ci ci]" {
+		t.Errorf("registry logins: %v", got)
+	}
+
+	request := httptest.NewRequest(http.MethodDelete, "/registries/"+host+"/credentials", nil)
+	recorder := httptest.NewRecorder()
+	utils.MakeHttpHandleFunc(h.handleDeleteCredentials)(recorder, mux.SetURLVars(request, map[string]string{"registry": host}))
+	if recorder.Code != http.StatusOK {
+		t.Fatalf("deleting credentials: %d %s", recorder.Code, recorder.Body)
+	}
+	if err := push(); err == nil {
+		t.Error("push succeeded after the credentials were deleted")
+	}
+}
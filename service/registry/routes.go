@@ -0,0 +1,88 @@
+package registry
+
+import (
+	"fmt"
+	. "github.com/LysetsDal/docker-api/client"
+	. "github.com/LysetsDal/docker-api/types"
+	. "github.com/LysetsDal/docker-api/utils"
+	"github.com/gorilla/mux"
+	"net/http"
+	"regexp"
+)
+
+var registryHostRegex = regexp.MustCompile(`^[a-z0-9]([a-z0-9.-]*[a-z0-9])?(:[0-9]+)?$`)
+
+type Handler struct {
+	Docker      *DockerClient
+	Credentials *CredentialStore
+}
+
+func NewHandler(sock http.Client, credentials *CredentialStore) *Handler {
+	return &Handler{
+		Docker:      NewDockerClient(sock),
+		Credentials: credentials,
+	}
+}
+
+// RegisterRoutes Registry credentials. Pulls and pushes pick them up through RegistryAuthTransport.
+func (h *Handler) RegisterRoutes(router *mux.Router) {
+	router.HandleFunc("/registries", MakeHttpHandleFunc(h.handleListCredentials)).Methods(http.MethodGet)
+	router.HandleFunc("/registries/{registry}/credentials", MakeHttpHandleFunc(h.handlePutCredentials)).Methods(http.MethodPut)
+	router.HandleFunc("/registries/{registry}/credentials", MakeHttpHandleFunc(h.handleDeleteCredentials)).Methods(http.MethodDelete)
+}
+
+// GET Registries with stored credentials, secrets left out
+func (h *Handler) handleListCredentials(w http.ResponseWriter, _ *http.Request) error {
+	return WriteJson(w, http.StatusOK, h.Credentials.List())
+}
+
+// PUT Store credentials for a registry hostname such as registry.example.com:5000 or docker.io.
+// ?verify=true checks a username and password against the registry first.
+func (h *Handler) handlePutCredentials(w http.ResponseWriter, r *http.Request) error {
+	registry := NormalizeRegistryHost(mux.Vars(r)["registry"])
+	if !registryHostRegex.MatchString(registry) {
+		return BadRequest("invalid registry hostname %q", registry)
+	}
+
+	request := RegistryCredentialRequest{}
+	if err := ParseJsonStrict(r, &request); err != nil {
+		return BadRequest("invalid credentials: %w", err)
+	}
+	switch {
+	case request.Token != "" && (request.Username != "" || request.Password != ""):
+		return BadRequest("set either Username and Password, or Token")
+	case request.Token == "" && (request.Username == "" || request.Password == ""):
+		return BadRequest("username and password are required unless a token is given")
+	}
+
+	if r.URL.Query().Get("verify") == "true" {
+		if request.Token != "" {
+			return BadRequest("only a username and password can be verified")
+		}
+		auth := AuthConfig{Username: request.Username, Password: request.Password, ServerAddress: ServerAddress(registry)}
+		if _, err := h.Docker.Login(r.Context(), auth); err != nil {
+			return WriteProblem(w, StatusCode(err), fmt.Sprintf("verifying credentials for %s: %s", registry, err))
+		}
+	}
+
+	credential, err := h.Credentials.Put(registry, request)
+	if err != nil {
+		return WriteError(w, err)
+	}
+
+	return WriteJson(w, http.StatusOK, credential)
+}
+
+func (h *Handler) handleDeleteCredentials(w http.ResponseWriter, r *http.Request) error {
+	registry := NormalizeRegistryHost(mux.Vars(r)["registry"])
+
+	deleted, err := h.Credentials.Delete(registry)
+	if err != nil {
+		return WriteError(w, err)
+	}
+	if !deleted {
+		return WriteProblem(w, http.StatusNotFound, fmt.Sprintf("No credentials for registry: %s", registry))
+	}
+
+	return WriteJson(w, http.StatusOK, ApiMessage{Message: fmt.Sprintf("Credentials for %s deleted", registry)})
+}
@@ -0,0 +1,339 @@
+package stack
+
+import (
+	"fmt"
+	. "github.com/LysetsDal/docker-api/config"
+	. "github.com/LysetsDal/docker-api/types"
+	. "github.com/LysetsDal/docker-api/utils"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+)
+
+const defaultNetwork = "default"
+
+var stackNameRegex = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]*$`)
+
+// stackPlan Everything needed to deploy a StackFile, resolved up front so that
+// a broken file is rejected before anything is created
+type stackPlan struct {
+	name     string
+	networks []networkPlan
+	volumes  []volumePlan
+	services []servicePlan
+}
+
+type networkPlan struct {
+	name     string
+	external bool
+	request  NetworkCreateRequest
+}
+
+type volumePlan struct {
+	name     string
+	external bool
+	request  VolumeCreateRequest
+}
+
+type servicePlan struct {
+	service       string
+	containerName string
+	payload       Payload
+	extraNetworks map[string]EndpointConfig
+	dependsOn     DependsOn
+}
+
+// buildPlan Validate the stack file and translate it into create requests, services in dependency order
+func buildPlan(stack StackFile) (stackPlan, error) {
+	plan := stackPlan{name: stack.Name}
+	if !stackNameRegex.MatchString(stack.Name) {
+		return plan, BadRequest("invalid stack name %q: use lowercase letters, digits, '-' and '_'", stack.Name)
+	}
+	if len(stack.Services) == 0 {
+		return plan, BadRequest("stack %q defines no services", stack.Name)
+	}
+
+	labels := map[string]string{StackLabel: stack.Name}
+
+	// Services without explicit networks join the stack's default network, like Compose
+	networks := stack.Networks
+	if networks == nil {
+		networks = map[string]StackNetwork{}
+	}
+	for _, service := range stack.Services {
+		if len(service.Networks) == 0 {
+			if _, ok := networks[defaultNetwork]; !ok {
+				networks[defaultNetwork] = StackNetwork{}
+			}
+		}
+	}
+
+	networkNames := map[string]string{}
+	for _, key := range sortedKeys(networks) {
+		network := networks[key]
+		name := resourceName(stack.Name, key, network.Name, network.External)
+		networkNames[key] = name
+		plan.networks = append(plan.networks, networkPlan{
+			name:     name,
+			external: network.External,
+			request: NetworkCreateRequest{
+				Name:           name,
+				Driver:         network.Driver,
+				Internal:       network.Internal,
+				Attachable:     network.Attachable,
+				Labels:         mergeLabels(network.Labels, labels),
+				Options:        network.DriverOpts,
+				CheckDuplicate: true,
+			},
+		})
+	}
+
+	volumeNames := map[string]string{}
+	for _, key := range sortedKeys(stack.Volumes) {
+		volume := stack.Volumes[key]
+		name := resourceName(stack.Name, key, volume.Name, volume.External)
+		volumeNames[key] = name
+		plan.volumes = append(plan.volumes, volumePlan{
+			name:     name,
+			external: volume.External,
+			request: VolumeCreateRequest{
+				Name:       name,
+				Driver:     volume.Driver,
+				DriverOpts: volume.DriverOpts,
+				Labels:     mergeLabels(volume.Labels, labels),
+			},
+		})
+	}
+
+	order, err := dependencyOrder(stack.Services)
+	if err != nil {
+		return plan, err
+	}
+
+	for _, name := range order {
+		service, err := buildService(stack.Name, name, stack.Services[name], networkNames, volumeNames)
+		if err != nil {
+			return plan, BadRequest("service %q: %w", name, err)
+		}
+		plan.services = append(plan.services, service)
+	}
+
+	return plan, nil
+}
+
+func buildService(stackName, name string, service StackService, networkNames, volumeNames map[string]string) (servicePlan, error) {
+	plan := servicePlan{
+		service:       name,
+		containerName: service.ContainerName,
+		dependsOn:     service.DependsOn,
+		extraNetworks: map[string]EndpointConfig{},
+	}
+	if plan.containerName == "" {
+		plan.containerName = fmt.Sprintf("%s-%s-1", stackName, name)
+	}
+	if service.Image == "" {
+		return plan, BadRequest("image is required")
+	}
+
+	payload := Payload{
+		Hostname:   service.Hostname,
+		User:       service.User,
+		WorkingDir: service.WorkingDir,
+		Image:      service.Image,
+		Cmd:        StrSlice(service.Command),
+		Entrypoint: StrSlice(service.Entrypoint),
+		Env:        service.Environment.List(),
+		Labels: mergeLabels(service.Labels, map[string]string{
+			StackLabel:        stackName,
+			StackServiceLabel: name,
+		}),
+	}
+
+	for _, spec := range service.Ports {
+		mappings, err := ParsePortSpec(spec)
+		if err != nil {
+			return plan, err
+		}
+		ApplyPortMappings(&payload, mappings)
+	}
+
+	for _, spec := range service.Volumes {
+		volume, err := ParseVolumeSpec(spec)
+		if err != nil {
+			return plan, err
+		}
+		switch {
+		case volume.IsAnonymous():
+			if payload.Volumes == nil {
+				payload.Volumes = map[string]struct{}{}
+			}
+			payload.Volumes[volume.Target] = struct{}{}
+			continue
+		case volume.IsBind():
+			if !strings.HasPrefix(volume.Source, "/") {
+				return plan, BadRequest("bind mount %q must use an absolute host path", spec)
+			}
+		default:
+			named, ok := volumeNames[volume.Source]
+			if !ok {
+				return plan, BadRequest("volume %q is not declared in the top-level volumes", volume.Source)
+			}
+			volume.Source = named
+		}
+		payload.HostConfig.Binds = append(payload.HostConfig.Binds, volume.Bind())
+	}
+
+	if service.Restart != "" {
+		policy, err := ParseRestartPolicy(service.Restart)
+		if err != nil {
+			return plan, err
+		}
+		payload.HostConfig.RestartPolicy = policy
+	}
+
+	if service.Healthcheck != nil {
+		healthcheck, err := buildHealthcheck(*service.Healthcheck)
+		if err != nil {
+			return plan, err
+		}
+		payload.Healthcheck = healthcheck
+	}
+
+	// The container is created on its first network, the rest are connected before it starts
+	serviceNetworks := service.Networks
+	if len(serviceNetworks) == 0 {
+		serviceNetworks = ServiceNetworks{defaultNetwork: {}}
+	}
+	for i, key := range sortedKeys(serviceNetworks) {
+		network, ok := networkNames[key]
+		if !ok {
+			return plan, BadRequest("network %q is not declared in the top-level networks", key)
+		}
+		endpoint := EndpointConfig{Aliases: append([]string{name}, serviceNetworks[key].Aliases...)}
+		if i == 0 {
+			payload.HostConfig.NetworkMode = network
+			payload.NetworkingConfig.EndpointsConfig = map[string]EndpointConfig{network: endpoint}
+			continue
+		}
+		plan.extraNetworks[network] = endpoint
+	}
+
+	if err := ValidatePayload(payload); err != nil {
+		return plan, err
+	}
+
+	plan.payload = payload
+	return plan, nil
+}
+
+// buildHealthcheck Compose uses duration strings, Docker wants nanoseconds
+func buildHealthcheck(healthcheck StackHealthcheck) (*HealthConfig, error) {
+	if healthcheck.Disable {
+		return &HealthConfig{Test: []string{"NONE"}}, nil
+	}
+
+	test := []string(healthcheck.Test)
+	if len(test) > 0 && test[0] != "CMD" && test[0] != "CMD-SHELL" && test[0] != "NONE" {
+		test = []string{"CMD-SHELL", strings.Join(test, " ")}
+	}
+
+	config := &HealthConfig{Test: test, Retries: healthcheck.Retries}
+	durations := []struct {
+		value  string
+		target *int64
+	}{
+		{healthcheck.Interval, &config.Interval},
+		{healthcheck.Timeout, &config.Timeout},
+		{healthcheck.StartPeriod, &config.StartPeriod},
+	}
+	for _, duration := range durations {
+		if duration.value == "" {
+			continue
+		}
+		parsed, err := time.ParseDuration(duration.value)
+		if err != nil {
+			return nil, BadRequest("invalid healthcheck duration %q", duration.value)
+		}
+		*duration.target = parsed.Nanoseconds()
+	}
+
+	return config, nil
+}
+
+// dependencyOrder Topological sort of the services (Kahn), alphabetical among independent services
+func dependencyOrder(services map[string]StackService) ([]string, error) {
+	remaining := map[string]int{}
+	dependents := map[string][]string{}
+	for name := range services {
+		remaining[name] = 0
+	}
+	for name, service := range services {
+		for dependency, condition := range service.DependsOn {
+			if _, ok := services[dependency]; !ok {
+				return nil, BadRequest("service %q depends on unknown service %q", name, dependency)
+			}
+			if condition != "service_started" && condition != "service_healthy" {
+				return nil, BadRequest("service %q: unsupported depends_on condition %q", name, condition)
+			}
+			remaining[name]++
+			dependents[dependency] = append(dependents[dependency], name)
+		}
+	}
+
+	order := make([]string, 0, len(services))
+	for len(order) < len(services) {
+		ready := make([]string, 0)
+		for name, count := range remaining {
+			if count == 0 {
+				ready = append(ready, name)
+			}
+		}
+		if len(ready) == 0 {
+			return nil, BadRequest("depends_on contains a cycle between %s", strings.Join(sortedKeys(remaining), ", "))
+		}
+
+		sort.Strings(ready)
+		for _, name := range ready {
+			delete(remaining, name)
+			for _, dependent := range dependents[name] {
+				remaining[dependent]--
+			}
+		}
+		order = append(order, ready...)
+	}
+
+	return order, nil
+}
+
+// resourceName Stack resources are prefixed with the stack name unless external or explicitly named
+func resourceName(stackName, key, explicit string, external bool) string {
+	switch {
+	case explicit != "":
+		return explicit
+	case external:
+		return key
+	default:
+		return stackName + "_" + key
+	}
+}
+
+func mergeLabels(labels map[string]string, extra map[string]string) map[string]string {
+	merged := map[string]string{}
+	for key, value := range labels {
+		merged[key] = value
+	}
+	for key, value := range extra {
+		merged[key] = value
+	}
+	return merged
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
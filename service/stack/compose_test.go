@@ -0,0 +1,178 @@
+package stack
+
+import (
+	"net/http"
+	"reflect"
+	"testing"
+
+	"github.com/LysetsDal/docker-api/types"
+	"gopkg.in/yaml.v3"
+)
+
+func TestDependencyOrder(t *testing.T) {
+	service := func(dependsOn ...string) types.StackService {
+		conditions := types.DependsOn{}
+		for _, dependency := range dependsOn {
+			conditions[dependency] = "service_started"
+		}
+		return types.StackService{DependsOn: conditions}
+	}
+
+	tests := []struct {
+		name     string
+		services map[string]types.StackService
+		want     []string
+		invalid  bool
+	}{
+		{name: "independent", services: map[string]types.StackService{"web": service(), "db": service(), "cache": service()}, want: []string{"cache", "db", "web"}},
+		{name: "chain", services: map[string]types.StackService{"web": service("api"), "api": service("db"), "db": service()}, want: []string{"db", "api", "web"}},
+		{
+			name:     "diamond",
+			services: map[string]types.StackService{"web": service("api", "worker"), "api": service("db"), "worker": service("db"), "db": service(), "cache": service()},
+			want:     []string{"cache", "db", "api", "worker", "web"},
+		},
+		{name: "unknown dependency", services: map[string]types.StackService{"web": service("db")}, invalid: true},
+		{name: "cycle", services: map[string]types.StackService{"a": service("b"), "b": service("a"), "c": service()}, invalid: true},
+		{name: "self", services: map[string]types.StackService{"a": service("a")}, invalid: true},
+		{
+			name:     "unsupported condition",
+			services: map[string]types.StackService{"web": {DependsOn: types.DependsOn{"db": "service_completed_successfully"}}, "db": service()},
+			invalid:  true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, err := dependencyOrder(test.services)
+			if (err != nil) != test.invalid {
+				t.Fatalf("error: %v", err)
+			}
+			if test.invalid {
+				if types.StatusCode(err) != http.StatusBadRequest {
+					t.Errorf("not a bad request: %v", err)
+				}
+				return
+			}
+			if !reflect.DeepEqual(got, test.want) {
+				t.Errorf("got %q, want %q", got, test.want)
+			}
+		})
+	}
+}
+
+func TestBuildPlan(t *testing.T) {
+	const stackFile = `
+name: shop
+services:
+  web:
+    image: nginx
+    command: nginx -g "daemon off;"
+    ports: ["8080:80"]
+    networks: [front, back]
+    depends_on: [api]
+  api:
+    image: shop/api:1
+    environment: [MODE=prod]
+    volumes: ["data:/data", "/srv/conf:/etc/api:ro", "/cache"]
+    networks:
+      back:
+        aliases: [backend]
+    healthcheck:
+      test: curl -f localhost
+      interval: 10s
+networks:
+  front:
+    driver_opts:
+      com.docker.network.bridge.name: br-shop
+  back:
+    internal: true
+  edge:
+    external: true
+volumes:
+  data:
+    driver_opts:
+      type: tmpfs
+`
+	stack := types.StackFile{}
+	if err := yaml.Unmarshal([]byte(stackFile), &stack); err != nil {
+		t.Fatal(err)
+	}
+
+	plan, err := buildPlan(stack)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	networks := map[string]networkPlan{}
+	for _, network := range plan.networks {
+		networks[network.name] = network
+	}
+	if len(networks) != 3 || !networks["edge"].external || !networks["shop_back"].request.Internal {
+		t.Errorf("networks: %+v", plan.networks)
+	}
+	if options := networks["shop_front"].request.Options; options["com.docker.network.bridge.name"] != "br-shop" {
+		t.Errorf("network driver_opts: %v", options)
+	}
+	if len(plan.volumes) != 1 || plan.volumes[0].name != "shop_data" || plan.volumes[0].request.DriverOpts["type"] != "tmpfs" {
+		t.Errorf("volumes: %+v", plan.volumes)
+	}
+
+	if len(plan.services) != 2 || plan.services[0].service != "api" || plan.services[1].service != "web" {
+		t.Fatalf("services: %+v", plan.services)
+	}
+	api, web := plan.services[0], plan.services[1]
+
+	if api.containerName != "shop-api-1" || api.payload.Labels["com.docker-api.stack.service"] != "api" {
+		t.Errorf("api: %s %v", api.containerName, api.payload.Labels)
+	}
+	if want := []string{"shop_data:/data", "/srv/conf:/etc/api:ro"}; !reflect.DeepEqual(api.payload.HostConfig.Binds, want) {
+		t.Errorf("api binds: got %q, want %q", api.payload.HostConfig.Binds, want)
+	}
+	if _, ok := api.payload.Volumes["/cache"]; !ok {
+		t.Errorf("api anonymous volume: %v", api.payload.Volumes)
+	}
+	if healthcheck := api.payload.Healthcheck; healthcheck == nil || healthcheck.Test[0] != "CMD-SHELL" || healthcheck.Interval != 10e9 {
+		t.Errorf("api healthcheck: %+v", healthcheck)
+	}
+	if endpoint := api.payload.NetworkingConfig.EndpointsConfig["shop_back"]; !reflect.DeepEqual(endpoint.Aliases, []string{"api", "backend"}) {
+		t.Errorf("api aliases: %v", endpoint.Aliases)
+	}
+
+	// web is created on its first network and connected to the other before it starts
+	if web.payload.HostConfig.NetworkMode != "shop_back" || len(web.extraNetworks) != 1 {
+		t.Errorf("web networks: %s, extra %v", web.payload.HostConfig.NetworkMode, web.extraNetworks)
+	}
+	if _, ok := web.extraNetworks["shop_front"]; !ok {
+		t.Errorf("web isn't connected to shop_front: %v", web.extraNetworks)
+	}
+	if want := (types.StrSlice{"nginx", "-g", `"daemon`, `off;"`}); !reflect.DeepEqual(web.payload.Cmd, want) {
+		t.Errorf("web command: got %q", web.payload.Cmd)
+	}
+	if bindings := web.payload.HostConfig.PortBindings["80/tcp"]; len(bindings) != 1 || bindings[0].HostPort != "8080" {
+		t.Errorf("web ports: %+v", web.payload.HostConfig.PortBindings)
+	}
+}
+
+func TestBuildPlanInvalid(t *testing.T) {
+	tests := []struct {
+		name  string
+		stack types.StackFile
+	}{
+		{name: "bad name", stack: types.StackFile{Name: "Shop", Services: map[string]types.StackService{"web": {Image: "nginx"}}}},
+		{name: "no services", stack: types.StackFile{Name: "shop"}},
+		{name: "no image", stack: types.StackFile{Name: "shop", Services: map[string]types.StackService{"web": {}}}},
+		{name: "undeclared volume", stack: types.StackFile{Name: "shop", Services: map[string]types.StackService{"web": {Image: "nginx", Volumes: []string{"data:/data"}}}}},
+		{name: "relative bind", stack: types.StackFile{Name: "shop", Services: map[string]types.StackService{"web": {Image: "nginx", Volumes: []string{"./conf:/etc/nginx"}}}}},
+		{name: "undeclared network", stack: types.StackFile{Name: "shop", Services: map[string]types.StackService{"web": {Image: "nginx", Networks: types.ServiceNetworks{"back": {}}}}}},
+		{name: "bad port", stack: types.StackFile{Name: "shop", Services: map[string]types.StackService{"web": {Image: "nginx", Ports: []string{"http"}}}}},
+		{name: "bad healthcheck", stack: types.StackFile{Name: "shop", Services: map[string]types.StackService{"web": {Image: "nginx", Healthcheck: &types.StackHealthcheck{Interval: "often"}}}}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if _, err := buildPlan(test.stack); types.StatusCode(err) != http.StatusBadRequest {
+				t.Errorf("got %v, want a bad request", err)
+			}
+		})
+	}
+}
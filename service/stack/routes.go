@@ -0,0 +1,308 @@
+package stack
+
+import (
+	"context"
+	"fmt"
+	. "github.com/LysetsDal/docker-api/client"
+	. "github.com/LysetsDal/docker-api/config"
+	"github.com/LysetsDal/docker-api/service/idempotency"
+	"github.com/LysetsDal/docker-api/service/operation"
+	. "github.com/LysetsDal/docker-api/types"
+	. "github.com/LysetsDal/docker-api/utils"
+	"github.com/gorilla/mux"
+	"gopkg.in/yaml.v3"
+	"io"
+	"net/http"
+	"sort"
+	"time"
+)
+
+const (
+	maxStackFileSize     = 1 << 20
+	defaultHealthTimeout = 2 * time.Minute
+)
+
+type Handler struct {
+	Docker      *DockerClient
+	Operations  *operation.Operations
+	Idempotency *idempotency.Keys
+}
+
+func NewHandler(sock http.Client, operations *operation.Operations, keys *idempotency.Keys) *Handler {
+	return &Handler{
+		Docker:      NewDockerClient(sock),
+		Operations:  operations,
+		Idempotency: keys,
+	}
+}
+
+// RegisterRoutes Stack controller
+func (h *Handler) RegisterRoutes(router *mux.Router) {
+	router.HandleFunc("/stacks", MakeHttpHandleFunc(h.handleListStacks)).Methods(http.MethodGet)
+	router.Handle("/stacks", h.Idempotency.Wrap(h.Operations.Async("stack.deploy", MakeHttpHandleFunc(h.handleDeployStack)))).Methods(http.MethodPost)
+	router.HandleFunc("/stacks/{name}", MakeHttpHandleFunc(h.handleGetStack)).Methods(http.MethodGet)
+	router.Handle("/stacks/{name}", h.Idempotency.Wrap(h.Operations.Async("stack.remove", MakeHttpHandleFunc(h.handleRemoveStack)))).Methods(http.MethodDelete)
+}
+
+// handleDeployStack
+// POST a Compose-style YAML (or JSON) file. The stack name is taken from ?name= or the top-level name key.
+// ?timeout= bounds how long to wait for service_healthy dependencies.
+func (h *Handler) handleDeployStack(w http.ResponseWriter, r *http.Request) error {
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxStackFileSize))
+	if err != nil {
+		return BadRequest("reading stack file: %w", err)
+	}
+
+	stack := StackFile{}
+	if err := yaml.Unmarshal(body, &stack); err != nil {
+		return BadRequest("invalid stack file: %w", err)
+	}
+	if name := r.URL.Query().Get("name"); name != "" {
+		stack.Name = name
+	}
+
+	healthTimeout := defaultHealthTimeout
+	if timeout := r.URL.Query().Get("timeout"); timeout != "" {
+		if healthTimeout, err = time.ParseDuration(timeout); err != nil {
+			return BadRequest("invalid timeout %q", timeout)
+		}
+	}
+
+	plan, err := buildPlan(stack)
+	if err != nil {
+		return err
+	}
+
+	existing, err := h.Docker.ListContainers(r.Context(), true, stackFilter(plan.name))
+	if err != nil {
+		return WriteError(w, err)
+	}
+	if len(existing) > 0 {
+		return WriteProblem(w, http.StatusConflict, fmt.Sprintf("stack %s is already deployed", plan.name))
+	}
+
+	if err := h.deploy(r.Context(), plan, healthTimeout); err != nil {
+		return WriteProblem(w, StatusCode(err), fmt.Sprintf("deploying stack %s: %s", plan.name, err))
+	}
+
+	summary, err := h.stackSummary(r.Context(), plan.name)
+	if err != nil {
+		return WriteError(w, err)
+	}
+
+	return WriteJson(w, http.StatusCreated, summary)
+}
+
+// deploy Create networks and volumes, then create and start the services in dependency order.
+// Nothing is rolled back on failure; DELETE /stacks/{name} cleans up a partial deploy.
+// Errors keep their kind through the wrapping, so a name in use is a Conflict, a dependency that
+// never became healthy a Conflict or Timeout and a failure of the daemon ErrDaemon.
+func (h *Handler) deploy(ctx context.Context, plan stackPlan, healthTimeout time.Duration) error {
+	for _, network := range plan.networks {
+		if err := h.ensureNetwork(ctx, network); err != nil {
+			return err
+		}
+	}
+
+	for _, volume := range plan.volumes {
+		if volume.external {
+			continue
+		}
+		if _, err := h.Docker.CreateVolume(ctx, volume.request); err != nil {
+			return fmt.Errorf("creating volume %s: %w", volume.name, err)
+		}
+	}
+
+	containerIds := map[string]string{}
+	for _, service := range plan.services {
+		for dependency, condition := range service.dependsOn {
+			if condition != "service_healthy" {
+				continue
+			}
+			if err := h.Docker.WaitHealthy(ctx, containerIds[dependency], healthTimeout); err != nil {
+				return fmt.Errorf("service %s waiting for %s: %w", service.service, dependency, err)
+			}
+		}
+
+		id, err := h.createService(ctx, service)
+		if err != nil {
+			return fmt.Errorf("service %s: %w", service.service, err)
+		}
+		containerIds[service.service] = id
+
+		if err := h.Docker.StartContainer(ctx, id); err != nil {
+			return fmt.Errorf("starting service %s: %w", service.service, err)
+		}
+	}
+
+	return nil
+}
+
+func (h *Handler) ensureNetwork(ctx context.Context, network networkPlan) error {
+	existing, err := h.Docker.ListNetworks(ctx, map[string][]string{"name": {network.name}})
+	if err != nil {
+		return err
+	}
+	for _, candidate := range existing {
+		// The name filter matches substrings
+		if candidate.Name == network.name {
+			return nil
+		}
+	}
+
+	if network.external {
+		return NotFound("external network %s does not exist", network.name)
+	}
+	if _, err := h.Docker.CreateNetwork(ctx, network.request); err != nil {
+		return fmt.Errorf("creating network %s: %w", network.name, err)
+	}
+	return nil
+}
+
+// createService Create the container and attach its extra networks
+func (h *Handler) createService(ctx context.Context, service servicePlan) (string, error) {
+	created, err := h.Docker.CreateContainerWithPull(ctx, service.containerName, service.payload)
+	if err != nil {
+		return "", err
+	}
+
+	for network, endpoint := range service.extraNetworks {
+		request := NetworkConnectRequest{Container: created.Id, EndpointConfig: endpoint}
+		if err := h.Docker.ConnectNetwork(ctx, network, request); err != nil {
+			return "", fmt.Errorf("connecting to network %s: %w", network, err)
+		}
+	}
+
+	return created.Id, nil
+}
+
+// GET List all stacks known from container labels
+func (h *Handler) handleListStacks(w http.ResponseWriter, r *http.Request) error {
+	containers, err := h.Docker.ListContainers(r.Context(), true, map[string][]string{"label": {StackLabel}})
+	if err != nil {
+		return WriteError(w, err)
+	}
+
+	names := map[string]bool{}
+	for _, c := range containers {
+		names[c.Labels[StackLabel]] = true
+	}
+
+	stacks := make([]StackSummary, 0, len(names))
+	for _, name := range sortedKeys(names) {
+		summary, err := h.stackSummary(r.Context(), name)
+		if err != nil {
+			return WriteError(w, err)
+		}
+		stacks = append(stacks, summary)
+	}
+
+	return WriteJson(w, http.StatusOK, stacks)
+}
+
+// GET Services, networks and volumes of a stack
+func (h *Handler) handleGetStack(w http.ResponseWriter, r *http.Request) error {
+	name := mux.Vars(r)["name"]
+
+	summary, err := h.stackSummary(r.Context(), name)
+	if err != nil {
+		return WriteError(w, err)
+	}
+	if len(summary.Services) == 0 && len(summary.Networks) == 0 && len(summary.Volumes) == 0 {
+		return WriteProblem(w, http.StatusNotFound, fmt.Sprintf("No such stack: %s", name))
+	}
+
+	return WriteJson(w, http.StatusOK, summary)
+}
+
+// DELETE Stop and remove the containers and networks of a stack. Volumes are kept unless ?volumes=true.
+func (h *Handler) handleRemoveStack(w http.ResponseWriter, r *http.Request) error {
+	name := mux.Vars(r)["name"]
+	ctx := r.Context()
+
+	summary, err := h.stackSummary(ctx, name)
+	if err != nil {
+		return WriteError(w, err)
+	}
+	if len(summary.Services) == 0 && len(summary.Networks) == 0 && len(summary.Volumes) == 0 {
+		return WriteProblem(w, http.StatusNotFound, fmt.Sprintf("No such stack: %s", name))
+	}
+
+	for _, service := range summary.Services {
+		if err := h.Docker.StopContainer(ctx, service.ContainerId, StopParams{}); err != nil && !IsNotFound(err) {
+			return WriteProblem(w, StatusCode(err), fmt.Sprintf("stopping %s: %s", service.ContainerName, err))
+		}
+		if err := h.Docker.RemoveContainer(ctx, service.ContainerId, true, false); err != nil && !IsNotFound(err) {
+			return WriteProblem(w, StatusCode(err), fmt.Sprintf("removing %s: %s", service.ContainerName, err))
+		}
+	}
+
+	for _, network := range summary.Networks {
+		if err := h.Docker.RemoveNetwork(ctx, network); err != nil && !IsNotFound(err) {
+			return WriteProblem(w, StatusCode(err), fmt.Sprintf("removing network %s: %s", network, err))
+		}
+	}
+
+	if r.URL.Query().Get("volumes") != "true" {
+		summary.Volumes = []string{}
+	}
+	for _, volume := range summary.Volumes {
+		if err := h.Docker.RemoveVolume(ctx, volume, false); err != nil && !IsNotFound(err) {
+			return WriteProblem(w, StatusCode(err), fmt.Sprintf("removing volume %s: %s", volume, err))
+		}
+	}
+
+	return WriteJson(w, http.StatusOK, summary)
+}
+
+// stackSummary Collect the labelled containers, networks and volumes of a stack
+func (h *Handler) stackSummary(ctx context.Context, name string) (StackSummary, error) {
+	filter := stackFilter(name)
+	summary := StackSummary{Name: name, Services: []StackServiceStatus{}, Networks: []string{}, Volumes: []string{}}
+
+	containers, err := h.Docker.ListContainers(ctx, true, filter)
+	if err != nil {
+		return summary, err
+	}
+	for _, c := range containers {
+		containerName := ""
+		if len(c.Names) > 0 {
+			containerName = c.Names[0][1:]
+		}
+		summary.Services = append(summary.Services, StackServiceStatus{
+			Service:       c.Labels[StackServiceLabel],
+			ContainerId:   c.Id,
+			ContainerName: containerName,
+			Image:         c.Image,
+			State:         c.State,
+			Status:        c.Status,
+		})
+	}
+	sort.Slice(summary.Services, func(i, j int) bool {
+		return summary.Services[i].Service < summary.Services[j].Service
+	})
+
+	networks, err := h.Docker.ListNetworks(ctx, filter)
+	if err != nil {
+		return summary, err
+	}
+	for _, network := range networks {
+		summary.Networks = append(summary.Networks, network.Name)
+	}
+	sort.Strings(summary.Networks)
+
+	volumes, err := h.Docker.ListVolumes(ctx, filter)
+	if err != nil {
+		return summary, err
+	}
+	for _, volume := range volumes {
+		summary.Volumes = append(summary.Volumes, volume.Name)
+	}
+	sort.Strings(summary.Volumes)
+
+	return summary, nil
+}
+
+func stackFilter(name string) map[string][]string {
+	return map[string][]string{"label": {StackLabel + "=" + name}}
+}
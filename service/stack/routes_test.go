@@ -0,0 +1,85 @@
+package stack
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/LysetsDal/docker-api/client"
+	"github.com/LysetsDal/docker-api/client/clienttest"
+	"github.com/LysetsDal/docker-api/utils"
+)
+
+func TestDeployErrors(t *testing.T) {
+	const stackFile = `
+services:
+  db:
+    image: postgres:16
+    volumes: ["data:/var/lib/postgresql/data"]
+    healthcheck:
+      test: ["CMD", "pg_isready"]
+  web:
+    image: nginx
+    depends_on:
+      db:
+        condition: service_healthy
+volumes:
+  data: {}
+`
+
+	tests := []struct {
+		name       string
+		stackFile  string
+		fail       string
+		dbState    string
+		wantStatus int
+	}{
+		{name: "name in use", fail: "POST /containers/create", wantStatus: http.StatusConflict},
+		{name: "daemon error", fail: "POST /volumes/create", wantStatus: http.StatusBadGateway},
+		{name: "unhealthy dependency", dbState: `{"Status":"running","Health":{"Status":"unhealthy"}}`, wantStatus: http.StatusConflict},
+		{name: "exited dependency", dbState: `{"Status":"exited"}`, wantStatus: http.StatusConflict},
+		{
+			name:       "missing external network",
+			stackFile:  "services:\n  web:\n    image: nginx\n    networks: [edge]\nnetworks:\n  edge:\n    external: true\n",
+			wantStatus: http.StatusNotFound,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			daemon := clienttest.NewDaemon(t, func(w http.ResponseWriter, r *http.Request) {
+				call := r.Method + " " + r.URL.Path
+				switch {
+				case call == test.fail && call == "POST /containers/create":
+					http.Error(w, `{"message":"Conflict. The container name is already in use"}`, http.StatusConflict)
+				case call == test.fail:
+					http.Error(w, `{"message":"boom"}`, http.StatusInternalServerError)
+				case call == "GET /containers/json", call == "GET /networks":
+					_, _ = w.Write([]byte(`[]`))
+				case call == "POST /containers/create":
+					_, _ = w.Write([]byte(`{"Id":"` + r.URL.Query().Get("name") + `"}`))
+				case call == "GET /containers/stack-db-1/json":
+					_, _ = w.Write([]byte(`{"Id":"stack-db-1","State":` + test.dbState + `}`))
+				case strings.HasSuffix(call, "/create"):
+					_, _ = w.Write([]byte(`{}`))
+				default:
+					w.WriteHeader(http.StatusNoContent)
+				}
+			})
+			h := &Handler{Docker: client.NewDockerClient(daemon.Sock())}
+
+			body := test.stackFile
+			if body == "" {
+				body = stackFile
+			}
+			request := httptest.NewRequest(http.MethodPost, "/stacks?name=stack&timeout=5s", strings.NewReader(body))
+			recorder := httptest.NewRecorder()
+			utils.MakeHttpHandleFunc(h.handleDeployStack)(recorder, request)
+
+			if recorder.Code != test.wantStatus {
+				t.Errorf("status %d, want %d: %s", recorder.Code, test.wantStatus, recorder.Body)
+			}
+		})
+	}
+}
@@ -0,0 +1,364 @@
+package system
+
+import (
+	"context"
+	"fmt"
+	. "github.com/LysetsDal/docker-api/client"
+	. "github.com/LysetsDal/docker-api/types"
+	"sort"
+	"strings"
+	"time"
+)
+
+// stoppedStates Container states a cleanup may remove
+var stoppedStates = map[string]bool{"created": true, "exited": true, "dead": true}
+
+// Cleanup Plans and executes removal of unused containers, images, volumes and build cache
+type Cleanup struct {
+	Docker *DockerClient
+}
+
+// Plan Work out what the policy would remove right now and how much space that frees
+func (c *Cleanup) Plan(ctx context.Context, policy CleanupPolicy) (CleanupPlan, error) {
+	usage, err := c.Docker.DiskUsage(ctx)
+	if err != nil {
+		return CleanupPlan{}, err
+	}
+
+	plan := CleanupPlan{Policy: policy, Created: time.Now().UTC()}
+
+	// Images used only by containers in the plan count as unused
+	removedUsers := map[string]int64{}
+	if policy.Containers != nil {
+		if plan.Containers, err = c.planContainers(ctx, usage.Containers, *policy.Containers); err != nil {
+			return CleanupPlan{}, err
+		}
+		for _, item := range plan.Containers.Items {
+			for _, container := range usage.Containers {
+				if container.Id == item.Id {
+					removedUsers[container.ImageID]++
+				}
+			}
+		}
+	}
+	if policy.Images != nil {
+		plan.Images = planImages(usage.Images, *policy.Images, removedUsers)
+	}
+	if policy.Volumes != nil {
+		plan.Volumes = planVolumes(usage.Volumes, *policy.Volumes)
+	}
+	if policy.BuildCache != nil {
+		plan.BuildCache = planBuildCache(usage.BuildCache, *policy.BuildCache)
+	}
+
+	for _, section := range []*CleanupSection{plan.Containers, plan.Images, plan.Volumes, plan.BuildCache} {
+		if section != nil {
+			plan.Reclaimable += section.Reclaimable
+		}
+	}
+	return plan, nil
+}
+
+// Execute Remove what the plan lists. Each item is checked again first, anything that has
+// started being used since the plan was made is skipped.
+func (c *Cleanup) Execute(ctx context.Context, plan CleanupPlan) (CleanupResult, error) {
+	result := CleanupResult{PlanId: plan.Id, Items: make([]CleanupItemResult, 0)}
+
+	if plan.Containers != nil {
+		usage, err := c.Docker.DiskUsage(ctx)
+		if err != nil {
+			return result, err
+		}
+		containers := map[string]Container{}
+		for _, container := range usage.Containers {
+			containers[container.Id] = container
+		}
+
+		for _, item := range plan.Containers.Items {
+			container, ok := containers[item.Id]
+			switch {
+			case !ok:
+				skip(&result, item, "no longer exists")
+			case !stoppedStates[container.State]:
+				skip(&result, item, "is "+container.State)
+			default:
+				record(&result, item, c.Docker.RemoveContainer(ctx, item.Id, false, false))
+			}
+		}
+	}
+
+	// Fetched after the containers are gone, so images they used count as unused
+	usage, err := c.Docker.DiskUsage(ctx)
+	if err != nil {
+		return result, err
+	}
+
+	if plan.Images != nil {
+		images := map[string]ImageSummary{}
+		for _, image := range usage.Images {
+			images[image.Id] = image
+		}
+
+		for _, item := range plan.Images.Items {
+			image, ok := images[item.Id]
+			switch {
+			case !ok:
+				skip(&result, item, "no longer exists")
+			case image.Containers > 0:
+				skip(&result, item, "is used by a container")
+			default:
+				// Forced, so an image tagged in several repositories goes in one call
+				record(&result, item, c.Docker.RemoveImage(ctx, item.Id, true))
+			}
+		}
+	}
+
+	if plan.Volumes != nil {
+		volumes := map[string]Volume{}
+		for _, volume := range usage.Volumes {
+			volumes[volume.Name] = volume
+		}
+
+		for _, item := range plan.Volumes.Items {
+			volume, ok := volumes[item.Id]
+			switch {
+			case !ok:
+				skip(&result, item, "no longer exists")
+			case volume.UsageData != nil && volume.UsageData.RefCount > 0:
+				skip(&result, item, "is used by a container")
+			default:
+				record(&result, item, c.Docker.RemoveVolume(ctx, item.Id, false))
+			}
+		}
+	}
+
+	if plan.BuildCache != nil && len(plan.BuildCache.Items) > 0 {
+		filters := map[string][]string{}
+		if hours := plan.Policy.BuildCache.OlderThanHours; hours > 0 {
+			filters["until"] = []string{fmt.Sprintf("%dh", hours)}
+		}
+
+		pruned, err := c.Docker.PruneBuildCache(ctx, plan.Policy.BuildCache.All, filters)
+		deleted := map[string]bool{}
+		for _, id := range pruned.CachesDeleted {
+			deleted[id] = true
+		}
+		for _, item := range plan.BuildCache.Items {
+			switch {
+			case err != nil:
+				record(&result, item, err)
+			case deleted[item.Id]:
+				record(&result, item, nil)
+			default:
+				skip(&result, item, "was not pruned by the daemon")
+			}
+		}
+		// The daemon knows exactly what it freed, the planned sizes are estimates
+		if err == nil {
+			result.SpaceReclaimed += pruned.SpaceReclaimed - plannedSize(plan.BuildCache.Items, deleted)
+		}
+	}
+
+	return result, nil
+}
+
+func (c *Cleanup) planContainers(ctx context.Context, containers []Container, policy ContainerCleanup) (*CleanupSection, error) {
+	section := &CleanupSection{Items: make([]CleanupItem, 0)}
+	cutoff := time.Now().Add(-time.Duration(policy.OlderThanHours) * time.Hour)
+
+	for _, container := range containers {
+		created := time.Unix(container.Created, 0)
+		if !stoppedStates[container.State] || created.After(cutoff) {
+			continue
+		}
+
+		// Age is counted from when the container stopped, which needs an inspect
+		stopped := created
+		inspect, err := c.Docker.InspectContainer(ctx, container.Id)
+		if IsNotFound(err) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		if finished, err := time.Parse(time.RFC3339Nano, inspect.State.FinishedAt); err == nil && finished.After(stopped) {
+			stopped = finished
+		}
+		if stopped.After(cutoff) {
+			continue
+		}
+
+		add(section, CleanupItem{
+			Id:     container.Id,
+			Name:   container.Name(),
+			Size:   container.SizeRw,
+			Reason: fmt.Sprintf("%s for %s", container.State, time.Since(stopped).Truncate(time.Minute)),
+		})
+	}
+	return section, nil
+}
+
+func planImages(images []ImageSummary, policy ImageCleanup, removedUsers map[string]int64) *CleanupSection {
+	section := &CleanupSection{Items: make([]CleanupItem, 0)}
+
+	// Rank every tagged image within its repositories, newest first. An image tagged several times in
+	// one repository (app:1.2 and app:latest) counts once.
+	byRepo := map[string][]ImageSummary{}
+	inRepo := map[string]map[string]bool{}
+	for _, image := range images {
+		for _, tag := range imageTags(image) {
+			repo, _ := SplitImageRef(tag)
+			if inRepo[repo] == nil {
+				inRepo[repo] = map[string]bool{}
+			}
+			if !inRepo[repo][image.Id] {
+				inRepo[repo][image.Id] = true
+				byRepo[repo] = append(byRepo[repo], image)
+			}
+		}
+	}
+	rank := map[string]map[string]int{}
+	for repo, repoImages := range byRepo {
+		sort.SliceStable(repoImages, func(i, j int) bool { return repoImages[i].Created > repoImages[j].Created })
+		rank[repo] = map[string]int{}
+		for i, image := range repoImages {
+			rank[repo][image.Id] = i
+		}
+	}
+
+	for _, image := range images {
+		if image.Containers-removedUsers[image.Id] > 0 {
+			continue
+		}
+
+		tags := imageTags(image)
+		reason := ""
+		switch {
+		case len(tags) == 0 && policy.Dangling:
+			reason = "dangling"
+		case len(tags) > 0 && policy.Unused:
+			kept := false
+			for _, tag := range tags {
+				repo, _ := SplitImageRef(tag)
+				kept = kept || rank[repo][image.Id] < policy.KeepLast
+			}
+			if !kept {
+				reason = fmt.Sprintf("unused and not among the %d newest of its repository", policy.KeepLast)
+			}
+		}
+		if reason == "" {
+			continue
+		}
+
+		name := shortId(image.Id)
+		if len(tags) > 0 {
+			name = strings.Join(tags, ", ")
+		}
+		size := image.Size
+		if image.SharedSize > 0 {
+			size -= image.SharedSize
+		}
+		add(section, CleanupItem{Id: image.Id, Name: name, Size: size, Reason: reason})
+	}
+	return section
+}
+
+func planVolumes(volumes []Volume, policy VolumeCleanup) *CleanupSection {
+	section := &CleanupSection{Items: make([]CleanupItem, 0)}
+
+	for _, volume := range volumes {
+		if volume.UsageData == nil || volume.UsageData.RefCount != 0 || isProtected(volume.Labels, policy.ProtectedLabels) {
+			continue
+		}
+
+		size := volume.UsageData.Size
+		if size < 0 {
+			size = 0
+		}
+		add(section, CleanupItem{Id: volume.Name, Name: volume.Name, Size: size, Reason: "not used by any container"})
+	}
+	return section
+}
+
+func planBuildCache(records []BuildCacheRecord, policy BuildCacheCleanup) *CleanupSection {
+	section := &CleanupSection{Items: make([]CleanupItem, 0)}
+	cutoff := time.Now().Add(-time.Duration(policy.OlderThanHours) * time.Hour)
+
+	for _, record := range records {
+		lastUsed := record.LastUsedAt
+		if lastUsed.IsZero() {
+			lastUsed = record.CreatedAt
+		}
+		if record.InUse || (record.Shared && !policy.All) || lastUsed.After(cutoff) {
+			continue
+		}
+
+		name := record.Description
+		if name == "" {
+			name = record.Type
+		}
+		add(section, CleanupItem{Id: record.ID, Name: name, Size: record.Size, Reason: fmt.Sprintf("unused since %s", lastUsed.Format(time.RFC3339))})
+	}
+	return section
+}
+
+func add(s *CleanupSection, item CleanupItem) {
+	s.Items = append(s.Items, item)
+	s.Reclaimable += item.Size
+}
+
+func record(r *CleanupResult, item CleanupItem, err error) {
+	if err != nil {
+		r.Items = append(r.Items, CleanupItemResult{Id: item.Id, Name: item.Name, Status: "failed", Error: err.Error()})
+		r.Failed++
+		return
+	}
+	r.Items = append(r.Items, CleanupItemResult{Id: item.Id, Name: item.Name, Status: "removed"})
+	r.Removed++
+	r.SpaceReclaimed += item.Size
+}
+
+func skip(r *CleanupResult, item CleanupItem, reason string) {
+	r.Items = append(r.Items, CleanupItemResult{Id: item.Id, Name: item.Name, Status: "skipped", Error: reason})
+	r.Skipped++
+}
+
+func plannedSize(items []CleanupItem, deleted map[string]bool) int64 {
+	var size int64
+	for _, item := range items {
+		if deleted[item.Id] {
+			size += item.Size
+		}
+	}
+	return size
+}
+
+// isProtected Reports whether labels match one of the "key" or "key=value" selectors
+func isProtected(labels map[string]string, selectors []string) bool {
+	for _, selector := range selectors {
+		key, value, hasValue := strings.Cut(selector, "=")
+		if actual, ok := labels[key]; ok && (!hasValue || actual == value) {
+			return true
+		}
+	}
+	return false
+}
+
+// imageTags RepoTags without the <none>:<none> placeholder of dangling images
+func imageTags(image ImageSummary) []string {
+	tags := make([]string, 0, len(image.RepoTags))
+	for _, tag := range image.RepoTags {
+		if tag != "<none>:<none>" {
+			tags = append(tags, tag)
+		}
+	}
+	return tags
+}
+
+func shortId(id string) string {
+	id = strings.TrimPrefix(id, "sha256:")
+	if len(id) > 12 {
+		return id[:12]
+	}
+	return id
+}
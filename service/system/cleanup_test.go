@@ -0,0 +1,131 @@
+package system
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/LysetsDal/docker-api/types"
+)
+
+// ids The ids of the section's items, in order
+func ids(section *types.CleanupSection) []string {
+	ids := []string{}
+	for _, item := range section.Items {
+		ids = append(ids, item.Id)
+	}
+	return ids
+}
+
+func TestPlanImages(t *testing.T) {
+	image := func(id string, created int64, containers int64, tags ...string) types.ImageSummary {
+		return types.ImageSummary{Id: id, Created: created, Containers: containers, RepoTags: tags, Size: 100}
+	}
+
+	tests := []struct {
+		name         string
+		images       []types.ImageSummary
+		policy       types.ImageCleanup
+		removedUsers map[string]int64
+		want         []string
+	}{
+		{
+			name:   "dangling",
+			images: []types.ImageSummary{image("a", 1, 0), image("b", 2, 0, "<none>:<none>"), image("c", 3, 0, "app:1"), image("d", 4, 1)},
+			policy: types.ImageCleanup{Dangling: true},
+			want:   []string{"a", "b"},
+		},
+		{
+			name:   "keep last",
+			images: []types.ImageSummary{image("a", 1, 0, "app:1"), image("b", 2, 0, "app:2"), image("c", 3, 0, "app:3"), image("x", 1, 0, "other:1")},
+			policy: types.ImageCleanup{Unused: true, KeepLast: 2},
+			want:   []string{"a"},
+		},
+		{
+			// Three tags on the newest image must not push the older ones out of the two kept
+			name: "several tags on one image",
+			images: []types.ImageSummary{
+				image("a", 1, 0, "app:1"), image("b", 2, 0, "app:2"),
+				image("c", 3, 0, "app:3", "app:latest", "app:stable"),
+			},
+			policy: types.ImageCleanup{Unused: true, KeepLast: 2},
+			want:   []string{"a"},
+		},
+		{
+			name:   "kept in another repository",
+			images: []types.ImageSummary{image("a", 1, 0, "app:1", "registry.local/app:1"), image("b", 2, 0, "app:2")},
+			policy: types.ImageCleanup{Unused: true, KeepLast: 1},
+			want:   []string{},
+		},
+		{
+			name:   "used",
+			images: []types.ImageSummary{image("a", 1, 1, "app:1"), image("b", 2, 2, "app:2")},
+			policy: types.ImageCleanup{Unused: true},
+			want:   []string{"a"},
+			// a's only container is removed by the same plan
+			removedUsers: map[string]int64{"a": 1, "b": 1},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			section := planImages(test.images, test.policy, test.removedUsers)
+			if got := ids(section); !reflect.DeepEqual(got, test.want) {
+				t.Errorf("got %q, want %q", got, test.want)
+			}
+			if section.Reclaimable != int64(100*len(test.want)) {
+				t.Errorf("reclaimable: got %d", section.Reclaimable)
+			}
+		})
+	}
+}
+
+func TestPlanVolumes(t *testing.T) {
+	volume := func(name string, refCount int64, labels map[string]string) types.Volume {
+		return types.Volume{Name: name, Labels: labels, UsageData: &types.VolumeUsageData{Size: 10, RefCount: refCount}}
+	}
+	volumes := []types.Volume{
+		volume("unused", 0, nil),
+		volume("used", 1, nil),
+		volume("kept", 0, map[string]string{"keep": ""}),
+		volume("prod", 0, map[string]string{"env": "prod"}),
+		volume("dev", 0, map[string]string{"env": "dev"}),
+		{Name: "unknown"},
+	}
+
+	section := planVolumes(volumes, types.VolumeCleanup{ProtectedLabels: []string{"keep", "env=prod"}})
+	if got, want := ids(section), []string{"unused", "dev"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got %q, want %q", got, want)
+	}
+	if section.Reclaimable != 20 {
+		t.Errorf("reclaimable: got %d", section.Reclaimable)
+	}
+}
+
+func TestPlanBuildCache(t *testing.T) {
+	old := time.Now().Add(-48 * time.Hour)
+	records := []types.BuildCacheRecord{
+		{ID: "old", CreatedAt: old},
+		{ID: "recent", CreatedAt: old, LastUsedAt: time.Now()},
+		{ID: "in use", CreatedAt: old, InUse: true},
+		{ID: "shared", CreatedAt: old, Shared: true},
+	}
+
+	tests := []struct {
+		name   string
+		policy types.BuildCacheCleanup
+		want   []string
+	}{
+		{name: "dangling", policy: types.BuildCacheCleanup{OlderThanHours: 24}, want: []string{"old"}},
+		{name: "all", policy: types.BuildCacheCleanup{OlderThanHours: 24, All: true}, want: []string{"old", "shared"}},
+		{name: "any age", policy: types.BuildCacheCleanup{All: true}, want: []string{"old", "recent", "shared"}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := ids(planBuildCache(records, test.policy)); !reflect.DeepEqual(got, test.want) {
+				t.Errorf("got %q, want %q", got, test.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,177 @@
+package system
+
+import (
+	"fmt"
+	. "github.com/LysetsDal/docker-api/client"
+	"github.com/LysetsDal/docker-api/service/operation"
+	. "github.com/LysetsDal/docker-api/types"
+	. "github.com/LysetsDal/docker-api/utils"
+	"github.com/gorilla/mux"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// planTTL How long a cleanup plan can be executed after it was made
+const planTTL = 15 * time.Minute
+
+type Handler struct {
+	Docker     *DockerClient
+	Cleanup    *Cleanup
+	Operations *operation.Operations
+
+	mu    sync.Mutex
+	plans map[string]CleanupPlan
+}
+
+func NewHandler(sock http.Client, operations *operation.Operations) *Handler {
+	docker := NewDockerClient(sock)
+	return &Handler{
+		Docker:     docker,
+		Cleanup:    &Cleanup{Docker: docker},
+		Operations: operations,
+		plans:      map[string]CleanupPlan{},
+	}
+}
+
+// RegisterRoutes Disk usage and cleanup
+func (h *Handler) RegisterRoutes(router *mux.Router) {
+	router.HandleFunc("/system/df", MakeHttpHandleFunc(h.handleDiskUsage)).Methods(http.MethodGet)
+	router.HandleFunc("/system/cleanup/plans", MakeHttpHandleFunc(h.handleCreatePlan)).Methods(http.MethodPost)
+	router.HandleFunc("/system/cleanup/plans/{id}", MakeHttpHandleFunc(h.handleGetPlan)).Methods(http.MethodGet)
+	router.Handle("/system/cleanup/plans/{id}/execute", h.Operations.Async("cleanup.execute", MakeHttpHandleFunc(h.handleExecutePlan))).Methods(http.MethodPost)
+}
+
+// GET The daemon's disk usage with a docker system df style summary per type
+func (h *Handler) handleDiskUsage(w http.ResponseWriter, r *http.Request) error {
+	usage, err := h.Docker.DiskUsage(r.Context())
+	if err != nil {
+		return WriteError(w, err)
+	}
+
+	return WriteJson(w, http.StatusOK, DiskUsageReport{Summary: summarize(usage), DiskUsage: usage})
+}
+
+// POST Plan a cleanup without removing anything. The plan lists every item with its size and the
+// space it would reclaim, and can be executed within 15 minutes.
+func (h *Handler) handleCreatePlan(w http.ResponseWriter, r *http.Request) error {
+	policy := CleanupPolicy{}
+	if err := ParseJsonStrict(r, &policy); err != nil {
+		return BadRequest("invalid cleanup policy: %w", err)
+	}
+	if err := ValidateCleanupPolicy(policy); err != nil {
+		return WriteValidationError(w, "invalid cleanup policy", err)
+	}
+
+	plan, err := h.Cleanup.Plan(r.Context(), policy)
+	if err != nil {
+		return WriteError(w, err)
+	}
+	plan.Id = RandomId()
+	plan.Expires = plan.Created.Add(planTTL)
+
+	h.mu.Lock()
+	for id, existing := range h.plans {
+		if time.Now().After(existing.Expires) {
+			delete(h.plans, id)
+		}
+	}
+	h.plans[plan.Id] = plan
+	h.mu.Unlock()
+
+	return WriteJson(w, http.StatusCreated, plan)
+}
+
+func (h *Handler) handleGetPlan(w http.ResponseWriter, r *http.Request) error {
+	id := mux.Vars(r)["id"]
+
+	plan, ok := h.plan(id)
+	if !ok {
+		return WriteProblem(w, http.StatusNotFound, fmt.Sprintf("No such cleanup plan: %s", id))
+	}
+
+	return WriteJson(w, http.StatusOK, plan)
+}
+
+// POST Remove what the plan lists. A plan runs once; items in use by then are skipped.
+func (h *Handler) handleExecutePlan(w http.ResponseWriter, r *http.Request) error {
+	id := mux.Vars(r)["id"]
+
+	h.mu.Lock()
+	plan, ok := h.plans[id]
+	delete(h.plans, id)
+	h.mu.Unlock()
+	if !ok || time.Now().After(plan.Expires) {
+		return WriteProblem(w, http.StatusNotFound, fmt.Sprintf("No such cleanup plan: %s", id))
+	}
+
+	result, err := h.Cleanup.Execute(r.Context(), plan)
+	if err != nil {
+		return WriteError(w, err)
+	}
+
+	return WriteJson(w, http.StatusOK, result)
+}
+
+func (h *Handler) plan(id string) (CleanupPlan, bool) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	plan, ok := h.plans[id]
+	if !ok || time.Now().After(plan.Expires) {
+		return CleanupPlan{}, false
+	}
+	return plan, true
+}
+
+// summarize The rows of docker system df. Reclaimable counts what nothing uses.
+func summarize(usage DiskUsage) []DiskUsageSummary {
+	images := DiskUsageSummary{Type: "Images", Total: len(usage.Images)}
+	for _, image := range usage.Images {
+		images.Size += image.Size
+		if image.Containers > 0 {
+			images.Active++
+			continue
+		}
+		reclaimable := image.Size
+		if image.SharedSize > 0 {
+			reclaimable -= image.SharedSize
+		}
+		images.Reclaimable += reclaimable
+	}
+
+	containers := DiskUsageSummary{Type: "Containers", Total: len(usage.Containers)}
+	for _, container := range usage.Containers {
+		containers.Size += container.SizeRw
+		if stoppedStates[container.State] {
+			containers.Reclaimable += container.SizeRw
+		} else {
+			containers.Active++
+		}
+	}
+
+	volumes := DiskUsageSummary{Type: "Local Volumes", Total: len(usage.Volumes)}
+	for _, volume := range usage.Volumes {
+		if volume.UsageData == nil || volume.UsageData.Size < 0 {
+			continue
+		}
+		volumes.Size += volume.UsageData.Size
+		if volume.UsageData.RefCount > 0 {
+			volumes.Active++
+		} else {
+			volumes.Reclaimable += volume.UsageData.Size
+		}
+	}
+
+	buildCache := DiskUsageSummary{Type: "Build Cache", Total: len(usage.BuildCache)}
+	for _, record := range usage.BuildCache {
+		buildCache.Size += record.Size
+		if record.InUse {
+			buildCache.Active++
+		} else if !record.Shared {
+			buildCache.Reclaimable += record.Size
+		}
+	}
+
+	return []DiskUsageSummary{images, containers, volumes, buildCache}
+}
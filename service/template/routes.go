@@ -0,0 +1,135 @@
+package template
+
+import (
+	"errors"
+	"fmt"
+	. "github.com/LysetsDal/docker-api/client"
+	. "github.com/LysetsDal/docker-api/config"
+	. "github.com/LysetsDal/docker-api/types"
+	. "github.com/LysetsDal/docker-api/utils"
+	"github.com/gorilla/mux"
+	"io"
+	"net/http"
+	"path/filepath"
+	"regexp"
+)
+
+var templateNameRegex = regexp.MustCompile(`^[a-zA-Z0-9][a-zA-Z0-9_.-]*$`)
+
+type Handler struct {
+	Docker    *DockerClient
+	Templates *JsonStore[ContainerTemplate]
+}
+
+func NewHandler(sock http.Client) (*Handler, error) {
+	templates, err := NewJsonStore[ContainerTemplate](filepath.Join(DataDir, "templates.json"))
+	if err != nil {
+		return nil, fmt.Errorf("loading templates: %w", err)
+	}
+
+	return &Handler{
+		Docker:    NewDockerClient(sock),
+		Templates: templates,
+	}, nil
+}
+
+// RegisterRoutes Container templates
+func (h *Handler) RegisterRoutes(router *mux.Router) {
+	router.HandleFunc("/templates", MakeHttpHandleFunc(h.handleListTemplates)).Methods(http.MethodGet)
+	router.HandleFunc("/templates/{name}", MakeHttpHandleFunc(h.handleGetTemplate)).Methods(http.MethodGet)
+	router.HandleFunc("/templates/{name}", MakeHttpHandleFunc(h.handlePutTemplate)).Methods(http.MethodPut)
+	router.HandleFunc("/templates/{name}", MakeHttpHandleFunc(h.handleDeleteTemplate)).Methods(http.MethodDelete)
+	router.HandleFunc("/templates/{name}/run", MakeHttpHandleFunc(h.handleRunTemplate)).Methods(http.MethodPost)
+}
+
+// GET All templates
+func (h *Handler) handleListTemplates(w http.ResponseWriter, _ *http.Request) error {
+	return WriteJson(w, http.StatusOK, h.Templates.List())
+}
+
+func (h *Handler) handleGetTemplate(w http.ResponseWriter, r *http.Request) error {
+	name := mux.Vars(r)["name"]
+
+	template, ok := h.Templates.Get(name)
+	if !ok {
+		return WriteProblem(w, http.StatusNotFound, fmt.Sprintf("No such template: %s", name))
+	}
+
+	return WriteJson(w, http.StatusOK, template)
+}
+
+// PUT Create or replace a template
+func (h *Handler) handlePutTemplate(w http.ResponseWriter, r *http.Request) error {
+	name := mux.Vars(r)["name"]
+	if !templateNameRegex.MatchString(name) {
+		return BadRequest("invalid template name %q", name)
+	}
+
+	template := ContainerTemplate{}
+	if err := ParseJsonStrict(r, &template); err != nil {
+		return BadRequest("invalid template: %w", err)
+	}
+	template.Name = name
+
+	if err := ValidateTemplate(template); err != nil {
+		return WriteValidationError(w, "invalid template", err)
+	}
+
+	if err := h.Templates.Put(name, template); err != nil {
+		return WriteError(w, err)
+	}
+
+	return WriteJson(w, http.StatusOK, template)
+}
+
+func (h *Handler) handleDeleteTemplate(w http.ResponseWriter, r *http.Request) error {
+	name := mux.Vars(r)["name"]
+
+	deleted, err := h.Templates.Delete(name)
+	if err != nil {
+		return WriteError(w, err)
+	}
+	if !deleted {
+		return WriteProblem(w, http.StatusNotFound, fmt.Sprintf("No such template: %s", name))
+	}
+
+	return WriteJson(w, http.StatusOK, ApiMessage{Message: fmt.Sprintf("Template %s deleted", name)})
+}
+
+// POST Create (and by default start) a container from a template:
+// {"Parameters": {"version": "16", "memory": 536870912}, "Name": "db-1", "Pull": "missing"}
+func (h *Handler) handleRunTemplate(w http.ResponseWriter, r *http.Request) error {
+	ctx := r.Context()
+	templateName := mux.Vars(r)["name"]
+
+	template, ok := h.Templates.Get(templateName)
+	if !ok {
+		return WriteProblem(w, http.StatusNotFound, fmt.Sprintf("No such template: %s", templateName))
+	}
+
+	// The body is optional when every parameter has a default
+	request := TemplateRunRequest{}
+	if err := ParseJsonStrict(r, &request); err != nil && !errors.Is(err, io.EOF) {
+		return BadRequest("invalid template run request: %w", err)
+	}
+	switch request.Pull {
+	case "", "missing", "always", "never":
+	default:
+		return BadRequest("pull must be missing, always or never")
+	}
+
+	payload, name, err := RenderTemplate(template, request.Parameters)
+	if err != nil {
+		return WriteValidationError(w, fmt.Sprintf("invalid parameters for template %s", templateName), err)
+	}
+	if request.Name != "" {
+		name = request.Name
+	}
+
+	response, err := h.Docker.RunContainer(ctx, name, payload, request.Pull, request.Start == nil || *request.Start)
+	if err != nil {
+		return WriteError(w, err)
+	}
+
+	return WriteJson(w, http.StatusCreated, response)
+}
@@ -0,0 +1,78 @@
+package template
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/LysetsDal/docker-api/client"
+	"github.com/LysetsDal/docker-api/client/clienttest"
+	"github.com/LysetsDal/docker-api/types"
+	"github.com/LysetsDal/docker-api/utils"
+	"github.com/gorilla/mux"
+)
+
+func TestHandleRunTemplate(t *testing.T) {
+	tests := []struct {
+		name       string
+		body       string
+		required   bool
+		wantStatus int
+		wantImage  string
+	}{
+		{name: "empty body uses the defaults", body: "", wantStatus: http.StatusCreated, wantImage: "postgres:16"},
+		{name: "parameters", body: `{"Parameters":{"version":"15"}}`, wantStatus: http.StatusCreated, wantImage: "postgres:15"},
+		{name: "empty body missing a required parameter", body: "", required: true, wantStatus: http.StatusBadRequest},
+		{name: "broken body", body: `{"Parameters":`, wantStatus: http.StatusBadRequest},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var created types.Payload
+			daemon := clienttest.NewDaemon(t, func(w http.ResponseWriter, r *http.Request) {
+				switch r.URL.Path {
+				case "/containers/create":
+					body, _ := io.ReadAll(r.Body)
+					_ = json.Unmarshal(body, &created)
+					w.WriteHeader(http.StatusCreated)
+					_, _ = w.Write([]byte(`{"Id":"c1"}`))
+				default:
+					w.WriteHeader(http.StatusNoContent)
+				}
+			})
+
+			templates, err := utils.NewJsonStore[types.ContainerTemplate](filepath.Join(t.TempDir(), "templates.json"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			parameter := types.TemplateParameter{Name: "version", Default: "16"}
+			if test.required {
+				parameter = types.TemplateParameter{Name: "version", Required: true}
+			}
+			_ = templates.Put("db", types.ContainerTemplate{
+				Name: "db", Parameters: []types.TemplateParameter{parameter},
+				Payload: json.RawMessage(`{"Image":"postgres:${version}"}`),
+			})
+
+			h := &Handler{
+				Docker:    client.NewDockerClient(daemon.Sock()),
+				Templates: templates,
+			}
+
+			request := httptest.NewRequest(http.MethodPost, "/templates/db/run", strings.NewReader(test.body))
+			recorder := httptest.NewRecorder()
+			utils.MakeHttpHandleFunc(h.handleRunTemplate)(recorder, mux.SetURLVars(request, map[string]string{"name": "db"}))
+
+			if recorder.Code != test.wantStatus {
+				t.Fatalf("status %d: %s", recorder.Code, recorder.Body)
+			}
+			if created.Image != test.wantImage {
+				t.Errorf("image: got %q, want %q", created.Image, test.wantImage)
+			}
+		})
+	}
+}
@@ -0,0 +1,21 @@
+package types
+
+import (
+	"os"
+	"time"
+)
+
+// PathStat Decoded X-Docker-Container-Path-Stat header
+type PathStat struct {
+	Name       string      `json:"name"`
+	Size       int64       `json:"size"`
+	Mode       os.FileMode `json:"mode"`
+	Mtime      time.Time   `json:"mtime"`
+	LinkTarget string      `json:"linkTarget"`
+}
+
+// ArchiveUploadResponse Files extracted by PUT /containers/{id}/archive
+type ArchiveUploadResponse struct {
+	Path  string   `json:"Path"`
+	Files []string `json:"Files,omitempty"`
+}
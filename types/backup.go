@@ -0,0 +1,31 @@
+package types
+
+import "time"
+
+// BackupOriginSchedule The origin of backups made by a volume's backup schedule. Backups made by a
+// job have the origin job/<name>, manual backups none.
+const BackupOriginSchedule = "schedule"
+
+// VolumeBackup A gzipped tar of a volume kept in the backup directory. The tar holds the
+// volume's contents under volume/. Scheduled is set for every backup with an Origin.
+type VolumeBackup struct {
+	Id        string    `json:"Id"`
+	Volume    string    `json:"Volume"`
+	File      string    `json:"File"`
+	Size      int64     `json:"Size"`
+	Sha256    string    `json:"Sha256"`
+	Created   time.Time `json:"Created"`
+	Scheduled bool      `json:"Scheduled"`
+	Origin    string    `json:"Origin,omitempty"`
+}
+
+// BackupSchedule Back up Volume every Every (a duration such as "6h"), keeping the Keep newest
+// backups the schedule made. Manual backups and those of jobs are never pruned by it.
+type BackupSchedule struct {
+	Volume     string    `json:"Volume"`
+	Every      string    `json:"Every"`
+	Keep       int       `json:"Keep"`
+	LastRun    time.Time `json:"LastRun"`
+	LastBackup string    `json:"LastBackup"`
+	LastError  string    `json:"LastError"`
+}
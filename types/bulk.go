@@ -0,0 +1,80 @@
+package types
+
+// Per container outcomes of a bulk operation
+const (
+	BulkSucceeded string = "succeeded"
+	BulkFailed    string = "failed"
+	BulkSkipped   string = "skipped"
+)
+
+// StopAllRequest Optional body for /containers/stopall. Without filters every running container is stopped.
+// Labels must all match ("key" or "key=value"); a container matching any of Names or any of Images
+// is included. Exclude lists ids, id prefixes or names that are never stopped; a prefix matching several
+// running containers is refused. Signal and T are passed to every stop. At most Parallelism containers
+// (default 4) are stopped at once, and anything not done by Deadline (a duration, default 2m) is
+// reported as failed or skipped.
+type StopAllRequest struct {
+	Labels      []string `json:"Labels"`
+	Names       []string `json:"Names"`
+	Images      []string `json:"Images"`
+	Exclude     []string `json:"Exclude"`
+	Parallelism int      `json:"Parallelism"`
+	Deadline    string   `json:"Deadline"`
+	StopParams
+}
+
+// Actions POST /containers/bulk can apply
+const (
+	BulkStart   string = "start"
+	BulkStop    string = "stop"
+	BulkRestart string = "restart"
+	BulkKill    string = "kill"
+	BulkPause   string = "pause"
+	BulkUnpause string = "unpause"
+	BulkRemove  string = "remove"
+)
+
+// BulkRequest Body for POST /containers/bulk. OnError is continue (default) or fail-fast, which
+// starts no further containers after the first failure; containers already done are not rolled back.
+// Signal applies to stop, restart and kill, T to stop and restart, Force and Volumes to remove.
+type BulkRequest struct {
+	Action      string       `json:"Action"`
+	Selector    BulkSelector `json:"Selector"`
+	Parallelism int          `json:"Parallelism"`
+	Deadline    string       `json:"Deadline"`
+	OnError     string       `json:"OnError"`
+	Signal      string       `json:"Signal"`
+	T           int          `json:"T"`
+	Force       bool         `json:"Force"`
+	Volumes     bool         `json:"Volumes"`
+}
+
+// BulkSelector Which containers a bulk action applies to; a container must match every part that is set.
+// Ids (full or any prefix that matches one selected container) and Names select those containers, Labels
+// must all match ("key" or "key=value") and Filter.Status limits the states (running, exited, paused, ...).
+type BulkSelector struct {
+	Ids    []string `json:"Ids"`
+	Names  []string `json:"Names"`
+	Labels []string `json:"Labels"`
+	Filter *Filter  `json:"Filter"`
+}
+
+// ContainerResult Outcome of a bulk action on one container
+type ContainerResult struct {
+	Id         string `json:"Id"`
+	Name       string `json:"Name"`
+	Status     string `json:"Status"`
+	Error      string `json:"Error,omitempty"`
+	DurationMs int64  `json:"DurationMs"`
+}
+
+// BulkReport Outcome of a bulk action, Results are in the order the containers were selected
+type BulkReport struct {
+	Action     string            `json:"Action"`
+	Total      int               `json:"Total"`
+	Succeeded  int               `json:"Succeeded"`
+	Failed     int               `json:"Failed"`
+	Skipped    int               `json:"Skipped"`
+	DurationMs int64             `json:"DurationMs"`
+	Results    []ContainerResult `json:"Results"`
+}
@@ -0,0 +1,36 @@
+package types
+
+// ContainerChange One entry of GET /containers/{id}/changes as the daemon sends it
+type ContainerChange struct {
+	Path string `json:"Path"`
+	Kind int    `json:"Kind"`
+}
+
+// Change kinds used by the daemon
+const (
+	ChangeModified = 0
+	ChangeAdded    = 1
+	ChangeDeleted  = 2
+)
+
+// ChangeKinds Text for each change kind
+var ChangeKinds = map[int]string{ChangeModified: "Modified", ChangeAdded: "Added", ChangeDeleted: "Deleted"}
+
+// FilesystemChange A ContainerChange with its kind decoded
+type FilesystemChange struct {
+	Path string `json:"Path"`
+	Kind string `json:"Kind"`
+}
+
+// ChangeSummary Number of changes below one top-level directory
+type ChangeSummary struct {
+	Directory string `json:"Directory"`
+	Added     int    `json:"Added"`
+	Modified  int    `json:"Modified"`
+	Deleted   int    `json:"Deleted"`
+}
+
+type ContainerChangesResponse struct {
+	Changes []FilesystemChange `json:"Changes"`
+	Summary []ChangeSummary    `json:"Summary"`
+}
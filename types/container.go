@@ -1,14 +1,31 @@
 package types
 
+import "strings"
+
 type Container struct {
-	Id              string          `json:"Id"`
-	Names           []string        `json:"Names"`
-	Image           string          `json:"Image"`
-	ImageID         string          `json:"ImageID"`
-	State           string          `json:"State"`
-	Status          string          `json:"Status"`
-	Ports           []Port          `json:"Ports"`
-	NetworkSettings NetworkSettings `json:"NetworkSettings"`
+	Id              string            `json:"Id"`
+	Names           []string          `json:"Names"`
+	Image           string            `json:"Image"`
+	ImageID         string            `json:"ImageID"`
+	Created         int64             `json:"Created"`
+	State           string            `json:"State"`
+	Status          string            `json:"Status"`
+	Ports           []Port            `json:"Ports"`
+	Labels          map[string]string `json:"Labels"`
+	SizeRw          int64             `json:"SizeRw,omitempty"`
+	SizeRootFs      int64             `json:"SizeRootFs,omitempty"`
+	NetworkSettings NetworkSettings   `json:"NetworkSettings"`
+}
+
+// Name The container's first name without the leading slash, or its short id when it has no name
+func (c Container) Name() string {
+	if len(c.Names) == 0 {
+		if len(c.Id) > 12 {
+			return c.Id[:12]
+		}
+		return c.Id
+	}
+	return strings.TrimPrefix(c.Names[0], "/")
 }
 
 type Port struct {
@@ -23,8 +40,24 @@ type Label struct {
 	ComExampleVersion string `json:"com.example.version"`
 }
 
+// NetworkSettings The list endpoint only fills Networks, inspect fills the rest
 type NetworkSettings struct {
-	Networks map[string]Network `json:"Networks"`
+	Bridge                 string                   `json:"Bridge,omitempty"`
+	SandboxID              string                   `json:"SandboxID,omitempty"`
+	SandboxKey             string                   `json:"SandboxKey,omitempty"`
+	HairpinMode            bool                     `json:"HairpinMode,omitempty"`
+	LinkLocalIPv6Address   string                   `json:"LinkLocalIPv6Address,omitempty"`
+	LinkLocalIPv6PrefixLen int                      `json:"LinkLocalIPv6PrefixLen,omitempty"`
+	Ports                  map[string][]PortBinding `json:"Ports,omitempty"`
+	EndpointID             string                   `json:"EndpointID,omitempty"`
+	Gateway                string                   `json:"Gateway,omitempty"`
+	GlobalIPv6Address      string                   `json:"GlobalIPv6Address,omitempty"`
+	GlobalIPv6PrefixLen    int                      `json:"GlobalIPv6PrefixLen,omitempty"`
+	IPAddress              string                   `json:"IPAddress,omitempty"`
+	IPPrefixLen            int                      `json:"IPPrefixLen,omitempty"`
+	IPv6Gateway            string                   `json:"IPv6Gateway,omitempty"`
+	MacAddress             string                   `json:"MacAddress,omitempty"`
+	Networks               map[string]Network       `json:"Networks"`
 }
 
 type Network struct {
@@ -35,8 +68,14 @@ type Network struct {
 	EndpointID  string    `json:"EndpointID"`
 	Gateway     string    `json:"Gateway"`
 	IPAddress   string    `json:"IPAddress"`
-	IPPrefixLen int       `json:"IPPrefixlen"`
+	IPPrefixLen int       `json:"IPPrefixLen"`
 	DNSNames    *[]string `json:"DNSNames"`
+
+	IPAMConfig          *IPAMConfig       `json:"IPAMConfig,omitempty"`
+	IPv6Gateway         string            `json:"IPv6Gateway,omitempty"`
+	GlobalIPv6Address   string            `json:"GlobalIPv6Address,omitempty"`
+	GlobalIPv6PrefixLen int               `json:"GlobalIPv6PrefixLen,omitempty"`
+	DriverOpts          map[string]string `json:"DriverOpts,omitempty"`
 }
 
 type StopParams struct {
@@ -44,23 +83,81 @@ type StopParams struct {
 	T      int    `json:"T"`
 }
 
+// InspectObject Response of GET /containers/{id}/json
 type InspectObject struct {
-	AppArmorProfile string   `json:"AppArmorProfile"`
-	Args            []string `json:"Args"`
-	Config          Config   `json:"Config"`
-	Created         string   `json:"Created"`
-	Driver          string   `json:"Driver"`
-	Mounts          []Mount  `json:"Mounts"`
+	Id              string          `json:"Id"`
+	Created         string          `json:"Created"`
+	Path            string          `json:"Path"`
+	Args            []string        `json:"Args"`
+	State           ContainerState  `json:"State"`
+	Image           string          `json:"Image"`
+	ResolvConfPath  string          `json:"ResolvConfPath"`
+	HostnamePath    string          `json:"HostnamePath"`
+	HostsPath       string          `json:"HostsPath"`
+	LogPath         string          `json:"LogPath"`
+	Name            string          `json:"Name"`
+	RestartCount    int             `json:"RestartCount"`
+	Driver          string          `json:"Driver"`
+	Platform        string          `json:"Platform"`
+	MountLabel      string          `json:"MountLabel"`
+	ProcessLabel    string          `json:"ProcessLabel"`
+	AppArmorProfile string          `json:"AppArmorProfile"`
+	ExecIDs         []string        `json:"ExecIDs"`
+	HostConfig      HostConfig      `json:"HostConfig"`
+	GraphDriver     GraphDriver     `json:"GraphDriver"`
+	SizeRw          *int64          `json:"SizeRw,omitempty"`
+	SizeRootFs      *int64          `json:"SizeRootFs,omitempty"`
+	Mounts          []Mount         `json:"Mounts"`
+	Config          Config          `json:"Config"`
+	NetworkSettings NetworkSettings `json:"NetworkSettings"`
+}
+
+type GraphDriver struct {
+	Name string            `json:"Name"`
+	Data map[string]string `json:"Data"`
+}
+
+type ContainerState struct {
+	Status     string  `json:"Status"`
+	Running    bool    `json:"Running"`
+	Paused     bool    `json:"Paused"`
+	Restarting bool    `json:"Restarting"`
+	OOMKilled  bool    `json:"OOMKilled"`
+	Dead       bool    `json:"Dead"`
+	Pid        int     `json:"Pid"`
+	ExitCode   int     `json:"ExitCode"`
+	Error      string  `json:"Error"`
+	StartedAt  string  `json:"StartedAt"`
+	FinishedAt string  `json:"FinishedAt"`
+	Health     *Health `json:"Health,omitempty"`
+}
+
+// Health Only present for containers with a healthcheck
+type Health struct {
+	Status        string      `json:"Status"`
+	FailingStreak int         `json:"FailingStreak"`
+	Log           []HealthLog `json:"Log"`
+}
+
+type HealthLog struct {
+	Start    string `json:"Start"`
+	End      string `json:"End"`
+	ExitCode int    `json:"ExitCode"`
+	Output   string `json:"Output"`
 }
 
 type Config struct {
 	AttachStderr    bool                   `json:"AttachStderr"`
 	AttachStdin     bool                   `json:"AttachStdin"`
 	AttachStdout    bool                   `json:"AttachStdout"`
-	Cmd             []string               `json:"Cmd"`
+	Cmd             StrSlice               `json:"Cmd"`
+	ArgsEscaped     bool                   `json:"ArgsEscaped,omitempty"`
+	OnBuild         []string               `json:"OnBuild"`
+	Entrypoint      StrSlice               `json:"Entrypoint"`
+	ExposedPorts    map[string]struct{}    `json:"ExposedPorts"`
 	DomainName      string                 `json:"Domainname"`
 	Env             []string               `json:"Env"`
-	Healthcheck     map[string]interface{} `json:"Healthcheck"`
+	Healthcheck     *HealthConfig          `json:"Healthcheck"`
 	Hostname        string                 `json:"Hostname"`
 	Image           string                 `json:"Image"`
 	Labels          map[string]string      `json:"Labels"`
@@ -73,10 +170,12 @@ type Config struct {
 	Volumes         map[string]interface{} `json:"Volumes"`
 	WorkingDir      string                 `json:"WorkingDir"`
 	StopSignal      string                 `json:"StopSignal"`
+	Shell           StrSlice               `json:"Shell"`
 	StopTimeout     int                    `json:"StopTimeout"`
 }
 
 type Mount struct {
+	Type        string `json:"Type"`
 	Name        string `json:"Name"`
 	Source      string `json:"Source"`
 	Destination string `json:"Destination"`
@@ -90,3 +189,203 @@ type Mount struct {
 type Processes struct {
 	Processes [][]string `json:"Processes"`
 }
+
+// CreatePayload Turn inspect output back into a create request for an equivalent container.
+// Static IPs and MAC addresses are kept; ClearAddresses drops them for a copy that runs next to the original.
+func (i InspectObject) CreatePayload() Payload {
+	config := i.Config
+
+	// Docker defaults the hostname to the short container id, which must not be copied
+	hostname := config.Hostname
+	if len(i.Id) >= 12 && hostname == i.Id[:12] {
+		hostname = ""
+	}
+
+	volumes := map[string]struct{}{}
+	for path := range config.Volumes {
+		volumes[path] = struct{}{}
+	}
+
+	endpoints := map[string]EndpointConfig{}
+	for name, network := range i.NetworkSettings.Networks {
+		endpoint := EndpointConfig{}
+		if network.Aliases != nil {
+			for _, alias := range *network.Aliases {
+				if len(i.Id) < 12 || alias != i.Id[:12] {
+					endpoint.Aliases = append(endpoint.Aliases, alias)
+				}
+			}
+		}
+		if network.Links != nil {
+			endpoint.Links = *network.Links
+		}
+		if network.IPAMConfig != nil {
+			endpoint.IPAMConfig = *network.IPAMConfig
+		}
+		endpoint.MacAddress = network.MacAddress
+		endpoints[name] = endpoint
+	}
+
+	return Payload{
+		Hostname:         hostname,
+		Domainname:       config.DomainName,
+		User:             config.User,
+		AttachStdin:      config.AttachStdin,
+		AttachStdout:     config.AttachStdout,
+		AttachStderr:     config.AttachStderr,
+		Tty:              config.Tty,
+		OpenStdin:        config.OpenStdin,
+		StdinOnce:        config.StdinOnce,
+		Env:              config.Env,
+		Cmd:              config.Cmd,
+		Entrypoint:       config.Entrypoint,
+		Image:            config.Image,
+		Labels:           config.Labels,
+		Volumes:          volumes,
+		WorkingDir:       config.WorkingDir,
+		NetworkDisabled:  config.NetworkDisabled,
+		ExposedPorts:     config.ExposedPorts,
+		StopSignal:       config.StopSignal,
+		Shell:            config.Shell,
+		StopTimeout:      config.StopTimeout,
+		Healthcheck:      config.Healthcheck,
+		HostConfig:       i.HostConfig,
+		NetworkingConfig: NetworkingConfig{EndpointsConfig: endpoints},
+	}
+}
+
+// ReplacementPayload CreatePayload for a container that takes over from i. Anonymous volumes (the
+// image's VOLUMEs and bare -v /path) are mounted by name, so the replacement keeps their data instead of
+// starting with new empty volumes.
+func (i InspectObject) ReplacementPayload() Payload {
+	payload := i.CreatePayload()
+
+	mounted := map[string]bool{}
+	for _, bind := range i.HostConfig.Binds {
+		if parts := strings.Split(bind, ":"); len(parts) >= 2 {
+			mounted[parts[1]] = true
+		}
+	}
+	for _, mount := range i.HostConfig.Mounts {
+		mounted[mount.Target] = true
+	}
+
+	// Copy, so the inspect data isn't changed through the shared slice
+	mounts := append([]HostMount(nil), payload.HostConfig.Mounts...)
+	for _, mount := range i.Mounts {
+		if mount.Type != "volume" || mount.Name == "" || mounted[mount.Destination] {
+			continue
+		}
+		mounts = append(mounts, HostMount{Type: "volume", Source: mount.Name, Target: mount.Destination, ReadOnly: !mount.RW})
+		delete(payload.Volumes, mount.Destination)
+	}
+	payload.HostConfig.Mounts = mounts
+
+	return payload
+}
+
+// ClearAddresses Let Docker pick the host ports, IPs and MAC addresses, so the container can run next
+// to the one the payload was copied from
+func (p *Payload) ClearAddresses() {
+	// Copied, so the inspect data isn't changed through the shared map
+	portBindings := make(map[string][]PortBinding, len(p.HostConfig.PortBindings))
+	for port, bindings := range p.HostConfig.PortBindings {
+		cleared := append([]PortBinding(nil), bindings...)
+		for i := range cleared {
+			cleared[i].HostPort = ""
+		}
+		portBindings[port] = cleared
+	}
+	p.HostConfig.PortBindings = portBindings
+	for name, endpoint := range p.NetworkingConfig.EndpointsConfig {
+		endpoint.IPAMConfig = IPAMConfig{}
+		endpoint.MacAddress = ""
+		p.NetworkingConfig.EndpointsConfig[name] = endpoint
+	}
+}
+
+// ExclusiveAddresses Whether i publishes fixed host ports or has static IPs, which a copy can't
+// hold while i is running
+func (i InspectObject) ExclusiveAddresses() bool {
+	for _, bindings := range i.HostConfig.PortBindings {
+		for _, binding := range bindings {
+			if binding.HostPort != "" && binding.HostPort != "0" {
+				return true
+			}
+		}
+	}
+	for _, network := range i.NetworkSettings.Networks {
+		if network.IPAMConfig != nil && (network.IPAMConfig.IPv4Address != "" || network.IPAMConfig.IPv6Address != "") {
+			return true
+		}
+	}
+	return false
+}
+
+// RecreateRequest Overrides for POST /containers/{id}/recreate and /containers/{id}/clone.
+// Unset fields keep the original container's value.
+type RecreateRequest struct {
+	Name      string            `json:"Name"`
+	Image     string            `json:"Image"`
+	Env       map[string]string `json:"Env"`
+	Resources *Resources        `json:"Resources"`
+	Start     *bool             `json:"Start"`
+}
+
+type Resources struct {
+	Memory            *int    `json:"Memory"`
+	MemorySwap        *int    `json:"MemorySwap"`
+	MemoryReservation *int    `json:"MemoryReservation"`
+	NanoCpus          *int64  `json:"NanoCpus"`
+	CpuShares         *int    `json:"CpuShares"`
+	CpuPeriod         *int    `json:"CpuPeriod"`
+	CpuQuota          *int    `json:"CpuQuota"`
+	CpusetCpus        *string `json:"CpusetCpus"`
+	PidsLimit         *int    `json:"PidsLimit"`
+}
+
+type RecreateResponse struct {
+	Id       string   `json:"Id"`
+	Name     string   `json:"Name"`
+	SourceId string   `json:"SourceId"`
+	Started  bool     `json:"Started"`
+	Warnings []string `json:"Warnings"`
+}
+
+// RunRequest docker run style shorthand for POST /containers/run, expanded into a Payload.
+// Pull is "missing" (default), "always" or "never"; Start defaults to true.
+type RunRequest struct {
+	Name       string            `json:"Name"`
+	Image      string            `json:"Image"`
+	Cmd        StrSlice          `json:"Cmd"`
+	Entrypoint StrSlice          `json:"Entrypoint"`
+	Env        map[string]string `json:"Env"`
+	Labels     map[string]string `json:"Labels"`
+	Ports      []string          `json:"Ports"`
+	Volumes    []string          `json:"Volumes"`
+	Memory     string            `json:"Memory"`
+	Cpus       float64           `json:"Cpus"`
+	Restart    string            `json:"Restart"`
+	Network    string            `json:"Network"`
+	WorkingDir string            `json:"WorkingDir"`
+	User       string            `json:"User"`
+	Tty        bool              `json:"Tty"`
+	AutoRemove bool              `json:"AutoRemove"`
+	Pull       string            `json:"Pull"`
+	Start      *bool             `json:"Start"`
+}
+
+type RunResponse struct {
+	Id       string   `json:"Id"`
+	Name     string   `json:"Name"`
+	Pulled   bool     `json:"Pulled"`
+	Started  bool     `json:"Started"`
+	Warnings []string `json:"Warnings"`
+}
+
+type ContainerWaitResponse struct {
+	StatusCode int `json:"StatusCode"`
+	Error      *struct {
+		Message string `json:"Message"`
+	} `json:"Error"`
+}
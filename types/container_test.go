@@ -0,0 +1,102 @@
+package types
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestReplacementPayload(t *testing.T) {
+	old := InspectObject{Id: "0123456789abcdef"}
+	old.Config.Image = "postgres:16"
+	old.Config.Volumes = map[string]interface{}{"/var/lib/postgresql/data": struct{}{}, "/cache": struct{}{}, "/named": struct{}{}}
+	old.HostConfig.Binds = []string{"named:/named", "/srv/conf:/etc/app:ro"}
+	old.HostConfig.Mounts = []HostMount{{Type: "volume", Source: "logs", Target: "/logs"}}
+	old.Mounts = []Mount{
+		{Type: "volume", Name: "3f2a", Destination: "/var/lib/postgresql/data", RW: true},
+		{Type: "volume", Name: "9c1b", Destination: "/cache", RW: false},
+		{Type: "volume", Name: "named", Destination: "/named", RW: true},
+		{Type: "volume", Name: "logs", Destination: "/logs", RW: true},
+		{Type: "bind", Source: "/srv/conf", Destination: "/etc/app"},
+	}
+
+	payload := old.ReplacementPayload()
+
+	wantMounts := []HostMount{
+		{Type: "volume", Source: "logs", Target: "/logs"},
+		{Type: "volume", Source: "3f2a", Target: "/var/lib/postgresql/data"},
+		{Type: "volume", Source: "9c1b", Target: "/cache", ReadOnly: true},
+	}
+	if !reflect.DeepEqual(payload.HostConfig.Mounts, wantMounts) {
+		t.Errorf("mounts: got %+v, want %+v", payload.HostConfig.Mounts, wantMounts)
+	}
+	if !reflect.DeepEqual(payload.HostConfig.Binds, old.HostConfig.Binds) {
+		t.Errorf("binds changed: %v", payload.HostConfig.Binds)
+	}
+	if wantVolumes := map[string]struct{}{"/named": {}}; !reflect.DeepEqual(payload.Volumes, wantVolumes) {
+		t.Errorf("volumes: got %v, want %v", payload.Volumes, wantVolumes)
+	}
+	if len(old.HostConfig.Mounts) != 1 {
+		t.Errorf("the inspect data was changed: %+v", old.HostConfig.Mounts)
+	}
+
+	// A clone gets new anonymous volumes
+	if clone := old.CreatePayload(); len(clone.HostConfig.Mounts) != 1 {
+		t.Errorf("CreatePayload carried anonymous volumes over: %+v", clone.HostConfig.Mounts)
+	}
+}
+
+func TestCreatePayloadAddresses(t *testing.T) {
+	old := InspectObject{Id: "0123456789abcdef"}
+	old.HostConfig.PortBindings = map[string][]PortBinding{"80/tcp": {{HostIp: "127.0.0.1", HostPort: "8080"}}}
+	old.NetworkSettings.Networks = map[string]Network{
+		"app":    {MacAddress: "02:42:ac:14:00:0a", IPAMConfig: &IPAMConfig{IPv4Address: "172.20.0.10"}},
+		"bridge": {MacAddress: "02:42:ac:11:00:02"},
+	}
+
+	payload := old.CreatePayload()
+	want := map[string]EndpointConfig{
+		"app":    {MacAddress: "02:42:ac:14:00:0a", IPAMConfig: IPAMConfig{IPv4Address: "172.20.0.10"}},
+		"bridge": {MacAddress: "02:42:ac:11:00:02"},
+	}
+	if !reflect.DeepEqual(payload.NetworkingConfig.EndpointsConfig, want) {
+		t.Errorf("endpoints: got %+v, want %+v", payload.NetworkingConfig.EndpointsConfig, want)
+	}
+	if !old.ExclusiveAddresses() {
+		t.Error("a static IP isn't exclusive")
+	}
+
+	payload.ClearAddresses()
+	want = map[string]EndpointConfig{"app": {}, "bridge": {}}
+	if !reflect.DeepEqual(payload.NetworkingConfig.EndpointsConfig, want) {
+		t.Errorf("cleared endpoints: got %+v", payload.NetworkingConfig.EndpointsConfig)
+	}
+	if binding := payload.HostConfig.PortBindings["80/tcp"][0]; binding != (PortBinding{HostIp: "127.0.0.1"}) {
+		t.Errorf("cleared binding: got %+v", binding)
+	}
+	if old.HostConfig.PortBindings["80/tcp"][0].HostPort != "8080" {
+		t.Error("the inspect data was changed")
+	}
+
+	dynamic := InspectObject{}
+	dynamic.HostConfig.PortBindings = map[string][]PortBinding{"80/tcp": {{HostPort: "0"}}}
+	if dynamic.ExclusiveAddresses() {
+		t.Error("a random host port is exclusive")
+	}
+}
+
+func TestContainerName(t *testing.T) {
+	tests := []struct {
+		container Container
+		want      string
+	}{
+		{Container{Id: "4f2a9c1be8d07a3e5b6c", Names: []string{"/web", "/app/web"}}, "web"},
+		{Container{Id: "4f2a9c1be8d07a3e5b6c"}, "4f2a9c1be8d0"},
+		{Container{Id: "4f2a"}, "4f2a"},
+	}
+
+	for _, test := range tests {
+		if got := test.container.Name(); got != test.want {
+			t.Errorf("%+v: got %q, want %q", test.container, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,30 @@
+package types
+
+// DeploymentUpdateRequest Body for POST /deployments/{label}/update.
+// Either Image (full reference) or Tag (applied to each container's current repository) is required.
+type DeploymentUpdateRequest struct {
+	Image         string `json:"Image"`
+	Tag           string `json:"Tag"`
+	BatchSize     int    `json:"BatchSize"`
+	Pause         string `json:"Pause"`
+	HealthTimeout string `json:"HealthTimeout"`
+	StopTimeout   int    `json:"StopTimeout"`
+}
+
+type DeploymentUpdateResult struct {
+	Label      string            `json:"Label"`
+	Status     string            `json:"Status"`
+	Error      string            `json:"Error,omitempty"`
+	Containers []ContainerUpdate `json:"Containers"`
+}
+
+type ContainerUpdate struct {
+	Name     string `json:"Name"`
+	OldId    string `json:"OldId"`
+	NewId    string `json:"NewId,omitempty"`
+	OldImage string `json:"OldImage"`
+	NewImage string `json:"NewImage"`
+	Status   string `json:"Status"`
+	Error    string `json:"Error,omitempty"`
+	Duration string `json:"Duration"`
+}
@@ -0,0 +1,34 @@
+package types
+
+// DesiredSpec A container spec the reconciliation loop keeps running. Replicas defaults to 1 when a
+// PUT leaves it out.
+type DesiredSpec struct {
+	Name     string  `json:"Name"`
+	Image    string  `json:"Image"`
+	Replicas int     `json:"Replicas"`
+	Config   Payload `json:"Config"`
+}
+
+// ReconcileAction One corrective step, planned (drift report) or executed. Starts and creates carry
+// the replica's consecutive attempt and when it may be started again.
+type ReconcileAction struct {
+	Spec        string `json:"Spec"`
+	Action      string `json:"Action"`
+	Replica     int    `json:"Replica,omitempty"`
+	ContainerId string `json:"ContainerId,omitempty"`
+	Reason      string `json:"Reason"`
+	Error       string `json:"Error,omitempty"`
+	Time        string `json:"Time,omitempty"`
+	Attempt     int    `json:"Attempt,omitempty"`
+	RetryAt     string `json:"RetryAt,omitempty"`
+}
+
+// DesiredStatus Desired vs. actual state of one spec
+type DesiredStatus struct {
+	Spec     string            `json:"Spec"`
+	Desired  int               `json:"Desired"`
+	Running  int               `json:"Running"`
+	InSync   bool              `json:"InSync"`
+	Drift    []ReconcileAction `json:"Drift"`
+	LastSync string            `json:"LastSync,omitempty"`
+}
@@ -0,0 +1,79 @@
+package types
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+)
+
+// Kinds of errors. Errors match their kind with errors.Is, and StatusCode answers each kind with its own status.
+var (
+	ErrBadRequest  = errors.New("bad request")
+	ErrNotFound    = errors.New("not found")
+	ErrConflict    = errors.New("conflict")
+	ErrNotModified = errors.New("not modified")
+	ErrUnavailable = errors.New("docker daemon unavailable")
+	ErrTimeout     = errors.New("timeout")
+	ErrDaemon      = errors.New("docker daemon error")
+)
+
+// KindError An error of one of the kinds, with its own message
+type KindError struct {
+	Kind error
+	Err  error
+}
+
+func (e *KindError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *KindError) Unwrap() []error {
+	return []error{e.Kind, e.Err}
+}
+
+// BadRequest The request is invalid, the message says why. %w wraps like fmt.Errorf.
+func BadRequest(format string, args ...any) error {
+	return &KindError{Kind: ErrBadRequest, Err: fmt.Errorf(format, args...)}
+}
+
+// NotFound The thing the request is about doesn't exist
+func NotFound(format string, args ...any) error {
+	return &KindError{Kind: ErrNotFound, Err: fmt.Errorf(format, args...)}
+}
+
+// Conflict The request conflicts with the current state, such as a name in use or a job already running
+func Conflict(format string, args ...any) error {
+	return &KindError{Kind: ErrConflict, Err: fmt.Errorf(format, args...)}
+}
+
+// Unavailable The Docker daemon couldn't be reached
+func Unavailable(err error) error {
+	return &KindError{Kind: ErrUnavailable, Err: err}
+}
+
+// Timeout The daemon or the operation took too long
+func Timeout(err error) error {
+	return &KindError{Kind: ErrTimeout, Err: err}
+}
+
+// StatusCode The response status for err by its kind, 500 for errors of no kind
+func StatusCode(err error) int {
+	switch {
+	case errors.Is(err, ErrNotModified):
+		return http.StatusNotModified
+	case errors.Is(err, ErrBadRequest):
+		return http.StatusBadRequest
+	case errors.Is(err, ErrNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, ErrConflict):
+		return http.StatusConflict
+	case errors.Is(err, ErrTimeout), errors.Is(err, context.DeadlineExceeded):
+		return http.StatusGatewayTimeout
+	case errors.Is(err, ErrUnavailable):
+		return http.StatusServiceUnavailable
+	case errors.Is(err, ErrDaemon):
+		return http.StatusBadGateway
+	}
+	return http.StatusInternalServerError
+}
@@ -0,0 +1,49 @@
+package types
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+)
+
+func TestStatusCode(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want int
+	}{
+		{name: "bad request", err: BadRequest("invalid name %q", "a b"), want: http.StatusBadRequest},
+		{name: "not found", err: NotFound("no template %s", "web"), want: http.StatusNotFound},
+		{name: "conflict", err: Conflict("job %s is running", "backup"), want: http.StatusConflict},
+		{name: "not modified", err: fmt.Errorf("template: %w", ErrNotModified), want: http.StatusNotModified},
+		{name: "unavailable", err: Unavailable(errors.New("dial unix: no such file")), want: http.StatusServiceUnavailable},
+		{name: "timeout", err: Timeout(errors.New("waiting for health")), want: http.StatusGatewayTimeout},
+		{name: "deadline", err: fmt.Errorf("pulling: %w", context.DeadlineExceeded), want: http.StatusGatewayTimeout},
+		{name: "daemon", err: fmt.Errorf("starting: %w", ErrDaemon), want: http.StatusBadGateway},
+		{name: "wrapped", err: fmt.Errorf("deploying web: %w", NotFound("network %s", "edge")), want: http.StatusNotFound},
+		{name: "kind wrapping another kind", err: BadRequest("invalid stack: %w", NotFound("x")), want: http.StatusBadRequest},
+		{name: "no kind", err: errors.New("nil map"), want: http.StatusInternalServerError},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := StatusCode(test.err); got != test.want {
+				t.Errorf("got %d, want %d", got, test.want)
+			}
+		})
+	}
+}
+
+func TestKindError(t *testing.T) {
+	cause := errors.New("dial unix /var/run/docker.sock: connect: no such file or directory")
+	err := fmt.Errorf("listing containers: %w", Unavailable(cause))
+
+	if err.Error() != "listing containers: "+cause.Error() {
+		t.Errorf("message: %s", err)
+	}
+	if !errors.Is(err, ErrUnavailable) || !errors.Is(err, cause) || errors.Is(err, ErrNotFound) {
+		t.Errorf("%v doesn't match its kind and cause", err)
+	}
+}
@@ -0,0 +1,18 @@
+package types
+
+import (
+	"net/http"
+	"time"
+)
+
+// IdempotencyRecord The response to the first request made with an Idempotency-Key. Fingerprint is a hash
+// of the method, path, query and body, so a retry can be told apart from reuse of the key.
+type IdempotencyRecord struct {
+	Key         string      `json:"Key"`
+	Fingerprint string      `json:"Fingerprint"`
+	StatusCode  int         `json:"StatusCode"`
+	Header      http.Header `json:"Header"`
+	Body        []byte      `json:"Body"`
+	Created     time.Time   `json:"Created"`
+	Expires     time.Time   `json:"Expires"`
+}
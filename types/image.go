@@ -0,0 +1,78 @@
+package types
+
+// JsonMessage One line of the progress stream returned by pull, push, load and build
+type JsonMessage struct {
+	Id             string         `json:"id,omitempty"`
+	Status         string         `json:"status,omitempty"`
+	Progress       string         `json:"progress,omitempty"`
+	ProgressDetail map[string]int `json:"progressDetail,omitempty"`
+	Stream         string         `json:"stream,omitempty"`
+	Error          string         `json:"error,omitempty"`
+	ErrorDetail    *ErrorDetail   `json:"errorDetail,omitempty"`
+}
+
+type ErrorDetail struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+// ImageInspect Subset of GET /images/{name}/json
+type ImageInspect struct {
+	Id          string   `json:"Id"`
+	RepoTags    []string `json:"RepoTags"`
+	RepoDigests []string `json:"RepoDigests"`
+	Created     string   `json:"Created"`
+	Size        int64    `json:"Size"`
+	Comment     string   `json:"Comment"`
+	Author      string   `json:"Author"`
+	Config      struct {
+		Labels map[string]string `json:"Labels"`
+	} `json:"Config"`
+}
+
+// ImageSummary One entry of GET /images/json
+type ImageSummary struct {
+	Id          string            `json:"Id"`
+	ParentId    string            `json:"ParentId"`
+	RepoTags    []string          `json:"RepoTags"`
+	RepoDigests []string          `json:"RepoDigests"`
+	Created     int64             `json:"Created"`
+	Size        int64             `json:"Size"`
+	SharedSize  int64             `json:"SharedSize"`
+	Labels      map[string]string `json:"Labels"`
+	Containers  int64             `json:"Containers"`
+}
+
+// CommitRequest Body for POST /containers/{id}/commit. Changes are Dockerfile instructions
+// such as "ENV DEBUG=1" or `CMD ["python", "app.py"]`. Pause defaults to true.
+type CommitRequest struct {
+	Repo    string   `json:"Repo"`
+	Tag     string   `json:"Tag"`
+	Message string   `json:"Message"`
+	Author  string   `json:"Author"`
+	Changes []string `json:"Changes"`
+	Pause   *bool    `json:"Pause"`
+}
+
+type CommitResponse struct {
+	Id string `json:"Id"`
+}
+
+// SnapshotRequest Body for POST /containers/{id}/snapshots. Tag defaults to the current time.
+type SnapshotRequest struct {
+	Tag     string `json:"Tag"`
+	Message string `json:"Message"`
+	Author  string `json:"Author"`
+	Pause   *bool  `json:"Pause"`
+}
+
+// Snapshot An image committed from a container by the snapshot endpoints
+type Snapshot struct {
+	Id          string   `json:"Id"`
+	RepoTags    []string `json:"RepoTags"`
+	Container   string   `json:"Container"`
+	ContainerId string   `json:"ContainerId"`
+	Message     string   `json:"Message"`
+	Created     string   `json:"Created"`
+	Size        int64    `json:"Size"`
+}
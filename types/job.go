@@ -0,0 +1,99 @@
+package types
+
+import "time"
+
+// Task types a job can run
+const (
+	TaskTemplate      string = "template"
+	TaskPrune         string = "prune"
+	TaskBackup        string = "backup"
+	TaskRestart       string = "restart"
+	TaskBusinessHours string = "business-hours"
+)
+
+// Job run statuses
+const (
+	RunRunning     string = "running"
+	RunSucceeded   string = "succeeded"
+	RunFailed      string = "failed"
+	RunInterrupted string = "interrupted"
+)
+
+// Job A task run on a cron schedule. Schedule is a five field cron expression or a descriptor
+// such as @daily or @every 30m, optionally prefixed with CRON_TZ=Europe/Copenhagen.
+type Job struct {
+	Name     string    `json:"Name"`
+	Schedule string    `json:"Schedule"`
+	Paused   bool      `json:"Paused"`
+	History  int       `json:"History"`
+	Task     JobTask   `json:"Task"`
+	Created  time.Time `json:"Created"`
+	Updated  time.Time `json:"Updated"`
+}
+
+// JobTask What a job does. Type selects which of the other fields is used.
+type JobTask struct {
+	Type          string             `json:"Type"`
+	Template      *TemplateTask      `json:"Template,omitempty"`
+	Prune         *CleanupPolicy     `json:"Prune,omitempty"`
+	Backup        *BackupTask        `json:"Backup,omitempty"`
+	Restart       *RestartTask       `json:"Restart,omitempty"`
+	BusinessHours *BusinessHoursTask `json:"BusinessHours,omitempty"`
+}
+
+// TemplateTask Run a one-shot container from a template and wait for it to exit. The container is removed
+// afterwards unless Keep is set. Timeout (a duration, default 1h) stops it if it runs too long. Pull is
+// missing (default), always or never, as for POST /templates/{name}/run.
+type TemplateTask struct {
+	Template   string         `json:"Template"`
+	Parameters map[string]any `json:"Parameters"`
+	Pull       string         `json:"Pull"`
+	Timeout    string         `json:"Timeout"`
+	Keep       bool           `json:"Keep"`
+}
+
+// BackupTask Back up each volume to the backup directory, keeping the Keep newest backups the job made
+type BackupTask struct {
+	Volumes []string `json:"Volumes"`
+	Keep    int      `json:"Keep"`
+}
+
+// RestartTask Restart the running containers carrying every label ("key" or "key=value")
+type RestartTask struct {
+	Labels  []string `json:"Labels"`
+	Timeout int      `json:"Timeout"`
+}
+
+// BusinessHoursTask Stop the labelled containers when a run falls outside Start-End ("08:00", "18:00")
+// on Days ("mon" to "sun", default mon-fri) in Timezone (default local). With StartInside they
+// are started again by runs inside business hours.
+type BusinessHoursTask struct {
+	Labels      []string `json:"Labels"`
+	Days        []string `json:"Days"`
+	Start       string   `json:"Start"`
+	End         string   `json:"End"`
+	Timezone    string   `json:"Timezone"`
+	StartInside bool     `json:"StartInside"`
+}
+
+// JobRun One run of a job. Logs holds the task's output, for template jobs the container's logs.
+// ExitCode is set for template jobs once the container has exited.
+type JobRun struct {
+	Id       string     `json:"Id"`
+	Job      string     `json:"Job"`
+	Trigger  string     `json:"Trigger"`
+	Status   string     `json:"Status"`
+	Started  time.Time  `json:"Started"`
+	Finished *time.Time `json:"Finished,omitempty"`
+	ExitCode *int       `json:"ExitCode,omitempty"`
+	Error    string     `json:"Error,omitempty"`
+	Logs     string     `json:"Logs"`
+}
+
+// JobInfo A job with its scheduling state
+type JobInfo struct {
+	Job
+	NextRun *time.Time `json:"NextRun,omitempty"`
+	Running bool       `json:"Running"`
+	LastRun *JobRun    `json:"LastRun,omitempty"`
+}
@@ -0,0 +1,36 @@
+package types
+
+// NetworkCreateRequest Body for POST /networks/create
+type NetworkCreateRequest struct {
+	Name           string            `json:"Name"`
+	Driver         string            `json:"Driver,omitempty"`
+	Internal       bool              `json:"Internal,omitempty"`
+	Attachable     bool              `json:"Attachable,omitempty"`
+	Labels         map[string]string `json:"Labels,omitempty"`
+	Options        map[string]string `json:"Options,omitempty"`
+	CheckDuplicate bool              `json:"CheckDuplicate"`
+}
+
+// NetworkCreateResponse Response from POST /networks/create
+type NetworkCreateResponse struct {
+	Id      string `json:"Id"`
+	Warning string `json:"Warning"`
+}
+
+// NetworkResource Network as returned by GET /networks
+type NetworkResource struct {
+	Name       string            `json:"Name"`
+	Id         string            `json:"Id"`
+	Created    string            `json:"Created"`
+	Scope      string            `json:"Scope"`
+	Driver     string            `json:"Driver"`
+	Internal   bool              `json:"Internal"`
+	Attachable bool              `json:"Attachable"`
+	Labels     map[string]string `json:"Labels"`
+}
+
+// NetworkConnectRequest Body for POST /networks/{id}/connect
+type NetworkConnectRequest struct {
+	Container      string         `json:"Container"`
+	EndpointConfig EndpointConfig `json:"EndpointConfig"`
+}
@@ -0,0 +1,38 @@
+package types
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// Operation statuses
+const (
+	OperationRunning   string = "running"
+	OperationSucceeded string = "succeeded"
+	OperationFailed    string = "failed"
+	OperationCancelled string = "cancelled"
+)
+
+// Operation A request run in the background after ?async=true or Prefer: respond-async.
+// Progress is the latest message of operations that stream progress, such as image pulls.
+type Operation struct {
+	Id       string           `json:"Id"`
+	Kind     string           `json:"Kind"`
+	Method   string           `json:"Method"`
+	Path     string           `json:"Path"`
+	Status   string           `json:"Status"`
+	Created  time.Time        `json:"Created"`
+	Finished *time.Time       `json:"Finished,omitempty"`
+	Progress json.RawMessage  `json:"Progress,omitempty"`
+	Result   *OperationResult `json:"Result,omitempty"`
+	Error    string           `json:"Error,omitempty"`
+}
+
+// OperationResult The response the request would have had. Body holds json responses (the last
+// message for streamed progress); other bodies are only counted in Size.
+type OperationResult struct {
+	StatusCode  int             `json:"StatusCode"`
+	ContentType string          `json:"ContentType"`
+	Body        json.RawMessage `json:"Body,omitempty"`
+	Size        int64           `json:"Size"`
+}
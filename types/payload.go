@@ -1,5 +1,10 @@
 package types
 
+import (
+	"encoding/json"
+	"fmt"
+)
+
 // Payload Define the Payload struct with all nested structs
 type Payload struct {
 	Hostname         string              `json:"Hostname"`
@@ -12,8 +17,8 @@ type Payload struct {
 	OpenStdin        bool                `json:"OpenStdin"`
 	StdinOnce        bool                `json:"StdinOnce"`
 	Env              []string            `json:"Env"`
-	Cmd              []string            `json:"Cmd"`
-	Entrypoint       string              `json:"Entrypoint"`
+	Cmd              StrSlice            `json:"Cmd"`
+	Entrypoint       StrSlice            `json:"Entrypoint"`
 	Image            string              `json:"Image"`
 	Labels           map[string]string   `json:"Labels"`
 	Volumes          map[string]struct{} `json:"Volumes"`
@@ -22,11 +27,31 @@ type Payload struct {
 	MacAddress       string              `json:"MacAddress"`
 	ExposedPorts     map[string]struct{} `json:"ExposedPorts"`
 	StopSignal       string              `json:"StopSignal"`
-	StopTimeout      int                 `json:"StopTimeout"`
+	Shell            StrSlice            `json:"Shell,omitempty"`
+	StopTimeout      int                 `json:"StopTimeout,omitempty"`
+	Healthcheck      *HealthConfig       `json:"Healthcheck,omitempty"`
 	HostConfig       HostConfig          `json:"HostConfig"`
 	NetworkingConfig NetworkingConfig    `json:"NetworkingConfig"`
 }
 
+// StrSlice A command as the Docker API takes it: a list of strings, or one string for a single element
+type StrSlice []string
+
+func (s *StrSlice) UnmarshalJSON(data []byte) error {
+	var list []string
+	if err := json.Unmarshal(data, &list); err == nil {
+		*s = list
+		return nil
+	}
+
+	var single string
+	if err := json.Unmarshal(data, &single); err != nil {
+		return fmt.Errorf("must be a string or a list of strings")
+	}
+	*s = StrSlice{single}
+	return nil
+}
+
 type HostConfig struct {
 	Binds                []string                 `json:"Binds"`
 	Links                []string                 `json:"Links"`
@@ -45,13 +70,13 @@ type HostConfig struct {
 	MaximumIOps          int                      `json:"MaximumIOps"`
 	MaximumIOBps         int                      `json:"MaximumIOBps"`
 	BlkioWeight          int                      `json:"BlkioWeight"`
-	BlkioWeightDevice    []BlkioDevice            `json:"BlkioWeightDevice"`
+	BlkioWeightDevice    []WeightDevice           `json:"BlkioWeightDevice"`
 	BlkioDeviceReadBps   []BlkioDevice            `json:"BlkioDeviceReadBps"`
 	BlkioDeviceReadIOps  []BlkioDevice            `json:"BlkioDeviceReadIOps"`
 	BlkioDeviceWriteBps  []BlkioDevice            `json:"BlkioDeviceWriteBps"`
 	BlkioDeviceWriteIOps []BlkioDevice            `json:"BlkioDeviceWriteIOps"`
 	DeviceRequests       []DeviceRequest          `json:"DeviceRequests"`
-	MemorySwappiness     int                      `json:"MemorySwappiness"`
+	MemorySwappiness     int                      `json:"MemorySwappiness,omitempty"`
 	OomKillDisable       bool                     `json:"OomKillDisable"`
 	OomScoreAdj          int                      `json:"OomScoreAdj"`
 	PidMode              string                   `json:"PidMode"`
@@ -78,10 +103,45 @@ type HostConfig struct {
 	CgroupParent         string                   `json:"CgroupParent"`
 	VolumeDriver         string                   `json:"VolumeDriver"`
 	ShmSize              int                      `json:"ShmSize"`
+	Mounts               []HostMount              `json:"Mounts,omitempty"`
+	Tmpfs                map[string]string        `json:"Tmpfs,omitempty"`
+	ExtraHosts           []string                 `json:"ExtraHosts,omitempty"`
+	Sysctls              map[string]string        `json:"Sysctls,omitempty"`
+	IpcMode              string                   `json:"IpcMode,omitempty"`
+	UTSMode              string                   `json:"UTSMode,omitempty"`
+	UsernsMode           string                   `json:"UsernsMode,omitempty"`
+	CgroupnsMode         string                   `json:"CgroupnsMode,omitempty"`
+	Runtime              string                   `json:"Runtime,omitempty"`
+	Init                 *bool                    `json:"Init,omitempty"`
+	Isolation            string                   `json:"Isolation,omitempty"`
+	DeviceCgroupRules    []string                 `json:"DeviceCgroupRules,omitempty"`
+	MaskedPaths          []string                 `json:"MaskedPaths,omitempty"`
+	ReadonlyPaths        []string                 `json:"ReadonlyPaths,omitempty"`
+	Annotations          map[string]string        `json:"Annotations,omitempty"`
 }
 
+// HostMount Entry of HostConfig.Mounts (the --mount flag)
+type HostMount struct {
+	Type          string         `json:"Type"`
+	Source        string         `json:"Source,omitempty"`
+	Target        string         `json:"Target"`
+	ReadOnly      bool           `json:"ReadOnly,omitempty"`
+	Consistency   string         `json:"Consistency,omitempty"`
+	BindOptions   map[string]any `json:"BindOptions,omitempty"`
+	VolumeOptions map[string]any `json:"VolumeOptions,omitempty"`
+	TmpfsOptions  map[string]any `json:"TmpfsOptions,omitempty"`
+}
+
+// BlkioDevice Throttle for one block device, in bytes or IO operations per second
 type BlkioDevice struct {
-	// Add appropriate fields here
+	Path string `json:"Path"`
+	Rate int64  `json:"Rate"`
+}
+
+// WeightDevice Relative block IO weight (10 to 1000) for one block device
+type WeightDevice struct {
+	Path   string `json:"Path"`
+	Weight int    `json:"Weight"`
 }
 
 type DeviceRequest struct {
@@ -93,6 +153,7 @@ type DeviceRequest struct {
 }
 
 type PortBinding struct {
+	HostIp   string `json:"HostIp"`
 	HostPort string `json:"HostPort"`
 }
 
@@ -101,12 +162,26 @@ type RestartPolicy struct {
 	MaximumRetryCount int    `json:"MaximumRetryCount"`
 }
 
+// Device Host device mapped into the container, CgroupPermissions is a combination of r, w and m
 type Device struct {
-	// Add appropriate fields here
+	PathOnHost        string `json:"PathOnHost"`
+	PathInContainer   string `json:"PathInContainer"`
+	CgroupPermissions string `json:"CgroupPermissions"`
 }
 
 type Ulimit struct {
-	// Add appropriate fields here
+	Name string `json:"Name"`
+	Soft int64  `json:"Soft"`
+	Hard int64  `json:"Hard"`
+}
+
+// HealthConfig Durations are in nanoseconds, as expected by the Docker daemon
+type HealthConfig struct {
+	Test        []string `json:"Test"`
+	Interval    int64    `json:"Interval"`
+	Timeout     int64    `json:"Timeout"`
+	StartPeriod int64    `json:"StartPeriod"`
+	Retries     int      `json:"Retries"`
 }
 
 type LogConfig struct {
@@ -122,6 +197,7 @@ type EndpointConfig struct {
 	IPAMConfig IPAMConfig `json:"IPAMConfig"`
 	Links      []string   `json:"Links"`
 	Aliases    []string   `json:"Aliases"`
+	MacAddress string     `json:"MacAddress,omitempty"`
 }
 
 type IPAMConfig struct {
@@ -0,0 +1,37 @@
+package types
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func TestStrSlice(t *testing.T) {
+	tests := []struct {
+		body    string
+		want    StrSlice
+		invalid bool
+	}{
+		{body: `{"Cmd":["sh","-c","date"]}`, want: StrSlice{"sh", "-c", "date"}},
+		{body: `{"Cmd":"date"}`, want: StrSlice{"date"}},
+		{body: `{"Cmd":"echo hello"}`, want: StrSlice{"echo hello"}},
+		{body: `{"Cmd":[]}`, want: StrSlice{}},
+		{body: `{"Cmd":null}`},
+		{body: `{}`},
+		{body: `{"Cmd":7}`, invalid: true},
+		{body: `{"Cmd":[7]}`, invalid: true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.body, func(t *testing.T) {
+			payload := Payload{}
+			err := json.Unmarshal([]byte(test.body), &payload)
+			if (err != nil) != test.invalid {
+				t.Fatalf("error: %v", err)
+			}
+			if !test.invalid && !reflect.DeepEqual(payload.Cmd, test.want) {
+				t.Errorf("got %#v, want %#v", payload.Cmd, test.want)
+			}
+		})
+	}
+}
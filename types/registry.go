@@ -0,0 +1,40 @@
+package types
+
+import "time"
+
+// AuthConfig Credentials in the form the daemon expects in X-Registry-Auth and POST /auth
+type AuthConfig struct {
+	Username      string `json:"username,omitempty"`
+	Password      string `json:"password,omitempty"`
+	ServerAddress string `json:"serveraddress,omitempty"`
+	RegistryToken string `json:"registrytoken,omitempty"`
+}
+
+type AuthResponse struct {
+	Status        string `json:"Status"`
+	IdentityToken string `json:"IdentityToken"`
+}
+
+// RegistryCredentialRequest Body for PUT /registries/{registry}/credentials.
+// Either Username and Password, or a bearer Token.
+type RegistryCredentialRequest struct {
+	Username string `json:"Username"`
+	Password string `json:"Password"`
+	Token    string `json:"Token"`
+}
+
+// RegistryCredential Stored credentials. Secret holds the encrypted password or token.
+type RegistryCredential struct {
+	Registry string    `json:"Registry"`
+	Username string    `json:"Username"`
+	Secret   string    `json:"Secret"`
+	Updated  time.Time `json:"Updated"`
+}
+
+// RegistryCredentialInfo A stored credential without its secret
+type RegistryCredentialInfo struct {
+	Registry string    `json:"Registry"`
+	Username string    `json:"Username"`
+	Token    bool      `json:"Token"`
+	Updated  time.Time `json:"Updated"`
+}
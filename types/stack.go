@@ -0,0 +1,201 @@
+package types
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// StackFile The subset of the Compose file format accepted by POST /stacks
+type StackFile struct {
+	Name     string                  `yaml:"name"`
+	Services map[string]StackService `yaml:"services"`
+	Networks map[string]StackNetwork `yaml:"networks"`
+	Volumes  map[string]StackVolume  `yaml:"volumes"`
+}
+
+type StackService struct {
+	Image         string            `yaml:"image"`
+	ContainerName string            `yaml:"container_name"`
+	Hostname      string            `yaml:"hostname"`
+	User          string            `yaml:"user"`
+	WorkingDir    string            `yaml:"working_dir"`
+	Command       StringList        `yaml:"command"`
+	Entrypoint    StringList        `yaml:"entrypoint"`
+	Environment   KeyValues         `yaml:"environment"`
+	Labels        KeyValues         `yaml:"labels"`
+	Ports         []string          `yaml:"ports"`
+	Volumes       []string          `yaml:"volumes"`
+	Networks      ServiceNetworks   `yaml:"networks"`
+	DependsOn     DependsOn         `yaml:"depends_on"`
+	Healthcheck   *StackHealthcheck `yaml:"healthcheck"`
+	Restart       string            `yaml:"restart"`
+}
+
+type StackNetwork struct {
+	Name       string            `yaml:"name"`
+	Driver     string            `yaml:"driver"`
+	Internal   bool              `yaml:"internal"`
+	Attachable bool              `yaml:"attachable"`
+	External   bool              `yaml:"external"`
+	Labels     KeyValues         `yaml:"labels"`
+	DriverOpts map[string]string `yaml:"driver_opts"`
+}
+
+type StackVolume struct {
+	Name       string            `yaml:"name"`
+	Driver     string            `yaml:"driver"`
+	External   bool              `yaml:"external"`
+	Labels     KeyValues         `yaml:"labels"`
+	DriverOpts map[string]string `yaml:"driver_opts"`
+}
+
+type StackHealthcheck struct {
+	Test        StringList `yaml:"test"`
+	Interval    string     `yaml:"interval"`
+	Timeout     string     `yaml:"timeout"`
+	StartPeriod string     `yaml:"start_period"`
+	Retries     int        `yaml:"retries"`
+	Disable     bool       `yaml:"disable"`
+}
+
+// StackSummary Response for the /stacks endpoints
+type StackSummary struct {
+	Name     string               `json:"Name"`
+	Services []StackServiceStatus `json:"Services"`
+	Networks []string             `json:"Networks"`
+	Volumes  []string             `json:"Volumes"`
+}
+
+type StackServiceStatus struct {
+	Service       string `json:"Service"`
+	ContainerId   string `json:"ContainerId"`
+	ContainerName string `json:"ContainerName"`
+	Image         string `json:"Image"`
+	State         string `json:"State"`
+	Status        string `json:"Status"`
+}
+
+// StringList Accepts either a single string (split on whitespace) or a list of strings
+type StringList []string
+
+func (s *StringList) UnmarshalYAML(node *yaml.Node) error {
+	if node.Kind == yaml.ScalarNode {
+		*s = strings.Fields(node.Value)
+		return nil
+	}
+
+	var list []string
+	if err := node.Decode(&list); err != nil {
+		return err
+	}
+	*s = list
+	return nil
+}
+
+// KeyValues Accepts either a mapping or a list of "KEY=VALUE" strings
+type KeyValues map[string]string
+
+func (kv *KeyValues) UnmarshalYAML(node *yaml.Node) error {
+	values := map[string]string{}
+
+	switch node.Kind {
+	case yaml.MappingNode:
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			values[node.Content[i].Value] = node.Content[i+1].Value
+		}
+	case yaml.SequenceNode:
+		for _, item := range node.Content {
+			key, value, _ := strings.Cut(item.Value, "=")
+			values[key] = value
+		}
+	default:
+		return fmt.Errorf("line %d: expected a mapping or a list of KEY=VALUE", node.Line)
+	}
+
+	*kv = values
+	return nil
+}
+
+// List Format as a sorted list of "KEY=VALUE"
+func (kv KeyValues) List() []string {
+	list := make([]string, 0, len(kv))
+	for key, value := range kv {
+		list = append(list, key+"="+value)
+	}
+	sort.Strings(list)
+	return list
+}
+
+// ServiceNetworks Accepts either a list of network names or a mapping of network name to options
+type ServiceNetworks map[string]ServiceNetwork
+
+type ServiceNetwork struct {
+	Aliases []string `yaml:"aliases"`
+}
+
+func (n *ServiceNetworks) UnmarshalYAML(node *yaml.Node) error {
+	networks := map[string]ServiceNetwork{}
+
+	if node.Kind == yaml.SequenceNode {
+		var names []string
+		if err := node.Decode(&names); err != nil {
+			return err
+		}
+		for _, name := range names {
+			networks[name] = ServiceNetwork{}
+		}
+		*n = networks
+		return nil
+	}
+
+	var mapping map[string]*ServiceNetwork
+	if err := node.Decode(&mapping); err != nil {
+		return err
+	}
+	for name, network := range mapping {
+		if network == nil {
+			network = &ServiceNetwork{}
+		}
+		networks[name] = *network
+	}
+	*n = networks
+	return nil
+}
+
+// DependsOn Service name to condition (service_started or service_healthy).
+// Accepts either a list of service names or the long mapping syntax.
+type DependsOn map[string]string
+
+func (d *DependsOn) UnmarshalYAML(node *yaml.Node) error {
+	dependencies := map[string]string{}
+
+	if node.Kind == yaml.SequenceNode {
+		var names []string
+		if err := node.Decode(&names); err != nil {
+			return err
+		}
+		for _, name := range names {
+			dependencies[name] = "service_started"
+		}
+		*d = dependencies
+		return nil
+	}
+
+	var mapping map[string]struct {
+		Condition string `yaml:"condition"`
+	}
+	if err := node.Decode(&mapping); err != nil {
+		return err
+	}
+	for name, dependency := range mapping {
+		if dependency.Condition == "" {
+			dependency.Condition = "service_started"
+		}
+		dependencies[name] = dependency.Condition
+	}
+	*d = dependencies
+	return nil
+}
@@ -0,0 +1,121 @@
+package types
+
+import "time"
+
+// DiskUsage Response from GET /system/df
+type DiskUsage struct {
+	LayersSize int64              `json:"LayersSize"`
+	Images     []ImageSummary     `json:"Images"`
+	Containers []Container        `json:"Containers"`
+	Volumes    []Volume           `json:"Volumes"`
+	BuildCache []BuildCacheRecord `json:"BuildCache"`
+}
+
+type BuildCacheRecord struct {
+	ID          string    `json:"ID"`
+	Parents     []string  `json:"Parents"`
+	Type        string    `json:"Type"`
+	Description string    `json:"Description"`
+	InUse       bool      `json:"InUse"`
+	Shared      bool      `json:"Shared"`
+	Size        int64     `json:"Size"`
+	CreatedAt   time.Time `json:"CreatedAt"`
+	LastUsedAt  time.Time `json:"LastUsedAt"`
+	UsageCount  int       `json:"UsageCount"`
+}
+
+// DiskUsageSummary One row of the docker system df table
+type DiskUsageSummary struct {
+	Type        string `json:"Type"`
+	Total       int    `json:"Total"`
+	Active      int    `json:"Active"`
+	Size        int64  `json:"Size"`
+	Reclaimable int64  `json:"Reclaimable"`
+}
+
+// DiskUsageReport Response of GET /system/df: the daemon's data plus per type totals
+type DiskUsageReport struct {
+	Summary []DiskUsageSummary `json:"Summary"`
+	DiskUsage
+}
+
+// CleanupPolicy What a cleanup plan may remove. Sections left out are not cleaned.
+type CleanupPolicy struct {
+	Containers *ContainerCleanup  `json:"Containers"`
+	Images     *ImageCleanup      `json:"Images"`
+	Volumes    *VolumeCleanup     `json:"Volumes"`
+	BuildCache *BuildCacheCleanup `json:"BuildCache"`
+}
+
+// ContainerCleanup Stopped containers that finished (or were created, if never started) more than OlderThanHours ago
+type ContainerCleanup struct {
+	OlderThanHours int `json:"OlderThanHours"`
+}
+
+// ImageCleanup Images no container uses: untagged ones if Dangling, and tagged ones beyond the
+// KeepLast newest of their repository if Unused
+type ImageCleanup struct {
+	Dangling bool `json:"Dangling"`
+	Unused   bool `json:"Unused"`
+	KeepLast int  `json:"KeepLast"`
+}
+
+// VolumeCleanup Volumes no container uses, unless they carry one of ProtectedLabels ("key" or "key=value")
+type VolumeCleanup struct {
+	ProtectedLabels []string `json:"ProtectedLabels"`
+}
+
+// BuildCacheCleanup Build cache not used for OlderThanHours. All includes cache that is still referenced
+// by images, otherwise only dangling cache goes.
+type BuildCacheCleanup struct {
+	OlderThanHours int  `json:"OlderThanHours"`
+	All            bool `json:"All"`
+}
+
+// CleanupItem One resource a plan would remove
+type CleanupItem struct {
+	Id     string `json:"Id"`
+	Name   string `json:"Name"`
+	Size   int64  `json:"Size"`
+	Reason string `json:"Reason"`
+}
+
+type CleanupSection struct {
+	Items       []CleanupItem `json:"Items"`
+	Reclaimable int64         `json:"Reclaimable"`
+}
+
+// CleanupPlan A dry run, executed by POST /system/cleanup/plans/{id}/execute before it expires
+type CleanupPlan struct {
+	Id          string          `json:"Id"`
+	Created     time.Time       `json:"Created"`
+	Expires     time.Time       `json:"Expires"`
+	Policy      CleanupPolicy   `json:"Policy"`
+	Containers  *CleanupSection `json:"Containers,omitempty"`
+	Images      *CleanupSection `json:"Images,omitempty"`
+	Volumes     *CleanupSection `json:"Volumes,omitempty"`
+	BuildCache  *CleanupSection `json:"BuildCache,omitempty"`
+	Reclaimable int64           `json:"Reclaimable"`
+}
+
+// CleanupItemResult What happened to one planned item. Status is removed, skipped or failed.
+type CleanupItemResult struct {
+	Id     string `json:"Id"`
+	Name   string `json:"Name"`
+	Status string `json:"Status"`
+	Error  string `json:"Error,omitempty"`
+}
+
+type CleanupResult struct {
+	PlanId         string              `json:"PlanId"`
+	Items          []CleanupItemResult `json:"Items"`
+	Removed        int                 `json:"Removed"`
+	Skipped        int                 `json:"Skipped"`
+	Failed         int                 `json:"Failed"`
+	SpaceReclaimed int64               `json:"SpaceReclaimed"`
+}
+
+type BuildCachePruneResponse struct {
+	CachesDeleted  []string `json:"CachesDeleted"`
+	SpaceReclaimed int64    `json:"SpaceReclaimed"`
+}
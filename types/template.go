@@ -0,0 +1,35 @@
+package types
+
+import "encoding/json"
+
+// ContainerTemplate A reusable partial create payload. String values in Payload and ContainerName may
+// contain ${param} placeholders; a value that is exactly "${param}" in a non-string field takes the parameter's type, so
+// {"HostConfig": {"Memory": "${memory}"}} becomes a number for an integer parameter.
+type ContainerTemplate struct {
+	Name          string              `json:"Name"`
+	Description   string              `json:"Description"`
+	ContainerName string              `json:"ContainerName"`
+	Parameters    []TemplateParameter `json:"Parameters"`
+	Payload       json.RawMessage     `json:"Payload"`
+}
+
+// TemplateParameter Schema for one placeholder. Type is string (default), integer, number or boolean.
+type TemplateParameter struct {
+	Name        string   `json:"Name"`
+	Description string   `json:"Description"`
+	Type        string   `json:"Type"`
+	Required    bool     `json:"Required"`
+	Default     any      `json:"Default"`
+	Enum        []string `json:"Enum"`
+	Pattern     string   `json:"Pattern"`
+	Minimum     *float64 `json:"Minimum"`
+	Maximum     *float64 `json:"Maximum"`
+}
+
+// TemplateRunRequest Body for POST /templates/{name}/run
+type TemplateRunRequest struct {
+	Name       string         `json:"Name"`
+	Parameters map[string]any `json:"Parameters"`
+	Pull       string         `json:"Pull"`
+	Start      *bool          `json:"Start"`
+}
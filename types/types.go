@@ -1,12 +1,23 @@
 package types
 
-import "net/http"
+import (
+	"net/http"
+	"strings"
+)
 
 // ApiFunc Decorator pattern to 'wrap' http.HandlerFunc
 type ApiFunc func(http.ResponseWriter, *http.Request) error
 
+// ApiError An RFC 7807 problem, sent as application/problem+json. Error repeats Detail for clients
+// of the earlier {"error": ...} responses, Fields lists what is wrong with an invalid request.
 type ApiError struct {
-	Error string `json:"error"`
+	Type      string       `json:"type"`
+	Title     string       `json:"title"`
+	Status    int          `json:"status"`
+	Detail    string       `json:"detail"`
+	RequestId string       `json:"request_id,omitempty"`
+	Error     string       `json:"error"`
+	Fields    []FieldError `json:"fields,omitempty"`
 }
 
 type ApiMessage struct {
@@ -18,6 +29,13 @@ type PruneResponse struct {
 	SpaceReclaimed    int      `json:"SpaceReclaimed"`
 }
 
+// PruneRequest Optional body for /containers/prune: only containers created more than Until
+// (a duration such as "24h") ago and carrying every label ("key" or "key=value") are removed
+type PruneRequest struct {
+	Until  string   `json:"Until"`
+	Labels []string `json:"Labels"`
+}
+
 type CreateContainerResponse struct {
 	Id       string   `json:"Id"`
 	Warnings []string `json:"Warnings"`
@@ -38,3 +56,20 @@ type CreateContainerRequest struct {
 type Filter struct {
 	Status []string `json:"Status"`
 }
+
+// FieldError One invalid field of a request, Field is a path such as HostConfig.PortBindings[80/tcp][0].HostPort
+type FieldError struct {
+	Field   string `json:"Field"`
+	Message string `json:"Message"`
+}
+
+// ValidationErrors All problems found in a request
+type ValidationErrors []FieldError
+
+func (v ValidationErrors) Error() string {
+	messages := make([]string, 0, len(v))
+	for _, fieldError := range v {
+		messages = append(messages, fieldError.Field+": "+fieldError.Message)
+	}
+	return strings.Join(messages, "; ")
+}
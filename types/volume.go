@@ -0,0 +1,33 @@
+package types
+
+// VolumeCreateRequest Body for POST /volumes/create
+type VolumeCreateRequest struct {
+	Name       string            `json:"Name"`
+	Driver     string            `json:"Driver,omitempty"`
+	DriverOpts map[string]string `json:"DriverOpts,omitempty"`
+	Labels     map[string]string `json:"Labels,omitempty"`
+}
+
+// Volume Volume as returned by the Docker daemon
+type Volume struct {
+	Name       string            `json:"Name"`
+	Driver     string            `json:"Driver"`
+	Mountpoint string            `json:"Mountpoint"`
+	CreatedAt  string            `json:"CreatedAt"`
+	Labels     map[string]string `json:"Labels"`
+	Scope      string            `json:"Scope"`
+	Options    map[string]string `json:"Options"`
+	UsageData  *VolumeUsageData  `json:"UsageData,omitempty"`
+}
+
+// VolumeUsageData Only filled by GET /system/df. -1 means the size or count is unknown.
+type VolumeUsageData struct {
+	Size     int64 `json:"Size"`
+	RefCount int64 `json:"RefCount"`
+}
+
+// VolumeListResponse Response from GET /volumes
+type VolumeListResponse struct {
+	Volumes  []Volume `json:"Volumes"`
+	Warnings []string `json:"Warnings"`
+}
@@ -0,0 +1,31 @@
+package utils
+
+import (
+	. "github.com/LysetsDal/docker-api/types"
+	"mime"
+	"net/http"
+)
+
+// ArchiveContentTypes Request bodies the daemon accepts as a (compressed) tar
+var ArchiveContentTypes = map[string]bool{
+	"":                    true,
+	"application/x-tar":   true,
+	"application/gzip":    true,
+	"application/x-gzip":  true,
+	"application/x-bzip2": true,
+	"application/x-xz":    true,
+}
+
+// MediaType The request's Content-Type without parameters, "" when it is not set
+func MediaType(r *http.Request) (string, error) {
+	contentType := r.Header.Get("Content-Type")
+	if contentType == "" {
+		return "", nil
+	}
+
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return "", BadRequest("invalid Content-Type: %w", err)
+	}
+	return mediaType, nil
+}
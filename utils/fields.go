@@ -0,0 +1,126 @@
+package utils
+
+import (
+	"encoding/json"
+	. "github.com/LysetsDal/docker-api/types"
+	"reflect"
+	"strings"
+)
+
+// ProjectFields Keep only the given (dotted) paths of v, e.g. "State.Health,Config.Image".
+// v is anything that marshals to a json object, schema the struct type describing it. Paths the schema
+// knows but v leaves out (nil pointers with omitempty) project to null; paths in neither are rejected.
+func ProjectFields(v any, schema reflect.Type, fields string) (map[string]any, error) {
+	encoded, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	source := map[string]any{}
+	if err := json.Unmarshal(encoded, &source); err != nil {
+		return nil, err
+	}
+
+	projected := map[string]any{}
+	for _, field := range strings.Split(fields, ",") {
+		field = strings.TrimSpace(field)
+		if field == "" {
+			continue
+		}
+
+		path := strings.Split(field, ".")
+		value, ok := lookupPath(source, path)
+		if !ok {
+			if !knownPath(schema, path) {
+				return nil, BadRequest("unknown field %q", field)
+			}
+			value = nil
+		}
+		setPath(projected, path, value)
+	}
+
+	return projected, nil
+}
+
+func lookupPath(source map[string]any, path []string) (any, bool) {
+	var current any = source
+	for _, key := range path {
+		object, ok := current.(map[string]any)
+		if !ok {
+			return nil, false
+		}
+		if current, ok = object[key]; !ok {
+			return nil, false
+		}
+	}
+	return current, true
+}
+
+// knownPath Whether path names a field of t, following json names, pointers, embedded structs and map
+// keys. Anything below an interface is accepted.
+func knownPath(t reflect.Type, path []string) bool {
+	for _, key := range path {
+		for t.Kind() == reflect.Pointer {
+			t = t.Elem()
+		}
+		switch t.Kind() {
+		case reflect.Struct:
+			fieldType, ok := jsonField(t, key)
+			if !ok {
+				return false
+			}
+			t = fieldType
+		case reflect.Map:
+			t = t.Elem()
+		case reflect.Interface:
+			return true
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// jsonField The type of t's field that marshals under name, looking into untagged embedded structs
+func jsonField(t reflect.Type, name string) (reflect.Type, bool) {
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		tagName, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if tagName == "-" {
+			continue
+		}
+
+		embedded := field.Type
+		if embedded.Kind() == reflect.Pointer {
+			embedded = embedded.Elem()
+		}
+		if field.Anonymous && tagName == "" && embedded.Kind() == reflect.Struct {
+			if fieldType, ok := jsonField(embedded, name); ok {
+				return fieldType, true
+			}
+			continue
+		}
+
+		if !field.IsExported() {
+			continue
+		}
+		if tagName == "" {
+			tagName = field.Name
+		}
+		if tagName == name {
+			return field.Type, true
+		}
+	}
+	return nil, false
+}
+
+func setPath(target map[string]any, path []string, value any) {
+	for _, key := range path[:len(path)-1] {
+		next, ok := target[key].(map[string]any)
+		if !ok {
+			next = map[string]any{}
+			target[key] = next
+		}
+		target = next
+	}
+	target[path[len(path)-1]] = value
+}
@@ -0,0 +1,70 @@
+package utils
+
+import (
+	"encoding/json"
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/LysetsDal/docker-api/types"
+)
+
+func TestProjectFields(t *testing.T) {
+	size := int64(42)
+	schema := reflect.TypeOf(types.InspectObject{})
+
+	healthy := types.InspectObject{Id: "abc"}
+	healthy.State.Status = "running"
+	healthy.State.Health = &types.Health{Status: "healthy"}
+	healthy.Config.Image = "nginx"
+	healthy.Config.Labels = map[string]string{"app": "web"}
+
+	sized := healthy
+	sized.SizeRw = &size
+
+	unhealthy := healthy
+	unhealthy.State.Health = nil
+
+	tests := []struct {
+		name    string
+		v       any
+		fields  string
+		want    string
+		invalid bool
+	}{
+		{name: "nested", v: healthy, fields: "State.Status,Config.Image", want: `{"Config":{"Image":"nginx"},"State":{"Status":"running"}}`},
+		{name: "present pointer", v: healthy, fields: "State.Health.Status", want: `{"State":{"Health":{"Status":"healthy"}}}`},
+		{name: "absent pointer", v: unhealthy, fields: "State.Health", want: `{"State":{"Health":null}}`},
+		{name: "below absent pointer", v: unhealthy, fields: "State.Health.Status", want: `{"State":{"Health":{"Status":null}}}`},
+		{name: "omitted size", v: healthy, fields: "SizeRw", want: `{"SizeRw":null}`},
+		{name: "size", v: sized, fields: "SizeRw", want: `{"SizeRw":42}`},
+		{name: "map key", v: healthy, fields: "Config.Labels.app", want: `{"Config":{"Labels":{"app":"web"}}}`},
+		{name: "absent map key", v: healthy, fields: "Config.Labels.tier", want: `{"Config":{"Labels":{"tier":null}}}`},
+		{name: "spaces and empty entries", v: healthy, fields: " Id , ,", want: `{"Id":"abc"}`},
+		{name: "raw message", v: json.RawMessage(`{"Id":"abc","Extra":{"A":1}}`), fields: "Extra.A,SizeRw", want: `{"Extra":{"A":1},"SizeRw":null}`},
+		{name: "unknown below absent pointer", v: unhealthy, fields: "State.Health.Nope", invalid: true},
+		{name: "unknown field", v: healthy, fields: "State.Nope", invalid: true},
+		{name: "unknown top level", v: healthy, fields: "Nope", invalid: true},
+		{name: "below a scalar", v: healthy, fields: "Config.Image.Name", invalid: true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			projected, err := ProjectFields(test.v, schema, test.fields)
+			if test.invalid {
+				if !errors.Is(err, types.ErrBadRequest) {
+					t.Fatalf("want a bad request, got %v", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			got, _ := json.Marshal(projected)
+			if string(got) != test.want {
+				t.Errorf("got %s, want %s", got, test.want)
+			}
+		})
+	}
+}
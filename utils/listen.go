@@ -0,0 +1,97 @@
+package utils
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"net"
+	"os"
+	"os/user"
+	"strconv"
+
+	"github.com/coreos/go-systemd/v22/activation"
+	"github.com/coreos/go-systemd/v22/daemon"
+)
+
+// SystemdListeners The sockets systemd passed via LISTEN_FDS, none when not socket activated
+func SystemdListeners() ([]net.Listener, error) {
+	listeners, err := activation.Listeners()
+	if err != nil {
+		return nil, fmt.Errorf("systemd socket activation: %w", err)
+	}
+
+	// Descriptors that aren't stream sockets come back as nil
+	var usable []net.Listener
+	for _, l := range listeners {
+		if l != nil {
+			usable = append(usable, l)
+		}
+	}
+	if len(usable) < len(listeners) {
+		return nil, fmt.Errorf("systemd passed %d sockets, only %d are stream sockets", len(listeners), len(usable))
+	}
+	return usable, nil
+}
+
+// SystemdReady Tell systemd the service is serving. Does nothing unless run by systemd with
+// Type=notify.
+func SystemdReady() {
+	_, _ = daemon.SdNotify(false, daemon.SdNotifyReady)
+}
+
+// SystemdStopping Tell systemd the service is shutting down
+func SystemdStopping() {
+	_, _ = daemon.SdNotify(false, daemon.SdNotifyStopping)
+}
+
+// ListenUnix Listen on a unix socket at path with permissions mode (octal) owned by group (a name or
+// id, unchanged when empty). A socket left behind by a previous run is removed; any other file at path
+// is an error. The socket is removed when the listener is closed.
+func ListenUnix(path, mode, group string) (net.Listener, error) {
+	perm, err := strconv.ParseUint(mode, 8, 32)
+	if err != nil || perm > 0o777 {
+		return nil, fmt.Errorf("unix socket mode must be octal permissions like 0660, got %q", mode)
+	}
+	gid := -1
+	if group != "" {
+		if gid, err = lookupGroup(group); err != nil {
+			return nil, err
+		}
+	}
+
+	if info, err := os.Lstat(path); err == nil {
+		if info.Mode().Type() != fs.ModeSocket {
+			return nil, fmt.Errorf("%s exists and is not a socket", path)
+		}
+		if err := os.Remove(path); err != nil {
+			return nil, fmt.Errorf("removing stale socket: %w", err)
+		}
+	} else if !errors.Is(err, fs.ErrNotExist) {
+		return nil, err
+	}
+
+	l, err := net.Listen("unix", path)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.Chmod(path, fs.FileMode(perm)); err != nil {
+		_ = l.Close()
+		return nil, fmt.Errorf("setting socket permissions: %w", err)
+	}
+	if err := os.Chown(path, -1, gid); err != nil {
+		_ = l.Close()
+		return nil, fmt.Errorf("setting socket group: %w", err)
+	}
+	return l, nil
+}
+
+func lookupGroup(group string) (int, error) {
+	if gid, err := strconv.Atoi(group); err == nil {
+		return gid, nil
+	}
+	g, err := user.LookupGroup(group)
+	if err != nil {
+		return 0, fmt.Errorf("unix socket group: %w", err)
+	}
+	return strconv.Atoi(g.Gid)
+}
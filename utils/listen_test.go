@@ -0,0 +1,95 @@
+package utils
+
+import (
+	"io/fs"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"syscall"
+	"testing"
+)
+
+// socketDir A directory short enough for unix socket paths, removed when the test ends
+func socketDir(t *testing.T) string {
+	dir, err := os.MkdirTemp("", "sock")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = os.RemoveAll(dir) })
+	return dir
+}
+
+func TestListenUnix(t *testing.T) {
+	path := filepath.Join(socketDir(t), "api.sock")
+	gid := strconv.Itoa(os.Getgid())
+
+	l, err := ListenUnix(path, "0660", gid)
+	if err != nil {
+		t.Fatal(err)
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Mode().Type() != fs.ModeSocket || info.Mode().Perm() != 0o660 {
+		t.Errorf("mode %v", info.Mode())
+	}
+	if owner := info.Sys().(*syscall.Stat_t).Gid; strconv.Itoa(int(owner)) != gid {
+		t.Errorf("group %d, want %s", owner, gid)
+	}
+
+	conn, err := net.Dial("unix", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	conn.Close()
+
+	// A socket left behind by a crash is replaced
+	l.(*net.UnixListener).SetUnlinkOnClose(false)
+	l.Close()
+	if l, err = ListenUnix(path, "600", ""); err != nil {
+		t.Fatalf("stale socket: %v", err)
+	}
+	l.Close()
+	if _, err := os.Lstat(path); !os.IsNotExist(err) {
+		t.Errorf("the socket outlived its listener: %v", err)
+	}
+}
+
+func TestListenUnixInvalid(t *testing.T) {
+	dir := socketDir(t)
+	file := filepath.Join(dir, "notes.txt")
+	if err := os.WriteFile(file, []byte("keep me"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name, path, mode, group string
+	}{
+		{name: "not octal", path: filepath.Join(dir, "a.sock"), mode: "rw-rw----"},
+		{name: "too wide", path: filepath.Join(dir, "a.sock"), mode: "4777"},
+		{name: "unknown group", path: filepath.Join(dir, "a.sock"), mode: "0660", group: "no-such-group-here"},
+		{name: "not a socket", path: file, mode: "0660"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if l, err := ListenUnix(test.path, test.mode, test.group); err == nil {
+				l.Close()
+				t.Error("no error")
+			}
+		})
+	}
+	if content, _ := os.ReadFile(file); string(content) != "keep me" {
+		t.Error("a regular file was replaced")
+	}
+}
+
+func TestSystemdListenersNotActivated(t *testing.T) {
+	t.Setenv("LISTEN_PID", "")
+	t.Setenv("LISTEN_FDS", "")
+	if listeners, err := SystemdListeners(); err != nil || len(listeners) != 0 {
+		t.Errorf("got %v, %v", listeners, err)
+	}
+}
@@ -0,0 +1,56 @@
+package utils
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"go.opentelemetry.io/otel/trace"
+)
+
+func TestNewLogger(t *testing.T) {
+	for _, test := range []struct{ level, format string }{{"verbose", "json"}, {"info", "xml"}} {
+		if _, err := NewLogger(&bytes.Buffer{}, test.level, test.format); err == nil {
+			t.Errorf("%s %s: no error", test.level, test.format)
+		}
+	}
+
+	output := &bytes.Buffer{}
+	logger, err := NewLogger(output, "INFO", "JSON")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	span := trace.NewSpanContext(trace.SpanContextConfig{TraceID: trace.TraceID{1}, SpanID: trace.SpanID{2}})
+	ctx := trace.ContextWithSpanContext(WithRequestId(context.Background(), "req-1"), span)
+
+	logger.DebugContext(ctx, "hidden")
+	logger.With("component", "jobs").InfoContext(ctx, "ran")
+	logger.Info("no request")
+
+	lines := strings.Split(strings.TrimSpace(output.String()), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("got %d lines: %s", len(lines), output)
+	}
+	record := map[string]string{}
+	if err := json.Unmarshal([]byte(lines[0]), &record); err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]string{
+		"msg":        "ran",
+		"component":  "jobs",
+		"request_id": "req-1",
+		"trace_id":   span.TraceID().String(),
+		"span_id":    span.SpanID().String(),
+	}
+	for key, value := range want {
+		if record[key] != value {
+			t.Errorf("%s: got %q, want %q", key, record[key], value)
+		}
+	}
+	if strings.Contains(lines[1], "request_id") {
+		t.Errorf("a line without a request has a request id: %s", lines[1])
+	}
+}
@@ -0,0 +1,60 @@
+package utils
+
+import "net/http"
+
+// ResponseRecorder An http.ResponseWriter that keeps the response instead of sending it, for handlers
+// run in the background or whose responses are stored. Only the first Limit bytes of the body are kept.
+type ResponseRecorder struct {
+	StatusCode int
+	Body       []byte
+	Size       int64
+	Truncated  bool
+	Limit      int
+
+	// OnWrite If set, sees every write as it happens
+	OnWrite func(p []byte)
+
+	header http.Header
+}
+
+func NewResponseRecorder(limit int) *ResponseRecorder {
+	return &ResponseRecorder{Limit: limit, header: http.Header{}}
+}
+
+func (r *ResponseRecorder) Header() http.Header {
+	return r.header
+}
+
+func (r *ResponseRecorder) WriteHeader(statusCode int) {
+	if r.StatusCode == 0 {
+		r.StatusCode = statusCode
+	}
+}
+
+func (r *ResponseRecorder) Write(p []byte) (int, error) {
+	r.WriteHeader(http.StatusOK)
+	r.Size += int64(len(p))
+
+	if room := r.Limit - len(r.Body); room < len(p) {
+		r.Body = append(r.Body, p[:max(room, 0)]...)
+		r.Truncated = true
+	} else {
+		r.Body = append(r.Body, p...)
+	}
+
+	if r.OnWrite != nil {
+		r.OnWrite(p)
+	}
+	return len(p), nil
+}
+
+// Flush Streaming handlers flush after every message, there is nothing to flush to
+func (r *ResponseRecorder) Flush() {}
+
+// Status The status code the handler sent, 200 if it sent none
+func (r *ResponseRecorder) Status() int {
+	if r.StatusCode == 0 {
+		return http.StatusOK
+	}
+	return r.StatusCode
+}
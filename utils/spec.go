@@ -0,0 +1,332 @@
+package utils
+
+import (
+	"fmt"
+	. "github.com/LysetsDal/docker-api/types"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// PortMapping One container port (e.g. "80/tcp") and its optional host binding
+type PortMapping struct {
+	ContainerPort string
+	Binding       *PortBinding
+}
+
+// VolumeSpec Parsed "source:target:mode" volume shorthand
+type VolumeSpec struct {
+	Source   string
+	Target   string
+	Mode     string
+	ReadOnly bool
+}
+
+// IsBind Reports whether the source is a host path rather than a named volume
+func (v VolumeSpec) IsBind() bool {
+	return strings.HasPrefix(v.Source, "/") || strings.HasPrefix(v.Source, ".") || strings.HasPrefix(v.Source, "~")
+}
+
+// IsAnonymous Reports whether the spec only names a container path
+func (v VolumeSpec) IsAnonymous() bool {
+	return v.Source == ""
+}
+
+// Bind Format the spec as a HostConfig.Binds entry
+func (v VolumeSpec) Bind() string {
+	if v.Mode == "" {
+		return v.Source + ":" + v.Target
+	}
+	return v.Source + ":" + v.Target + ":" + v.Mode
+}
+
+var restartPolicies = map[string]bool{"no": true, "always": true, "unless-stopped": true, "on-failure": true}
+
+// ParsePortSpec Parse docker run style port specs:
+// "80", "80/udp", "8080:80", "127.0.0.1:8080:80/tcp", "[::1]::80" and ranges such as "8000-8001:80-81"
+func ParsePortSpec(spec string) ([]PortMapping, error) {
+	rest, proto := spec, "tcp"
+	if slash := strings.LastIndex(spec, "/"); slash >= 0 {
+		rest, proto = spec[:slash], strings.ToLower(spec[slash+1:])
+	}
+	if proto != "tcp" && proto != "udp" && proto != "sctp" {
+		return nil, fmt.Errorf("invalid protocol %q in port spec %q", proto, spec)
+	}
+
+	hostIp := ""
+	if strings.HasPrefix(rest, "[") {
+		end := strings.Index(rest, "]:")
+		if end < 0 {
+			return nil, fmt.Errorf("invalid IPv6 host address in port spec %q", spec)
+		}
+		hostIp, rest = rest[1:end], rest[end+2:]
+	}
+
+	parts := strings.Split(rest, ":")
+	hostPorts, containerPorts := "", ""
+	switch {
+	case len(parts) == 1:
+		containerPorts = parts[0]
+	case len(parts) == 2:
+		hostPorts, containerPorts = parts[0], parts[1]
+	case len(parts) == 3 && hostIp == "":
+		hostIp, hostPorts, containerPorts = parts[0], parts[1], parts[2]
+	default:
+		return nil, fmt.Errorf("invalid port spec %q", spec)
+	}
+
+	containerStart, containerEnd, err := parsePortRange(containerPorts)
+	if err != nil {
+		return nil, fmt.Errorf("invalid container port in %q: %w", spec, err)
+	}
+
+	hostStart, hostEnd := 0, 0
+	if hostPorts != "" {
+		if hostStart, hostEnd, err = parsePortRange(hostPorts); err != nil {
+			return nil, fmt.Errorf("invalid host port in %q: %w", spec, err)
+		}
+		if hostEnd-hostStart != containerEnd-containerStart && hostStart != hostEnd {
+			return nil, fmt.Errorf("host and container port ranges differ in size in %q", spec)
+		}
+	}
+
+	bind := hostIp != "" || hostPorts != "" || len(parts) > 1
+	mappings := make([]PortMapping, 0, containerEnd-containerStart+1)
+	for port := containerStart; port <= containerEnd; port++ {
+		mapping := PortMapping{ContainerPort: fmt.Sprintf("%d/%s", port, proto)}
+		if bind {
+			hostPort := ""
+			if hostStart != 0 {
+				hostPort = strconv.Itoa(hostStart)
+				if hostEnd != hostStart {
+					hostPort = strconv.Itoa(hostStart + port - containerStart)
+				}
+			}
+			mapping.Binding = &PortBinding{HostIp: hostIp, HostPort: hostPort}
+		}
+		mappings = append(mappings, mapping)
+	}
+
+	return mappings, nil
+}
+
+func parsePortRange(ports string) (int, int, error) {
+	start, end, isRange := strings.Cut(ports, "-")
+	first, err := parsePort(start)
+	if err != nil {
+		return 0, 0, err
+	}
+	if !isRange {
+		return first, first, nil
+	}
+
+	last, err := parsePort(end)
+	if err != nil {
+		return 0, 0, err
+	}
+	if last < first {
+		return 0, 0, fmt.Errorf("range %q ends before it starts", ports)
+	}
+	return first, last, nil
+}
+
+func parsePort(port string) (int, error) {
+	number, err := strconv.Atoi(port)
+	if err != nil || number < 1 || number > 65535 {
+		return 0, fmt.Errorf("%q is not a port between 1 and 65535", port)
+	}
+	return number, nil
+}
+
+// ApplyPortMappings Add the mappings to ExposedPorts and HostConfig.PortBindings
+func ApplyPortMappings(payload *Payload, mappings []PortMapping) {
+	if payload.ExposedPorts == nil {
+		payload.ExposedPorts = map[string]struct{}{}
+	}
+	for _, mapping := range mappings {
+		payload.ExposedPorts[mapping.ContainerPort] = struct{}{}
+		if mapping.Binding == nil {
+			continue
+		}
+		if payload.HostConfig.PortBindings == nil {
+			payload.HostConfig.PortBindings = map[string][]PortBinding{}
+		}
+		payload.HostConfig.PortBindings[mapping.ContainerPort] = append(payload.HostConfig.PortBindings[mapping.ContainerPort], *mapping.Binding)
+	}
+}
+
+// ParseVolumeSpec Parse "source:target[:mode]" or an anonymous "target"
+func ParseVolumeSpec(spec string) (VolumeSpec, error) {
+	parts := strings.Split(spec, ":")
+	volume := VolumeSpec{}
+	switch len(parts) {
+	case 1:
+		volume.Target = parts[0]
+	case 2:
+		volume.Source, volume.Target = parts[0], parts[1]
+	case 3:
+		volume.Source, volume.Target, volume.Mode = parts[0], parts[1], parts[2]
+	default:
+		return volume, fmt.Errorf("invalid volume spec %q", spec)
+	}
+
+	if !strings.HasPrefix(volume.Target, "/") {
+		return volume, fmt.Errorf("container path in %q must be absolute", spec)
+	}
+	if len(parts) > 1 && volume.Source == "" {
+		return volume, fmt.Errorf("missing source in volume spec %q", spec)
+	}
+
+	for _, option := range strings.Split(volume.Mode, ",") {
+		switch option {
+		case "", "rw", "z", "Z", "nocopy", "shared", "rshared", "slave", "rslave", "private", "rprivate":
+		case "ro":
+			volume.ReadOnly = true
+		default:
+			return volume, fmt.Errorf("invalid mode %q in volume spec %q", option, spec)
+		}
+	}
+
+	return volume, nil
+}
+
+// ParseRestartPolicy Parse "no", "always", "unless-stopped", "on-failure" or "on-failure:N"
+func ParseRestartPolicy(policy string) (RestartPolicy, error) {
+	name, retries, hasRetries := strings.Cut(policy, ":")
+	if name == "" {
+		name = "no"
+	}
+	if !restartPolicies[name] {
+		return RestartPolicy{}, fmt.Errorf("invalid restart policy %q", policy)
+	}
+
+	restartPolicy := RestartPolicy{Name: name}
+	if hasRetries {
+		if name != "on-failure" {
+			return RestartPolicy{}, fmt.Errorf("only on-failure accepts a retry count, got %q", policy)
+		}
+		count, err := strconv.Atoi(retries)
+		if err != nil || count < 0 {
+			return RestartPolicy{}, fmt.Errorf("invalid retry count in restart policy %q", policy)
+		}
+		restartPolicy.MaximumRetryCount = count
+	}
+
+	return restartPolicy, nil
+}
+
+// ParseMemory Parse sizes such as "512m", "1.5g", "64MiB" or a plain number of bytes
+func ParseMemory(size string) (int64, error) {
+	value := strings.ToLower(strings.TrimSpace(size))
+	value = strings.TrimSuffix(strings.TrimSuffix(value, "ib"), "b")
+
+	multiplier := int64(1)
+	if value != "" {
+		switch value[len(value)-1] {
+		case 'k':
+			multiplier = 1 << 10
+		case 'm':
+			multiplier = 1 << 20
+		case 'g':
+			multiplier = 1 << 30
+		case 't':
+			multiplier = 1 << 40
+		}
+		if multiplier != 1 {
+			value = value[:len(value)-1]
+		}
+	}
+
+	number, err := strconv.ParseFloat(value, 64)
+	bytes := number * float64(multiplier)
+	if err != nil || math.IsNaN(number) || number < 0 || bytes >= math.MaxInt64 {
+		return 0, fmt.Errorf("invalid size %q", size)
+	}
+
+	return int64(bytes), nil
+}
+
+// ExpandRunRequest Expand the shorthand into a full create payload. Errors are ValidationErrors
+// pointing at the shorthand field, followed by any problems ValidatePayload finds in the result.
+func ExpandRunRequest(request RunRequest) (Payload, error) {
+	v := &validator{}
+	payload := Payload{
+		Image:      request.Image,
+		Cmd:        request.Cmd,
+		Entrypoint: request.Entrypoint,
+		Labels:     request.Labels,
+		WorkingDir: request.WorkingDir,
+		User:       request.User,
+		Tty:        request.Tty,
+	}
+	payload.HostConfig.AutoRemove = request.AutoRemove
+	payload.HostConfig.NetworkMode = request.Network
+
+	keys := make([]string, 0, len(request.Env))
+	for key := range request.Env {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		payload.Env = append(payload.Env, key+"="+request.Env[key])
+	}
+
+	for i, spec := range request.Ports {
+		mappings, err := ParsePortSpec(spec)
+		if err != nil {
+			v.fail(fmt.Sprintf("Ports[%d]", i), "%s", err)
+			continue
+		}
+		ApplyPortMappings(&payload, mappings)
+	}
+
+	for i, spec := range request.Volumes {
+		volume, err := ParseVolumeSpec(spec)
+		switch {
+		case err != nil:
+			v.fail(fmt.Sprintf("Volumes[%d]", i), "%s", err)
+		case volume.IsAnonymous():
+			if payload.Volumes == nil {
+				payload.Volumes = map[string]struct{}{}
+			}
+			payload.Volumes[volume.Target] = struct{}{}
+		case volume.IsBind() && !strings.HasPrefix(volume.Source, "/"):
+			v.fail(fmt.Sprintf("Volumes[%d]", i), "host path %q must be absolute", volume.Source)
+		default:
+			payload.HostConfig.Binds = append(payload.HostConfig.Binds, volume.Bind())
+		}
+	}
+
+	if request.Memory != "" {
+		memory, err := ParseMemory(request.Memory)
+		if err != nil {
+			v.fail("Memory", "%s, use a size such as 512m or 2g", err)
+		}
+		payload.HostConfig.Memory = int(memory)
+	}
+
+	if request.Cpus < 0 {
+		v.fail("Cpus", "must not be negative")
+	}
+	payload.HostConfig.NanoCpus = int64(request.Cpus * 1e9)
+
+	if request.Restart != "" {
+		policy, err := ParseRestartPolicy(request.Restart)
+		if err != nil {
+			v.fail("Restart", "%s", err)
+		}
+		payload.HostConfig.RestartPolicy = policy
+	}
+
+	switch request.Pull {
+	case "", "missing", "always", "never":
+	default:
+		v.fail("Pull", "must be missing, always or never")
+	}
+
+	if len(v.errors) > 0 {
+		return payload, v.errors
+	}
+	return payload, ValidatePayload(payload)
+}
@@ -0,0 +1,131 @@
+package utils
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/LysetsDal/docker-api/types"
+)
+
+func TestParsePortSpec(t *testing.T) {
+	bound := func(port, hostIp, hostPort string) PortMapping {
+		return PortMapping{ContainerPort: port, Binding: &types.PortBinding{HostIp: hostIp, HostPort: hostPort}}
+	}
+
+	tests := []struct {
+		spec    string
+		want    []PortMapping
+		invalid bool
+	}{
+		{spec: "80", want: []PortMapping{{ContainerPort: "80/tcp"}}},
+		{spec: "53/UDP", want: []PortMapping{{ContainerPort: "53/udp"}}},
+		{spec: "8080:80", want: []PortMapping{bound("80/tcp", "", "8080")}},
+		{spec: ":80", want: []PortMapping{bound("80/tcp", "", "")}},
+		{spec: "127.0.0.1:8080:80/tcp", want: []PortMapping{bound("80/tcp", "127.0.0.1", "8080")}},
+		{spec: "127.0.0.1::80", want: []PortMapping{bound("80/tcp", "127.0.0.1", "")}},
+		{spec: "[::1]::80", want: []PortMapping{bound("80/tcp", "::1", "")}},
+		{spec: "[::1]:8080:80/sctp", want: []PortMapping{bound("80/sctp", "::1", "8080")}},
+		{spec: "8000-8001:80-81", want: []PortMapping{bound("80/tcp", "", "8000"), bound("81/tcp", "", "8001")}},
+		{spec: "8000:80-81", want: []PortMapping{bound("80/tcp", "", "8000"), bound("81/tcp", "", "8000")}},
+		{spec: "80-81", want: []PortMapping{{ContainerPort: "80/tcp"}, {ContainerPort: "81/tcp"}}},
+		{spec: "80/icmp", invalid: true},
+		{spec: "", invalid: true},
+		{spec: "0", invalid: true},
+		{spec: "65536", invalid: true},
+		{spec: "http", invalid: true},
+		{spec: "81-80", invalid: true},
+		{spec: "8000-8002:80-81", invalid: true},
+		{spec: "[::1:8080:80", invalid: true},
+		{spec: "[::1]:127.0.0.1:8080:80", invalid: true},
+		{spec: "a:b:8080:80", invalid: true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.spec, func(t *testing.T) {
+			got, err := ParsePortSpec(test.spec)
+			if (err != nil) != test.invalid {
+				t.Fatalf("error: %v", err)
+			}
+			if !test.invalid && !reflect.DeepEqual(got, test.want) {
+				t.Errorf("got %+v, want %+v", got, test.want)
+			}
+		})
+	}
+}
+
+func TestParseVolumeSpec(t *testing.T) {
+	tests := []struct {
+		spec      string
+		want      VolumeSpec
+		bind      bool
+		anonymous bool
+		invalid   bool
+	}{
+		{spec: "/data", want: VolumeSpec{Target: "/data"}, anonymous: true},
+		{spec: "pgdata:/var/lib/postgresql/data", want: VolumeSpec{Source: "pgdata", Target: "/var/lib/postgresql/data"}},
+		{spec: "/srv/conf:/etc/app:ro", want: VolumeSpec{Source: "/srv/conf", Target: "/etc/app", Mode: "ro", ReadOnly: true}, bind: true},
+		{spec: "./conf:/etc/app:ro,z", want: VolumeSpec{Source: "./conf", Target: "/etc/app", Mode: "ro,z", ReadOnly: true}, bind: true},
+		{spec: "cache:/cache:rw,nocopy", want: VolumeSpec{Source: "cache", Target: "/cache", Mode: "rw,nocopy"}},
+		{spec: "data", invalid: true},
+		{spec: "data:relative", invalid: true},
+		{spec: ":/data", invalid: true},
+		{spec: "data:/data:rx", invalid: true},
+		{spec: "a:b:/c:ro", invalid: true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.spec, func(t *testing.T) {
+			got, err := ParseVolumeSpec(test.spec)
+			if (err != nil) != test.invalid {
+				t.Fatalf("error: %v", err)
+			}
+			if test.invalid {
+				return
+			}
+			if got != test.want {
+				t.Errorf("got %+v, want %+v", got, test.want)
+			}
+			if got.IsBind() != test.bind || got.IsAnonymous() != test.anonymous {
+				t.Errorf("bind %v, anonymous %v", got.IsBind(), got.IsAnonymous())
+			}
+		})
+	}
+}
+
+func TestParseMemory(t *testing.T) {
+	tests := []struct {
+		size    string
+		want    int64
+		invalid bool
+	}{
+		{size: "1048576", want: 1 << 20},
+		{size: "100b", want: 100},
+		{size: "512k", want: 512 << 10},
+		{size: "512m", want: 512 << 20},
+		{size: "512MB", want: 512 << 20},
+		{size: "64MiB", want: 64 << 20},
+		{size: "1.5g", want: 3 << 29},
+		{size: " 2G ", want: 2 << 30},
+		{size: "1t", want: 1 << 40},
+		{size: "0", want: 0},
+		{size: "", invalid: true},
+		{size: "m", invalid: true},
+		{size: "-1m", invalid: true},
+		{size: "12x", invalid: true},
+		{size: "nan", invalid: true},
+		{size: "inf", invalid: true},
+		{size: "1e30", invalid: true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.size, func(t *testing.T) {
+			got, err := ParseMemory(test.size)
+			if (err != nil) != test.invalid {
+				t.Fatalf("error: %v", err)
+			}
+			if got != test.want {
+				t.Errorf("got %d, want %d", got, test.want)
+			}
+		})
+	}
+}
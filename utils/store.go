@@ -0,0 +1,129 @@
+package utils
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+)
+
+// JsonStore Named records kept in memory and persisted as a single json file
+type JsonStore[T any] struct {
+	mu      sync.RWMutex
+	path    string
+	records map[string]T
+}
+
+// NewJsonStore Load the store from path. A missing file is an empty store.
+func NewJsonStore[T any](path string) (*JsonStore[T], error) {
+	store := &JsonStore[T]{
+		path:    path,
+		records: map[string]T{},
+	}
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return store, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, &store.records); err != nil {
+		return nil, err
+	}
+
+	return store, nil
+}
+
+func (s *JsonStore[T]) Get(name string) (T, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	record, ok := s.records[name]
+	return record, ok
+}
+
+// Names All record names, sorted
+func (s *JsonStore[T]) Names() []string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	names := make([]string, 0, len(s.records))
+	for name := range s.records {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// List All records, sorted by name
+func (s *JsonStore[T]) List() []T {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	names := make([]string, 0, len(s.records))
+	for name := range s.records {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	records := make([]T, 0, len(names))
+	for _, name := range names {
+		records = append(records, s.records[name])
+	}
+	return records
+}
+
+func (s *JsonStore[T]) Put(name string, record T) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	previous, existed := s.records[name]
+	s.records[name] = record
+	if err := s.save(); err != nil {
+		if existed {
+			s.records[name] = previous
+		} else {
+			delete(s.records, name)
+		}
+		return err
+	}
+	return nil
+}
+
+// Delete Remove a record, reporting whether it existed
+func (s *JsonStore[T]) Delete(name string) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	record, ok := s.records[name]
+	if !ok {
+		return false, nil
+	}
+
+	delete(s.records, name)
+	if err := s.save(); err != nil {
+		s.records[name] = record
+		return false, err
+	}
+	return true, nil
+}
+
+// save Write to a temp file and rename it over the old one, so a crash never leaves half a file
+func (s *JsonStore[T]) save() error {
+	data, err := json.MarshalIndent(s.records, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(s.path), 0o700); err != nil {
+		return err
+	}
+
+	tmp := s.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, s.path)
+}
@@ -0,0 +1,370 @@
+package utils
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	. "github.com/LysetsDal/docker-api/config"
+	. "github.com/LysetsDal/docker-api/types"
+	"math"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+var (
+	placeholderRegex   = regexp.MustCompile(`\$\{([a-zA-Z_][a-zA-Z0-9_]*)\}`)
+	parameterNameRegex = regexp.MustCompile(`^[a-zA-Z_][a-zA-Z0-9_]*$`)
+)
+
+var parameterTypes = map[string]bool{"": true, "string": true, "integer": true, "number": true, "boolean": true}
+
+// ValidateTemplate Check a template before it is stored: the parameter schema must be well formed,
+// defaults must satisfy it, every placeholder must name a declared parameter and the payload must
+// render to a Payload.
+func ValidateTemplate(template ContainerTemplate) error {
+	v := &validator{}
+
+	declared := map[string]bool{}
+	for i, parameter := range template.Parameters {
+		field := fmt.Sprintf("Parameters[%d]", i)
+		switch {
+		case !parameterNameRegex.MatchString(parameter.Name):
+			v.fail(field+".Name", "%q must be a letter or underscore followed by letters, digits or underscores", parameter.Name)
+		case declared[parameter.Name]:
+			v.fail(field+".Name", "%q is declared more than once", parameter.Name)
+		}
+		declared[parameter.Name] = true
+
+		if !parameterTypes[parameter.Type] {
+			v.fail(field+".Type", "must be string, integer, number or boolean")
+			continue
+		}
+		if parameter.Pattern != "" {
+			if _, err := regexp.Compile(parameter.Pattern); err != nil {
+				v.fail(field+".Pattern", "%s", err)
+				continue
+			}
+		}
+		if parameter.Minimum != nil && parameter.Maximum != nil && *parameter.Minimum > *parameter.Maximum {
+			v.fail(field+".Minimum", "must not be greater than Maximum")
+		}
+		if parameter.Default != nil {
+			if _, err := resolveParameter(parameter, parameter.Default); err != nil {
+				v.fail(field+".Default", "%s", err)
+			}
+		}
+	}
+
+	var payload any
+	if err := json.Unmarshal(template.Payload, &payload); err != nil {
+		v.fail("Payload", "%s", err)
+	} else if _, ok := payload.(map[string]any); !ok {
+		v.fail("Payload", "must be a json object")
+	}
+
+	used := placeholderRegex.FindAllStringSubmatch(string(template.Payload)+template.ContainerName, -1)
+	for _, match := range used {
+		if !declared[match[1]] {
+			v.fail("Payload", "placeholder ${%s} has no matching parameter", match[1])
+			declared[match[1]] = true
+		}
+	}
+
+	if len(v.errors) > 0 {
+		return v.errors
+	}
+
+	// Render with sample values, so fields Payload doesn't have or values of the wrong type are
+	// refused now rather than on every run
+	samples := map[string]any{}
+	for _, parameter := range template.Parameters {
+		samples[parameter.Name] = sampleValue(parameter)
+	}
+	if _, err := renderPayload(template.Payload, samples); err != nil {
+		v.fail("Payload", "%s", err)
+		return v.errors
+	}
+	return nil
+}
+
+// RenderTemplate Resolve values against the template's schema and substitute them into the payload.
+// Returns the create payload and container name, or ValidationErrors for bad values or a bad result.
+func RenderTemplate(template ContainerTemplate, values map[string]any) (Payload, string, error) {
+	v := &validator{}
+
+	declared := map[string]bool{}
+	resolved := map[string]any{}
+	for _, parameter := range template.Parameters {
+		declared[parameter.Name] = true
+		field := "Parameters." + parameter.Name
+
+		value, given := values[parameter.Name]
+		if !given || value == nil {
+			value = parameter.Default
+		}
+		if value == nil {
+			if parameter.Required {
+				v.fail(field, "is required")
+			}
+			resolved[parameter.Name] = nil
+			continue
+		}
+
+		typed, err := resolveParameter(parameter, value)
+		if err != nil {
+			v.fail(field, "%s", err)
+			continue
+		}
+		resolved[parameter.Name] = typed
+	}
+
+	names := make([]string, 0, len(values))
+	for name := range values {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if !declared[name] {
+			v.fail("Parameters."+name, "is not a parameter of template %s", template.Name)
+		}
+	}
+
+	if len(v.errors) > 0 {
+		return Payload{}, "", v.errors
+	}
+
+	payload, err := renderPayload(template.Payload, resolved)
+	if err != nil {
+		return Payload{}, "", ValidationErrors{{Field: "Payload", Message: err.Error()}}
+	}
+
+	if payload.Labels == nil {
+		payload.Labels = map[string]string{}
+	}
+	payload.Labels[TemplateLabel] = template.Name
+
+	name := substituteString(template.ContainerName, resolved)
+	return payload, name, ValidatePayload(payload)
+}
+
+// renderPayload Substitute values into the payload's placeholders and decode the result as a Payload,
+// refusing fields Payload doesn't have
+func renderPayload(raw json.RawMessage, values map[string]any) (Payload, error) {
+	decoder := json.NewDecoder(bytes.NewReader(raw))
+	decoder.UseNumber()
+	var tree any
+	if err := decoder.Decode(&tree); err != nil {
+		return Payload{}, err
+	}
+
+	rendered, err := json.Marshal(substitute(tree, values, reflect.TypeOf(Payload{})))
+	if err != nil {
+		return Payload{}, err
+	}
+
+	payload := Payload{}
+	decoder = json.NewDecoder(bytes.NewReader(rendered))
+	decoder.DisallowUnknownFields()
+	err = decoder.Decode(&payload)
+	return payload, err
+}
+
+// sampleValue A value of the parameter's type to render the payload with before any values are
+// given: its default, else its first allowed value, else the type's zero value
+func sampleValue(parameter TemplateParameter) any {
+	if parameter.Default != nil {
+		if typed, err := resolveParameter(parameter, parameter.Default); err == nil {
+			return typed
+		}
+	}
+	if len(parameter.Enum) > 0 {
+		if typed, err := resolveParameter(parameter, parameter.Enum[0]); err == nil {
+			return typed
+		}
+	}
+	switch parameter.Type {
+	case "integer":
+		return int64(0)
+	case "number":
+		return float64(0)
+	case "boolean":
+		return false
+	}
+	return ""
+}
+
+// resolveParameter Convert a json value (or its string form) to the parameter's type and check the constraints
+func resolveParameter(parameter TemplateParameter, value any) (any, error) {
+	var typed any
+	switch parameter.Type {
+	case "integer":
+		number, err := toNumber(value)
+		if err != nil || number != math.Trunc(number) {
+			return nil, fmt.Errorf("%v is not an integer", value)
+		}
+		typed = int64(number)
+	case "number":
+		number, err := toNumber(value)
+		if err != nil {
+			return nil, fmt.Errorf("%v is not a number", value)
+		}
+		typed = number
+	case "boolean":
+		switch b := value.(type) {
+		case bool:
+			typed = b
+		case string:
+			parsed, err := strconv.ParseBool(b)
+			if err != nil {
+				return nil, fmt.Errorf("%q is not a boolean", b)
+			}
+			typed = parsed
+		default:
+			return nil, fmt.Errorf("%v is not a boolean", value)
+		}
+	default:
+		s, ok := value.(string)
+		if !ok {
+			return nil, fmt.Errorf("%v is not a string", value)
+		}
+		typed = s
+	}
+
+	if number, ok := toFloat(typed); ok {
+		if parameter.Minimum != nil && number < *parameter.Minimum {
+			return nil, fmt.Errorf("%v is less than the minimum %v", value, *parameter.Minimum)
+		}
+		if parameter.Maximum != nil && number > *parameter.Maximum {
+			return nil, fmt.Errorf("%v is greater than the maximum %v", value, *parameter.Maximum)
+		}
+	}
+
+	text := formatParameter(typed)
+	if len(parameter.Enum) > 0 {
+		allowed := false
+		for _, option := range parameter.Enum {
+			allowed = allowed || option == text
+		}
+		if !allowed {
+			return nil, fmt.Errorf("%q is not one of %s", text, strings.Join(parameter.Enum, ", "))
+		}
+	}
+	if parameter.Pattern != "" {
+		pattern, err := regexp.Compile("^(?:" + parameter.Pattern + ")$")
+		if err != nil {
+			return nil, err
+		}
+		if !pattern.MatchString(text) {
+			return nil, fmt.Errorf("%q does not match %s", text, parameter.Pattern)
+		}
+	}
+
+	return typed, nil
+}
+
+func toNumber(value any) (float64, error) {
+	switch n := value.(type) {
+	case float64:
+		return n, nil
+	case int:
+		return float64(n), nil
+	case json.Number:
+		return n.Float64()
+	case string:
+		return strconv.ParseFloat(n, 64)
+	}
+	return 0, fmt.Errorf("%v is not a number", value)
+}
+
+func toFloat(value any) (float64, bool) {
+	switch n := value.(type) {
+	case int64:
+		return float64(n), true
+	case float64:
+		return n, true
+	}
+	return 0, false
+}
+
+func formatParameter(value any) string {
+	switch typed := value.(type) {
+	case nil:
+		return ""
+	case string:
+		return typed
+	case int64:
+		return strconv.FormatInt(typed, 10)
+	case float64:
+		return strconv.FormatFloat(typed, 'f', -1, 64)
+	case bool:
+		return strconv.FormatBool(typed)
+	}
+	return fmt.Sprint(value)
+}
+
+// substitute Replace placeholders in every string and object key. A string that is exactly one
+// placeholder becomes the typed value, so integers and booleans land as json numbers and bools,
+// unless target (the Payload field the node decodes into) is itself a string.
+func substitute(node any, values map[string]any, target reflect.Type) any {
+	for target != nil && target.Kind() == reflect.Pointer {
+		target = target.Elem()
+	}
+
+	switch typed := node.(type) {
+	case map[string]any:
+		out := make(map[string]any, len(typed))
+		for key, value := range typed {
+			out[substituteString(key, values)] = substitute(value, values, childType(target, key))
+		}
+		return out
+	case []any:
+		var elem reflect.Type
+		if target != nil && (target.Kind() == reflect.Slice || target.Kind() == reflect.Array) {
+			elem = target.Elem()
+		}
+		out := make([]any, len(typed))
+		for i, value := range typed {
+			out[i] = substitute(value, values, elem)
+		}
+		return out
+	case string:
+		match := placeholderRegex.FindStringSubmatch(typed)
+		if match != nil && match[0] == typed && (target == nil || target.Kind() != reflect.String) {
+			return values[match[1]]
+		}
+		return substituteString(typed, values)
+	}
+	return node
+}
+
+// childType The type a json object member decodes into, matching field names the way encoding/json does
+func childType(parent reflect.Type, key string) reflect.Type {
+	if parent == nil {
+		return nil
+	}
+	switch parent.Kind() {
+	case reflect.Map:
+		return parent.Elem()
+	case reflect.Struct:
+		for i := 0; i < parent.NumField(); i++ {
+			field := parent.Field(i)
+			name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+			if name == "" {
+				name = field.Name
+			}
+			if strings.EqualFold(name, key) {
+				return field.Type
+			}
+		}
+	}
+	return nil
+}
+
+func substituteString(s string, values map[string]any) string {
+	return placeholderRegex.ReplaceAllStringFunc(s, func(placeholder string) string {
+		return formatParameter(values[placeholder[2:len(placeholder)-1]])
+	})
+}
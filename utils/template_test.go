@@ -0,0 +1,70 @@
+package utils
+
+import (
+	"encoding/json"
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/LysetsDal/docker-api/types"
+)
+
+func TestRenderTemplateCommand(t *testing.T) {
+	template := types.ContainerTemplate{
+		Name:       "tool",
+		Parameters: []types.TemplateParameter{{Name: "command", Default: "date"}},
+		Payload:    json.RawMessage(`{"Image":"alpine","Entrypoint":"/bin/sh","Cmd":"${command}"}`),
+	}
+
+	payload, _, err := RenderTemplate(template, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := (types.StrSlice{"/bin/sh"}); !reflect.DeepEqual(payload.Entrypoint, want) {
+		t.Errorf("entrypoint: got %q, want %q", payload.Entrypoint, want)
+	}
+	if want := (types.StrSlice{"date"}); !reflect.DeepEqual(payload.Cmd, want) {
+		t.Errorf("cmd: got %q, want %q", payload.Cmd, want)
+	}
+}
+
+func TestValidateTemplate(t *testing.T) {
+	parameters := []types.TemplateParameter{
+		{Name: "port", Type: "integer", Required: true},
+		{Name: "debug", Type: "boolean", Default: false},
+		{Name: "command", Required: true},
+		{Name: "tier", Enum: []string{"web", "worker"}},
+	}
+
+	tests := []struct {
+		name      string
+		payload   string
+		wantField string
+	}{
+		{
+			name:    "valid",
+			payload: `{"Image":"app","Cmd":"${command}","Env":["DEBUG=${debug}"],"Labels":{"tier":"${tier}"},"ExposedPorts":{"${port}/tcp":{}},"Tty":"${debug}"}`,
+		},
+		{name: "unknown field", payload: `{"Image":"app","Imagee":"${command}"}`, wantField: "Payload"},
+		{name: "unknown nested field", payload: `{"Image":"app","HostConfig":{"Memroy":1}}`, wantField: "Payload"},
+		{name: "wrong type", payload: `{"Image":"app","Tty":"${command}"}`, wantField: "Payload"},
+		{name: "undeclared placeholder", payload: `{"Image":"${image}"}`, wantField: "Payload"},
+		{name: "not an object", payload: `["app"]`, wantField: "Payload"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			err := ValidateTemplate(types.ContainerTemplate{Name: "app", Parameters: parameters, Payload: json.RawMessage(test.payload)})
+			if test.wantField == "" {
+				if err != nil {
+					t.Fatal(err)
+				}
+				return
+			}
+			var fields types.ValidationErrors
+			if !errors.As(err, &fields) || len(fields) != 1 || fields[0].Field != test.wantField {
+				t.Errorf("got %v, want one error for %s", err, test.wantField)
+			}
+		})
+	}
+}
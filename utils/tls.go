@@ -0,0 +1,284 @@
+package utils
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math/big"
+	"net"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// Roles a client certificate maps to. Viewers may only read (GET and HEAD).
+const (
+	RoleAdmin  string = "admin"
+	RoleViewer string = "viewer"
+)
+
+// TLSFiles Where the listener's certificate, key, client CA bundle and roles are read from. ClientCA
+// and Roles are optional.
+type TLSFiles struct {
+	Cert     string
+	Key      string
+	ClientCA string
+	Roles    string
+}
+
+// TLSReloader Serves the certificate, client CAs and roles read from TLSFiles. Reload swaps them in for
+// new connections; if a file is broken the previous ones stay in use.
+type TLSReloader struct {
+	files TLSFiles
+
+	mu        sync.RWMutex
+	cert      *tls.Certificate
+	clientCAs *x509.CertPool
+	roles     map[string]string
+	modified  map[string]time.Time
+}
+
+func NewTLSReloader(files TLSFiles) (*TLSReloader, error) {
+	t := &TLSReloader{files: files}
+	if err := t.Reload(); err != nil {
+		return nil, err
+	}
+	return t, nil
+}
+
+// Reload Read the files again
+func (t *TLSReloader) Reload() error {
+	modified := t.modTimes()
+
+	cert, err := tls.LoadX509KeyPair(t.files.Cert, t.files.Key)
+	if err != nil {
+		return fmt.Errorf("loading certificate: %w", err)
+	}
+
+	var clientCAs *x509.CertPool
+	if t.files.ClientCA != "" {
+		bundle, err := os.ReadFile(t.files.ClientCA)
+		if err != nil {
+			return fmt.Errorf("loading client CA: %w", err)
+		}
+		clientCAs = x509.NewCertPool()
+		if !clientCAs.AppendCertsFromPEM(bundle) {
+			return fmt.Errorf("no certificates in client CA %s", t.files.ClientCA)
+		}
+	}
+
+	var roles map[string]string
+	if t.files.Roles != "" {
+		if roles, err = loadRoles(t.files.Roles); err != nil {
+			return err
+		}
+	}
+
+	t.mu.Lock()
+	t.cert, t.clientCAs, t.roles, t.modified = &cert, clientCAs, roles, modified
+	t.mu.Unlock()
+	return nil
+}
+
+// Config The listener's tls.Config. Certificates, client CAs and roles are looked up per connection,
+// so reloads apply without a restart.
+func (t *TLSReloader) Config() *tls.Config {
+	getCertificate := func(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+		t.mu.RLock()
+		defer t.mu.RUnlock()
+		return t.cert, nil
+	}
+
+	return &tls.Config{
+		MinVersion:     tls.VersionTLS12,
+		GetCertificate: getCertificate,
+		// Also needed here: net/http only sets up HTTP/2 for a server whose config offers it
+		NextProtos: []string{"h2", "http/1.1"},
+		GetConfigForClient: func(*tls.ClientHelloInfo) (*tls.Config, error) {
+			t.mu.RLock()
+			clientCAs := t.clientCAs
+			t.mu.RUnlock()
+
+			config := &tls.Config{
+				MinVersion:     tls.VersionTLS12,
+				GetCertificate: getCertificate,
+				NextProtos:     []string{"h2", "http/1.1"},
+			}
+			if clientCAs != nil {
+				config.ClientCAs = clientCAs
+				config.ClientAuth = tls.RequireAndVerifyClientCert
+			}
+			return config, nil
+		},
+	}
+}
+
+// ClientAuth Whether client certificates are required
+func (t *TLSReloader) ClientAuth() bool {
+	return t.files.ClientCA != ""
+}
+
+// Role The role of the verified client certificate in state, by its full subject or else its common
+// name. Without a roles file every verified client is admin.
+func (t *TLSReloader) Role(state *tls.ConnectionState) (string, bool) {
+	if state == nil || len(state.VerifiedChains) == 0 {
+		return "", false
+	}
+	subject := state.VerifiedChains[0][0].Subject
+
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+
+	if t.roles == nil {
+		return RoleAdmin, true
+	}
+	if role, ok := t.roles[subject.String()]; ok {
+		return role, true
+	}
+	role, ok := t.roles[subject.CommonName]
+	return role, ok
+}
+
+// Run Reload on SIGHUP, and when a file's modification time changes (checked every interval)
+func (t *TLSReloader) Run(ctx context.Context, interval time.Duration) {
+	hangup := make(chan os.Signal, 1)
+	signal.Notify(hangup, syscall.SIGHUP)
+	defer signal.Stop(hangup)
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-hangup:
+			t.reload("SIGHUP")
+		case <-ticker.C:
+			if t.changed() {
+				t.reload("files changed")
+			}
+		}
+	}
+}
+
+func (t *TLSReloader) reload(reason string) {
+	if err := t.Reload(); err != nil {
+		slog.Error("reloading TLS files, keeping the previous ones", "reason", reason, "error", err)
+		// Don't retry the same broken files every interval
+		t.mu.Lock()
+		t.modified = t.modTimes()
+		t.mu.Unlock()
+		return
+	}
+	slog.Info("reloaded TLS files", "reason", reason)
+}
+
+func (t *TLSReloader) changed() bool {
+	modified := t.modTimes()
+
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	for path, modTime := range modified {
+		if !modTime.Equal(t.modified[path]) {
+			return true
+		}
+	}
+	return false
+}
+
+func (t *TLSReloader) modTimes() map[string]time.Time {
+	modified := map[string]time.Time{}
+	for _, path := range []string{t.files.Cert, t.files.Key, t.files.ClientCA, t.files.Roles} {
+		if path == "" {
+			continue
+		}
+		if info, err := os.Stat(path); err == nil {
+			modified[path] = info.ModTime()
+		}
+	}
+	return modified
+}
+
+func loadRoles(path string) (map[string]string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("loading roles: %w", err)
+	}
+
+	roles := map[string]string{}
+	if err := json.Unmarshal(data, &roles); err != nil {
+		return nil, fmt.Errorf("roles file %s must map subjects to roles: %w", path, err)
+	}
+	for subject, role := range roles {
+		if role != RoleAdmin && role != RoleViewer {
+			return nil, fmt.Errorf("role of %s must be %s or %s, got %q", subject, RoleAdmin, RoleViewer, role)
+		}
+	}
+	return roles, nil
+}
+
+// SelfSignedCertificate Write a self-signed certificate and key for localhost and this host to
+// certFile and keyFile, unless both already exist. For development only.
+func SelfSignedCertificate(certFile, keyFile string) error {
+	_, certErr := os.Stat(certFile)
+	_, keyErr := os.Stat(keyFile)
+	if certErr == nil && keyErr == nil {
+		return nil
+	}
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return err
+	}
+	serial, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	if err != nil {
+		return err
+	}
+
+	template := &x509.Certificate{
+		SerialNumber:          serial,
+		Subject:               pkix.Name{CommonName: "docker-api self-signed"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().AddDate(1, 0, 0),
+		KeyUsage:              x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		BasicConstraintsValid: true,
+		DNSNames:              []string{"localhost"},
+		IPAddresses:           []net.IP{net.IPv4(127, 0, 0, 1), net.IPv6loopback},
+	}
+	if hostname, err := os.Hostname(); err == nil && hostname != "localhost" {
+		template.DNSNames = append(template.DNSNames, hostname)
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		return err
+	}
+	keyDer, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		return err
+	}
+
+	for _, path := range []string{certFile, keyFile} {
+		if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+			return err
+		}
+	}
+	return errors.Join(
+		os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: keyDer}), 0o600),
+		os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0o644),
+	)
+}
@@ -0,0 +1,213 @@
+package utils
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io"
+	"log"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// testCA Issues client certificates for the tests
+type testCA struct {
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+	pem  []byte
+}
+
+func newTestCA(t *testing.T) *testCA {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "test CA"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, _ := x509.ParseCertificate(der)
+	return &testCA{cert: cert, key: key, pem: pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})}
+}
+
+// issue A client certificate for subject
+func (ca *testCA) issue(t *testing.T, subject pkix.Name) tls.Certificate {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      subject,
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, ca.cert, &key.PublicKey, ca.key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key}
+}
+
+// tlsFiles A server certificate and key, ca as the client CA and roles as the roles file
+func tlsFiles(t *testing.T, ca *testCA, roles string) TLSFiles {
+	t.Helper()
+	dir := t.TempDir()
+	files := TLSFiles{Cert: filepath.Join(dir, "cert.pem"), Key: filepath.Join(dir, "key.pem")}
+	if err := SelfSignedCertificate(files.Cert, files.Key); err != nil {
+		t.Fatal(err)
+	}
+	if ca != nil {
+		files.ClientCA = filepath.Join(dir, "ca.pem")
+		if err := os.WriteFile(files.ClientCA, ca.pem, 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if roles != "" {
+		files.Roles = filepath.Join(dir, "roles.json")
+		if err := os.WriteFile(files.Roles, []byte(roles), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return files
+}
+
+func TestLoadRoles(t *testing.T) {
+	for _, roles := range []string{`["admin"]`, `{"CN=ci":"owner"}`, `{`} {
+		path := filepath.Join(t.TempDir(), "roles.json")
+		if err := os.WriteFile(path, []byte(roles), 0o600); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := loadRoles(path); err == nil {
+			t.Errorf("%s: no error", roles)
+		}
+	}
+}
+
+func TestTLSReloaderRoles(t *testing.T) {
+	ca := newTestCA(t)
+	roles := `{"CN=ci,O=Example":"admin","dashboard":"viewer"}`
+
+	tests := []struct {
+		name     string
+		roles    string
+		subject  pkix.Name
+		wantRole string
+	}{
+		{name: "full subject", roles: roles, subject: pkix.Name{CommonName: "ci", Organization: []string{"Example"}}, wantRole: RoleAdmin},
+		{name: "common name", roles: roles, subject: pkix.Name{CommonName: "dashboard", Organization: []string{"Other"}}, wantRole: RoleViewer},
+		{name: "unlisted", roles: roles, subject: pkix.Name{CommonName: "ci", Organization: []string{"Other"}}},
+		{name: "no roles file", subject: pkix.Name{CommonName: "anyone"}, wantRole: RoleAdmin},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			reloader, err := NewTLSReloader(tlsFiles(t, ca, test.roles))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var role string
+			var ok bool
+			server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				role, ok = reloader.Role(r.TLS)
+			}))
+			server.TLS = reloader.Config()
+			server.StartTLS()
+			defer server.Close()
+
+			client := &http.Client{Transport: &http.Transport{TLSClientConfig: &tls.Config{
+				InsecureSkipVerify: true,
+				Certificates:       []tls.Certificate{ca.issue(t, test.subject)},
+			}}}
+			response, err := client.Get(server.URL)
+			if err != nil {
+				t.Fatal(err)
+			}
+			response.Body.Close()
+
+			if role != test.wantRole || ok != (test.wantRole != "") {
+				t.Errorf("got %q %v, want %q", role, ok, test.wantRole)
+			}
+		})
+	}
+
+	// Without a certificate the handshake fails, a certificate from another CA as well
+	reloader, err := NewTLSReloader(tlsFiles(t, ca, ""))
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	server.TLS = reloader.Config()
+	server.Config.ErrorLog = log.New(io.Discard, "", 0)
+	server.StartTLS()
+	defer server.Close()
+
+	for name, certificates := range map[string][]tls.Certificate{"none": nil, "other CA": {newTestCA(t).issue(t, pkix.Name{CommonName: "ci"})}} {
+		client := &http.Client{Transport: &http.Transport{TLSClientConfig: &tls.Config{InsecureSkipVerify: true, Certificates: certificates}}}
+		if response, err := client.Get(server.URL); err == nil {
+			response.Body.Close()
+			t.Errorf("%s: connected", name)
+		}
+	}
+}
+
+func TestTLSReloaderReload(t *testing.T) {
+	files := tlsFiles(t, nil, `{"ci":"admin"}`)
+	reloader, err := NewTLSReloader(files)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if reloader.changed() {
+		t.Error("changed right after loading")
+	}
+
+	// A broken roles file is refused and the previous roles stay in use
+	if err := os.WriteFile(files.Roles, []byte(`{"ci":"root"}`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	future := time.Now().Add(time.Minute)
+	_ = os.Chtimes(files.Roles, future, future)
+	if !reloader.changed() {
+		t.Fatal("the roles file's change wasn't noticed")
+	}
+	reloader.reload("test")
+	if reloader.changed() {
+		t.Error("broken files are retried every interval")
+	}
+	if reloader.roles["ci"] != RoleAdmin {
+		t.Errorf("roles: %v", reloader.roles)
+	}
+
+	if err := os.WriteFile(files.Roles, []byte(`{"ci":"viewer"}`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := reloader.Reload(); err != nil {
+		t.Fatal(err)
+	}
+	if reloader.roles["ci"] != RoleViewer {
+		t.Errorf("roles after reload: %v", reloader.roles)
+	}
+}
@@ -0,0 +1,45 @@
+package utils
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"go.opentelemetry.io/otel"
+)
+
+func TestSetupTracing(t *testing.T) {
+	provider, propagator := otel.GetTracerProvider(), otel.GetTextMapPropagator()
+	t.Cleanup(func() {
+		otel.SetTracerProvider(provider)
+		otel.SetTextMapPropagator(propagator)
+	})
+
+	if _, err := SetupTracing(context.Background(), "jaeger", ""); err == nil {
+		t.Error("unknown exporter: no error")
+	}
+
+	file := filepath.Join(t.TempDir(), "spans.json")
+	shutdown, err := SetupTracing(context.Background(), "file", file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, span := otel.Tracer("test").Start(context.Background(), "GET /api/v1/containers")
+	span.End()
+	if err := shutdown(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	spans, err := os.ReadFile(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(spans), `"Name":"GET /api/v1/containers"`) || !strings.Contains(string(spans), `"Value":"docker-api"`) {
+		t.Errorf("spans: %s", spans)
+	}
+	if fields := otel.GetTextMapPropagator().Fields(); !strings.Contains(strings.Join(fields, " "), "traceparent") {
+		t.Errorf("propagator fields: %v", fields)
+	}
+}
@@ -2,9 +2,10 @@ package utils
 
 import (
 	"encoding/json"
-	"fmt"
+	"errors"
 	. "github.com/LysetsDal/docker-api/types"
 	"io"
+	"log/slog"
 	"net/http"
 )
 
@@ -16,31 +17,108 @@ func ReadJson(body io.Reader, decodeTo any) error {
 
 func ParseJson(r *http.Request, payload any) error {
 	if r.Body == nil {
-		return fmt.Errorf("missing request body")
+		return BadRequest("missing request body")
 	}
 
 	return json.NewDecoder(r.Body).Decode(payload)
 }
 
+// ParseJsonStrict ParseJson, but fields the payload type doesn't know are an error instead of being dropped
+func ParseJsonStrict(r *http.Request, payload any) error {
+	if r.Body == nil {
+		return BadRequest("missing request body")
+	}
+
+	decoder := json.NewDecoder(r.Body)
+	decoder.DisallowUnknownFields()
+	return decoder.Decode(payload)
+}
+
 // WriteJson Write Json with standard header.
 func WriteJson(w http.ResponseWriter, status int, v any) error {
-	w.WriteHeader(status)
 	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
 	return json.NewEncoder(w).Encode(v)
 }
 
-func WriteError(w http.ResponseWriter, status int, err error) {
-	WriteJson(w, status, map[string]string{"error": err.Error()})
+// WriteProblem Write an RFC 7807 problem with the status and the detail saying what went wrong. The
+// request id comes from the response header requestMW set.
+func WriteProblem(w http.ResponseWriter, status int, detail string) error {
+	return writeProblem(w, ApiError{Status: status, Detail: detail})
+}
+
+// WriteError The problem for err, with the status of its kind
+func WriteError(w http.ResponseWriter, err error) error {
+	return WriteProblem(w, StatusCode(err), err.Error())
+}
+
+// WriteValidationError A 400 problem listing the invalid fields of err, a ValidationErrors
+func WriteValidationError(w http.ResponseWriter, detail string, err error) error {
+	problem := ApiError{Status: http.StatusBadRequest, Detail: detail}
+	var fields ValidationErrors
+	if errors.As(err, &fields) {
+		problem.Fields = fields
+	}
+	return writeProblem(w, problem)
+}
+
+func writeProblem(w http.ResponseWriter, problem ApiError) error {
+	// A 304 has no body
+	if problem.Status == http.StatusNotModified {
+		w.WriteHeader(problem.Status)
+		return nil
+	}
+
+	problem.Type = "about:blank"
+	problem.Title = http.StatusText(problem.Status)
+	problem.Error = problem.Detail
+	problem.RequestId = w.Header().Get(RequestIdHeader)
+
+	w.Header().Set("Content-Type", "application/problem+json")
+	w.WriteHeader(problem.Status)
+	return json.NewEncoder(w).Encode(problem)
 }
 
-// MakeHttpHandleFunc Make custom http.HandlerFunc
+// MakeHttpHandleFunc Make custom http.HandlerFunc. An error the handler returns before writing anything
+// is answered with a problem for its kind, so handlers return BadRequest(...) for invalid input; errors
+// of no kind are 500s.
 func MakeHttpHandleFunc(f ApiFunc) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		if err := f(w, r); err != nil {
-			err := WriteJson(w, http.StatusBadRequest, ApiError{Error: err.Error()})
-			if err != nil {
+		tracked := &writeTracker{ResponseWriter: w}
+		if err := f(tracked, r); err != nil {
+			if tracked.wrote {
+				slog.WarnContext(r.Context(), "handler failed after writing its response", "error", err)
 				return
 			}
+			_ = WriteError(w, err)
 		}
 	}
 }
+
+// writeTracker Remembers whether a response has been started
+type writeTracker struct {
+	http.ResponseWriter
+	wrote bool
+}
+
+func (t *writeTracker) WriteHeader(statusCode int) {
+	t.wrote = true
+	t.ResponseWriter.WriteHeader(statusCode)
+}
+
+func (t *writeTracker) Write(p []byte) (int, error) {
+	t.wrote = true
+	return t.ResponseWriter.Write(p)
+}
+
+func (t *writeTracker) Flush() {
+	if flusher, ok := t.ResponseWriter.(http.Flusher); ok {
+		t.wrote = true
+		flusher.Flush()
+	}
+}
+
+// Unwrap Lets http.ResponseController reach the underlying writer
+func (t *writeTracker) Unwrap() http.ResponseWriter {
+	return t.ResponseWriter
+}
@@ -0,0 +1,102 @@
+package utils
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"github.com/LysetsDal/docker-api/types"
+)
+
+func TestMakeHttpHandleFunc(t *testing.T) {
+	tests := []struct {
+		name       string
+		handler    types.ApiFunc
+		wantStatus int
+		wantDetail string
+	}{
+		{
+			name: "kind",
+			handler: func(w http.ResponseWriter, r *http.Request) error {
+				return fmt.Errorf("deploying: %w", types.Conflict("name %s in use", "web"))
+			},
+			wantStatus: http.StatusConflict,
+			wantDetail: "deploying: name web in use",
+		},
+		{
+			name:       "no kind",
+			handler:    func(w http.ResponseWriter, r *http.Request) error { return errors.New("nil map") },
+			wantStatus: http.StatusInternalServerError,
+			wantDetail: "nil map",
+		},
+		{
+			name:       "not modified",
+			handler:    func(w http.ResponseWriter, r *http.Request) error { return types.ErrNotModified },
+			wantStatus: http.StatusNotModified,
+		},
+		{
+			// The response is already on its way, the error can only be logged
+			name: "after writing",
+			handler: func(w http.ResponseWriter, r *http.Request) error {
+				w.WriteHeader(http.StatusAccepted)
+				return types.NotFound("gone")
+			},
+			wantStatus: http.StatusAccepted,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			recorder := httptest.NewRecorder()
+			recorder.Header().Set(RequestIdHeader, "req-1")
+			MakeHttpHandleFunc(test.handler)(recorder, httptest.NewRequest(http.MethodGet, "/", nil))
+
+			if recorder.Code != test.wantStatus {
+				t.Fatalf("status %d: %s", recorder.Code, recorder.Body)
+			}
+			if test.wantDetail == "" {
+				if recorder.Body.Len() != 0 {
+					t.Errorf("body: %s", recorder.Body)
+				}
+				return
+			}
+
+			if contentType := recorder.Header().Get("Content-Type"); contentType != "application/problem+json" {
+				t.Errorf("Content-Type: %s", contentType)
+			}
+			problem := types.ApiError{}
+			if err := json.Unmarshal(recorder.Body.Bytes(), &problem); err != nil {
+				t.Fatal(err)
+			}
+			want := types.ApiError{
+				Type:      "about:blank",
+				Title:     http.StatusText(test.wantStatus),
+				Status:    test.wantStatus,
+				Detail:    test.wantDetail,
+				RequestId: "req-1",
+				Error:     test.wantDetail,
+			}
+			if !reflect.DeepEqual(problem, want) {
+				t.Errorf("got %+v, want %+v", problem, want)
+			}
+		})
+	}
+}
+
+func TestWriteValidationError(t *testing.T) {
+	recorder := httptest.NewRecorder()
+	err := fmt.Errorf("checking: %w", types.ValidationErrors{{Field: "Image", Message: "is required"}})
+	_ = WriteValidationError(recorder, "invalid container", err)
+
+	problem := types.ApiError{}
+	if err := json.Unmarshal(recorder.Body.Bytes(), &problem); err != nil {
+		t.Fatal(err)
+	}
+	if recorder.Code != http.StatusBadRequest || problem.Detail != "invalid container" || len(problem.Fields) != 1 || problem.Fields[0].Field != "Image" {
+		t.Errorf("status %d: %+v", recorder.Code, problem)
+	}
+}
@@ -0,0 +1,459 @@
+package utils
+
+import (
+	"fmt"
+	. "github.com/LysetsDal/docker-api/types"
+	"net"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	minMemory    = 6 * 1024 * 1024
+	minCpuPeriod = 1000
+	maxCpuPeriod = 1000000
+)
+
+var (
+	exposedPortRegex = regexp.MustCompile(`^[0-9]+(-[0-9]+)?(/(tcp|udp|sctp))?$`)
+	ulimitNameRegex  = regexp.MustCompile(`^[a-z]+$`)
+	imageTagRegex    = regexp.MustCompile(`^[a-zA-Z0-9_][a-zA-Z0-9_.-]{0,127}$`)
+)
+
+// commitInstructions Dockerfile instructions the daemon accepts as commit changes
+var commitInstructions = map[string]bool{
+	"CMD": true, "ENTRYPOINT": true, "ENV": true, "EXPOSE": true, "LABEL": true,
+	"ONBUILD": true, "USER": true, "VOLUME": true, "WORKDIR": true, "STOPSIGNAL": true,
+}
+
+// validator Collects field errors
+type validator struct {
+	errors ValidationErrors
+}
+
+func (v *validator) fail(field, format string, args ...any) {
+	v.errors = append(v.errors, FieldError{Field: field, Message: fmt.Sprintf(format, args...)})
+}
+
+// ValidatePayload Check a create request before it is sent to the Docker daemon.
+// Returns nil or ValidationErrors listing every problem found.
+func ValidatePayload(payload Payload) error {
+	v := &validator{}
+
+	if strings.TrimSpace(payload.Image) == "" {
+		v.fail("Image", "is required")
+	}
+
+	for i, variable := range payload.Env {
+		key, _, _ := strings.Cut(variable, "=")
+		switch {
+		case key == "":
+			v.fail(fmt.Sprintf("Env[%d]", i), "%q must be KEY=VALUE", variable)
+		case strings.ContainsAny(key, " \t\n\x00"):
+			v.fail(fmt.Sprintf("Env[%d]", i), "variable name %q contains whitespace", key)
+		}
+	}
+
+	for port := range payload.ExposedPorts {
+		if !exposedPortRegex.MatchString(port) {
+			v.fail(fmt.Sprintf("ExposedPorts[%s]", port), "must be PORT[/PROTOCOL] with protocol tcp, udp or sctp")
+		}
+	}
+
+	if payload.StopTimeout < 0 {
+		v.fail("StopTimeout", "must not be negative")
+	}
+
+	validateHealthcheck(v, payload.Healthcheck)
+	validateHostConfig(v, payload.HostConfig)
+
+	if len(v.errors) == 0 {
+		return nil
+	}
+	sort.SliceStable(v.errors, func(i, j int) bool {
+		return v.errors[i].Field < v.errors[j].Field
+	})
+	return v.errors
+}
+
+func validateHealthcheck(v *validator, healthcheck *HealthConfig) {
+	if healthcheck == nil {
+		return
+	}
+	if len(healthcheck.Test) > 0 {
+		switch healthcheck.Test[0] {
+		case "NONE", "CMD", "CMD-SHELL":
+		default:
+			v.fail("Healthcheck.Test[0]", "must be NONE, CMD or CMD-SHELL")
+		}
+	}
+	const minDuration = 1000000 // Docker rejects non-zero durations below 1ms
+	durations := map[string]int64{
+		"Healthcheck.Interval":    healthcheck.Interval,
+		"Healthcheck.Timeout":     healthcheck.Timeout,
+		"Healthcheck.StartPeriod": healthcheck.StartPeriod,
+	}
+	for field, duration := range durations {
+		if duration != 0 && duration < minDuration {
+			v.fail(field, "must be 0 or at least 1ms (in nanoseconds)")
+		}
+	}
+	if healthcheck.Retries < 0 {
+		v.fail("Healthcheck.Retries", "must not be negative")
+	}
+}
+
+func validateHostConfig(v *validator, hostConfig HostConfig) {
+	validateMemory(v, hostConfig)
+	validateCpu(v, hostConfig)
+
+	for port, bindings := range hostConfig.PortBindings {
+		field := fmt.Sprintf("HostConfig.PortBindings[%s]", port)
+		if !exposedPortRegex.MatchString(port) {
+			v.fail(field, "must be PORT[/PROTOCOL] with protocol tcp, udp or sctp")
+		}
+		for i, binding := range bindings {
+			if binding.HostIp != "" && net.ParseIP(binding.HostIp) == nil {
+				v.fail(fmt.Sprintf("%s[%d].HostIp", field, i), "%q is not an IP address", binding.HostIp)
+			}
+			if binding.HostPort != "" {
+				if _, _, err := parsePortRange(binding.HostPort); err != nil {
+					v.fail(fmt.Sprintf("%s[%d].HostPort", field, i), "%s", err)
+				}
+			}
+		}
+	}
+
+	for i, bind := range hostConfig.Binds {
+		field := fmt.Sprintf("HostConfig.Binds[%d]", i)
+		volume, err := ParseVolumeSpec(bind)
+		switch {
+		case err != nil:
+			v.fail(field, "%s", err)
+		case volume.IsAnonymous():
+			v.fail(field, "%q must be SOURCE:TARGET[:MODE]", bind)
+		case volume.IsBind() && !strings.HasPrefix(volume.Source, "/"):
+			v.fail(field, "host path %q must be absolute", volume.Source)
+		}
+	}
+
+	for i, mount := range hostConfig.Mounts {
+		field := fmt.Sprintf("HostConfig.Mounts[%d]", i)
+		switch mount.Type {
+		case "bind", "volume", "tmpfs", "npipe", "cluster":
+		default:
+			v.fail(field+".Type", "must be bind, volume, tmpfs, npipe or cluster")
+		}
+		if !strings.HasPrefix(mount.Target, "/") {
+			v.fail(field+".Target", "must be an absolute path")
+		}
+		if mount.Type == "bind" && !strings.HasPrefix(mount.Source, "/") {
+			v.fail(field+".Source", "bind source must be an absolute host path")
+		}
+	}
+
+	policy := hostConfig.RestartPolicy
+	if policy.Name != "" && !restartPolicies[policy.Name] {
+		v.fail("HostConfig.RestartPolicy.Name", "must be one of no, always, unless-stopped or on-failure")
+	}
+	if policy.MaximumRetryCount < 0 {
+		v.fail("HostConfig.RestartPolicy.MaximumRetryCount", "must not be negative")
+	}
+	if policy.MaximumRetryCount > 0 && policy.Name != "on-failure" {
+		v.fail("HostConfig.RestartPolicy.MaximumRetryCount", "is only valid with the on-failure policy")
+	}
+	if hostConfig.AutoRemove && policy.Name != "" && policy.Name != "no" {
+		v.fail("HostConfig.AutoRemove", "conflicts with restart policy %s", policy.Name)
+	}
+
+	if hostConfig.BlkioWeight != 0 && (hostConfig.BlkioWeight < 10 || hostConfig.BlkioWeight > 1000) {
+		v.fail("HostConfig.BlkioWeight", "must be between 10 and 1000")
+	}
+	for i, device := range hostConfig.BlkioWeightDevice {
+		field := fmt.Sprintf("HostConfig.BlkioWeightDevice[%d]", i)
+		if device.Path == "" {
+			v.fail(field+".Path", "is required")
+		}
+		if device.Weight < 10 || device.Weight > 1000 {
+			v.fail(field+".Weight", "must be between 10 and 1000")
+		}
+	}
+	throttles := map[string][]BlkioDevice{
+		"BlkioDeviceReadBps":   hostConfig.BlkioDeviceReadBps,
+		"BlkioDeviceReadIOps":  hostConfig.BlkioDeviceReadIOps,
+		"BlkioDeviceWriteBps":  hostConfig.BlkioDeviceWriteBps,
+		"BlkioDeviceWriteIOps": hostConfig.BlkioDeviceWriteIOps,
+	}
+	for name, devices := range throttles {
+		for i, device := range devices {
+			field := fmt.Sprintf("HostConfig.%s[%d]", name, i)
+			if device.Path == "" {
+				v.fail(field+".Path", "is required")
+			}
+			if device.Rate < 0 {
+				v.fail(field+".Rate", "must not be negative")
+			}
+		}
+	}
+
+	for i, device := range hostConfig.Devices {
+		field := fmt.Sprintf("HostConfig.Devices[%d]", i)
+		if device.PathOnHost == "" {
+			v.fail(field+".PathOnHost", "is required")
+		}
+		if device.PathInContainer != "" && !strings.HasPrefix(device.PathInContainer, "/") {
+			v.fail(field+".PathInContainer", "must be an absolute path")
+		}
+		if strings.Trim(device.CgroupPermissions, "rwm") != "" {
+			v.fail(field+".CgroupPermissions", "must be a combination of r, w and m")
+		}
+	}
+
+	for i, ulimit := range hostConfig.Ulimits {
+		field := fmt.Sprintf("HostConfig.Ulimits[%d]", i)
+		if !ulimitNameRegex.MatchString(ulimit.Name) {
+			v.fail(field+".Name", "%q is not a ulimit name", ulimit.Name)
+		}
+		if ulimit.Soft > ulimit.Hard && ulimit.Hard != -1 {
+			v.fail(field+".Soft", "must not exceed the hard limit %d", ulimit.Hard)
+		}
+	}
+}
+
+func validateMemory(v *validator, hostConfig HostConfig) {
+	if hostConfig.Memory < 0 {
+		v.fail("HostConfig.Memory", "must not be negative")
+	} else if hostConfig.Memory > 0 && hostConfig.Memory < minMemory {
+		v.fail("HostConfig.Memory", "minimum memory limit is 6MB (%d bytes)", minMemory)
+	}
+
+	switch {
+	case hostConfig.MemorySwap < -1:
+		v.fail("HostConfig.MemorySwap", "must be -1 (unlimited) or a byte count")
+	case hostConfig.MemorySwap > 0 && hostConfig.Memory == 0:
+		v.fail("HostConfig.MemorySwap", "requires HostConfig.Memory to be set")
+	case hostConfig.MemorySwap > 0 && hostConfig.MemorySwap < hostConfig.Memory:
+		v.fail("HostConfig.MemorySwap", "must be at least HostConfig.Memory, it includes memory")
+	}
+
+	if hostConfig.MemoryReservation < 0 {
+		v.fail("HostConfig.MemoryReservation", "must not be negative")
+	} else if hostConfig.Memory > 0 && hostConfig.MemoryReservation > hostConfig.Memory {
+		v.fail("HostConfig.MemoryReservation", "must not exceed HostConfig.Memory")
+	}
+
+	if hostConfig.MemorySwappiness < -1 || hostConfig.MemorySwappiness > 100 {
+		v.fail("HostConfig.MemorySwappiness", "must be between 0 and 100, or -1 for the daemon's default")
+	}
+	if hostConfig.ShmSize < 0 {
+		v.fail("HostConfig.ShmSize", "must not be negative")
+	}
+	if hostConfig.PidsLimit < -1 {
+		v.fail("HostConfig.PidsLimit", "must be -1 (unlimited) or a positive count")
+	}
+}
+
+func validateCpu(v *validator, hostConfig HostConfig) {
+	if hostConfig.NanoCpus < 0 {
+		v.fail("HostConfig.NanoCpus", "must not be negative")
+	}
+	if hostConfig.NanoCpus > 0 && (hostConfig.CpuPeriod > 0 || hostConfig.CpuQuota > 0) {
+		v.fail("HostConfig.NanoCpus", "conflicts with HostConfig.CpuPeriod and HostConfig.CpuQuota, set one or the other")
+	}
+
+	if hostConfig.CpuPeriod != 0 && (hostConfig.CpuPeriod < minCpuPeriod || hostConfig.CpuPeriod > maxCpuPeriod) {
+		v.fail("HostConfig.CpuPeriod", "must be between %d and %d microseconds", minCpuPeriod, maxCpuPeriod)
+	}
+	switch {
+	case hostConfig.CpuQuota < -1:
+		v.fail("HostConfig.CpuQuota", "must be -1 (unlimited) or at least %d microseconds", minCpuPeriod)
+	case hostConfig.CpuQuota > 0 && hostConfig.CpuQuota < minCpuPeriod:
+		v.fail("HostConfig.CpuQuota", "must be at least %d microseconds", minCpuPeriod)
+	}
+
+	if hostConfig.CpuShares < 0 || (hostConfig.CpuShares > 0 && hostConfig.CpuShares < 2) {
+		v.fail("HostConfig.CpuShares", "must be 0 (default) or at least 2")
+	}
+	if hostConfig.CpuPercent < 0 || hostConfig.CpuPercent > 100 {
+		v.fail("HostConfig.CpuPercent", "must be between 0 and 100")
+	}
+}
+
+// ValidateCommitRequest Check the tag and Dockerfile changes of a commit request
+func ValidateCommitRequest(request CommitRequest) error {
+	v := &validator{}
+
+	if request.Tag != "" {
+		if request.Repo == "" {
+			v.fail("Repo", "is required when Tag is set")
+		}
+		if !imageTagRegex.MatchString(request.Tag) {
+			v.fail("Tag", "%q is not a valid tag", request.Tag)
+		}
+	}
+	if strings.ContainsAny(request.Repo, " \t\n") {
+		v.fail("Repo", "must not contain whitespace")
+	}
+
+	for i, change := range request.Changes {
+		instruction, _, _ := strings.Cut(strings.TrimSpace(change), " ")
+		if !commitInstructions[strings.ToUpper(instruction)] {
+			v.fail(fmt.Sprintf("Changes[%d]", i), "%q is not supported, use one of CMD, ENTRYPOINT, ENV, EXPOSE, LABEL, ONBUILD, USER, VOLUME, WORKDIR or STOPSIGNAL", instruction)
+		}
+	}
+
+	if len(v.errors) > 0 {
+		return v.errors
+	}
+	return nil
+}
+
+// ValidateCleanupPolicy Check that a cleanup policy selects something and has sensible limits
+func ValidateCleanupPolicy(policy CleanupPolicy) error {
+	v := &validator{}
+
+	if policy.Containers == nil && policy.Images == nil && policy.Volumes == nil && policy.BuildCache == nil {
+		v.fail("Policy", "must include at least one of Containers, Images, Volumes or BuildCache")
+	}
+	if policy.Containers != nil && policy.Containers.OlderThanHours < 0 {
+		v.fail("Containers.OlderThanHours", "must not be negative")
+	}
+	if images := policy.Images; images != nil {
+		if !images.Dangling && !images.Unused {
+			v.fail("Images", "must set Dangling, Unused or both")
+		}
+		if images.KeepLast < 0 {
+			v.fail("Images.KeepLast", "must not be negative")
+		}
+	}
+	if policy.Volumes != nil {
+		for i, label := range policy.Volumes.ProtectedLabels {
+			if key, _, _ := strings.Cut(label, "="); strings.TrimSpace(key) == "" {
+				v.fail(fmt.Sprintf("Volumes.ProtectedLabels[%d]", i), "must be key or key=value")
+			}
+		}
+	}
+	if policy.BuildCache != nil && policy.BuildCache.OlderThanHours < 0 {
+		v.fail("BuildCache.OlderThanHours", "must not be negative")
+	}
+
+	if len(v.errors) > 0 {
+		return v.errors
+	}
+	return nil
+}
+
+// ValidateLabelSelectors Check that each label selector is "key" or "key=value". The errors name field.
+func ValidateLabelSelectors(field string, labels []string) error {
+	v := &validator{}
+	validateLabels(v, field, labels)
+
+	if len(v.errors) > 0 {
+		return v.errors
+	}
+	return nil
+}
+
+// validateLabels Check that each label selector is "key" or "key=value"
+func validateLabels(v *validator, field string, labels []string) {
+	for i, label := range labels {
+		if key, _, _ := strings.Cut(label, "="); strings.TrimSpace(key) == "" {
+			v.fail(fmt.Sprintf("%s[%d]", field, i), "must be key or key=value")
+		}
+	}
+}
+
+// ValidateStopAllRequest Check the filters and limits of a stop-all request
+func ValidateStopAllRequest(request StopAllRequest) error {
+	v := &validator{}
+
+	validateLabels(v, "Labels", request.Labels)
+	for i, name := range request.Names {
+		if strings.TrimSpace(name) == "" {
+			v.fail(fmt.Sprintf("Names[%d]", i), "must not be empty")
+		}
+	}
+	for i, image := range request.Images {
+		if strings.TrimSpace(image) == "" {
+			v.fail(fmt.Sprintf("Images[%d]", i), "must not be empty")
+		}
+	}
+	validateBulkLimits(v, request.Parallelism, request.Deadline)
+	if request.T < 0 {
+		v.fail("T", "must not be negative")
+	}
+
+	if len(v.errors) > 0 {
+		return v.errors
+	}
+	return nil
+}
+
+// containerStatuses The states a container status filter accepts
+var containerStatuses = map[string]bool{
+	"created": true, "restarting": true, "running": true, "removing": true, "paused": true, "exited": true, "dead": true,
+}
+
+// ValidateBulkRequest Check a bulk request's action, selector and limits. The selector must narrow the
+// containers down somehow, an empty one is rejected rather than meaning every container.
+func ValidateBulkRequest(request BulkRequest) error {
+	v := &validator{}
+
+	switch request.Action {
+	case BulkStart, BulkStop, BulkRestart, BulkKill, BulkPause, BulkUnpause, BulkRemove:
+	default:
+		v.fail("Action", "must be one of start, stop, restart, kill, pause, unpause or remove")
+	}
+
+	selector := request.Selector
+	if len(selector.Ids) == 0 && len(selector.Names) == 0 && len(selector.Labels) == 0 && (selector.Filter == nil || len(selector.Filter.Status) == 0) {
+		v.fail("Selector", "must set at least one of Ids, Names, Labels or Filter.Status")
+	}
+	for i, id := range selector.Ids {
+		if strings.TrimSpace(id) == "" {
+			v.fail(fmt.Sprintf("Selector.Ids[%d]", i), "must not be empty")
+		}
+	}
+	for i, name := range selector.Names {
+		if strings.TrimSpace(name) == "" {
+			v.fail(fmt.Sprintf("Selector.Names[%d]", i), "must not be empty")
+		}
+	}
+	validateLabels(v, "Selector.Labels", selector.Labels)
+	if selector.Filter != nil {
+		for i, status := range selector.Filter.Status {
+			if !containerStatuses[status] {
+				v.fail(fmt.Sprintf("Selector.Filter.Status[%d]", i), "%q is not a container status", status)
+			}
+		}
+	}
+
+	validateBulkLimits(v, request.Parallelism, request.Deadline)
+	switch request.OnError {
+	case "", "continue", "fail-fast":
+	default:
+		v.fail("OnError", "must be continue or fail-fast")
+	}
+	if request.T < 0 {
+		v.fail("T", "must not be negative")
+	}
+
+	if len(v.errors) > 0 {
+		return v.errors
+	}
+	return nil
+}
+
+// validateBulkLimits Parallelism between 0 (the default) and 32, Deadline empty or a positive duration
+func validateBulkLimits(v *validator, parallelism int, deadline string) {
+	if parallelism < 0 || parallelism > 32 {
+		v.fail("Parallelism", "must be between 1 and 32")
+	}
+	if deadline != "" {
+		if duration, err := time.ParseDuration(deadline); err != nil || duration <= 0 {
+			v.fail("Deadline", "must be a positive duration such as 90s")
+		}
+	}
+}
@@ -0,0 +1,161 @@
+package utils
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/LysetsDal/docker-api/types"
+)
+
+// fields The fields named by a ValidationErrors, in order
+func fields(t *testing.T, err error) []string {
+	if err == nil {
+		return []string{}
+	}
+	var validation types.ValidationErrors
+	if !errors.As(err, &validation) {
+		t.Fatalf("not a ValidationErrors: %v", err)
+	}
+	names := []string{}
+	for _, fieldError := range validation {
+		names = append(names, fieldError.Field)
+	}
+	return names
+}
+
+func TestValidatePayload(t *testing.T) {
+	tests := []struct {
+		name   string
+		modify func(p *types.Payload)
+		want   []string
+	}{
+		{name: "valid", modify: func(p *types.Payload) {}, want: []string{}},
+		{name: "no image", modify: func(p *types.Payload) { p.Image = " " }, want: []string{"Image"}},
+		{name: "env", modify: func(p *types.Payload) { p.Env = []string{"A=1", "=2", "B C=3", "D"} }, want: []string{"Env[1]", "Env[2]"}},
+		{
+			name: "exposed ports",
+			modify: func(p *types.Payload) {
+				p.ExposedPorts = map[string]struct{}{"80/tcp": {}, "8000-8010": {}, "53/icmp": {}}
+			},
+			want: []string{"ExposedPorts[53/icmp]"},
+		},
+		{name: "stop timeout", modify: func(p *types.Payload) { p.StopTimeout = -1 }, want: []string{"StopTimeout"}},
+		{
+			name: "healthcheck",
+			modify: func(p *types.Payload) {
+				p.Healthcheck = &types.HealthConfig{Test: []string{"RUN", "true"}, Interval: 1000, Timeout: 0, Retries: -1}
+			},
+			want: []string{"Healthcheck.Interval", "Healthcheck.Retries", "Healthcheck.Test[0]"},
+		},
+		{
+			name: "port bindings",
+			modify: func(p *types.Payload) {
+				p.HostConfig.PortBindings = map[string][]types.PortBinding{
+					"80/tcp": {{HostIp: "0.0.0.0", HostPort: "8080"}, {HostIp: "localhost", HostPort: "70000"}},
+				}
+			},
+			want: []string{"HostConfig.PortBindings[80/tcp][1].HostIp", "HostConfig.PortBindings[80/tcp][1].HostPort"},
+		},
+		{
+			name: "binds",
+			modify: func(p *types.Payload) {
+				p.HostConfig.Binds = []string{"data:/data", "/data", "./conf:/etc/app", "data:rel"}
+			},
+			want: []string{"HostConfig.Binds[1]", "HostConfig.Binds[2]", "HostConfig.Binds[3]"},
+		},
+		{
+			name: "mounts",
+			modify: func(p *types.Payload) {
+				p.HostConfig.Mounts = []types.HostMount{{Type: "volume", Source: "data", Target: "/data"}, {Type: "bind", Source: "conf", Target: "etc"}, {Type: "nfs", Target: "/x"}}
+			},
+			want: []string{"HostConfig.Mounts[1].Source", "HostConfig.Mounts[1].Target", "HostConfig.Mounts[2].Type"},
+		},
+		{name: "too little memory", modify: func(p *types.Payload) { p.HostConfig.Memory = 1 << 20 }, want: []string{"HostConfig.Memory"}},
+		{name: "swap without memory", modify: func(p *types.Payload) { p.HostConfig.MemorySwap = 1 << 30 }, want: []string{"HostConfig.MemorySwap"}},
+		{name: "swap below memory", modify: func(p *types.Payload) { p.HostConfig.Memory, p.HostConfig.MemorySwap = 1<<30, 1<<29 }, want: []string{"HostConfig.MemorySwap"}},
+		{name: "unlimited swap", modify: func(p *types.Payload) { p.HostConfig.Memory, p.HostConfig.MemorySwap = 1<<30, -1 }, want: []string{}},
+		{name: "reservation above memory", modify: func(p *types.Payload) { p.HostConfig.Memory, p.HostConfig.MemoryReservation = 1<<30, 1<<31 }, want: []string{"HostConfig.MemoryReservation"}},
+		{name: "default swappiness", modify: func(p *types.Payload) { p.HostConfig.MemorySwappiness = -1 }, want: []string{}},
+		{name: "swappiness", modify: func(p *types.Payload) { p.HostConfig.MemorySwappiness = 101 }, want: []string{"HostConfig.MemorySwappiness"}},
+		{name: "pids limit", modify: func(p *types.Payload) { p.HostConfig.PidsLimit = -2 }, want: []string{"HostConfig.PidsLimit"}},
+		{name: "nano cpus and quota", modify: func(p *types.Payload) {
+			p.HostConfig.NanoCpus, p.HostConfig.CpuPeriod, p.HostConfig.CpuQuota = 1e9, 100000, 50000
+		}, want: []string{"HostConfig.NanoCpus"}},
+		{name: "period without quota", modify: func(p *types.Payload) { p.HostConfig.CpuPeriod = 100000 }, want: []string{}},
+		{name: "period out of range", modify: func(p *types.Payload) { p.HostConfig.CpuPeriod, p.HostConfig.CpuQuota = 10, 50000 }, want: []string{"HostConfig.CpuPeriod"}},
+		{name: "quota", modify: func(p *types.Payload) { p.HostConfig.CpuQuota = 10 }, want: []string{"HostConfig.CpuQuota"}},
+		{name: "shares", modify: func(p *types.Payload) { p.HostConfig.CpuShares = 1 }, want: []string{"HostConfig.CpuShares"}},
+		{
+			name: "restart policy",
+			modify: func(p *types.Payload) {
+				p.HostConfig.RestartPolicy = types.RestartPolicy{Name: "always", MaximumRetryCount: 3}
+				p.HostConfig.AutoRemove = true
+			},
+			want: []string{"HostConfig.AutoRemove", "HostConfig.RestartPolicy.MaximumRetryCount"},
+		},
+		{name: "unknown restart policy", modify: func(p *types.Payload) { p.HostConfig.RestartPolicy.Name = "sometimes" }, want: []string{"HostConfig.RestartPolicy.Name"}},
+		{
+			name: "devices and ulimits",
+			modify: func(p *types.Payload) {
+				p.HostConfig.Devices = []types.Device{{PathOnHost: "/dev/fuse", PathInContainer: "dev/fuse", CgroupPermissions: "rwx"}}
+				p.HostConfig.Ulimits = []types.Ulimit{{Name: "nofile", Soft: 2048, Hard: 1024}, {Name: "NOFILE", Soft: 1, Hard: -1}}
+			},
+			want: []string{"HostConfig.Devices[0].CgroupPermissions", "HostConfig.Devices[0].PathInContainer", "HostConfig.Ulimits[0].Soft", "HostConfig.Ulimits[1].Name"},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			payload := types.Payload{Image: "nginx"}
+			test.modify(&payload)
+
+			if got := fields(t, ValidatePayload(payload)); !reflect.DeepEqual(got, test.want) {
+				t.Errorf("got %q, want %q", got, test.want)
+			}
+		})
+	}
+}
+
+func TestValidateCommitRequest(t *testing.T) {
+	tests := []struct {
+		name    string
+		request types.CommitRequest
+		want    []string
+	}{
+		{name: "valid", request: types.CommitRequest{Repo: "app", Tag: "v1", Changes: []string{"CMD [\"nginx\"]", "env A=1"}}, want: []string{}},
+		{name: "tag without repo", request: types.CommitRequest{Tag: "v1"}, want: []string{"Repo"}},
+		{name: "bad tag", request: types.CommitRequest{Repo: "app", Tag: "-v1"}, want: []string{"Tag"}},
+		{name: "unsupported change", request: types.CommitRequest{Changes: []string{"RUN apt-get update"}}, want: []string{"Changes[0]"}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := fields(t, ValidateCommitRequest(test.request)); !reflect.DeepEqual(got, test.want) {
+				t.Errorf("got %q, want %q", got, test.want)
+			}
+		})
+	}
+}
+
+func TestValidateCleanupPolicy(t *testing.T) {
+	tests := []struct {
+		name   string
+		policy types.CleanupPolicy
+		want   []string
+	}{
+		{name: "empty", policy: types.CleanupPolicy{}, want: []string{"Policy"}},
+		{name: "dangling images", policy: types.CleanupPolicy{Images: &types.ImageCleanup{Dangling: true}}, want: []string{}},
+		{name: "images without a selection", policy: types.CleanupPolicy{Images: &types.ImageCleanup{KeepLast: -1}}, want: []string{"Images", "Images.KeepLast"}},
+		{name: "negative age", policy: types.CleanupPolicy{Containers: &types.ContainerCleanup{OlderThanHours: -1}}, want: []string{"Containers.OlderThanHours"}},
+		{name: "protected labels", policy: types.CleanupPolicy{Volumes: &types.VolumeCleanup{ProtectedLabels: []string{"keep", "=x"}}}, want: []string{"Volumes.ProtectedLabels[1]"}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := fields(t, ValidateCleanupPolicy(test.policy)); !reflect.DeepEqual(got, test.want) {
+				t.Errorf("got %q, want %q", got, test.want)
+			}
+		})
+	}
+}